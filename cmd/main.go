@@ -3,10 +3,15 @@ package main
 import (
 	"context"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"revelbus/cmd/web"
+	"revelbus/internal/platform/migrations"
+	"revelbus/internal/platform/outbox"
+	"revelbus/internal/platform/reminders"
+	"revelbus/internal/platform/trash"
 	"revelbus/pkg/database"
 	"revelbus/pkg/sessions"
 	"syscall"
@@ -36,11 +41,32 @@ func main() {
 		log.Fatalf("DB Ping : %v", err)
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrations.Command(masterDB, os.Args[2:], os.Stdout); err != nil {
+			log.Fatalf("migrate : %v", err)
+		}
+		return
+	}
+
+	if err := migrations.Check(masterDB); err != nil {
+		log.Fatalf("startup : %v", err)
+	}
+
+	outbox.Start()
+	reminders.Start()
+	trash.Start()
+
 	sesh := sessions.GetSession()
 
+	// requests' contexts come from this one, so cancelling it stops their
+	// queries
+	base, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+
 	srv := http.Server{
 		Addr:           viper.GetString("addr"),
 		Handler:        sesh.Use(web.Routes()),
+		BaseContext:    func(net.Listener) context.Context { return base },
 		IdleTimeout:    time.Minute,
 		ReadTimeout:    5 * time.Second,
 		WriteTimeout:   5 * time.Second,
@@ -69,10 +95,28 @@ func main() {
 
 		if err := srv.Shutdown(ctx); err != nil {
 			log.Printf("Graceful shutdown did not complete in %v : %v", (5 * time.Second), err)
+			cancelRequests()
 			if err := srv.Close(); err != nil {
 				log.Fatalf("Could not stop http server: %v", err)
 			}
 		}
+
+		if err := reminders.Stop(ctx); err != nil {
+			log.Printf("Reminders did not stop in %v : %v", (5 * time.Second), err)
+		}
+
+		if err := trash.Stop(ctx); err != nil {
+			log.Printf("Trash did not stop in %v : %v", (5 * time.Second), err)
+		}
+
+		// the server may have used up ctx, and mail still to go out deserves
+		// a full wait of its own
+		drainCtx, drainCancel := context.WithTimeout(context.Background(), (10 * time.Second))
+		defer drainCancel()
+
+		if err := outbox.Stop(drainCtx); err != nil {
+			log.Printf("Outbox did not drain in %v : %v", (10 * time.Second), err)
+		}
 	}
 
 	log.Println("main : Completed")
@@ -1,16 +1,87 @@
 package handlers
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"revelbus/cmd/web/utils"
 	"revelbus/cmd/web/view"
 	"revelbus/internal/platform/domain"
 	"revelbus/internal/platform/domain/models"
+	"revelbus/internal/platform/emails"
 	"revelbus/internal/platform/flash"
+	"strings"
+
+	"github.com/gorilla/mux"
 )
 
 func UserDashboard(w http.ResponseWriter, r *http.Request) {
-	view.Render(w, r, "user-dashboard", &view.View{})
+	u, err := utils.IsAuthenticated(r)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	bookings, err := db.Bookings.FindByUser(r.Context(), u.ID)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	list, err := db.Sessions.FetchAll(r.Context(), u.ID)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	token, err := utils.CurrentSessionToken(r)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	for _, s := range *list {
+		s.Current = s.Token == token
+	}
+
+	view.Render(w, r, "user-dashboard", &view.View{
+		Bookings: bookings,
+		Sessions: list,
+	})
+}
+
+func RevokeSession(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	u, err := utils.IsAuthenticated(r)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	s := &models.Session{
+		ID:     utils.ToInt(id),
+		UserID: u.ID,
+	}
+
+	err = db.Sessions.Revoke(r.Context(), s)
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = flash.Add(w, r, utils.MsgSessionRevoked, "success")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/u/", http.StatusSeeOther)
 }
 
 func ProfileForm(w http.ResponseWriter, r *http.Request) {
@@ -57,9 +128,18 @@ func PostProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = u.Update()
-	if err != nil {
-		if err == domain.ErrDuplicateEmail {
+	// a new address only replaces the old one once it's been confirmed, so
+	// someone riding a stolen session can't take over the account with it
+	changed := !strings.EqualFold(f.Email, u.Email.String)
+
+	if changed {
+		taken, err := db.Users.EmailInUse(r.Context(), f.Email)
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+
+		if taken {
 			f.Errors["Email"] = "E-mail address is already in use"
 			view.Render(w, r, "profile", &view.View{
 				Form:  f,
@@ -67,20 +147,43 @@ func PostProfile(w http.ResponseWriter, r *http.Request) {
 			})
 			return
 		}
-		view.ServerError(w, r, err)
-		return
 	}
 
 	u.Name = utils.NewNullStr(f.Name)
-	u.Email = utils.NewNullStr(f.Email)
 
-	err = utils.SetUserSession(w, r, u)
+	err = db.InTx(r.Context(), func(ctx context.Context) error {
+		err := db.Users.Update(ctx, u)
+		if err != nil || !changed {
+			return err
+		}
+
+		v, token, err := db.Verifications.Create(ctx, u, f.Email)
+		if err != nil {
+			return err
+		}
+
+		err = emails.ConfirmEmailChange(ctx, u, f.Email, v.ID, token)
+		if err != nil {
+			return err
+		}
+
+		return alertSecurity(r.WithContext(ctx), u, models.SecurityEmailChangeRequested, f.Email)
+	})
 	if err != nil {
+		if err == domain.ErrConflict {
+			view.ClientError(w, r, http.StatusConflict)
+			return
+		}
 		view.ServerError(w, r, err)
 		return
 	}
 
-	err = flash.Add(w, r, utils.MsgSuccessfullyUpdated, "success")
+	msg := utils.MsgSuccessfullyUpdated
+	if changed {
+		msg = fmt.Sprintf(utils.MsgEmailChangeSent, f.Email)
+	}
+
+	err = flash.Add(w, r, msg, "success")
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
@@ -123,7 +226,7 @@ func PostPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = u.VerifyAndUpdatePassword(f.OldPassword, f.Password)
+	err = db.Users.VerifyAndUpdatePassword(r.Context(), u, f.OldPassword, f.Password)
 	if err != nil {
 		if err == domain.ErrInvalidCredentials {
 			err = flash.Add(w, r, utils.MsgInvalidCredentials, "danger")
@@ -142,6 +245,19 @@ func PostPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the new password signed the user out everywhere, this device included
+	err = utils.SetUserSession(w, r, u)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = alertSecurity(r, u, models.SecurityPasswordChanged, "")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
 	err = flash.Add(w, r, utils.MsgSuccessfullyUpdated, "success")
 	if err != nil {
 		view.ServerError(w, r, err)
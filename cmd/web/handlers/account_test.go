@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"revelbus/cmd/web/utils"
+	"revelbus/internal/platform/domain/models"
+	"strconv"
+	"testing"
+)
+
+func TestPostPassword(t *testing.T) {
+	s := newStore(t)
+	u := newUser(t, s, "pat@example.com", "user")
+	laptop := signIn(t, u)
+	signIn(t, u)
+
+	w := request{
+		method: "POST",
+		target: "/u/password",
+		form: url.Values{
+			"old_password":     {"secret"},
+			"password":         {"new secret"},
+			"confirm_password": {"new secret"},
+		},
+		cookies: laptop,
+	}.do(PostPassword)
+	wantRedirect(t, w, "/u/password")
+
+	// the phone is signed out, the laptop gets a fresh session
+	sessions, err := s.Sessions.FetchAll(context.Background(), u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*sessions) != 1 {
+		t.Fatalf("got %d sessions, want just the one that changed the password", len(*sessions))
+	}
+
+	r := httptest.NewRequest("GET", "/u/password", nil)
+	for _, c := range w.Result().Cookies() {
+		r.AddCookie(c)
+	}
+
+	me, err := utils.IsAuthenticated(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if me == nil || me.ID != u.ID {
+		t.Fatalf("got signed in user %+v, want %d", me, u.ID)
+	}
+}
+
+func TestPostProfileEmailTaken(t *testing.T) {
+	s := newStore(t)
+	u := newUser(t, s, "pat@example.com", "user")
+	newUser(t, s, "sam@example.com", "user")
+
+	w := request{
+		method: "POST",
+		target: "/u/profile",
+		form: url.Values{
+			"name":  {"Pat"},
+			"email": {"sam@example.com"},
+		},
+		cookies: signIn(t, u),
+	}.do(PostProfile)
+	wantBody(t, w, http.StatusOK, "already in use")
+
+	// nothing's saved from a form that was turned away
+	got := &models.User{ID: u.ID}
+	err := s.Users.Fetch(context.Background(), got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name.String != "Test User" {
+		t.Fatalf("the name changed to %q", got.Name.String)
+	}
+}
+
+func TestVerifyEmailTaken(t *testing.T) {
+	s := newStore(t)
+	u := newUser(t, s, "pat@example.com", "user")
+
+	v, token, err := s.Verifications.Create(context.Background(), u, "sam@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newUser(t, s, "sam@example.com", "user")
+
+	w := request{
+		method: "GET",
+		target: "/auth/verify?id=" + strconv.Itoa(v.ID) + "&token=" + token,
+	}.do(VerifyEmail)
+	wantRedirect(t, w, "/")
+
+	// the link isn't used up by an address change that didn't happen
+	_, err = s.Verifications.Find(context.Background(), v.ID, token)
+	if err != nil {
+		t.Fatalf("the link stopped working: %v", err)
+	}
+
+	got := &models.User{ID: u.ID}
+	err = s.Users.Fetch(context.Background(), got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Email.String != "pat@example.com" || got.IsVerified() {
+		t.Fatalf("got %q verified %v, want the old address unverified", got.Email.String, got.IsVerified())
+	}
+}
@@ -19,7 +19,7 @@ func SettingsForm(w http.ResponseWriter, r *http.Request) {
 		ID: 1,
 	}
 
-	err := s.Fetch()
+	err := db.Settings.Fetch(r.Context(), s)
 	if err != nil {
 		if err == domain.ErrNotFound {
 			view.Render(w, r, "settings", &view.View{
@@ -32,24 +32,15 @@ func SettingsForm(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	galleries, err := models.FetchGalleries()
+	galleries, err := db.Galleries.FetchAll(r.Context())
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
 	}
 
-	f := &models.SettingsForm{
-		ID:                strconv.Itoa(s.ID),
-		ContactBlurb:      s.ContactBlurb.String,
-		AboutBlurb:        s.AboutBlurb.String,
-		AboutContent:      s.AboutContent.String,
-		HomeGalleryID:     int(s.HomeGalleryID.Int64),
-		HomeGalleryActive: s.HomeGalleryActive,
-	}
-
 	view.Render(w, r, "settings", &view.View{
 		Title:     "Settings",
-		Form:      f,
+		Form:      settingsForm(s),
 		Galleries: galleries,
 	})
 }
@@ -63,11 +54,13 @@ func PostSettings(w http.ResponseWriter, r *http.Request) {
 
 	f := &models.SettingsForm{
 		ID:                r.PostForm.Get("id"),
+		Version:           utils.ToInt(r.PostForm.Get("version")),
 		ContactBlurb:      r.PostForm.Get("contact_blurb"),
 		AboutBlurb:        r.PostForm.Get("about_blurb"),
 		AboutContent:      r.PostForm.Get("about_content"),
 		HomeGalleryID:     utils.ToInt(r.PostForm.Get("home_gallery")),
 		HomeGalleryActive: (len(r.Form["home_gallery_active"]) == 1),
+		RequireAdmin2FA:   (len(r.Form["require_admin_2fa"]) == 1),
 	}
 
 	if !f.Valid() {
@@ -76,33 +69,36 @@ func PostSettings(w http.ResponseWriter, r *http.Request) {
 		}
 
 		view.Render(w, r, "settings", v)
+		return
 	}
 
 	var msg string
 
 	s := models.Settings{
 		ID:                utils.ToInt(f.ID),
+		Version:           f.Version,
 		ContactBlurb:      utils.NewNullStr(f.ContactBlurb),
 		AboutBlurb:        utils.NewNullStr(f.AboutBlurb),
 		AboutContent:      utils.NewNullStr(f.AboutContent),
 		HomeGalleryID:     utils.NewNullInt(f.HomeGalleryID),
 		HomeGalleryActive: f.HomeGalleryActive,
+		RequireAdmin2FA:   f.RequireAdmin2FA,
 	}
 
-	if f.ID != "" {
-		err := s.Update()
-		if err != nil {
-			view.ServerError(w, r, err)
-			return
-		}
+	if s.ID != 0 {
+		err = db.Settings.Update(r.Context(), &s)
 		msg = utils.MsgSuccessfullyUpdated
 	} else {
-		err := s.Create()
-		if err != nil {
-			view.ServerError(w, r, err)
+		err = db.Settings.Create(r.Context(), &s)
+		msg = utils.MsgSuccessfullyCreated
+	}
+	if err != nil {
+		if err == domain.ErrConflict {
+			settingsConflict(w, r, f)
 			return
 		}
-		msg = utils.MsgSuccessfullyCreated
+		view.ServerError(w, r, err)
+		return
 	}
 
 	err = flash.Add(w, r, msg, "success")
@@ -113,3 +109,37 @@ func PostSettings(w http.ResponseWriter, r *http.Request) {
 
 	http.Redirect(w, r, "/admin/settings", http.StatusSeeOther)
 }
+
+// settingsForm fills in the settings form from s.
+func settingsForm(s *models.Settings) *models.SettingsForm {
+	return &models.SettingsForm{
+		ID:                strconv.Itoa(s.ID),
+		Version:           s.Version,
+		ContactBlurb:      s.ContactBlurb.String,
+		AboutBlurb:        s.AboutBlurb.String,
+		AboutContent:      s.AboutContent.String,
+		HomeGalleryID:     int(s.HomeGalleryID.Int64),
+		HomeGalleryActive: s.HomeGalleryActive,
+		RequireAdmin2FA:   s.RequireAdmin2FA,
+	}
+}
+
+// settingsConflict shows f, which couldn't be saved, next to the settings as
+// someone else has saved them since.
+func settingsConflict(w http.ResponseWriter, r *http.Request, f *models.SettingsForm) {
+	s := &models.Settings{
+		ID: utils.ToInt(f.ID),
+	}
+
+	err := db.Settings.Fetch(r.Context(), s)
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
+	}
+
+	view.Conflict(w, r, models.NewConflict("Settings", "/admin/settings", f, settingsForm(s)))
+}
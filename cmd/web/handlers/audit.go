@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+	"revelbus/cmd/web/utils"
+	"revelbus/cmd/web/view"
+	"revelbus/internal/platform/domain"
+	"revelbus/internal/platform/domain/models"
+
+	"github.com/gorilla/mux"
+)
+
+// auditPageSize is how many entries the audit log shows at a time.
+const auditPageSize = 50
+
+func ListAuditLog(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	f := &models.AuditFilter{
+		EntityType: q.Get("type"),
+		EntityID:   utils.ToInt(q.Get("id")),
+		Action:     q.Get("action"),
+		Actor:      q.Get("actor"),
+	}
+
+	page := utils.ToInt(q.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	// one past the page says whether there's another after it
+	entries, err := db.AuditLog.Fetch(r.Context(), f, auditPageSize+1, (page-1)*auditPageSize)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	v := &view.View{
+		Title:        "Audit Log",
+		Audit:        entries,
+		AuditFilter:  f,
+		AuditActions: models.AuditActions,
+		AuditTypes:   models.AuditTypes,
+	}
+
+	if len(*entries) > auditPageSize {
+		*entries = (*entries)[:auditPageSize]
+		v.NextPage = "/admin/audit?" + f.Query(page+1)
+	}
+
+	if page > 1 {
+		v.PrevPage = "/admin/audit?" + f.Query(page-1)
+	}
+
+	view.Render(w, r, "audit", v)
+}
+
+func TripHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	t := &models.Trip{
+		ID: utils.ToInt(id),
+	}
+
+	err := db.Trips.Fetch(r.Context(), t)
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
+	}
+
+	f := &models.AuditFilter{
+		EntityType: models.AuditTrip,
+		EntityID:   t.ID,
+	}
+
+	entries, err := db.AuditLog.Fetch(r.Context(), f, auditPageSize, 0)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	view.Render(w, r, "trip-history", &view.View{
+		ActiveKey:   "history",
+		Audit:       entries,
+		AuditFilter: f,
+		Trip:        t,
+	})
+}
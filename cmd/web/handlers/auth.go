@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"revelbus/cmd/web/utils"
 	"revelbus/cmd/web/view"
@@ -8,10 +9,51 @@ import (
 	"revelbus/internal/platform/domain/models"
 	"revelbus/internal/platform/emails"
 	"revelbus/internal/platform/flash"
+	"revelbus/internal/platform/ratelimit"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/spf13/viper"
 )
 
+const loginIPWindow = 15 * time.Minute
+
+var (
+	loginLimiter      *ratelimit.Limiter
+	loginLimiterOnce  sync.Once
+	forgotLimiter     *ratelimit.Limiter
+	forgotLimiterOnce sync.Once
+)
+
+// getLoginLimiter counts failed logins per IP, by default 20 every 15
+// minutes, to slow down guessing across many accounts.
+func getLoginLimiter() *ratelimit.Limiter {
+	loginLimiterOnce.Do(func() {
+		max := viper.GetInt("login.ip_failures")
+		if max <= 0 {
+			max = 20
+		}
+		loginLimiter = ratelimit.New(max, loginIPWindow)
+	})
+	return loginLimiter
+}
+
+// getForgotLimiter caps recovery emails per IP and per address, by default 3
+// an hour each.
+func getForgotLimiter() *ratelimit.Limiter {
+	forgotLimiterOnce.Do(func() {
+		max := viper.GetInt("forgot.per_hour")
+		if max <= 0 {
+			max = 3
+		}
+		forgotLimiter = ratelimit.New(max, time.Hour)
+	})
+	return forgotLimiter
+}
+
 func SignupForm(w http.ResponseWriter, r *http.Request) {
 	view.Render(w, r, "signup", &view.View{
 		Form:  new(models.UserForm),
@@ -48,7 +90,7 @@ func PostSignup(w http.ResponseWriter, r *http.Request) {
 		Role:     utils.NewNullStr(f.Role),
 	}
 
-	err = u.Create()
+	err = db.Users.Create(r.Context(), &u)
 	if err != nil {
 		if err == domain.ErrDuplicateEmail {
 			f.Errors["Email"] = "E-mail address is already in use"
@@ -62,6 +104,12 @@ func PostSignup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	err = sendVerification(r.Context(), &u)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
 	err = flash.Add(w, r, utils.MsgSuccessfulSignup, "success")
 	if err != nil {
 		view.ServerError(w, r, err)
@@ -97,13 +145,47 @@ func PostLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ip := utils.ClientIP(r)
+
+	if getLoginLimiter().Exceeded(ip) {
+		loginThrottled(w, r, f, loginIPWindow)
+		return
+	}
+
+	wait, err := db.Users.LoginWait(r.Context(), f.Email)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	if wait > 0 {
+		loginThrottled(w, r, f, wait)
+		return
+	}
+
 	u := &models.User{
 		Email: utils.NewNullStr(f.Email),
 	}
 
-	err = u.VerifyUser(f.Password)
+	err = db.Users.VerifyUser(r.Context(), u, f.Password)
 	if err != nil {
 		if err == domain.ErrInvalidCredentials {
+			getLoginLimiter().Allow(ip)
+
+			locked, err := db.Users.RecordFailedLogin(r.Context(), f.Email)
+			if err != nil {
+				view.ServerError(w, r, err)
+				return
+			}
+
+			if locked != nil {
+				err = accountLocked(r, locked)
+				if err != nil {
+					view.ServerError(w, r, err)
+					return
+				}
+			}
+
 			err = flash.Add(w, r, utils.MsgUnsuccessfulLogin, "danger")
 			if err != nil {
 				view.ServerError(w, r, err)
@@ -120,7 +202,18 @@ func PostLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = utils.SetUserSession(w, r, u)
+	if u.TOTPEnabled {
+		err = utils.SetPendingLogin(w, r, u)
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+
+		http.Redirect(w, r, "/auth/two-factor", http.StatusSeeOther)
+		return
+	}
+
+	err = completeLogin(w, r, u)
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
@@ -129,8 +222,90 @@ func PostLogin(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/u", http.StatusSeeOther)
 }
 
+// completeLogin signs the user in once they've passed every check, and logs
+// it, warning them by email if it's from a device they haven't used before.
+func completeLogin(w http.ResponseWriter, r *http.Request, u *models.User) error {
+	err := db.Users.ResetFailedLogins(r.Context(), u)
+	if err != nil {
+		return err
+	}
+
+	err = utils.SetUserSession(w, r, u)
+	if err != nil {
+		return err
+	}
+
+	known, err := db.SecurityEvents.KnownDevice(r.Context(), u.ID, r.UserAgent())
+	if err != nil {
+		return err
+	}
+
+	if known {
+		_, err = logSecurity(r, u, models.SecurityLogin, "")
+		return err
+	}
+
+	err = alertSecurity(r, u, models.SecurityNewDevice, "")
+	return err
+}
+
+// accountLocked emails the owner of an account that a failed login just
+// locked, and logs it.
+func accountLocked(r *http.Request, u *models.User) error {
+	err := emails.AccountLocked(r.Context(), u)
+	if err != nil {
+		return err
+	}
+
+	_, err = logSecurity(r, u, models.SecurityLocked, "")
+	return err
+}
+
+// loginThrottled turns a login attempt away without checking the password.
+func loginThrottled(w http.ResponseWriter, r *http.Request, f *models.UserForm, wait time.Duration) {
+	err := flash.Add(w, r, fmt.Sprintf(utils.MsgLoginThrottled, waitText(wait)), "danger")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	view.Render(w, r, "login", &view.View{
+		Form:  f,
+		Title: "Login",
+	})
+}
+
+// waitText rounds a wait up to something readable, e.g. "12 seconds".
+func waitText(d time.Duration) string {
+	if d < time.Minute {
+		n := int((d + time.Second - 1) / time.Second)
+		if n == 1 {
+			return "1 second"
+		}
+		return strconv.Itoa(n) + " seconds"
+	}
+
+	n := int((d + time.Minute - 1) / time.Minute)
+	if n == 1 {
+		return "1 minute"
+	}
+	return strconv.Itoa(n) + " minutes"
+}
+
 func Logout(w http.ResponseWriter, r *http.Request) {
-	err := utils.RemoveUserSession(w, r)
+	// an admin viewing as someone else goes back to being themselves
+	uid, err := utils.StopImpersonating(w, r)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	if uid != 0 {
+		http.Redirect(w, r, "/admin/user?id="+strconv.Itoa(uid), http.StatusSeeOther)
+		return
+	}
+
+	err = utils.RemoveUserSession(w, r)
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
@@ -165,21 +340,39 @@ func PostForgotPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Past the limit the response stays the same but no email goes out, so
+	// the form can't be used to flood someone's inbox.
+	if !getForgotLimiter().Allow(utils.ClientIP(r)) || !getForgotLimiter().Allow(strings.ToLower(f.Email)) {
+		err = flash.Add(w, r, utils.MsgRecoverySent, "success")
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
 	u := models.User{
 		Email: utils.NewNullStr(f.Email),
 	}
 
-	err = u.Fetch()
+	err = db.Users.Fetch(r.Context(), &u)
 	if err == nil {
-		rh := utils.RandomString(20)
-
-		err = u.SetRecover(rh)
+		p, token, err := db.PasswordResets.Create(r.Context(), u.ID)
 		if err != nil {
 			view.ServerError(w, r, err)
 			return
 		}
 
-		emails.RecoverAccount(u.Email.String, rh)
+		err = emails.RecoverAccount(r.Context(), u.Email.String, p.ID, token)
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+	} else if err != domain.ErrNotFound {
+		view.ServerError(w, r, err)
+		return
 	}
 
 	err = flash.Add(w, r, utils.MsgRecoverySent, "success")
@@ -194,36 +387,28 @@ func PostForgotPassword(w http.ResponseWriter, r *http.Request) {
 func ResetPasswordForm(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 
-	email := vars["email"]
-	hash := vars["hash"]
-
-	if email == "" || hash == "" {
-		err := flash.Add(w, r, utils.MsgInvalidRecovery, "warning")
-		if err != nil {
-			view.ServerError(w, r, err)
-			return
-		}
-		http.Redirect(w, r, "/auth/forgot", http.StatusSeeOther)
-	}
-
-	u := &models.User{
-		Email: utils.NewNullStr(email),
-	}
+	id := vars["id"]
+	token := vars["token"]
 
-	err := u.CheckRecover(hash)
+	_, err := db.PasswordResets.Find(r.Context(), utils.ToInt(id), token)
 	if err != nil {
-		err := flash.Add(w, r, utils.MsgInvalidRecovery, "warning")
-		if err != nil {
-			view.ServerError(w, r, err)
+		if err == domain.ErrInvalidCredentials {
+			err := flash.Add(w, r, utils.MsgInvalidRecovery, "warning")
+			if err != nil {
+				view.ServerError(w, r, err)
+				return
+			}
+			http.Redirect(w, r, "/auth/forgot", http.StatusSeeOther)
 			return
 		}
-		http.Redirect(w, r, "/auth/forgot", http.StatusSeeOther)
+		view.ServerError(w, r, err)
+		return
 	}
 
 	view.Render(w, r, "reset", &view.View{
 		Form: &models.UserForm{
-			Email:        email,
-			RecoveryHash: hash,
+			ResetID:    id,
+			ResetToken: token,
 		},
 		Title: "Reset Password",
 	})
@@ -237,13 +422,13 @@ func PostPasswordReset(w http.ResponseWriter, r *http.Request) {
 	}
 
 	f := &models.UserForm{
-		Email:           r.PostForm.Get("email"),
 		Password:        r.PostForm.Get("password"),
 		ConfirmPassword: r.PostForm.Get("confirm_password"),
-		RecoveryHash:    r.PostForm.Get("recovery_hash"),
+		ResetID:         r.PostForm.Get("reset_id"),
+		ResetToken:      r.PostForm.Get("reset_token"),
 	}
 
-	if !f.ValidPassword() || !f.ValidForgot() {
+	if !f.ValidPassword() {
 		view.Render(w, r, "reset", &view.View{
 			Form:  f,
 			Title: "Reset Password",
@@ -251,29 +436,41 @@ func PostPasswordReset(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	u := &models.User{
-		Email: utils.NewNullStr(f.Email),
+	p, err := db.PasswordResets.Find(r.Context(), utils.ToInt(f.ResetID), f.ResetToken)
+	if err == nil {
+		err = db.PasswordResets.Redeem(r.Context(), p, f.Password)
 	}
-
-	err = u.Recover(f.RecoveryHash, f.Password)
 	if err != nil {
 		if err == domain.ErrInvalidCredentials {
-			err = flash.Add(w, r, utils.MsgInvalidCredentials, "danger")
+			err = flash.Add(w, r, utils.MsgInvalidRecovery, "warning")
 			if err != nil {
 				view.ServerError(w, r, err)
 				return
 			}
 
-			view.Render(w, r, "reset", &view.View{
-				Form:  f,
-				Title: "Reset Password",
-			})
+			http.Redirect(w, r, "/auth/forgot", http.StatusSeeOther)
 			return
 		}
 		view.ServerError(w, r, err)
 		return
 	}
 
+	u := &models.User{
+		ID: p.UserID,
+	}
+
+	err = db.Users.Fetch(r.Context(), u)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = alertSecurity(r, u, models.SecurityPasswordReset, "")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
 	err = flash.Add(w, r, utils.MsgPasswordResetSuccessful, "success")
 	if err != nil {
 		view.ServerError(w, r, err)
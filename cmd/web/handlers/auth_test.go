@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"revelbus/internal/platform/domain/models"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestPostLogin(t *testing.T) {
+	s := newStore(t)
+	u := newUser(t, s, "pat@example.com", "user")
+
+	w := request{
+		method: "POST",
+		target: "/auth/login",
+		form:   url.Values{"email": {"pat@example.com"}, "password": {"wrong"}},
+	}.do(PostLogin)
+	wantBody(t, w, http.StatusOK, `name="password"`)
+
+	sessions, err := s.Sessions.FetchAll(context.Background(), u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*sessions) != 0 {
+		t.Fatalf("got %d sessions after a wrong password, want none", len(*sessions))
+	}
+
+	w = request{
+		method: "POST",
+		target: "/auth/login",
+		form:   url.Values{"email": {"pat@example.com"}, "password": {"secret"}},
+	}.do(PostLogin)
+	wantRedirect(t, w, "/u")
+
+	sessions, err = s.Sessions.FetchAll(context.Background(), u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*sessions) != 1 {
+		t.Fatalf("got %d sessions, want 1", len(*sessions))
+	}
+}
+
+// resetPassword posts the recovery form for reset id with token.
+func resetPassword(id int, token string, pw string) *httptest.ResponseRecorder {
+	return request{
+		method: "POST",
+		target: "/auth/reset",
+		form: url.Values{
+			"reset_id":         {strconv.Itoa(id)},
+			"reset_token":      {token},
+			"password":         {pw},
+			"confirm_password": {pw},
+		},
+	}.do(PostPasswordReset)
+}
+
+func TestPostPasswordReset(t *testing.T) {
+	s := newStore(t)
+	u := newUser(t, s, "pat@example.com", "user")
+	signIn(t, u)
+
+	p, token, err := s.PasswordResets.Create(context.Background(), u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, otherToken, err := s.PasswordResets.Create(context.Background(), u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := resetPassword(p.ID, "not-the-token", "new secret")
+	wantRedirect(t, w, "/auth/forgot")
+
+	w = resetPassword(p.ID, token, "new secret")
+	wantRedirect(t, w, "/auth/login")
+
+	err = s.Users.VerifyUser(context.Background(), &models.User{Email: u.Email}, "new secret")
+	if err != nil {
+		t.Fatalf("can't sign in with the new password: %v", err)
+	}
+
+	sessions, err := s.Sessions.FetchAll(context.Background(), u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*sessions) != 0 {
+		t.Fatalf("got %d sessions after the reset, want the user signed out everywhere", len(*sessions))
+	}
+
+	// the link only works once
+	w = resetPassword(p.ID, token, "third secret")
+	wantRedirect(t, w, "/auth/forgot")
+
+	// and the user's other links went with it
+	w = resetPassword(other.ID, otherToken, "third secret")
+	wantRedirect(t, w, "/auth/forgot")
+
+	err = s.Users.VerifyUser(context.Background(), &models.User{Email: u.Email}, "new secret")
+	if err != nil {
+		t.Fatalf("a used link changed the password: %v", err)
+	}
+}
+
+func TestPostPasswordResetExpired(t *testing.T) {
+	s := newStore(t)
+	u := newUser(t, s, "pat@example.com", "user")
+
+	viper.Set("recovery.ttl", "1ns")
+	defer viper.Set("recovery.ttl", "")
+
+	p, token, err := s.PasswordResets.Create(context.Background(), u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+
+	w := resetPassword(p.ID, token, "new secret")
+	wantRedirect(t, w, "/auth/forgot")
+
+	err = s.Users.VerifyUser(context.Background(), &models.User{Email: u.Email}, "secret")
+	if err != nil {
+		t.Fatalf("an expired link changed the password: %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"net/http"
+	"revelbus/cmd/web/utils"
+	"revelbus/cmd/web/view"
+	"revelbus/internal/platform/domain"
+	"revelbus/internal/platform/domain/models"
+	"revelbus/internal/platform/flash"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func PostBooking(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		view.ClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	u, err := utils.IsAuthenticated(r)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	t := &models.Trip{
+		ID: utils.ToInt(r.PostForm.Get("trip_id")),
+	}
+
+	err = db.Trips.Fetch(r.Context(), t)
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
+	}
+
+	if t.Status.String != "published" || t.Start.Before(time.Now()) {
+		view.ClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	b := &models.Booking{
+		TripID: t.ID,
+		UserID: u.ID,
+	}
+
+	msg := utils.MsgTripBooked
+	err = db.Bookings.Create(r.Context(), b)
+	if err == domain.ErrDuplicate {
+		msg = utils.MsgAlreadyBooked
+	} else if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = flash.Add(w, r, msg, "success")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/trip/"+t.Slug.String, http.StatusSeeOther)
+}
+
+func CancelBooking(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	u, err := utils.IsAuthenticated(r)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	b := &models.Booking{
+		ID:     utils.ToInt(id),
+		UserID: u.ID,
+	}
+
+	err = db.Bookings.Cancel(r.Context(), b)
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = flash.Add(w, r, utils.MsgBookingCancelled, "success")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/u/", http.StatusSeeOther)
+}
+
+func TripRiders(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	t := &models.Trip{
+		ID: utils.ToInt(id),
+	}
+
+	err := db.Trips.Fetch(r.Context(), t)
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
+	}
+
+	riders, err := db.Bookings.FetchRiders(r.Context(), t.ID)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	vendors, err := db.Vendors.FetchAll(r.Context(), true)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	view.Render(w, r, "trip-riders", &view.View{
+		ActiveKey: "riders",
+		Bookings:  riders,
+		Trip:      t,
+		Vendors:   vendors,
+	})
+}
+
+func CheckInRider(w http.ResponseWriter, r *http.Request) {
+	checkIn(w, r, r.FormValue("checkin"), true)
+}
+
+func UndoCheckIn(w http.ResponseWriter, r *http.Request) {
+	checkIn(w, r, r.FormValue("uncheck"), false)
+}
+
+func checkIn(w http.ResponseWriter, r *http.Request, bid string, in bool) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	b := &models.Booking{
+		ID:     utils.ToInt(bid),
+		TripID: utils.ToInt(id),
+	}
+
+	err := db.Bookings.CheckIn(r.Context(), b, in)
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
+	}
+
+	msg := utils.MsgRiderCheckedIn
+	if !in {
+		msg = utils.MsgRiderCheckInUndone
+	}
+
+	err = flash.Add(w, r, msg, "success")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/trip/"+id+"?riders", http.StatusSeeOther)
+}
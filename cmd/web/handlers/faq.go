@@ -27,7 +27,7 @@ func FaqForm(w http.ResponseWriter, r *http.Request) {
 		ID: utils.ToInt(id),
 	}
 
-	err := faq.Fetch()
+	err := db.FAQs.Fetch(r.Context(), faq)
 	if err != nil {
 		if err == domain.ErrNotFound {
 			view.NotFound(w, r)
@@ -37,18 +37,9 @@ func FaqForm(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	f := &models.FAQForm{
-		ID:       strconv.Itoa(faq.ID),
-		Question: faq.Question.String,
-		Answer:   faq.Answer.String,
-		Category: faq.Category.String,
-		Order:    strconv.FormatInt(faq.Order.Int64, 10),
-		Active:   faq.Active,
-	}
-
 	view.Render(w, r, "faq-admin", &view.View{
 		Title: "FAQ",
-		Form:  f,
+		Form:  faqForm(faq),
 	})
 }
 
@@ -61,6 +52,7 @@ func PostFAQ(w http.ResponseWriter, r *http.Request) {
 
 	f := &models.FAQForm{
 		ID:       r.PostForm.Get("id"),
+		Version:  utils.ToInt(r.PostForm.Get("version")),
 		Question: r.PostForm.Get("question"),
 		Category: r.PostForm.Get("category"),
 		Answer:   r.PostForm.Get("answer"),
@@ -79,12 +71,14 @@ func PostFAQ(w http.ResponseWriter, r *http.Request) {
 		}
 
 		view.Render(w, r, "faq-admin", v)
+		return
 	}
 
 	var msg string
 
 	faq := models.FAQ{
 		ID:       utils.ToInt(f.ID),
+		Version:  f.Version,
 		Question: utils.NewNullStr(f.Question),
 		Answer:   utils.NewNullStr(f.Answer),
 		Category: utils.NewNullStr(f.Category),
@@ -93,18 +87,21 @@ func PostFAQ(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if faq.ID != 0 {
-		err := faq.Update()
+		err := db.FAQs.Update(r.Context(), &faq)
 		if err != nil {
 			if err == domain.ErrNotFound {
 				view.NotFound(w, r)
 				return
+			} else if err == domain.ErrConflict {
+				faqConflict(w, r, f)
+				return
 			}
 			view.ServerError(w, r, err)
 			return
 		}
 		msg = utils.MsgSuccessfullyUpdated
 	} else {
-		err := faq.Create()
+		err := db.FAQs.Create(r.Context(), &faq)
 		if err != nil {
 			view.ServerError(w, r, err)
 			return
@@ -123,8 +120,41 @@ func PostFAQ(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/admin/faq?id="+id, http.StatusSeeOther)
 }
 
+// faqForm fills in the FAQ form from faq.
+func faqForm(faq *models.FAQ) *models.FAQForm {
+	return &models.FAQForm{
+		ID:       strconv.Itoa(faq.ID),
+		Version:  faq.Version,
+		Question: faq.Question.String,
+		Answer:   faq.Answer.String,
+		Category: faq.Category.String,
+		Order:    strconv.FormatInt(faq.Order.Int64, 10),
+		Active:   faq.Active,
+	}
+}
+
+// faqConflict shows f, which couldn't be saved, next to the FAQ as someone
+// else has saved it since.
+func faqConflict(w http.ResponseWriter, r *http.Request, f *models.FAQForm) {
+	faq := &models.FAQ{
+		ID: utils.ToInt(f.ID),
+	}
+
+	err := db.FAQs.Fetch(r.Context(), faq)
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
+	}
+
+	view.Conflict(w, r, models.NewConflict("FAQ", "/admin/faq?id="+f.ID, f, faqForm(faq)))
+}
+
 func ListFAQs(w http.ResponseWriter, r *http.Request) {
-	faqs, err := models.FetchFAQs()
+	faqs, err := db.FAQs.FetchAll(r.Context())
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
@@ -144,13 +174,17 @@ func RemoveFAQ(w http.ResponseWriter, r *http.Request) {
 		ID: utils.ToInt(id),
 	}
 
-	err := faq.Delete()
+	err := db.FAQs.Delete(r.Context(), &faq)
 	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		}
 		view.ServerError(w, r, err)
 		return
 	}
 
-	err = flash.Add(w, r, utils.MsgSuccessfullyRemoved, "success")
+	err = flash.Add(w, r, utils.MsgMovedToTrash, "success")
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
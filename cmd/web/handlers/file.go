@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"context"
+	"database/sql"
 	"net/http"
 	"revelbus/cmd/web/utils"
 	"revelbus/cmd/web/view"
@@ -26,7 +28,7 @@ func PostUpload(w http.ResponseWriter, r *http.Request) {
 
 	fldr := r.PostForm.Get("fldr")
 
-	if _, err = utils.UploadFile(w, r, "files", "uploads/files/"+fldr, false); err != nil {
+	if _, err = utils.UploadFile(r.Context(), r, "files", "uploads/files/"+fldr, false); err != nil {
 		view.ServerError(w, r, err)
 		return
 	}
@@ -35,7 +37,7 @@ func PostUpload(w http.ResponseWriter, r *http.Request) {
 }
 
 func ListFiles(w http.ResponseWriter, r *http.Request) {
-	files, err := models.FetchFiles()
+	files, err := db.Files.FetchAll(r.Context())
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
@@ -54,7 +56,7 @@ func RemoveFile(w http.ResponseWriter, r *http.Request) {
 		ID: utils.ToInt(id),
 	}
 
-	err := utils.DeleteFile(f)
+	err := db.Files.Delete(r.Context(), f)
 	if err != nil {
 		if err == domain.ErrCannotDelete {
 			err = flash.Add(w, r, utils.MsgCannotRemove, "warning")
@@ -75,3 +77,22 @@ func RemoveFile(w http.ResponseWriter, r *http.Request) {
 
 	http.Redirect(w, r, "/admin/files", http.StatusSeeOther)
 }
+
+// deleteUnused deletes the file with id, unless a vendor, trip, gallery or
+// pending vendor change still shows it.
+func deleteUnused(ctx context.Context, id sql.NullInt64) error {
+	if !id.Valid {
+		return nil
+	}
+
+	f := &models.File{
+		ID: int(id.Int64),
+	}
+
+	used, err := db.Files.InUse(ctx, f)
+	if err != nil || used {
+		return err
+	}
+
+	return db.Files.Delete(ctx, f)
+}
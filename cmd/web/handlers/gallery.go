@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"net/http"
 	"revelbus/cmd/web/utils"
 	"revelbus/cmd/web/view"
@@ -27,7 +28,7 @@ func GalleryForm(w http.ResponseWriter, r *http.Request) {
 		ID: utils.ToInt(id),
 	}
 
-	err := g.Fetch()
+	err := db.Galleries.Fetch(r.Context(), g)
 	if err != nil {
 		if err == domain.ErrNotFound {
 			view.NotFound(w, r)
@@ -37,15 +38,9 @@ func GalleryForm(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	f := &models.GalleryForm{
-		ID:     strconv.Itoa(g.ID),
-		Name:   g.Name.String,
-		Folder: g.Folder.String,
-	}
-
 	view.Render(w, r, "gallery", &view.View{
 		Title:   g.Name.String,
-		Form:    f,
+		Form:    galleryForm(g),
 		Gallery: g,
 	})
 }
@@ -58,9 +53,10 @@ func PostGallery(w http.ResponseWriter, r *http.Request) {
 	}
 
 	f := &models.GalleryForm{
-		ID:     r.PostForm.Get("id"),
-		Name:   r.PostForm.Get("name"),
-		Folder: r.PostForm.Get("folder"),
+		ID:      r.PostForm.Get("id"),
+		Version: utils.ToInt(r.PostForm.Get("version")),
+		Name:    r.PostForm.Get("name"),
+		Folder:  r.PostForm.Get("folder"),
 	}
 
 	if !f.Valid() {
@@ -73,42 +69,51 @@ func PostGallery(w http.ResponseWriter, r *http.Request) {
 		}
 
 		view.Render(w, r, "gallery", v)
+		return
 	}
 
 	var msg string
 
 	g := models.Gallery{
-		ID:   utils.ToInt(f.ID),
-		Name: utils.NewNullStr(f.Name),
+		ID:      utils.ToInt(f.ID),
+		Version: f.Version,
+		Name:    utils.NewNullStr(f.Name),
 	}
 
 	if g.ID != 0 {
-		err := g.Update()
+		err := db.InTx(r.Context(), func(ctx context.Context) error {
+			err := db.Galleries.Update(ctx, &g)
+			if err != nil {
+				return err
+			}
+
+			uploads, err := utils.UploadFile(ctx, r, "files", "uploads/files/"+g.Folder.String, true)
+			if err != nil {
+				return err
+			}
+
+			for _, f := range uploads {
+				err = db.Galleries.AttachImage(ctx, &g, strconv.Itoa(f.ID))
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
 		if err != nil {
 			if err == domain.ErrNotFound {
 				view.NotFound(w, r)
 				return
+			} else if err == domain.ErrConflict {
+				galleryConflict(w, r, f)
+				return
 			}
 			view.ServerError(w, r, err)
 			return
 		}
-
-		uploads, err := utils.UploadFile(w, r, "files", "uploads/files/"+g.Folder.String, true)
-		if err != nil {
-			view.ServerError(w, r, err)
-			return
-		}
-
-		for _, f := range uploads {
-			g.AttachImage(strconv.Itoa(f.ID))
-			if err != nil {
-				view.ServerError(w, r, err)
-				return
-			}
-		}
 		msg = utils.MsgSuccessfullyUpdated
 	} else {
-		err := g.Create()
+		err := db.Galleries.Create(r.Context(), &g)
 		if err != nil {
 			view.ServerError(w, r, err)
 			return
@@ -127,8 +132,38 @@ func PostGallery(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/admin/gallery?id="+id, http.StatusSeeOther)
 }
 
+// galleryForm fills in the gallery form from g.
+func galleryForm(g *models.Gallery) *models.GalleryForm {
+	return &models.GalleryForm{
+		ID:      strconv.Itoa(g.ID),
+		Version: g.Version,
+		Name:    g.Name.String,
+		Folder:  g.Folder.String,
+	}
+}
+
+// galleryConflict shows f, which couldn't be saved, next to the gallery as
+// someone else has saved it since.
+func galleryConflict(w http.ResponseWriter, r *http.Request, f *models.GalleryForm) {
+	g := &models.Gallery{
+		ID: utils.ToInt(f.ID),
+	}
+
+	err := db.Galleries.Fetch(r.Context(), g)
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
+	}
+
+	view.Conflict(w, r, models.NewConflict(g.Name.String, "/admin/gallery?id="+f.ID, f, galleryForm(g)))
+}
+
 func ListGalleries(w http.ResponseWriter, r *http.Request) {
-	galleries, err := models.FetchGalleries()
+	galleries, err := db.Galleries.FetchAll(r.Context())
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
@@ -148,25 +183,18 @@ func RemoveGallery(w http.ResponseWriter, r *http.Request) {
 		ID: utils.ToInt(id),
 	}
 
-	err := g.Fetch()
-	if err != nil {
-		view.ServerError(w, r, err)
-		return
-	}
-
-	err = utils.DeleteFolder("uploads/files/" + g.Folder.String)
-	if err != nil {
-		view.ServerError(w, r, err)
-		return
-	}
-
-	err = g.Delete()
+	// the images stay on disk until the gallery is purged from the trash
+	err := db.Galleries.Delete(r.Context(), &g)
 	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		}
 		view.ServerError(w, r, err)
 		return
 	}
 
-	err = flash.Add(w, r, utils.MsgSuccessfullyRemoved, "success")
+	err = flash.Add(w, r, utils.MsgMovedToTrash, "success")
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
@@ -184,7 +212,7 @@ func DetachImage(w http.ResponseWriter, r *http.Request) {
 		ID: utils.ToInt(id),
 	}
 
-	err := g.DetachImage(fid)
+	err := db.Galleries.DetachImage(r.Context(), &g, fid)
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
@@ -194,7 +222,7 @@ func DetachImage(w http.ResponseWriter, r *http.Request) {
 		ID: utils.ToInt(fid),
 	}
 
-	err = utils.DeleteFile(f)
+	err = db.Files.Delete(r.Context(), f)
 	if err != nil {
 		if err == domain.ErrCannotDelete {
 			err = flash.Add(w, r, utils.MsgCannotRemove, "warning")
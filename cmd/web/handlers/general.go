@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"html/template"
+	"log"
 	"net/http"
+	"revelbus/cmd/web/utils"
 	"revelbus/cmd/web/view"
 	"revelbus/internal/platform/cal"
 	"revelbus/internal/platform/domain"
@@ -10,18 +12,19 @@ import (
 	"revelbus/internal/platform/emails"
 	"revelbus/internal/platform/flash"
 	"revelbus/internal/platform/forms"
+	"revelbus/internal/platform/spam"
 
 	"github.com/gorilla/mux"
 )
 
 func Index(w http.ResponseWriter, r *http.Request) {
-	trips, err := models.FindUpcomingTrips(3)
+	trips, err := db.Trips.FindUpcoming(r.Context(), 3)
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
 	}
 
-	slides, err := models.FindActiveSlides()
+	slides, err := db.Slides.FindActive(r.Context())
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
@@ -31,7 +34,7 @@ func Index(w http.ResponseWriter, r *http.Request) {
 		ID: 1,
 	}
 
-	err = s.Fetch()
+	err = db.Settings.Fetch(r.Context(), s)
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
@@ -48,13 +51,16 @@ func Index(w http.ResponseWriter, r *http.Request) {
 			ID: int(s.HomeGalleryID.Int64),
 		}
 
-		err = g.Fetch()
-		if err != nil {
+		err = db.Galleries.Fetch(r.Context(), g)
+		if err != nil && err != domain.ErrNotFound {
 			view.ServerError(w, r, err)
 			return
 		}
 
-		v.Gallery = g
+		// a gallery in the trash just isn't shown
+		if err == nil {
+			v.Gallery = g
+		}
 	}
 
 	view.Render(w, r, "home", v)
@@ -65,7 +71,7 @@ func About(w http.ResponseWriter, r *http.Request) {
 		ID: 1,
 	}
 
-	err := s.Fetch()
+	err := db.Settings.Fetch(r.Context(), &s)
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
@@ -84,7 +90,7 @@ func Contact(w http.ResponseWriter, r *http.Request) {
 		ID: 1,
 	}
 
-	err := s.Fetch()
+	err := db.Settings.Fetch(r.Context(), &s)
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
@@ -94,6 +100,9 @@ func Contact(w http.ResponseWriter, r *http.Request) {
 		ActiveKey: "contact",
 		Title:     "Contact",
 		Blurb:     s.ContactBlurb.String,
+		Form: &forms.ContactForm{
+			Stamp: spam.Stamp(),
+		},
 	})
 }
 
@@ -109,23 +118,86 @@ func ContactPost(w http.ResponseWriter, r *http.Request) {
 		Phone:   r.PostForm.Get("phone"),
 		Email:   r.PostForm.Get("email"),
 		Message: r.PostForm.Get("message"),
+		Stamp:   r.PostForm.Get("stamp"),
+		Website: r.PostForm.Get("website"),
+	}
+
+	ip := utils.ClientIP(r)
+
+	// Bots that fill in the hidden field or submit faster than a person
+	// could type get the same response as everyone else and are dropped.
+	if f.Website != "" || spam.TooFast(f.Stamp, contactMinTime()) {
+		log.Printf("contact : Dropped bot submission from %s", ip)
+
+		err = flash.Add(w, r, utils.MsgMessageSent, "success")
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+
+		http.Redirect(w, r, "/contact-us", http.StatusSeeOther)
+		return
 	}
 
 	if !f.Valid() {
-		v := &view.View{
-			Form: f,
+		s := models.Settings{
+			ID: 1,
 		}
 
-		view.Render(w, r, "contact", v)
+		err := db.Settings.Fetch(r.Context(), &s)
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+
+		view.Render(w, r, "contact", &view.View{
+			ActiveKey: "contact",
+			Title:     "Contact",
+			Blurb:     s.ContactBlurb.String,
+			Form:      f,
+		})
+		return
 	}
 
-	err = emails.ContactEmail(f)
+	if !getContactLimiter().Allow(ip) {
+		err = flash.Add(w, r, utils.MsgTooManyMessages, "warning")
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+
+		http.Redirect(w, r, "/contact-us", http.StatusSeeOther)
+		return
+	}
+
+	m := &models.Message{
+		Name:  utils.NewNullStr(f.Name),
+		Email: utils.NewNullStr(f.Email),
+		Phone: utils.NewNullStr(f.Phone),
+		Body:  utils.NewNullStr(f.Message),
+		IP:    utils.NewNullStr(ip),
+		Score: spam.Score(f.Name, f.Email, f.Message),
+	}
+
+	if m.Score >= contactMaxScore() {
+		m.Status = utils.NewNullStr(models.MessageSpam)
+	}
+
+	err = db.Messages.Create(r.Context(), m)
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
 	}
 
-	err = flash.Add(w, r, "Your message has been sent!", "success")
+	if m.Status.String != models.MessageSpam {
+		err = emails.ContactEmail(r.Context(), f, m.ID)
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+	}
+
+	err = flash.Add(w, r, utils.MsgMessageSent, "success")
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
@@ -135,7 +207,7 @@ func ContactPost(w http.ResponseWriter, r *http.Request) {
 }
 
 func Trips(w http.ResponseWriter, r *http.Request) {
-	trips, err := models.FindUpcomingTripsByMonth()
+	trips, err := db.Trips.FindUpcomingByMonth(r.Context())
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
@@ -152,7 +224,7 @@ func Trip(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	slug := vars["slug"]
 
-	t, err := models.FindBySlug(slug)
+	t, err := db.Trips.FindBySlug(r.Context(), slug)
 	if err != nil {
 		if err == domain.ErrNotFound {
 			view.NotFound(w, r)
@@ -164,7 +236,7 @@ func Trip(w http.ResponseWriter, r *http.Request) {
 
 	t.CalendarLinks = cal.GetCalendarLinks(t)
 
-	trips, err := models.FindUpcomingTrips(2)
+	trips, err := db.Trips.FindUpcoming(r.Context(), 2)
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
@@ -178,17 +250,31 @@ func Trip(w http.ResponseWriter, r *http.Request) {
 		Content:   template.HTML(t.Description.String),
 	}
 
+	u, err := utils.IsAuthenticated(r)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	if u != nil {
+		v.Booked, err = db.Bookings.IsBooked(r.Context(), t.ID, u.ID)
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+	}
+
 	view.Render(w, r, "trip", v)
 }
 
 func Faq(w http.ResponseWriter, r *http.Request) {
-	faqs, err := models.FindActiveFAQs()
+	faqs, err := db.FAQs.FindActive(r.Context())
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
 	}
 
-	trips, err := models.FindUpcomingTrips(2)
+	trips, err := db.Trips.FindUpcoming(r.Context(), 2)
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
@@ -206,7 +292,7 @@ func Ical(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	slug := vars["slug"]
 
-	t, err := models.FindBySlug(slug)
+	t, err := db.Trips.FindBySlug(r.Context(), slug)
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
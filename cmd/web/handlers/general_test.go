@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"revelbus/cmd/web/utils"
+	"revelbus/internal/platform/domain/models"
+	"revelbus/internal/platform/spam"
+	"testing"
+	"time"
+)
+
+func TestIndex(t *testing.T) {
+	s := newStore(t)
+
+	trip := &models.Trip{
+		Title:  utils.NewNullStr("Wine Country Tour"),
+		Slug:   utils.NewNullStr("wine-country-tour"),
+		Status: utils.NewNullStr("published"),
+		Start:  time.Now().Add(7 * 24 * time.Hour),
+		End:    time.Now().Add(8 * 24 * time.Hour),
+	}
+
+	err := s.Trips.Create(context.Background(), trip)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := request{method: "GET", target: "/"}.do(Index)
+	wantBody(t, w, http.StatusOK, "Wine Country Tour")
+}
+
+func TestContactPost(t *testing.T) {
+	s := newStore(t)
+
+	w := request{
+		method: "POST",
+		target: "/contact",
+		form: url.Values{
+			"name":    {"Pat"},
+			"email":   {"pat@example.com"},
+			"message": {"Do you stop in Napa?"},
+			"stamp":   {spam.Stamp()},
+		},
+	}.do(ContactPost)
+	wantRedirect(t, w, "/contact-us")
+
+	messages, err := s.Messages.FetchAll(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*messages) != 1 || (*messages)[0].Body.String != "Do you stop in Napa?" {
+		t.Fatalf("got messages %+v, want the one sent", *messages)
+	}
+}
+
+func TestContactPostDropsBots(t *testing.T) {
+	s := newStore(t)
+
+	w := request{
+		method: "POST",
+		target: "/contact",
+		form: url.Values{
+			"name":    {"Pat"},
+			"email":   {"pat@example.com"},
+			"message": {"Do you stop in Napa?"},
+			"stamp":   {spam.Stamp()},
+			"website": {"http://example.com"},
+		},
+	}.do(ContactPost)
+
+	// a bot sees the same thing as everyone else
+	wantRedirect(t, w, "/contact-us")
+
+	messages, err := s.Messages.FetchAll(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*messages) != 0 {
+		t.Fatalf("got %d messages, want none", len(*messages))
+	}
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"revelbus/cmd/web/utils"
+	"revelbus/internal/platform/domain/models"
+	"revelbus/internal/platform/domain/store"
+	"revelbus/pkg/sessions"
+	"strings"
+	"testing"
+
+	"github.com/alexedwards/scs"
+	"github.com/gorilla/mux"
+	"github.com/spf13/viper"
+)
+
+func TestMain(m *testing.M) {
+	viper.Set("files.tpl", "../../../views")
+	viper.Set("contact.min_time", "1ns")
+	sessions.SetManager(scs.NewCookieManager("Yv3Rb8q0TzKc1mWp6LdS9nXe2HaJ4fUg"))
+
+	os.Exit(m.Run())
+}
+
+// newStore gives the test an empty store of its own.
+func newStore(t *testing.T) *store.Store {
+	t.Helper()
+
+	s := store.NewMemory()
+	UseStore(s)
+
+	err := s.Settings.Create(context.Background(), &models.Settings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// request is one call to a handler, made the way a browser would.
+type request struct {
+	method  string
+	target  string
+	form    url.Values
+	vars    map[string]string
+	cookies []*http.Cookie
+}
+
+func (req request) do(h http.HandlerFunc) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(req.method, req.target, strings.NewReader(req.form.Encode()))
+	if req.form != nil {
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	for _, c := range req.cookies {
+		r.AddCookie(c)
+	}
+	if req.vars != nil {
+		r = mux.SetURLVars(r, req.vars)
+	}
+
+	w := httptest.NewRecorder()
+	h(w, r)
+	return w
+}
+
+// newUser adds a user with the role and password "secret".
+func newUser(t *testing.T, s *store.Store, email string, role string) *models.User {
+	t.Helper()
+
+	u := &models.User{
+		Name:     utils.NewNullStr("Test User"),
+		Email:    utils.NewNullStr(email),
+		Password: utils.NewNullStr("secret"),
+		Role:     utils.NewNullStr(role),
+	}
+
+	err := s.Users.Create(context.Background(), u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+// signIn starts a session for u and returns the cookies that carry it.
+func signIn(t *testing.T, u *models.User) []*http.Cookie {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+
+	err := utils.SetUserSession(w, r, u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w.Result().Cookies()
+}
+
+func wantRedirect(t *testing.T, w *httptest.ResponseRecorder, to string) {
+	t.Helper()
+
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("got status %d, want %d; body: %s", w.Code, http.StatusSeeOther, w.Body.String())
+	}
+	if got := w.Header().Get("Location"); got != to {
+		t.Fatalf("redirected to %q, want %q", got, to)
+	}
+}
+
+func wantBody(t *testing.T, w *httptest.ResponseRecorder, code int, substr string) {
+	t.Helper()
+
+	if w.Code != code {
+		t.Fatalf("got status %d, want %d; body: %s", w.Code, code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), substr) {
+		t.Fatalf("body doesn't contain %q:\n%s", substr, w.Body.String())
+	}
+}
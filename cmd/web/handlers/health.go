@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"revelbus/pkg/database"
+)
+
+type health struct {
+	Status string    `json:"status"`
+	Error  string    `json:"error,omitempty"`
+	Pool   *poolInfo `json:"pool,omitempty"`
+}
+
+type poolInfo struct {
+	MaxOpen           int    `json:"max_open"`
+	Open              int    `json:"open"`
+	InUse             int    `json:"in_use"`
+	Idle              int    `json:"idle"`
+	WaitCount         int64  `json:"wait_count"`
+	WaitDuration      string `json:"wait_duration"`
+	MaxIdleClosed     int64  `json:"max_idle_closed"`
+	MaxLifetimeClosed int64  `json:"max_lifetime_closed"`
+}
+
+// Health tells load balancers and monitoring whether the site can reach its
+// database, answering 503 when it can't. Anyone can ask, so the details only
+// go to the log; admins can see them at HealthDetails.
+func Health(w http.ResponseWriter, r *http.Request) {
+	h, code := checkHealth(r)
+
+	if p := h.Pool; code != http.StatusOK && p != nil {
+		log.Printf("health : %s (pool: %d open, %d in use, %d idle, %d waited %s)", h.Error, p.Open, p.InUse, p.Idle, p.WaitCount, p.WaitDuration)
+	} else if code != http.StatusOK {
+		log.Printf("health : %s", h.Error)
+	}
+
+	writeHealth(w, code, &health{
+		Status: h.Status,
+	})
+}
+
+// HealthDetails is the health check with the database error, if there is
+// one, and how the connection pool is doing.
+func HealthDetails(w http.ResponseWriter, r *http.Request) {
+	h, code := checkHealth(r)
+	writeHealth(w, code, h)
+}
+
+func checkHealth(r *http.Request) (*health, int) {
+	h := &health{
+		Status: "ok",
+	}
+
+	pool, err := database.GetConnection()
+	if err != nil {
+		h.Status = "unavailable"
+		h.Error = err.Error()
+		return h, http.StatusServiceUnavailable
+	}
+
+	ctx, cancel := database.WithTimeout(r.Context())
+	defer cancel()
+
+	code := http.StatusOK
+
+	err = pool.PingContext(ctx)
+	if err != nil {
+		h.Status = "unavailable"
+		h.Error = err.Error()
+		code = http.StatusServiceUnavailable
+	}
+
+	s := pool.Stats()
+	h.Pool = &poolInfo{
+		MaxOpen:           s.MaxOpenConnections,
+		Open:              s.OpenConnections,
+		InUse:             s.InUse,
+		Idle:              s.Idle,
+		WaitCount:         s.WaitCount,
+		WaitDuration:      s.WaitDuration.String(),
+		MaxIdleClosed:     s.MaxIdleClosed,
+		MaxLifetimeClosed: s.MaxLifetimeClosed,
+	}
+
+	return h, code
+}
+
+func writeHealth(w http.ResponseWriter, code int, h *health) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(h)
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestHealth(t *testing.T) {
+	// nothing listens here, so the database is down
+	viper.Set("db.host", "127.0.0.1")
+	viper.Set("db.port", 1)
+
+	w := request{method: "GET", target: "/health"}.do(Health)
+	wantBody(t, w, http.StatusServiceUnavailable, `"status":"unavailable"`)
+
+	if body := w.Body.String(); strings.Contains(body, "error") || strings.Contains(body, "pool") {
+		t.Fatalf("the public health check gives away details: %s", body)
+	}
+
+	w = request{method: "GET", target: "/admin/health"}.do(HealthDetails)
+	wantBody(t, w, http.StatusServiceUnavailable, `"error":`)
+	wantBody(t, w, http.StatusServiceUnavailable, `"pool":`)
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"revelbus/cmd/web/utils"
+	"revelbus/cmd/web/view"
+	"revelbus/internal/platform/domain"
+	"revelbus/internal/platform/domain/models"
+	"revelbus/internal/platform/flash"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// Impersonate lets an admin see the site the way the user does. Admins can't
+// be impersonated, so it can't be used to pick up more access.
+func Impersonate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	me, err := utils.IsAuthenticated(r)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	u := &models.User{
+		ID: utils.ToInt(id),
+	}
+
+	err = db.Users.Fetch(r.Context(), u)
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
+	}
+
+	if u.ID == me.ID || u.Role.String == models.RoleAdmin {
+		err = flash.Add(w, r, utils.MsgCannotImpersonate, "warning")
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+
+		http.Redirect(w, r, "/admin/user?id="+id, http.StatusSeeOther)
+		return
+	}
+
+	err = utils.StartImpersonating(w, r, me, u)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = flash.Add(w, r, fmt.Sprintf(utils.MsgImpersonating, u.Name.String), "success")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/u", http.StatusSeeOther)
+}
+
+func StopImpersonating(w http.ResponseWriter, r *http.Request) {
+	uid, err := utils.StopImpersonating(w, r)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	if uid == 0 {
+		http.Redirect(w, r, "/u", http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/user?id="+strconv.Itoa(uid), http.StatusSeeOther)
+}
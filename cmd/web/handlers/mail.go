@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+	"revelbus/cmd/web/utils"
+	"revelbus/cmd/web/view"
+	"revelbus/internal/platform/domain"
+	"revelbus/internal/platform/domain/models"
+	"revelbus/internal/platform/flash"
+	"revelbus/internal/platform/outbox"
+	"revelbus/pkg/email"
+
+	"github.com/gorilla/mux"
+)
+
+func ListFailedEmails(w http.ResponseWriter, r *http.Request) {
+	emails, err := db.Outbox.FetchFailed(r.Context())
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	view.Render(w, r, "outbox", &view.View{
+		Title:  "Failed Mail",
+		Emails: emails,
+	})
+}
+
+func ResendEmail(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	e := &models.OutboxEmail{
+		ID: utils.ToInt(id),
+	}
+
+	err := db.Outbox.Resend(r.Context(), e)
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
+	}
+
+	outbox.Wake()
+
+	err = flash.Add(w, r, utils.MsgEmailRequeued, "success")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/mail", http.StatusSeeOther)
+}
+
+// Mailbox lists mail captured by a development transport. It's a 404 when
+// mail is really being sent.
+func Mailbox(w http.ResponseWriter, r *http.Request) {
+	mb, ok := email.GetMailer().(email.Mailbox)
+	if !ok {
+		view.NotFound(w, r)
+		return
+	}
+
+	view.Render(w, r, "mailbox", &view.View{
+		Title:   "Mailbox",
+		Mailbox: mb.Messages(),
+	})
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"net/http"
+	"revelbus/cmd/web/utils"
+	"revelbus/cmd/web/view"
+	"revelbus/internal/platform/domain"
+	"revelbus/internal/platform/domain/models"
+	"revelbus/internal/platform/emails"
+	"revelbus/internal/platform/flash"
+	"revelbus/internal/platform/ratelimit"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/spf13/viper"
+)
+
+var (
+	contactLimiter     *ratelimit.Limiter
+	contactLimiterOnce sync.Once
+)
+
+// getContactLimiter caps contact form messages per IP, by default 5 an hour.
+func getContactLimiter() *ratelimit.Limiter {
+	contactLimiterOnce.Do(func() {
+		max := viper.GetInt("contact.per_hour")
+		if max <= 0 {
+			max = 5
+		}
+		contactLimiter = ratelimit.New(max, time.Hour)
+	})
+	return contactLimiter
+}
+
+// contactMinTime is how long a person takes to fill in the contact form at
+// the very least.
+func contactMinTime() time.Duration {
+	if d := viper.GetDuration("contact.min_time"); d > 0 {
+		return d
+	}
+	return 3 * time.Second
+}
+
+// contactMaxScore is the spam score at which a message goes to the spam
+// folder instead of the inbox.
+func contactMaxScore() int {
+	if n := viper.GetInt("contact.max_score"); n > 0 {
+		return n
+	}
+	return 5
+}
+
+func ListMessages(w http.ResponseWriter, r *http.Request) {
+	folder := r.URL.Query().Get("folder")
+
+	messages, err := db.Messages.FetchAll(r.Context(), folder)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	view.Render(w, r, "messages", &view.View{
+		ActiveKey: folder,
+		Title:     "Inbox",
+		Messages:  messages,
+	})
+}
+
+func ShowMessage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	m := &models.Message{
+		ID: utils.ToInt(id),
+	}
+
+	err := db.Messages.Fetch(r.Context(), m)
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
+	}
+
+	if m.Status.String == models.MessageUnread {
+		err = db.Messages.SetStatus(r.Context(), m, models.MessageRead)
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+	}
+
+	view.Render(w, r, "message", &view.View{
+		Title:   m.Name.String,
+		Message: m,
+		Form: &models.ReplyForm{
+			Subject: "Re: Your message to Revel Bus",
+		},
+	})
+}
+
+func PostReply(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	err := r.ParseForm()
+	if err != nil {
+		view.ClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	m := &models.Message{
+		ID: utils.ToInt(id),
+	}
+
+	err = db.Messages.Fetch(r.Context(), m)
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
+	}
+
+	f := &models.ReplyForm{
+		Subject: r.PostForm.Get("subject"),
+		Body:    r.PostForm.Get("body"),
+	}
+
+	if !f.Valid() {
+		view.Render(w, r, "message", &view.View{
+			Title:   m.Name.String,
+			Message: m,
+			Form:    f,
+		})
+		return
+	}
+
+	err = emails.ContactReply(r.Context(), m, f.Subject, f.Body)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = db.Messages.MarkReplied(r.Context(), m)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = flash.Add(w, r, utils.MsgReplySent, "success")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/message/"+strconv.Itoa(m.ID), http.StatusSeeOther)
+}
+
+func ArchiveMessage(w http.ResponseWriter, r *http.Request) {
+	setMessageStatus(w, r, models.MessageArchived)
+}
+
+func UnreadMessage(w http.ResponseWriter, r *http.Request) {
+	setMessageStatus(w, r, models.MessageUnread)
+}
+
+// RestoreMessage moves an archived or spam message back to the inbox.
+func RestoreMessage(w http.ResponseWriter, r *http.Request) {
+	setMessageStatus(w, r, models.MessageRead)
+}
+
+func setMessageStatus(w http.ResponseWriter, r *http.Request, status string) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	m := &models.Message{
+		ID: utils.ToInt(id),
+	}
+
+	err := db.Messages.SetStatus(r.Context(), m, status)
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = flash.Add(w, r, utils.MsgSuccessfullyUpdated, "success")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/messages", http.StatusSeeOther)
+}
+
+func RemoveMessage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	m := &models.Message{
+		ID: utils.ToInt(id),
+	}
+
+	err := db.Messages.Delete(r.Context(), m)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = flash.Add(w, r, utils.MsgSuccessfullyRemoved, "success")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/messages", http.StatusSeeOther)
+}
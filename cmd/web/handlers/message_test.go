@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"revelbus/cmd/web/utils"
+	"revelbus/internal/platform/domain/models"
+	"strconv"
+	"testing"
+)
+
+func TestShowMessage(t *testing.T) {
+	s := newStore(t)
+	admin := newUser(t, s, "admin@example.com", models.RoleAdmin)
+	cookies := signIn(t, admin)
+
+	m := &models.Message{
+		Name:  utils.NewNullStr("Pat"),
+		Email: utils.NewNullStr("pat@example.com"),
+		Body:  utils.NewNullStr("Do you stop in Napa?"),
+	}
+
+	err := s.Messages.Create(context.Background(), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := request{
+		method:  "GET",
+		target:  "/admin/message/" + strconv.Itoa(m.ID),
+		vars:    map[string]string{"id": strconv.Itoa(m.ID)},
+		cookies: cookies,
+	}.do(ShowMessage)
+	wantBody(t, w, http.StatusOK, "Do you stop in Napa?")
+
+	err = s.Messages.Fetch(context.Background(), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Status.String != models.MessageRead {
+		t.Fatalf("got status %q, want %q once opened", m.Status.String, models.MessageRead)
+	}
+
+	w = request{
+		method:  "GET",
+		target:  "/admin/message/99",
+		vars:    map[string]string{"id": "99"},
+		cookies: cookies,
+	}.do(ShowMessage)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("got status %d for a missing message, want %d", w.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,371 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"revelbus/cmd/web/utils"
+	"revelbus/cmd/web/view"
+	"revelbus/internal/platform/domain"
+	"revelbus/internal/platform/domain/models"
+	"revelbus/internal/platform/flash"
+	"revelbus/pkg/sessions"
+	"sync"
+	"time"
+
+	oidc "github.com/coreos/go-oidc"
+	"github.com/spf13/viper"
+	"golang.org/x/oauth2"
+)
+
+const (
+	oidcStateKey    = "OIDCState"
+	oidcNonceKey    = "OIDCNonce"
+	oidcVerifierKey = "OIDCVerifier"
+	oidcTimeout     = 10 * time.Second
+)
+
+var (
+	oidcProvider *oidc.Provider
+	oidcMu       sync.Mutex
+)
+
+// oidcClient holds what's needed to sign someone in with the configured
+// provider.
+type oidcClient struct {
+	config   oauth2.Config
+	verifier *oidc.IDTokenVerifier
+	issuer   string
+	label    string
+}
+
+// idClaims are the parts of the ID token we use.
+type idClaims struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	Nonce         string `json:"nonce"`
+}
+
+// getOIDC sets up the OpenID Connect provider from the oidc config, looking
+// it up through discovery the first time it's needed. A failed lookup isn't
+// kept, so the next login tries again.
+func getOIDC() (*oidcClient, error) {
+	issuer := viper.GetString("oidc.issuer")
+	clientID := viper.GetString("oidc.client_id")
+	if issuer == "" || clientID == "" {
+		return nil, nil
+	}
+
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+
+	if oidcProvider == nil {
+		// the provider keeps this context to fetch signing keys later, so it
+		// can't be one that gets cancelled
+		ctx := oidc.ClientContext(context.Background(), &http.Client{Timeout: oidcTimeout})
+
+		p, err := oidc.NewProvider(ctx, issuer)
+		if err != nil {
+			return nil, err
+		}
+		oidcProvider = p
+	}
+
+	label := viper.GetString("oidc.label")
+	if label == "" {
+		label = "single sign-on"
+	}
+
+	return &oidcClient{
+		config: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: viper.GetString("oidc.client_secret"),
+			Endpoint:     oidcProvider.Endpoint(),
+			RedirectURL:  viper.GetString("url") + "/auth/oidc/callback",
+			Scopes:       []string{oidc.ScopeOpenID, "email", "profile"},
+		},
+		verifier: oidcProvider.Verifier(&oidc.Config{ClientID: clientID}),
+		issuer:   issuer,
+		label:    label,
+	}, nil
+}
+
+// OIDCLogin sends the user off to the provider to sign in. The state, nonce
+// and PKCE verifier stay in their session so the callback can check the
+// answer is for this login.
+func OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	c, err := getOIDC()
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	if c == nil {
+		view.NotFound(w, r)
+		return
+	}
+
+	s := sessions.GetSession().Load(r)
+
+	values := map[string]string{}
+	for _, key := range []string{oidcStateKey, oidcNonceKey, oidcVerifierKey} {
+		token, err := domain.NewToken()
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+
+		err = s.PutString(w, key, token)
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+		values[key] = token
+	}
+
+	challenge := sha256.Sum256([]byte(values[oidcVerifierKey]))
+
+	url := c.config.AuthCodeURL(values[oidcStateKey],
+		oidc.Nonce(values[oidcNonceKey]),
+		oauth2.SetAuthURLParam("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:])),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	)
+
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+// OIDCCallback finishes signing in with the provider. Someone who has signed
+// in this way before is matched on their provider account. Otherwise, if the
+// provider vouches for their email address, it's linked to the account with
+// that address, or a new account is made for them.
+func OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	c, err := getOIDC()
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	if c == nil {
+		view.NotFound(w, r)
+		return
+	}
+
+	s := sessions.GetSession().Load(r)
+
+	values := map[string]string{}
+	for _, key := range []string{oidcStateKey, oidcNonceKey, oidcVerifierKey} {
+		values[key], err = s.GetString(key)
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+
+		// each login attempt only gets one go
+		err = s.Remove(w, key)
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+	}
+
+	state := r.URL.Query().Get("state")
+	if values[oidcStateKey] == "" || subtle.ConstantTimeCompare([]byte(state), []byte(values[oidcStateKey])) != 1 {
+		oidcFailed(w, r, c, utils.MsgSignInFailed)
+		return
+	}
+
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		// they cancelled or the provider turned them down
+		oidcFailed(w, r, c, utils.MsgSignInFailed)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(oidc.ClientContext(r.Context(), &http.Client{Timeout: oidcTimeout}), oidcTimeout)
+	defer cancel()
+
+	claims, subject, err := c.exchange(ctx, code, values[oidcVerifierKey], values[oidcNonceKey])
+	if err != nil {
+		oidcFailed(w, r, c, utils.MsgSignInFailed)
+		return
+	}
+
+	u, err := db.Identities.FindUser(r.Context(), c.issuer, subject)
+	if err == domain.ErrNotFound {
+		if !claims.EmailVerified || claims.Email == "" {
+			oidcFailed(w, r, c, utils.MsgIdentityUnverified)
+			return
+		}
+
+		u, err = linkIdentity(r, c, subject, claims)
+	}
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	wait, err := db.Users.LoginWait(r.Context(), u.Email.String)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	if wait > 0 {
+		err = flash.Add(w, r, fmt.Sprintf(utils.MsgLoginThrottled, waitText(wait)), "danger")
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+
+	if u.TOTPEnabled {
+		err = utils.SetPendingLogin(w, r, u)
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+
+		http.Redirect(w, r, "/auth/two-factor", http.StatusSeeOther)
+		return
+	}
+
+	err = completeLogin(w, r, u)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/u", http.StatusSeeOther)
+}
+
+// exchange trades the code for tokens and checks the ID token: its
+// signature, issuer, audience and expiry, then that its nonce is the one we
+// sent.
+func (c *oidcClient) exchange(ctx context.Context, code string, verifier string, nonce string) (*idClaims, string, error) {
+	t, err := c.config.Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", verifier))
+	if err != nil {
+		return nil, "", err
+	}
+
+	raw, ok := t.Extra("id_token").(string)
+	if !ok {
+		return nil, "", errors.New("oidc: no id_token in token response")
+	}
+
+	token, err := c.verifier.Verify(ctx, raw)
+	if err != nil {
+		return nil, "", err
+	}
+
+	claims := &idClaims{}
+	err = token.Claims(claims)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if nonce == "" || subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, "", errors.New("oidc: nonce doesn't match")
+	}
+
+	return claims, token.Subject, nil
+}
+
+// linkIdentity ties the provider account to the user with its email address,
+// making the user first if there isn't one. The provider has confirmed the
+// address, so the account counts as verified. An existing account that was
+// never verified could have been made by anyone who knew the address, so
+// their password, sessions and two-factor are thrown out before it's handed
+// over.
+func linkIdentity(r *http.Request, c *oidcClient, subject string, claims *idClaims) (*models.User, error) {
+	u := &models.User{
+		Email: utils.NewNullStr(claims.Email),
+	}
+
+	err := db.InTx(r.Context(), func(ctx context.Context) error {
+		// they sign in through the provider, but can always set a password
+		// with a recovery email later
+		pw, err := domain.NewToken()
+		if err != nil {
+			return err
+		}
+
+		err = db.Users.Fetch(ctx, u)
+		if err == domain.ErrNotFound {
+			name := claims.Name
+			if name == "" {
+				name = claims.Email
+			}
+
+			u = &models.User{
+				Name:     utils.NewNullStr(name),
+				Email:    utils.NewNullStr(claims.Email),
+				Password: utils.NewNullStr(pw),
+				Role:     utils.NewNullStr(models.RoleUser),
+			}
+
+			err = db.Users.Create(ctx, u)
+			if err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		} else if !u.IsVerified() {
+			err = db.Users.UpdatePassword(ctx, u, pw)
+			if err != nil {
+				return err
+			}
+
+			err = db.Sessions.RevokeAll(ctx, u.ID)
+			if err != nil {
+				return err
+			}
+
+			if u.TOTPEnabled {
+				err = db.Users.DisableTwoFactor(ctx, u)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		if !u.IsVerified() {
+			err = db.Users.SetVerified(ctx, u, true)
+			if err != nil {
+				return err
+			}
+		}
+
+		i := &models.Identity{
+			UserID:  u.ID,
+			Issuer:  c.issuer,
+			Subject: subject,
+			Email:   utils.NewNullStr(claims.Email),
+		}
+
+		return db.Identities.Create(ctx, i)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = alertSecurity(r, u, models.SecurityIdentityLinked, c.label)
+	return u, err
+}
+
+func oidcFailed(w http.ResponseWriter, r *http.Request, c *oidcClient, msg string) {
+	err := flash.Add(w, r, fmt.Sprintf(msg, c.label), "danger")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+}
@@ -0,0 +1,401 @@
+package handlers
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"revelbus/cmd/web/utils"
+	"revelbus/internal/platform/domain"
+	"revelbus/internal/platform/domain/models"
+	"revelbus/internal/platform/domain/store"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const testClientID = "revelbus"
+
+// fakeProvider is an OpenID Connect provider with just enough of discovery,
+// JWKS and the token endpoint to sign someone in. Each code it hands out
+// answers with the claims it was given, but only to a verifier matching the
+// PKCE challenge it was issued for.
+type fakeProvider struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]fakeGrant
+}
+
+type fakeGrant struct {
+	challenge string
+	claims    map[string]interface{}
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &fakeProvider{
+		key:   key,
+		codes: map[string]fakeGrant{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/keys", p.keys)
+	mux.HandleFunc("/token", p.token)
+	p.Server = httptest.NewServer(mux)
+
+	// the provider is looked up once and kept, so each test starts afresh
+	oidcProvider = nil
+	viper.Set("oidc.issuer", p.URL)
+	viper.Set("oidc.client_id", testClientID)
+
+	t.Cleanup(func() {
+		p.Close()
+		oidcProvider = nil
+		viper.Set("oidc.issuer", "")
+		viper.Set("oidc.client_id", "")
+	})
+	return p
+}
+
+func (p *fakeProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"issuer":                                p.URL,
+		"authorization_endpoint":                p.URL + "/auth",
+		"token_endpoint":                        p.URL + "/token",
+		"jwks_uri":                              p.URL + "/keys",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (p *fakeProvider) keys(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"kid": "test",
+			"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (p *fakeProvider) token(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	g, ok := p.codes[r.PostFormValue("code")]
+	delete(p.codes, r.PostFormValue("code"))
+	p.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant"}`))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     p.sign(g.claims),
+	})
+}
+
+// sign makes an RS256 JWT of claims.
+func (p *fakeProvider) sign(claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sum := sha256.Sum256([]byte(signed))
+
+	sig, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, sum[:])
+	if err != nil {
+		panic(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// login starts signing in and returns the session cookies and the query the
+// provider was sent.
+func (p *fakeProvider) login(t *testing.T) ([]*http.Cookie, url.Values) {
+	t.Helper()
+
+	w := request{method: "GET", target: "/auth/oidc"}.do(OIDCLogin)
+	if w.Code != http.StatusFound {
+		t.Fatalf("got status %d, want %d; body: %s", w.Code, http.StatusFound, w.Body.String())
+	}
+
+	to, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w.Result().Cookies(), to.Query()
+}
+
+// grant hands out a code for the login in q, answering with claims on top
+// of the ones a well-behaved provider would send.
+func (p *fakeProvider) grant(q url.Values, claims map[string]interface{}) string {
+	c := map[string]interface{}{
+		"iss":   p.URL,
+		"aud":   testClientID,
+		"sub":   "subject-1",
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": q.Get("nonce"),
+	}
+	for k, v := range claims {
+		c[k] = v
+	}
+
+	code, _ := domain.NewToken()
+
+	p.mu.Lock()
+	p.codes[code] = fakeGrant{challenge: q.Get("code_challenge"), claims: c}
+	p.mu.Unlock()
+	return code
+}
+
+func callback(cookies []*http.Cookie, state string, code string) *httptest.ResponseRecorder {
+	return request{
+		method:  "GET",
+		target:  "/auth/oidc/callback?" + url.Values{"state": {state}, "code": {code}}.Encode(),
+		cookies: cookies,
+	}.do(OIDCCallback)
+}
+
+func wantSessions(t *testing.T, s *store.Store, userID int, n int) {
+	t.Helper()
+
+	sessions, err := s.Sessions.FetchAll(context.Background(), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*sessions) != n {
+		t.Fatalf("got %d sessions, want %d", len(*sessions), n)
+	}
+}
+
+func TestOIDCLogin(t *testing.T) {
+	s := newStore(t)
+	p := newFakeProvider(t)
+
+	cookies, q := p.login(t)
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		t.Fatalf("no PKCE challenge in %v", q)
+	}
+
+	code := p.grant(q, map[string]interface{}{
+		"email":          "pat@example.com",
+		"email_verified": true,
+		"name":           "Pat",
+	})
+
+	w := callback(cookies, q.Get("state"), code)
+	wantRedirect(t, w, "/u")
+
+	u, err := s.Identities.FindUser(context.Background(), p.URL, "subject-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Email.String != "pat@example.com" || !u.IsVerified() {
+		t.Fatalf("got user %+v, want pat@example.com verified", u)
+	}
+	wantSessions(t, s, u.ID, 1)
+
+	// the state, nonce and verifier were for that login only
+	w = callback(cookies, q.Get("state"), p.grant(q, nil))
+	wantRedirect(t, w, "/auth/login")
+	wantSessions(t, s, u.ID, 1)
+}
+
+func TestOIDCLoginRejected(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		// breaks the login some other way than the ID token
+		change func(q url.Values)
+	}{
+		{
+			name:   "nonce",
+			claims: map[string]interface{}{"nonce": "someone else's"},
+		},
+		{
+			name:   "audience",
+			claims: map[string]interface{}{"aud": "another-client"},
+		},
+		{
+			name:   "issuer",
+			claims: map[string]interface{}{"iss": "https://elsewhere.example.com"},
+		},
+		{
+			name:   "expired",
+			claims: map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()},
+		},
+		{
+			name: "verifier",
+			change: func(q url.Values) {
+				sum := sha256.Sum256([]byte("not the verifier"))
+				q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(sum[:]))
+			},
+		},
+		{
+			name: "state",
+			change: func(q url.Values) {
+				q.Set("state", "forged")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStore(t)
+			p := newFakeProvider(t)
+			u := newUser(t, s, "pat@example.com", "user")
+
+			cookies, q := p.login(t)
+			if tt.change != nil {
+				tt.change(q)
+			}
+
+			claims := map[string]interface{}{
+				"email":          "pat@example.com",
+				"email_verified": true,
+			}
+			for k, v := range tt.claims {
+				claims[k] = v
+			}
+
+			w := callback(cookies, q.Get("state"), p.grant(q, claims))
+			wantRedirect(t, w, "/auth/login")
+			wantSessions(t, s, u.ID, 0)
+
+			_, err := s.Identities.FindUser(context.Background(), p.URL, "subject-1")
+			if err != domain.ErrNotFound {
+				t.Fatalf("got %v looking up the identity, want it not linked", err)
+			}
+		})
+	}
+}
+
+func TestOIDCLoginLinksVerifiedEmail(t *testing.T) {
+	s := newStore(t)
+	p := newFakeProvider(t)
+	u := newUser(t, s, "pat@example.com", "user")
+
+	// an address the provider hasn't checked could be anyone's
+	cookies, q := p.login(t)
+	code := p.grant(q, map[string]interface{}{
+		"email":          "pat@example.com",
+		"email_verified": false,
+	})
+
+	w := callback(cookies, q.Get("state"), code)
+	wantRedirect(t, w, "/auth/login")
+	wantSessions(t, s, u.ID, 0)
+
+	_, err := s.Identities.FindUser(context.Background(), p.URL, "subject-1")
+	if err != domain.ErrNotFound {
+		t.Fatalf("got %v looking up the identity, want it not linked", err)
+	}
+
+	cookies, q = p.login(t)
+	code = p.grant(q, map[string]interface{}{
+		"email":          "pat@example.com",
+		"email_verified": true,
+	})
+
+	w = callback(cookies, q.Get("state"), code)
+	wantRedirect(t, w, "/u")
+
+	linked, err := s.Identities.FindUser(context.Background(), p.URL, "subject-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if linked.ID != u.ID {
+		t.Fatalf("linked to user %d, want the existing %d", linked.ID, u.ID)
+	}
+	wantSessions(t, s, u.ID, 1)
+
+	users, err := s.Users.FetchAll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 {
+		t.Fatalf("got %d users, want just the linked one", len(users))
+	}
+}
+
+func TestOIDCLoginLinksUnverifiedAccount(t *testing.T) {
+	s := newStore(t)
+	p := newFakeProvider(t)
+
+	// someone signed up with the address before its owner did
+	u := newUser(t, s, "pat@example.com", "user")
+	signIn(t, u)
+	wantSessions(t, s, u.ID, 1)
+
+	cookies, q := p.login(t)
+	code := p.grant(q, map[string]interface{}{
+		"email":          "pat@example.com",
+		"email_verified": true,
+	})
+
+	w := callback(cookies, q.Get("state"), code)
+	wantRedirect(t, w, "/u")
+
+	// only the session that was just started is left
+	wantSessions(t, s, u.ID, 1)
+
+	err := s.Users.VerifyUser(context.Background(), &models.User{Email: utils.NewNullStr("pat@example.com")}, "secret")
+	if err == nil {
+		t.Fatal("the password set before the address was verified still works")
+	}
+}
+
+func TestOIDCLoginLinksVerifiedAccount(t *testing.T) {
+	s := newStore(t)
+	p := newFakeProvider(t)
+
+	u := newUser(t, s, "pat@example.com", "user")
+	err := s.Users.SetVerified(context.Background(), u, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signIn(t, u)
+
+	cookies, q := p.login(t)
+	code := p.grant(q, map[string]interface{}{
+		"email":          "pat@example.com",
+		"email_verified": true,
+	})
+
+	w := callback(cookies, q.Get("state"), code)
+	wantRedirect(t, w, "/u")
+	wantSessions(t, s, u.ID, 2)
+
+	err = s.Users.VerifyUser(context.Background(), &models.User{Email: utils.NewNullStr("pat@example.com")}, "secret")
+	if err != nil {
+		t.Fatalf("got %v signing in with the password, want it unchanged", err)
+	}
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"revelbus/cmd/web/utils"
+	"revelbus/cmd/web/view"
+	"revelbus/internal/platform/domain"
+	"revelbus/internal/platform/domain/models"
+	"revelbus/internal/platform/emails"
+	"revelbus/internal/platform/flash"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+func PartnerDashboard(w http.ResponseWriter, r *http.Request) {
+	u, err := utils.IsAuthenticated(r)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	vendors, err := db.Partners.FetchUserVendors(r.Context(), u.ID)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	view.Render(w, r, "partner-dashboard", &view.View{
+		Title:   "Partner Portal",
+		Vendors: vendors,
+	})
+}
+
+func PartnerVendorForm(w http.ResponseWriter, r *http.Request) {
+	v, _, ok := partnerVendor(w, r)
+	if !ok {
+		return
+	}
+
+	c, err := db.VendorChanges.FindPending(r.Context(), v.ID)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	// show what they last asked for, so resubmitting doesn't undo it
+	f := &models.VendorForm{
+		ID:      strconv.Itoa(v.ID),
+		Name:    v.Name.String,
+		Address: v.Address.String,
+		City:    v.City.String,
+		State:   v.State.String,
+		Zip:     v.Zip.String,
+		Phone:   v.Phone.String,
+		Email:   v.Email.String,
+		URL:     v.URL.String,
+		BrandID: int(v.BrandID.Int64),
+	}
+
+	if v.Brand != nil {
+		f.Brand = v.Brand.Thumb.String
+	}
+
+	if c != nil {
+		f.Name = c.Name.String
+		f.Address = c.Address.String
+		f.City = c.City.String
+		f.State = c.State.String
+		f.Zip = c.Zip.String
+		f.Phone = c.Phone.String
+		f.Email = c.Email.String
+		f.URL = c.URL.String
+		f.BrandID = int(c.BrandID.Int64)
+		f.Brand = c.Brand.Thumb.String
+	}
+
+	view.Render(w, r, "partner-vendor", &view.View{
+		ActiveKey: "listing",
+		Change:    c,
+		Form:      f,
+		Title:     v.Name.String,
+		Vendor:    v,
+	})
+}
+
+// PostPartnerVendor saves a partner's edits for an admin to approve. Nothing
+// changes on the site until then.
+func PostPartnerVendor(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		view.ClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	v, u, ok := partnerVendor(w, r)
+	if !ok {
+		return
+	}
+
+	f := &models.VendorForm{
+		ID:      strconv.Itoa(v.ID),
+		Name:    r.PostForm.Get("name"),
+		Address: r.PostForm.Get("address"),
+		City:    r.PostForm.Get("city"),
+		State:   r.PostForm.Get("state"),
+		Zip:     r.PostForm.Get("zip"),
+		Phone:   r.PostForm.Get("phone"),
+		Email:   r.PostForm.Get("email"),
+		URL:     r.PostForm.Get("url"),
+		BrandID: utils.ToInt(r.PostForm.Get("brand_id")),
+	}
+
+	if !f.Valid() {
+		view.Render(w, r, "partner-vendor", &view.View{
+			ActiveKey: "listing",
+			Form:      f,
+			Title:     v.Name.String,
+			Vendor:    v,
+		})
+		return
+	}
+
+	previous, err := db.VendorChanges.FindPending(r.Context(), v.ID)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	c := &models.VendorChange{
+		VendorID: v.ID,
+		UserID:   u.ID,
+		Name:     utils.NewNullStr(f.Name),
+		Address:  utils.NewNullStr(f.Address),
+		City:     utils.NewNullStr(f.City),
+		State:    utils.NewNullStr(f.State),
+		Zip:      utils.NewNullStr(f.Zip),
+		Phone:    utils.NewNullStr(f.Phone),
+		Email:    utils.NewNullStr(f.Email),
+		URL:      utils.NewNullStr(f.URL),
+	}
+
+	if f.BrandID != 0 {
+		c.BrandID = utils.NewNullInt(f.BrandID)
+	}
+
+	err = db.InTx(r.Context(), func(ctx context.Context) error {
+		image, err := utils.UploadFile(ctx, r, "brand_image", "uploads/vendor", true)
+		if err != nil {
+			return err
+		}
+
+		if len(image) > 0 {
+			c.BrandID = utils.NewNullInt(image[0].ID)
+		} else if len(r.Form["deleteimg"]) == 1 {
+			c.BrandID = sql.NullInt64{}
+		}
+
+		// a partner can only point at their current logo or one they
+		// uploaded for this listing, not any file on the site
+		if c.BrandID.Valid && c.BrandID != v.BrandID && len(image) == 0 && (previous == nil || c.BrandID != previous.BrandID) {
+			c.BrandID = v.BrandID
+		}
+
+		err = db.VendorChanges.Submit(ctx, c)
+		if err != nil {
+			return err
+		}
+
+		// a logo uploaded for the change this one replaced is no use to
+		// anyone now
+		if previous != nil && previous.BrandID != c.BrandID && previous.BrandID != v.BrandID {
+			return deleteUnused(ctx, previous.BrandID)
+		}
+		return nil
+	})
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = emails.VendorChangeSubmitted(r.Context(), v, u)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = flash.Add(w, r, utils.MsgVendorChangeSubmitted, "success")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/partner/vendor/"+strconv.Itoa(v.ID), http.StatusSeeOther)
+}
+
+func PartnerTrips(w http.ResponseWriter, r *http.Request) {
+	v, _, ok := partnerVendor(w, r)
+	if !ok {
+		return
+	}
+
+	trips, err := db.Partners.FetchVendorTrips(r.Context(), v.ID)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	view.Render(w, r, "partner-trips", &view.View{
+		ActiveKey:    "trips",
+		PartnerTrips: trips,
+		Title:        v.Name.String,
+		Vendor:       v,
+	})
+}
+
+// partnerVendor loads the vendor in the URL, as long as the signed in user is
+// linked to it. Anyone else gets a 404, so the portal doesn't reveal which
+// vendors exist. It writes the response itself when it fails.
+func partnerVendor(w http.ResponseWriter, r *http.Request) (*models.Vendor, *models.User, bool) {
+	vars := mux.Vars(r)
+	id := utils.ToInt(vars["id"])
+
+	u, err := utils.IsAuthenticated(r)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return nil, nil, false
+	}
+
+	ok, err := db.Partners.IsVendorUser(r.Context(), id, u.ID)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return nil, nil, false
+	}
+
+	if !ok {
+		view.NotFound(w, r)
+		return nil, nil, false
+	}
+
+	v := &models.Vendor{
+		ID: id,
+	}
+
+	err = db.Vendors.Fetch(r.Context(), v)
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return nil, nil, false
+		}
+		view.ServerError(w, r, err)
+		return nil, nil, false
+	}
+
+	return v, u, true
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"revelbus/cmd/web/utils"
+	"revelbus/cmd/web/view"
+	"revelbus/internal/platform/domain"
+	"revelbus/internal/platform/domain/models"
+	"revelbus/internal/platform/flash"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// revisionPerms is the permission needed to see and revert the revisions of
+// each kind of content; the same one it takes to edit it.
+var revisionPerms = map[string]string{
+	models.AuditTrip:     models.PermTrips,
+	models.AuditFAQ:      models.PermFAQs,
+	models.AuditSlide:    models.PermContent,
+	models.AuditSettings: models.PermSettings,
+}
+
+func ListRevisions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	t := vars["type"]
+	id := utils.ToInt(vars["id"])
+
+	if !canRevise(w, r, t) {
+		return
+	}
+
+	revisions, err := db.Revisions.FetchAll(r.Context(), t, id)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	v := &view.View{
+		Title:     "Revisions",
+		Revision:  &models.Revision{EntityType: t, EntityID: id},
+		Revisions: revisions,
+	}
+
+	// a trip's revisions are a tab of the trip
+	if t == models.AuditTrip {
+		v.Trip = &models.Trip{ID: id}
+		v.ActiveKey = "revisions"
+
+		err = db.Trips.Fetch(r.Context(), v.Trip)
+		if err != nil && err != domain.ErrNotFound {
+			view.ServerError(w, r, err)
+			return
+		}
+		if err != nil {
+			v.Trip = nil
+		}
+	}
+
+	view.Render(w, r, "revisions", v)
+}
+
+func ShowRevision(w http.ResponseWriter, r *http.Request) {
+	rev, ok := fetchRevision(w, r)
+	if !ok {
+		return
+	}
+
+	prev, err := db.Revisions.Previous(r.Context(), rev)
+	if err != nil && err != domain.ErrNotFound {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	view.Render(w, r, "revision", &view.View{
+		Title:        "Revision",
+		Revision:     rev,
+		RevisionDiff: rev.Diff(prev),
+	})
+}
+
+// RevertRevision saves the revision's content over what's there now, which
+// makes a new revision rather than forgetting the ones since.
+func RevertRevision(w http.ResponseWriter, r *http.Request) {
+	rev, ok := fetchRevision(w, r)
+	if !ok {
+		return
+	}
+
+	err := revert(r.Context(), rev)
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = flash.Add(w, r, utils.MsgReverted, "success")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/revisions/"+rev.EntityType+"/"+strconv.Itoa(rev.EntityID), http.StatusSeeOther)
+}
+
+func revert(ctx context.Context, rev *models.Revision) error {
+	switch rev.EntityType {
+	case models.AuditTrip:
+		t := &models.Trip{ID: rev.EntityID}
+		err := db.Trips.Fetch(ctx, t)
+		if err != nil {
+			return err
+		}
+		rev.Apply(t)
+		return db.Trips.Update(ctx, t)
+	case models.AuditFAQ:
+		f := &models.FAQ{ID: rev.EntityID}
+		err := db.FAQs.Fetch(ctx, f)
+		if err != nil {
+			return err
+		}
+		rev.Apply(f)
+		return db.FAQs.Update(ctx, f)
+	case models.AuditSlide:
+		s := &models.Slide{ID: rev.EntityID}
+		err := db.Slides.Fetch(ctx, s)
+		if err != nil {
+			return err
+		}
+		rev.Apply(s)
+		return db.Slides.Update(ctx, s)
+	case models.AuditSettings:
+		s := &models.Settings{ID: rev.EntityID}
+		err := db.Settings.Fetch(ctx, s)
+		if err != nil {
+			return err
+		}
+		rev.Apply(s)
+		return db.Settings.Update(ctx, s)
+	}
+	return domain.ErrNotFound
+}
+
+// fetchRevision loads the revision in the URL, if the user's allowed to see
+// it. Otherwise it writes the response and returns false.
+func fetchRevision(w http.ResponseWriter, r *http.Request) (*models.Revision, bool) {
+	vars := mux.Vars(r)
+
+	rev := &models.Revision{
+		ID: utils.ToInt(vars["id"]),
+	}
+
+	err := db.Revisions.Fetch(r.Context(), rev)
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return nil, false
+		}
+		view.ServerError(w, r, err)
+		return nil, false
+	}
+
+	if !canRevise(w, r, rev.EntityType) {
+		return nil, false
+	}
+	return rev, true
+}
+
+// canRevise checks the user can edit content of type t, writing the response
+// and returning false if not.
+func canRevise(w http.ResponseWriter, r *http.Request, t string) bool {
+	u, err := utils.IsAuthenticated(r)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return false
+	}
+
+	p, ok := revisionPerms[t]
+	if !ok || u == nil || !u.Can(p) {
+		view.NotFound(w, r)
+		return false
+	}
+	return true
+}
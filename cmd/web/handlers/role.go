@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"net/http"
+	"revelbus/cmd/web/utils"
+	"revelbus/cmd/web/view"
+	"revelbus/internal/platform/domain"
+	"revelbus/internal/platform/domain/models"
+	"revelbus/internal/platform/flash"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+func RoleForm(w http.ResponseWriter, r *http.Request) {
+	id := r.FormValue("id")
+
+	if id == "" {
+		view.Render(w, r, "role", &view.View{
+			Form:        new(models.RoleForm),
+			Permissions: models.Permissions,
+			Title:       "New Role",
+		})
+		return
+	}
+
+	role := &models.Role{
+		ID: utils.ToInt(id),
+	}
+
+	err := db.Roles.Fetch(r.Context(), role)
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
+	}
+
+	f := roleForm(role)
+
+	view.Render(w, r, "role", &view.View{
+		Form:        f,
+		Permissions: models.Permissions,
+		Title:       f.Label,
+	})
+}
+
+func PostRole(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		view.ClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	f := &models.RoleForm{
+		ID:          r.PostForm.Get("id"),
+		Version:     utils.ToInt(r.PostForm.Get("version")),
+		Name:        r.PostForm.Get("name"),
+		Label:       r.PostForm.Get("label"),
+		Permissions: r.PostForm["permissions"],
+	}
+
+	if !f.Valid() {
+		v := &view.View{
+			Form:        f,
+			Permissions: models.Permissions,
+			Title:       f.Label,
+		}
+
+		if f.ID == "" {
+			v.Title = "New Role"
+		}
+
+		view.Render(w, r, "role", v)
+		return
+	}
+
+	var msg string
+
+	role := &models.Role{
+		ID:          utils.ToInt(f.ID),
+		Version:     f.Version,
+		Name:        utils.NewNullStr(f.Name),
+		Label:       utils.NewNullStr(f.Label),
+		Permissions: f.Permissions,
+	}
+
+	if role.ID != 0 {
+		// the name is fixed once a role exists, so take it from the database
+		// rather than trusting the form
+		existing := &models.Role{
+			ID: role.ID,
+		}
+
+		err := db.Roles.Fetch(r.Context(), existing)
+		if err != nil {
+			if err == domain.ErrNotFound {
+				view.NotFound(w, r)
+				return
+			}
+			view.ServerError(w, r, err)
+			return
+		}
+		role.Name = existing.Name
+
+		err = db.Roles.Update(r.Context(), role)
+		if err != nil {
+			if err == domain.ErrConflict {
+				// existing was read after the save that got in first
+				view.Conflict(w, r, models.NewConflict(existing.Label.String, "/admin/role?id="+f.ID, f, roleForm(existing)))
+				return
+			}
+			view.ServerError(w, r, err)
+			return
+		}
+		msg = utils.MsgSuccessfullyUpdated
+	} else {
+		err := db.Roles.Create(r.Context(), role)
+		if err != nil {
+			if err == domain.ErrDuplicate {
+				f.Errors["Name"] = "A role with that name already exists."
+				view.Render(w, r, "role", &view.View{
+					Form:        f,
+					Permissions: models.Permissions,
+					Title:       "New Role",
+				})
+				return
+			}
+			view.ServerError(w, r, err)
+			return
+		}
+		msg = utils.MsgSuccessfullyCreated
+	}
+
+	err = flash.Add(w, r, msg, "success")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	id := strconv.Itoa(role.ID)
+
+	http.Redirect(w, r, "/admin/role?id="+id, http.StatusSeeOther)
+}
+
+// roleForm fills in the role form from role.
+func roleForm(role *models.Role) *models.RoleForm {
+	return &models.RoleForm{
+		ID:          strconv.Itoa(role.ID),
+		Version:     role.Version,
+		Name:        role.Name.String,
+		Label:       role.Label.String,
+		Permissions: role.Permissions,
+	}
+}
+
+func ListRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := db.Roles.FetchAll(r.Context())
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	view.Render(w, r, "roles", &view.View{
+		Title: "Roles",
+		Roles: roles,
+	})
+}
+
+func RemoveRole(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	role := &models.Role{
+		ID: utils.ToInt(id),
+	}
+
+	err := db.Roles.Delete(r.Context(), role)
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		} else if err == domain.ErrCannotDelete {
+			err = flash.Add(w, r, utils.MsgRoleInUse, "warning")
+			if err != nil {
+				view.ServerError(w, r, err)
+				return
+			}
+
+			http.Redirect(w, r, "/admin/roles", http.StatusSeeOther)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = flash.Add(w, r, utils.MsgSuccessfullyRemoved, "success")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/roles", http.StatusSeeOther)
+}
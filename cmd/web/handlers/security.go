@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+	"revelbus/cmd/web/utils"
+	"revelbus/cmd/web/view"
+	"revelbus/internal/platform/domain/models"
+	"revelbus/internal/platform/emails"
+)
+
+// securityLogSize is how much activity the security page shows.
+const securityLogSize = 50
+
+func SecurityLog(w http.ResponseWriter, r *http.Request) {
+	u, err := utils.IsAuthenticated(r)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	events, err := db.SecurityEvents.FetchAll(r.Context(), u.ID, securityLogSize)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	view.Render(w, r, "security", &view.View{
+		Events: events,
+		Title:  "Security Activity",
+	})
+}
+
+// logSecurity adds an entry to the user's security activity log.
+func logSecurity(r *http.Request, u *models.User, kind string, detail string) (*models.SecurityEvent, error) {
+	e := &models.SecurityEvent{
+		UserID:    u.ID,
+		Kind:      kind,
+		Detail:    utils.NewNullStr(detail),
+		IP:        utils.NewNullStr(utils.ClientIP(r)),
+		UserAgent: utils.NewNullStr(r.UserAgent()),
+	}
+
+	err := db.SecurityEvents.Create(r.Context(), e)
+	return e, err
+}
+
+// alertSecurity logs the event and emails the user about it.
+func alertSecurity(r *http.Request, u *models.User, kind string, detail string) error {
+	e, err := logSecurity(r, u, kind, detail)
+	if err != nil {
+		return err
+	}
+
+	err = emails.SecurityAlert(r.Context(), u.Email.String, e)
+	return err
+}
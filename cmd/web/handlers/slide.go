@@ -27,7 +27,7 @@ func SlideForm(w http.ResponseWriter, r *http.Request) {
 		ID: utils.ToInt(id),
 	}
 
-	err := s.Fetch()
+	err := db.Slides.Fetch(r.Context(), s)
 	if err != nil {
 		if err == domain.ErrNotFound {
 			view.NotFound(w, r)
@@ -37,18 +37,9 @@ func SlideForm(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	f := &models.SlideForm{
-		ID:     strconv.Itoa(s.ID),
-		Title:  s.Title.String,
-		Blurb:  s.Blurb.String,
-		Style:  s.Style.String,
-		Order:  strconv.FormatInt(s.Order.Int64, 10),
-		Active: s.Active,
-	}
-
 	view.Render(w, r, "slide", &view.View{
 		Title: s.Title.String,
-		Form:  f,
+		Form:  slideForm(s),
 	})
 }
 
@@ -60,12 +51,13 @@ func PostSlide(w http.ResponseWriter, r *http.Request) {
 	}
 
 	f := &models.SlideForm{
-		ID:     r.PostForm.Get("id"),
-		Title:  r.PostForm.Get("title"),
-		Blurb:  r.PostForm.Get("blurb"),
-		Style:  r.PostForm.Get("style"),
-		Order:  r.PostForm.Get("order"),
-		Active: (len(r.Form["active"]) == 1),
+		ID:      r.PostForm.Get("id"),
+		Version: utils.ToInt(r.PostForm.Get("version")),
+		Title:   r.PostForm.Get("title"),
+		Blurb:   r.PostForm.Get("blurb"),
+		Style:   r.PostForm.Get("style"),
+		Order:   r.PostForm.Get("order"),
+		Active:  (len(r.Form["active"]) == 1),
 	}
 
 	if !f.Valid() {
@@ -78,32 +70,37 @@ func PostSlide(w http.ResponseWriter, r *http.Request) {
 		}
 
 		view.Render(w, r, "slide", v)
+		return
 	}
 
 	var msg string
 
 	s := models.Slide{
-		ID:     utils.ToInt(f.ID),
-		Title:  utils.NewNullStr(f.Title),
-		Blurb:  utils.NewNullStr(f.Blurb),
-		Style:  utils.NewNullStr(f.Style),
-		Order:  utils.NewNullInt(utils.ToInt(f.Order)),
-		Active: f.Active,
+		ID:      utils.ToInt(f.ID),
+		Version: f.Version,
+		Title:   utils.NewNullStr(f.Title),
+		Blurb:   utils.NewNullStr(f.Blurb),
+		Style:   utils.NewNullStr(f.Style),
+		Order:   utils.NewNullInt(utils.ToInt(f.Order)),
+		Active:  f.Active,
 	}
 
 	if s.ID != 0 {
-		err := s.Update()
+		err := db.Slides.Update(r.Context(), &s)
 		if err != nil {
 			if err == domain.ErrNotFound {
 				view.NotFound(w, r)
 				return
+			} else if err == domain.ErrConflict {
+				slideConflict(w, r, f)
+				return
 			}
 			view.ServerError(w, r, err)
 			return
 		}
 		msg = utils.MsgSuccessfullyUpdated
 	} else {
-		err := s.Create()
+		err := db.Slides.Create(r.Context(), &s)
 		if err != nil {
 			view.ServerError(w, r, err)
 			return
@@ -122,8 +119,41 @@ func PostSlide(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/admin/slide?id="+id, http.StatusSeeOther)
 }
 
+// slideForm fills in the slide form from s.
+func slideForm(s *models.Slide) *models.SlideForm {
+	return &models.SlideForm{
+		ID:      strconv.Itoa(s.ID),
+		Version: s.Version,
+		Title:   s.Title.String,
+		Blurb:   s.Blurb.String,
+		Style:   s.Style.String,
+		Order:   strconv.FormatInt(s.Order.Int64, 10),
+		Active:  s.Active,
+	}
+}
+
+// slideConflict shows f, which couldn't be saved, next to the slide as
+// someone else has saved it since.
+func slideConflict(w http.ResponseWriter, r *http.Request, f *models.SlideForm) {
+	s := &models.Slide{
+		ID: utils.ToInt(f.ID),
+	}
+
+	err := db.Slides.Fetch(r.Context(), s)
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
+	}
+
+	view.Conflict(w, r, models.NewConflict(s.Title.String, "/admin/slide?id="+f.ID, f, slideForm(s)))
+}
+
 func ListSlides(w http.ResponseWriter, r *http.Request) {
-	slides, err := models.FetchSlides()
+	slides, err := db.Slides.FetchAll(r.Context())
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
@@ -143,7 +173,7 @@ func RemoveSlide(w http.ResponseWriter, r *http.Request) {
 		ID: utils.ToInt(id),
 	}
 
-	err := s.Delete()
+	err := db.Slides.Delete(r.Context(), &s)
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
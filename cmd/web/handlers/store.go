@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"revelbus/cmd/web/utils"
+	"revelbus/internal/platform/domain/store"
+	"revelbus/internal/platform/outbox"
+)
+
+// db is where handlers load and save everything. It's MySQL, with every
+// change written to the audit log, unless UseStore swaps it, as tests do
+// with store.NewMemory.
+var db = store.Audited(store.NewMySQL())
+
+// UseStore swaps the store for the handlers and for the helpers and mail
+// queue they use.
+func UseStore(s *store.Store) {
+	db = s
+	utils.UseStore(s)
+	outbox.UseStore(s.Outbox)
+}
@@ -0,0 +1,431 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"revelbus/cmd/web/utils"
+	"revelbus/cmd/web/view"
+	"revelbus/internal/platform/domain"
+	"revelbus/internal/platform/domain/models"
+	"revelbus/internal/platform/emails"
+	"revelbus/internal/platform/flash"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func PostSubscribe(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		view.ClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	f := &models.SubscriberForm{
+		Email: strings.TrimSpace(r.PostForm.Get("email")),
+	}
+
+	back := backPath(r)
+
+	if !f.Valid() {
+		err = flash.Add(w, r, f.Errors["Email"], "warning")
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+
+	s := &models.Subscriber{
+		Email: utils.NewNullStr(f.Email),
+	}
+
+	err = db.Subscribers.Fetch(r.Context(), s)
+	switch {
+	case err == domain.ErrNotFound:
+		err = db.Subscribers.Create(r.Context(), s)
+		if err == nil {
+			err = emails.ConfirmSubscription(r.Context(), s)
+		}
+	case err != nil:
+	case s.Status.String == models.SubscriberActive:
+		// already on the list, say the same thing so addresses can't be probed
+	default:
+		err = db.Subscribers.Resubscribe(r.Context(), s)
+		if err == nil {
+			err = emails.ConfirmSubscription(r.Context(), s)
+		}
+	}
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = flash.Add(w, r, utils.MsgSubscriptionPending, "success")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+func ConfirmSubscription(w http.ResponseWriter, r *http.Request) {
+	s, err := db.Subscribers.Confirm(r.Context(), r.FormValue("token"))
+	if err != nil {
+		if err == domain.ErrNotFound {
+			err = flash.Add(w, r, utils.MsgInvalidSubscriptionLink, "warning")
+			if err != nil {
+				view.ServerError(w, r, err)
+				return
+			}
+
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = flash.Add(w, r, utils.MsgSubscriptionConfirmed, "success")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/subscribe/preferences?token="+url.QueryEscape(s.UnsubscribeToken.String), http.StatusSeeOther)
+}
+
+func SubscriptionPreferences(w http.ResponseWriter, r *http.Request) {
+	s, err := db.Subscribers.FindByToken(r.Context(), r.FormValue("token"))
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
+	}
+
+	categories, err := db.Trips.FetchCategories(r.Context())
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	view.Render(w, r, "subscription", &view.View{
+		Title:      "Subscription",
+		Categories: categories,
+		Form: &models.SubscriberForm{
+			Email:     s.Email.String,
+			Token:     s.UnsubscribeToken.String,
+			Interests: s.Interests,
+		},
+	})
+}
+
+func PostSubscriptionPreferences(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		view.ClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	token := r.PostForm.Get("token")
+
+	s, err := db.Subscribers.FindByToken(r.Context(), token)
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = db.Subscribers.SetInterests(r.Context(), s, r.PostForm["interests"])
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = flash.Add(w, r, utils.MsgSuccessfullyUpdated, "success")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/subscribe/preferences?token="+url.QueryEscape(token), http.StatusSeeOther)
+}
+
+func Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	s, err := db.Subscribers.FindByToken(r.Context(), r.FormValue("token"))
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = db.Subscribers.Unsubscribe(r.Context(), s)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = flash.Add(w, r, utils.MsgUnsubscribed, "success")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func ListSubscribers(w http.ResponseWriter, r *http.Request) {
+	subscribers, err := db.Subscribers.FetchAll(r.Context())
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	view.Render(w, r, "subscribers", &view.View{
+		Title:       "Subscribers",
+		Subscribers: subscribers,
+	})
+}
+
+func ExportSubscribers(w http.ResponseWriter, r *http.Request) {
+	subscribers, err := db.Subscribers.FetchAll(r.Context())
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", "attachment; filename=subscribers-"+time.Now().Format("20060102")+".csv")
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"email", "name", "status", "created"})
+	for _, s := range *subscribers {
+		cw.Write([]string{s.Email.String, s.Name.String, s.Status.String, s.Created.Format(domain.TimeFormat)})
+	}
+	cw.Flush()
+
+	if err := cw.Error(); err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+}
+
+// ImportSubscribers reads a CSV of email[,name] rows. Addresses already on
+// the list are left alone. Unless confirm is checked, imported addresses are
+// subscribed without going through double opt-in.
+func ImportSubscribers(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseMultipartForm(10 << 20)
+	if err != nil {
+		view.ClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		view.ClientError(w, r, http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	confirm := len(r.Form["confirm"]) == 1
+
+	cr := csv.NewReader(file)
+	cr.FieldsPerRecord = -1
+
+	added := 0
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			view.ClientError(w, r, http.StatusBadRequest)
+			return
+		}
+
+		f := &models.SubscriberForm{
+			Email: strings.TrimSpace(rec[0]),
+		}
+		if !f.Valid() {
+			continue
+		}
+
+		s := &models.Subscriber{
+			Email:  utils.NewNullStr(f.Email),
+			Status: utils.NewNullStr(models.SubscriberActive),
+		}
+		if len(rec) > 1 {
+			s.Name = utils.NewNullStr(strings.TrimSpace(rec[1]))
+		}
+		if confirm {
+			s.Status = utils.NewNullStr(models.SubscriberPending)
+		}
+
+		err = db.Subscribers.Create(r.Context(), s)
+		if err == domain.ErrDuplicateEmail {
+			continue
+		}
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+
+		if confirm {
+			err = emails.ConfirmSubscription(r.Context(), s)
+			if err != nil {
+				view.ServerError(w, r, err)
+				return
+			}
+		}
+		added++
+	}
+
+	err = flash.Add(w, r, fmt.Sprintf(utils.MsgSubscribersImported, added), "success")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/subscribers", http.StatusSeeOther)
+}
+
+func RemoveSubscriber(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	s := &models.Subscriber{
+		ID: utils.ToInt(id),
+	}
+
+	err := db.Subscribers.Delete(r.Context(), s)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = flash.Add(w, r, utils.MsgSuccessfullyRemoved, "success")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/subscribers", http.StatusSeeOther)
+}
+
+func NewsletterForm(w http.ResponseWriter, r *http.Request) {
+	categories, err := db.Trips.FetchCategories(r.Context())
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	f := &models.NewsletterForm{
+		Category: r.FormValue("category"),
+	}
+
+	if r.FormValue("build") != "" {
+		trips, err := db.Trips.FindUpcoming(r.Context(), 0)
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+
+		now := time.Now()
+		month := models.Trips{}
+		for _, t := range *trips {
+			if t.Start.Year() != now.Year() || t.Start.Month() != now.Month() {
+				continue
+			}
+			if f.Category != "" && t.Category.String != f.Category {
+				continue
+			}
+			month = append(month, t)
+		}
+
+		f.Subject = "New Revel Bus trips for " + now.Format("January")
+		f.Body = emails.TripsDigest(month)
+	}
+
+	view.Render(w, r, "newsletter", &view.View{
+		Title:      "Newsletter",
+		Form:       f,
+		Categories: categories,
+	})
+}
+
+func PostNewsletter(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		view.ClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	f := &models.NewsletterForm{
+		Subject:  r.PostForm.Get("subject"),
+		Body:     r.PostForm.Get("body"),
+		Category: r.PostForm.Get("category"),
+	}
+
+	if !f.Valid() {
+		categories, err := db.Trips.FetchCategories(r.Context())
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+
+		view.Render(w, r, "newsletter", &view.View{
+			Title:      "Newsletter",
+			Form:       f,
+			Categories: categories,
+		})
+		return
+	}
+
+	subscribers, err := db.Subscribers.FindActive(r.Context(), f.Category)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	for _, s := range subscribers {
+		err = emails.Newsletter(r.Context(), s, f.Subject, f.Body)
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+	}
+
+	err = flash.Add(w, r, fmt.Sprintf(utils.MsgNewsletterQueued, len(subscribers)), "success")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/newsletter", http.StatusSeeOther)
+}
+
+// backPath sends the visitor back to the page they subscribed from, as long
+// as it's on this site.
+func backPath(r *http.Request) string {
+	ref, err := url.Parse(r.Referer())
+	if err != nil || ref.Path == "" || (ref.Host != "" && ref.Host != r.Host) {
+		return "/"
+	}
+	return ref.Path
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"context"
+	"net/url"
+	"revelbus/cmd/web/utils"
+	"revelbus/internal/platform/domain/models"
+	"testing"
+)
+
+func TestSubscribe(t *testing.T) {
+	s := newStore(t)
+
+	w := request{
+		method: "POST",
+		target: "/subscribe",
+		form:   url.Values{"email": {" pat@example.com "}},
+	}.do(PostSubscribe)
+	wantRedirect(t, w, "/")
+
+	sub := &models.Subscriber{Email: utils.NewNullStr("pat@example.com")}
+
+	err := s.Subscribers.Fetch(context.Background(), sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.Status.String != models.SubscriberPending {
+		t.Fatalf("got status %q, want %q", sub.Status.String, models.SubscriberPending)
+	}
+
+	w = request{
+		method: "GET",
+		target: "/subscribe/confirm?token=" + url.QueryEscape(sub.ConfirmToken.String),
+	}.do(ConfirmSubscription)
+	wantRedirect(t, w, "/subscribe/preferences?token="+url.QueryEscape(sub.UnsubscribeToken.String))
+
+	err = s.Subscribers.Fetch(context.Background(), sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.Status.String != models.SubscriberActive {
+		t.Fatalf("got status %q, want %q", sub.Status.String, models.SubscriberActive)
+	}
+
+	// the link only works once
+	w = request{
+		method: "GET",
+		target: "/subscribe/confirm?token=" + url.QueryEscape(sub.ConfirmToken.String),
+	}.do(ConfirmSubscription)
+	wantRedirect(t, w, "/")
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"revelbus/cmd/web/utils"
+	"revelbus/cmd/web/view"
+	"revelbus/internal/platform/domain"
+	"revelbus/internal/platform/domain/models"
+	"revelbus/internal/platform/domain/store"
+	"revelbus/internal/platform/flash"
+	"revelbus/internal/platform/trash"
+
+	"github.com/gorilla/mux"
+)
+
+func ListTrash(w http.ResponseWriter, r *http.Request) {
+	u, err := utils.IsAuthenticated(r)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	types := []string{}
+	for _, t := range models.TrashTypes {
+		if trash.Allowed(u, t) {
+			types = append(types, t)
+		}
+	}
+
+	t := r.URL.Query().Get("type")
+	if t == "" && len(types) > 0 {
+		t = types[0]
+	}
+
+	if !trash.Allowed(u, t) {
+		view.NotFound(w, r)
+		return
+	}
+
+	items, err := db.Trash.Fetch(r.Context(), t)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	view.Render(w, r, "trash", &view.View{
+		Title:      "Trash",
+		Trash:      items,
+		ActiveKey:  t,
+		TrashTypes: types,
+	})
+}
+
+func RestoreTrash(w http.ResponseWriter, r *http.Request) {
+	moveTrash(w, r, trash.Restore, utils.MsgRestored)
+}
+
+func PurgeTrash(w http.ResponseWriter, r *http.Request) {
+	moveTrash(w, r, trash.Purge, utils.MsgPurged)
+}
+
+// moveTrash restores or purges the record in the URL, if the user's allowed
+// to touch that kind of record.
+func moveTrash(w http.ResponseWriter, r *http.Request, move func(ctx context.Context, s *store.Store, u *models.User, t string, id int) error, msg string) {
+	vars := mux.Vars(r)
+	t := vars["type"]
+	id := vars["id"]
+
+	u, err := utils.IsAuthenticated(r)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	if !trash.Allowed(u, t) {
+		view.NotFound(w, r)
+		return
+	}
+
+	err = move(r.Context(), db, u, t, utils.ToInt(id))
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		} else if err == domain.ErrCannotDelete {
+			err = flash.Add(w, r, utils.MsgCannotRemove, "warning")
+			if err != nil {
+				view.ServerError(w, r, err)
+				return
+			}
+
+			http.Redirect(w, r, "/admin/trash?type="+t, http.StatusSeeOther)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = flash.Add(w, r, msg, "success")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/trash?type="+t, http.StatusSeeOther)
+}
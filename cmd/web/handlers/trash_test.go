@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"revelbus/internal/platform/domain"
+	"revelbus/internal/platform/domain/models"
+	"strconv"
+	"testing"
+)
+
+func TestRestoreTrash(t *testing.T) {
+	s := newStore(t)
+
+	f := &models.FAQ{}
+	err := s.FAQs.Create(context.Background(), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.FAQs.Delete(context.Background(), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restore := func(u *models.User) int {
+		w := request{
+			method:  "POST",
+			target:  "/admin/trash/faq/" + strconv.Itoa(f.ID) + "?restore",
+			vars:    map[string]string{"type": models.AuditFAQ, "id": strconv.Itoa(f.ID)},
+			cookies: signIn(t, u),
+		}.do(RestoreTrash)
+		return w.Code
+	}
+
+	// they can open the trash, but not touch FAQs
+	manager := newUser(t, s, "sam@example.com", "vendor-manager")
+	if code := restore(manager); code != http.StatusNotFound {
+		t.Fatalf("got status %d restoring without the permission, want %d", code, http.StatusNotFound)
+	}
+
+	err = s.FAQs.Fetch(context.Background(), &models.FAQ{ID: f.ID})
+	if err != domain.ErrNotFound {
+		t.Fatalf("got %v fetching the FAQ, want it still in the trash", err)
+	}
+
+	editor := newUser(t, s, "pat@example.com", "editor")
+	if code := restore(editor); code != http.StatusSeeOther {
+		t.Fatalf("got status %d restoring, want %d", code, http.StatusSeeOther)
+	}
+
+	err = s.FAQs.Fetch(context.Background(), &models.FAQ{ID: f.ID})
+	if err != nil {
+		t.Fatalf("got %v fetching the restored FAQ", err)
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"net/http"
 	"revelbus/cmd/web/utils"
@@ -18,7 +19,10 @@ func TripForm(w http.ResponseWriter, r *http.Request) {
 
 	if id == "" {
 		view.Render(w, r, "admin-trip", &view.View{
-			Form:  new(models.TripForm),
+			Form: &models.TripForm{
+				Remind:   true,
+				FollowUp: true,
+			},
 			Title: "New Trip",
 		})
 		return
@@ -28,7 +32,7 @@ func TripForm(w http.ResponseWriter, r *http.Request) {
 		ID: utils.ToInt(id),
 	}
 
-	err := t.Fetch()
+	err := db.Trips.Fetch(r.Context(), t)
 	if err != nil {
 		if err == domain.ErrNotFound {
 			view.NotFound(w, r)
@@ -38,41 +42,21 @@ func TripForm(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	vendors, err := models.FetchVendors(true)
+	vendors, err := db.Vendors.FetchAll(r.Context(), true)
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
 	}
 
-	galleries, err := models.FetchGalleries()
+	galleries, err := db.Galleries.FetchAll(r.Context())
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
 	}
 
-	f := &models.TripForm{
-		ID:           id,
-		Title:        t.Title.String,
-		Slug:         t.Slug.String,
-		Status:       t.Status.String,
-		Blurb:        t.Blurb.String,
-		Description:  t.Description.String,
-		Start:        t.Start.Format(domain.TimeFormat),
-		End:          t.End.Format(domain.TimeFormat),
-		Price:        t.Price.String,
-		TicketingURL: t.TicketingURL.String,
-		Notes:        t.Notes.String,
-		ImageID:      int(t.ImageID.Int64),
-		GalleryID:    int(t.GalleryID.Int64),
-	}
-
-	if t.Image != nil {
-		f.Image = t.Image.Thumb.String
-	}
-
 	view.Render(w, r, "admin-trip", &view.View{
 		ActiveKey: "trip",
-		Form:      f,
+		Form:      tripForm(t),
 		Trip:      t,
 		Vendors:   vendors,
 		Galleries: galleries,
@@ -88,9 +72,11 @@ func PostTrip(w http.ResponseWriter, r *http.Request) {
 
 	f := &models.TripForm{
 		ID:           r.PostForm.Get("id"),
+		Version:      utils.ToInt(r.PostForm.Get("version")),
 		Title:        r.PostForm.Get("title"),
 		Slug:         r.PostForm.Get("slug"),
 		Status:       r.PostForm.Get("status"),
+		Category:     r.PostForm.Get("category"),
 		Blurb:        r.PostForm.Get("blurb"),
 		Description:  r.PostForm.Get("description"),
 		Start:        r.PostForm.Get("start"),
@@ -98,6 +84,9 @@ func PostTrip(w http.ResponseWriter, r *http.Request) {
 		TicketingURL: r.PostForm.Get("ticketing_url"),
 		Price:        r.PostForm.Get("price"),
 		Notes:        r.PostForm.Get("notes"),
+		Pickup:       r.PostForm.Get("pickup"),
+		Remind:       (len(r.Form["remind"]) == 1),
+		FollowUp:     (len(r.Form["follow_up"]) == 1),
 		ImageID:      utils.ToInt(r.PostForm.Get("image_id")),
 		GalleryID:    utils.ToInt(r.PostForm.Get("gallery_id")),
 	}
@@ -112,15 +101,18 @@ func PostTrip(w http.ResponseWriter, r *http.Request) {
 		}
 
 		view.Render(w, r, "admin-trip", v)
+		return
 	}
 
 	var msg string
 
 	t := models.Trip{
 		ID:           utils.ToInt(f.ID),
+		Version:      f.Version,
 		Title:        utils.NewNullStr(f.Title),
 		Slug:         utils.NewNullStr(f.Slug),
 		Status:       utils.NewNullStr(f.Status),
+		Category:     utils.NewNullStr(f.Category),
 		Blurb:        utils.NewNullStr(f.Blurb),
 		Description:  utils.NewNullStr(f.Description),
 		Start:        domain.ToTime(f.Start),
@@ -128,6 +120,9 @@ func PostTrip(w http.ResponseWriter, r *http.Request) {
 		TicketingURL: utils.NewNullStr(f.TicketingURL),
 		Price:        utils.NewNullStr(f.Price),
 		Notes:        utils.NewNullStr(f.Notes),
+		Pickup:       utils.NewNullStr(f.Pickup),
+		Remind:       f.Remind,
+		FollowUp:     f.FollowUp,
 	}
 
 	if f.ImageID != 0 {
@@ -142,47 +137,46 @@ func PostTrip(w http.ResponseWriter, r *http.Request) {
 		t.GalleryID = sql.NullInt64{}
 	}
 
-	image, err := utils.UploadFile(w, r, "trip_image", "uploads/trip", true)
-	if err != nil {
-		view.ServerError(w, r, err)
-		return
-	}
-
-	if len(image) > 0 {
-		t.ImageID = utils.NewNullInt(image[0].ID)
-	} else if (f.ImageID != 0) && (len(r.Form["deleteimg"]) == 1) {
-		image := &models.File{
-			ID: f.ImageID,
-		}
-
-		err = utils.DeleteFile(image)
+	// the upload, the old image's removal and the save stand or fall together
+	err = db.InTx(r.Context(), func(ctx context.Context) error {
+		image, err := utils.UploadFile(ctx, r, "trip_image", "uploads/trip", true)
 		if err != nil {
-			view.ServerError(w, r, err)
-			return
+			return err
 		}
 
-		t.ImageID = sql.NullInt64{}
-	}
+		if len(image) > 0 {
+			t.ImageID = utils.NewNullInt(image[0].ID)
+		} else if (f.ImageID != 0) && (len(r.Form["deleteimg"]) == 1) {
+			image := &models.File{
+				ID: f.ImageID,
+			}
 
-	if t.ID != 0 {
-		err := t.Update()
-		if err != nil {
-			if err == domain.ErrNotFound {
-				view.NotFound(w, r)
-				return
+			err = db.Files.Delete(ctx, image)
+			if err != nil {
+				return err
 			}
-			view.ServerError(w, r, err)
-			return
+
+			t.ImageID = sql.NullInt64{}
 		}
 
-		msg = utils.MsgSuccessfullyUpdated
-	} else {
-		err := t.Create()
-		if err != nil {
-			view.ServerError(w, r, err)
-			return
+		if t.ID != 0 {
+			msg = utils.MsgSuccessfullyUpdated
+			return db.Trips.Update(ctx, &t)
 		}
+
 		msg = utils.MsgSuccessfullyCreated
+		return db.Trips.Create(ctx, &t)
+	})
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		} else if err == domain.ErrConflict {
+			tripConflict(w, r, f)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
 	}
 
 	err = flash.Add(w, r, msg, "success")
@@ -196,8 +190,62 @@ func PostTrip(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/admin/trip?id="+id, http.StatusSeeOther)
 }
 
+// tripForm fills in the trip form from t.
+func tripForm(t *models.Trip) *models.TripForm {
+	f := &models.TripForm{
+		ID:           strconv.Itoa(t.ID),
+		Version:      t.Version,
+		Title:        t.Title.String,
+		Slug:         t.Slug.String,
+		Status:       t.Status.String,
+		Category:     t.Category.String,
+		Blurb:        t.Blurb.String,
+		Description:  t.Description.String,
+		Start:        t.Start.Format(domain.TimeFormat),
+		End:          t.End.Format(domain.TimeFormat),
+		Price:        t.Price.String,
+		TicketingURL: t.TicketingURL.String,
+		Notes:        t.Notes.String,
+		Pickup:       t.Pickup.String,
+		Remind:       t.Remind,
+		FollowUp:     t.FollowUp,
+		ImageID:      int(t.ImageID.Int64),
+		GalleryID:    int(t.GalleryID.Int64),
+	}
+
+	if t.Image != nil {
+		f.Image = t.Image.Thumb.String
+	}
+	return f
+}
+
+// tripConflict shows f, which couldn't be saved, next to the trip as someone
+// else has saved it since.
+func tripConflict(w http.ResponseWriter, r *http.Request, f *models.TripForm) {
+	t := &models.Trip{
+		ID: utils.ToInt(f.ID),
+	}
+
+	err := db.Trips.Fetch(r.Context(), t)
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
+	}
+
+	saved := tripForm(t)
+
+	// the thumbnail isn't posted back with the form
+	f.Image = saved.Image
+
+	view.Conflict(w, r, models.NewConflict(t.Title.String, "/admin/trip?id="+f.ID, f, saved))
+}
+
 func ListTrips(w http.ResponseWriter, r *http.Request) {
-	trips, err := models.FetchTrips()
+	trips, err := db.Trips.FetchAll(r.Context())
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
@@ -217,7 +265,8 @@ func RemoveTrip(w http.ResponseWriter, r *http.Request) {
 		ID: utils.ToInt(id),
 	}
 
-	err := t.GetBase()
+	// the image stays with the trip in the trash and goes when it's purged
+	err := db.Trips.Delete(r.Context(), t)
 	if err != nil {
 		if err == domain.ErrNotFound {
 			view.NotFound(w, r)
@@ -227,25 +276,7 @@ func RemoveTrip(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if int(t.ImageID.Int64) != 0 {
-		image := &models.File{
-			ID: int(t.ImageID.Int64),
-		}
-
-		err = utils.DeleteFile(image)
-		if err != nil {
-			view.ServerError(w, r, err)
-			return
-		}
-	}
-
-	err = t.Delete()
-	if err != nil {
-		view.ServerError(w, r, err)
-		return
-	}
-
-	err = flash.Add(w, r, utils.MsgSuccessfullyRemoved, "success")
+	err = flash.Add(w, r, utils.MsgMovedToTrash, "success")
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
@@ -262,13 +293,13 @@ func TripPartners(w http.ResponseWriter, r *http.Request) {
 		ID: utils.ToInt(id),
 	}
 
-	err := t.Fetch()
+	err := db.Trips.Fetch(r.Context(), t)
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
 	}
 
-	vendors, err := models.FetchVendors(true)
+	vendors, err := db.Vendors.FetchAll(r.Context(), true)
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
@@ -289,13 +320,13 @@ func TripVenues(w http.ResponseWriter, r *http.Request) {
 		ID: utils.ToInt(id),
 	}
 
-	err := t.Fetch()
+	err := db.Trips.Fetch(r.Context(), t)
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
 	}
 
-	vendors, err := models.FetchVendors(true)
+	vendors, err := db.Vendors.FetchAll(r.Context(), true)
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
@@ -325,7 +356,7 @@ func AttachVendor(w http.ResponseWriter, r *http.Request) {
 		ID: utils.ToInt(id),
 	}
 
-	err = t.AttachVendor(role, vid)
+	err = db.Trips.AttachVendor(r.Context(), &t, role, vid)
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
@@ -350,7 +381,7 @@ func DetachVendor(w http.ResponseWriter, r *http.Request) {
 		ID: utils.ToInt(id),
 	}
 
-	err := t.DetachVendor(role, vid)
+	err := db.Trips.DetachVendor(r.Context(), &t, role, vid)
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
@@ -375,7 +406,7 @@ func UpdateVenueStatus(w http.ResponseWriter, r *http.Request) {
 		ID: utils.ToInt(id),
 	}
 
-	err := t.SetVenueStatus(vid, isPrimary)
+	err := db.Trips.SetVenueStatus(r.Context(), &t, vid, isPrimary)
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
@@ -0,0 +1,472 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"revelbus/cmd/web/utils"
+	"revelbus/cmd/web/view"
+	"revelbus/internal/platform/domain/models"
+	"revelbus/internal/platform/flash"
+	"revelbus/pkg/sessions"
+	"revelbus/pkg/totp"
+	"time"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const (
+	totpIssuer       = "Revel Bus"
+	pendingSecretKey = "PendingTOTPSecret"
+)
+
+func TwoFactorForm(w http.ResponseWriter, r *http.Request) {
+	u, err := utils.IsAuthenticated(r)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	f := &models.TwoFactorForm{}
+
+	if u.TOTPEnabled {
+		f.Remaining, err = db.Users.RemainingRecoveryCodes(r.Context(), u)
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+	} else {
+		f.Secret, err = pendingSecret(w, r)
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+	}
+
+	view.Render(w, r, "two-factor", &view.View{
+		Form:  f,
+		Title: "Two-Factor Authentication",
+	})
+}
+
+// TwoFactorQR is the QR code for the secret being set up, for scanning into
+// an authenticator app.
+func TwoFactorQR(w http.ResponseWriter, r *http.Request) {
+	u, err := utils.IsAuthenticated(r)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	s := sessions.GetSession().Load(r)
+	secret, err := s.GetString(pendingSecretKey)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	if secret == "" {
+		view.NotFound(w, r)
+		return
+	}
+
+	png, err := qrcode.Encode(totp.URI(totpIssuer, u.Email.String, secret), qrcode.Medium, 256)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(png)
+}
+
+// PostTwoFactor finishes setting up 2FA once the user enters a code from the
+// secret they scanned.
+func PostTwoFactor(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		view.ClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	u, err := utils.IsAuthenticated(r)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	secret, err := pendingSecret(w, r)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	f := &models.TwoFactorForm{
+		Secret: secret,
+		Code:   r.PostForm.Get("code"),
+	}
+
+	if !f.Valid() {
+		view.Render(w, r, "two-factor", &view.View{
+			Form:  f,
+			Title: "Two-Factor Authentication",
+		})
+		return
+	}
+
+	// replacing an app that's already set up takes a code from the old one
+	if u.TOTPEnabled {
+		msg, err := confirmSecondFactor(r, u, r.PostForm.Get("current_code"))
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+
+		if msg != "" {
+			f.Errors["Code"] = msg
+			view.Render(w, r, "two-factor", &view.View{
+				Form:  f,
+				Title: "Two-Factor Authentication",
+			})
+			return
+		}
+	}
+
+	step, ok := totp.Validate(secret, f.Code, time.Now(), 0)
+	if !ok {
+		f.Errors["Code"] = utils.MsgInvalidCode
+		view.Render(w, r, "two-factor", &view.View{
+			Form:  f,
+			Title: "Two-Factor Authentication",
+		})
+		return
+	}
+
+	err = db.Users.EnableTwoFactor(r.Context(), u, secret, step)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = sessions.GetSession().Load(r).Remove(w, pendingSecretKey)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = alertSecurity(r, u, models.SecurityTwoFactorEnabled, "")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	showRecoveryCodes(w, r, u, utils.MsgTwoFactorEnabled)
+}
+
+func PostRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	u, ok := verifySecondFactor(w, r)
+	if !ok {
+		return
+	}
+
+	err := alertSecurity(r, u, models.SecurityRecoveryCodes, "")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	showRecoveryCodes(w, r, u, utils.MsgRecoveryCodesRegenerated)
+}
+
+func PostDisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	u, ok := verifySecondFactor(w, r)
+	if !ok {
+		return
+	}
+
+	if u.IsStaff() {
+		require, err := db.Settings.RequireAdmin2FA(r.Context())
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+
+		if require {
+			err = flash.Add(w, r, utils.MsgTwoFactorRequired, "warning")
+			if err != nil {
+				view.ServerError(w, r, err)
+				return
+			}
+
+			http.Redirect(w, r, "/u/two-factor", http.StatusSeeOther)
+			return
+		}
+	}
+
+	err := db.Users.DisableTwoFactor(r.Context(), u)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = alertSecurity(r, u, models.SecurityTwoFactorDisabled, "")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = flash.Add(w, r, utils.MsgTwoFactorDisabled, "success")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/u/profile", http.StatusSeeOther)
+}
+
+func TwoFactorLoginForm(w http.ResponseWriter, r *http.Request) {
+	u, err := utils.PendingLogin(r)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	if u == nil {
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+
+	view.Render(w, r, "two-factor-login", &view.View{
+		Form:  new(models.TwoFactorForm),
+		Title: "Two-Factor Authentication",
+	})
+}
+
+// PostTwoFactorLogin is the second login step. Wrong codes count towards the
+// account's failed logins, so they lock it out the same as wrong passwords.
+func PostTwoFactorLogin(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		view.ClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	u, err := utils.PendingLogin(r)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	if u == nil {
+		err = flash.Add(w, r, utils.MsgLoginExpired, "warning")
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+
+	f := &models.TwoFactorForm{
+		Code: r.PostForm.Get("code"),
+	}
+
+	if !f.Valid() {
+		view.Render(w, r, "two-factor-login", &view.View{
+			Form:  f,
+			Title: "Two-Factor Authentication",
+		})
+		return
+	}
+
+	wait, err := db.Users.LoginWait(r.Context(), u.Email.String)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	if wait > 0 {
+		err = utils.ClearPendingLogin(w, r)
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+
+		loginThrottled(w, r, new(models.UserForm), wait)
+		return
+	}
+
+	ok, err := checkSecondFactor(r.Context(), u, f.Code)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	if !ok {
+		err = secondFactorFailed(r, u)
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+
+		f.Errors["Code"] = utils.MsgInvalidCode
+		view.Render(w, r, "two-factor-login", &view.View{
+			Form:  f,
+			Title: "Two-Factor Authentication",
+		})
+		return
+	}
+
+	err = utils.ClearPendingLogin(w, r)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = completeLogin(w, r, u)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/u", http.StatusSeeOther)
+}
+
+// checkSecondFactor accepts either a code from the authenticator app or one
+// of the user's recovery codes.
+func checkSecondFactor(ctx context.Context, u *models.User, code string) (bool, error) {
+	ok, err := db.Users.VerifyTOTP(ctx, u, code)
+	if err != nil || ok {
+		return ok, err
+	}
+
+	return db.Users.UseRecoveryCode(ctx, u, code)
+}
+
+// secondFactorFailed counts a wrong code towards the account's failed logins,
+// so codes can't be guessed any faster than passwords.
+func secondFactorFailed(r *http.Request, u *models.User) error {
+	locked, err := db.Users.RecordFailedLogin(r.Context(), u.Email.String)
+	if err != nil || locked == nil {
+		return err
+	}
+
+	return accountLocked(r, locked)
+}
+
+// confirmSecondFactor checks a code from a user who's already signed in and
+// returns what's wrong with it, if anything. It's held to the same lockout as
+// signing in, so a stolen session can't be used to guess codes either.
+func confirmSecondFactor(r *http.Request, u *models.User, code string) (string, error) {
+	wait, err := db.Users.LoginWait(r.Context(), u.Email.String)
+	if err != nil {
+		return "", err
+	}
+
+	if wait > 0 {
+		return fmt.Sprintf(utils.MsgLoginThrottled, waitText(wait)), nil
+	}
+
+	ok, err := checkSecondFactor(r.Context(), u, code)
+	if err != nil {
+		return "", err
+	}
+
+	if !ok {
+		return utils.MsgInvalidCode, secondFactorFailed(r, u)
+	}
+
+	return "", db.Users.ResetFailedLogins(r.Context(), u)
+}
+
+// verifySecondFactor makes a signed in user confirm a code before changing
+// their 2FA settings. It writes the response itself when they don't.
+func verifySecondFactor(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	err := r.ParseForm()
+	if err != nil {
+		view.ClientError(w, r, http.StatusBadRequest)
+		return nil, false
+	}
+
+	u, err := utils.IsAuthenticated(r)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return nil, false
+	}
+
+	f := &models.TwoFactorForm{
+		Code: r.PostForm.Get("code"),
+	}
+
+	ok := f.Valid()
+	if ok {
+		msg, err := confirmSecondFactor(r, u, f.Code)
+		if err != nil {
+			view.ServerError(w, r, err)
+			return nil, false
+		}
+
+		if msg != "" {
+			f.Errors["Code"] = msg
+			ok = false
+		}
+	}
+
+	if !ok {
+		f.Remaining, err = db.Users.RemainingRecoveryCodes(r.Context(), u)
+		if err != nil {
+			view.ServerError(w, r, err)
+			return nil, false
+		}
+
+		view.Render(w, r, "two-factor", &view.View{
+			Form:  f,
+			Title: "Two-Factor Authentication",
+		})
+		return nil, false
+	}
+
+	return u, true
+}
+
+func showRecoveryCodes(w http.ResponseWriter, r *http.Request, u *models.User, msg string) {
+	codes, err := db.Users.NewRecoveryCodes(r.Context(), u)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = flash.Add(w, r, msg, "success")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	view.Render(w, r, "two-factor", &view.View{
+		Form: &models.TwoFactorForm{
+			RecoveryCodes: codes,
+		},
+		Title: "Two-Factor Authentication",
+	})
+}
+
+// pendingSecret is the secret being set up, kept in the session until the
+// user proves they've added it to their app.
+func pendingSecret(w http.ResponseWriter, r *http.Request) (string, error) {
+	s := sessions.GetSession().Load(r)
+
+	secret, err := s.GetString(pendingSecretKey)
+	if err != nil || secret != "" {
+		return secret, err
+	}
+
+	secret, err = totp.NewSecret()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.PutString(w, pendingSecretKey, secret)
+	return secret, err
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"revelbus/internal/platform/domain/models"
+	"revelbus/internal/platform/domain/store"
+	"revelbus/pkg/sessions"
+	"revelbus/pkg/totp"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// withTwoFactor turns 2FA on for u and returns the secret it uses.
+func withTwoFactor(t *testing.T, s *store.Store, u *models.User) string {
+	t.Helper()
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Users.EnableTwoFactor(context.Background(), u, secret, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return secret
+}
+
+func code(t *testing.T, secret string) string {
+	t.Helper()
+
+	c, err := totp.Code(secret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestPostDisableTwoFactorLockout(t *testing.T) {
+	viper.Set("login.max_failures", 3)
+	viper.Set("login.delay_after", 10)
+	defer viper.Set("login.max_failures", 0)
+	defer viper.Set("login.delay_after", 0)
+
+	s := newStore(t)
+	u := newUser(t, s, "pat@example.com", "user")
+	secret := withTwoFactor(t, s, u)
+	cookies := signIn(t, u)
+
+	disable := func(c string) *httptest.ResponseRecorder {
+		return request{
+			method:  "POST",
+			target:  "/u/two-factor?disable",
+			form:    url.Values{"code": {c}},
+			cookies: cookies,
+		}.do(PostDisableTwoFactor)
+	}
+
+	for i := 0; i < 3; i++ {
+		w := disable("000000")
+		wantBody(t, w, http.StatusOK, "That code didn")
+	}
+
+	locked, err := s.Users.FetchLocked(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locked) != 1 || locked[0].ID != u.ID {
+		t.Fatalf("got locked users %+v, want just %d", locked, u.ID)
+	}
+
+	// the right code doesn't help once the account is locked
+	w := disable(code(t, secret))
+	wantBody(t, w, http.StatusOK, "Too many failed login attempts")
+
+	ok, err := s.Users.VerifyTOTP(context.Background(), u, code(t, secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("2FA was turned off for a locked account")
+	}
+}
+
+func TestPostTwoFactorReenroll(t *testing.T) {
+	s := newStore(t)
+	u := newUser(t, s, "pat@example.com", "user")
+	old := withTwoFactor(t, s, u)
+	cookies := signIn(t, u)
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the new secret waits in the session like it would after the form loads
+	r := httptest.NewRequest("GET", "/u/two-factor", nil)
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	rec := httptest.NewRecorder()
+
+	err = sessions.GetSession().Load(r).PutString(rec, pendingSecretKey, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := request{
+		method:  "POST",
+		target:  "/u/two-factor",
+		form:    url.Values{"code": {code(t, secret)}},
+		cookies: cookies,
+	}.do(PostTwoFactor)
+	wantBody(t, w, http.StatusOK, "That code didn")
+
+	ok, err := s.Users.VerifyTOTP(context.Background(), u, code(t, old))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("the secret was replaced without a code from the old one")
+	}
+}
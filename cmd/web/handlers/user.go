@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"net/http"
 	"revelbus/cmd/web/utils"
 	"revelbus/cmd/web/view"
@@ -16,9 +17,18 @@ import (
 func UserForm(w http.ResponseWriter, r *http.Request) {
 	id := r.FormValue("id")
 
+	roles, err := db.Roles.FetchAll(r.Context())
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
 	if id == "" {
 		view.Render(w, r, "user", &view.View{
-			Form:  new(models.UserForm),
+			Form: &models.UserForm{
+				Role: models.RoleUser,
+			},
+			Roles: roles,
 			Title: "New User",
 		})
 		return
@@ -28,7 +38,7 @@ func UserForm(w http.ResponseWriter, r *http.Request) {
 		ID: utils.ToInt(id),
 	}
 
-	err := u.Fetch()
+	err = db.Users.Fetch(r.Context(), u)
 	if err != nil {
 		if err == domain.ErrNotFound {
 			view.NotFound(w, r)
@@ -38,16 +48,33 @@ func UserForm(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	f := &models.UserForm{
-		ID:    id,
-		Name:  u.Name.String,
-		Email: u.Email.String,
-		Role:  u.Role.String,
+	f := userForm(u)
+
+	list, err := db.Sessions.FetchAll(r.Context(), u.ID)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	events, err := db.SecurityEvents.FetchAll(r.Context(), u.ID, securityLogSize)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	impersonations, err := db.Impersonations.FetchAll(r.Context(), u.ID, securityLogSize)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
 	}
 
 	view.Render(w, r, "user", &view.View{
-		Title: f.Name,
-		Form:  f,
+		Title:          f.Name,
+		Form:           f,
+		Events:         events,
+		Impersonations: impersonations,
+		Roles:          roles,
+		Sessions:       list,
 	})
 }
 
@@ -59,15 +86,80 @@ func PostUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	f := &models.UserForm{
-		ID:    r.PostForm.Get("id"),
-		Name:  r.PostForm.Get("name"),
-		Email: r.PostForm.Get("email"),
-		Role:  r.PostForm.Get("role"),
+		ID:       r.PostForm.Get("id"),
+		Version:  utils.ToInt(r.PostForm.Get("version")),
+		Name:     r.PostForm.Get("name"),
+		Email:    r.PostForm.Get("email"),
+		Role:     r.PostForm.Get("role"),
+		Verified: len(r.PostForm["verified"]) == 1,
 	}
 
-	if !f.Valid() {
+	me, err := utils.IsAuthenticated(r)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	if me == nil {
+		view.ClientError(w, r, http.StatusForbidden)
+		return
+	}
+
+	// the user as they are now, to check the change against
+	old := &models.User{}
+	if f.ID != "" {
+		old.ID = utils.ToInt(f.ID)
+
+		err = db.Users.Fetch(r.Context(), old)
+		if err != nil {
+			if err == domain.ErrNotFound {
+				view.NotFound(w, r)
+				return
+			}
+			view.ServerError(w, r, err)
+			return
+		}
+
+		if !canManage(me, old) {
+			notPermitted(w, r, "/admin/user?id="+f.ID)
+			return
+		}
+	}
+
+	valid := f.Valid()
+	if valid && f.Role == models.RoleAdmin && !isAdmin(me) {
+		f.Errors["Role"] = utils.MsgAdminOnly
+		valid = false
+	} else if valid && old.ID == me.ID && f.Role != old.Role.String {
+		f.Errors["Role"] = utils.MsgOwnRole
+		valid = false
+	}
+
+	if valid {
+		role := &models.Role{
+			Name: utils.NewNullStr(f.Role),
+		}
+
+		err = db.Roles.Fetch(r.Context(), role)
+		if err == domain.ErrNotFound {
+			f.Errors["Role"] = "Please choose a role."
+			valid = false
+		} else if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+	}
+
+	if !valid {
+		roles, err := db.Roles.FetchAll(r.Context())
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+
 		v := &view.View{
-			Form: f,
+			Form:  f,
+			Roles: roles,
 		}
 
 		if f.ID == "" {
@@ -75,53 +167,85 @@ func PostUser(w http.ResponseWriter, r *http.Request) {
 		}
 
 		view.Render(w, r, "user", v)
+		return
 	}
 
 	var msg string
 
 	u := models.User{
-		ID:    utils.ToInt(f.ID),
-		Name:  utils.NewNullStr(f.Name),
-		Email: utils.NewNullStr(f.Email),
-		Role:  utils.NewNullStr(f.Role),
+		ID:      utils.ToInt(f.ID),
+		Version: f.Version,
+		Name:    utils.NewNullStr(f.Name),
+		Email:   utils.NewNullStr(f.Email),
+		Role:    utils.NewNullStr(f.Role),
 	}
 
-	if u.ID != 0 {
-		err := u.Update()
-		if err != nil {
-			if err == domain.ErrNotFound {
-				view.NotFound(w, r)
-				return
+	// the save, the new password and the mail with it stand or fall together
+	err = db.InTx(r.Context(), func(ctx context.Context) error {
+		var pw string
+		var err error
+
+		if u.ID != 0 {
+			msg = utils.MsgSuccessfullyUpdated
+
+			err = db.Users.Update(ctx, &u)
+			if err != nil {
+				return err
 			}
-			view.ServerError(w, r, err)
-			return
-		}
 
-		if len(r.Form["reset_password"]) == 1 {
-			pw := utils.RandomString(14)
+			if len(r.Form["reset_password"]) == 1 {
+				pw, err = utils.RandomString(14)
+				if err != nil {
+					return err
+				}
+
+				err = db.Users.UpdatePassword(ctx, &u, pw)
+				if err != nil {
+					return err
+				}
+
+				_, err = logSecurity(r.WithContext(ctx), &u, models.SecurityPasswordReset, models.ResetByAdmin)
+				if err != nil {
+					return err
+				}
+			}
+		} else {
+			msg = utils.MsgSuccessfullyCreated
 
-			err := u.UpdatePassword(pw)
+			pw, err = utils.RandomString(14)
 			if err != nil {
-				view.ServerError(w, r, err)
-				return
+				return err
 			}
 
-			emails.NewPassword(u.Email.String, pw)
-		}
+			u.Password = utils.NewNullStr(pw)
 
-		msg = utils.MsgSuccessfullyUpdated
-	} else {
-		pw := utils.RandomString(14)
-		u.Password = utils.NewNullStr(pw)
+			err = db.Users.Create(ctx, &u)
+			if err != nil {
+				return err
+			}
+		}
 
-		err := u.Create()
+		err = db.Users.SetVerified(ctx, &u, f.Verified)
 		if err != nil {
-			view.ServerError(w, r, err)
-			return
+			return err
 		}
 
-		emails.NewPassword(u.Email.String, pw)
-		msg = utils.MsgSuccessfullyCreated
+		if pw == "" {
+			return nil
+		}
+
+		return emails.NewPassword(ctx, u.Email.String, pw)
+	})
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		} else if err == domain.ErrConflict {
+			userConflict(w, r, f)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
 	}
 
 	err = flash.Add(w, r, msg, "success")
@@ -135,8 +259,40 @@ func PostUser(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/admin/user?id="+id, http.StatusSeeOther)
 }
 
+// userForm fills in the user form from u.
+func userForm(u *models.User) *models.UserForm {
+	return &models.UserForm{
+		ID:       strconv.Itoa(u.ID),
+		Version:  u.Version,
+		Name:     u.Name.String,
+		Email:    u.Email.String,
+		Role:     u.Role.String,
+		Verified: u.IsVerified(),
+	}
+}
+
+// userConflict shows f, which couldn't be saved, next to the user as someone
+// else has saved them since.
+func userConflict(w http.ResponseWriter, r *http.Request, f *models.UserForm) {
+	u := &models.User{
+		ID: utils.ToInt(f.ID),
+	}
+
+	err := db.Users.Fetch(r.Context(), u)
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
+	}
+
+	view.Conflict(w, r, models.NewConflict(u.Name.String, "/admin/user?id="+f.ID, f, userForm(u)))
+}
+
 func ListUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := models.FetchUsers()
+	users, err := db.Users.FetchAll(r.Context())
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
@@ -148,21 +304,82 @@ func ListUsers(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func ListLockedUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := db.Users.FetchLocked(r.Context())
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	view.Render(w, r, "locked-users", &view.View{
+		Title: "Locked Accounts",
+		Users: &users,
+	})
+}
+
+func UnlockUser(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	u := &models.User{
+		ID: utils.ToInt(id),
+	}
+
+	err := db.Users.Unlock(r.Context(), u)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = flash.Add(w, r, utils.MsgAccountUnlocked, "success")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/users?locked", http.StatusSeeOther)
+}
+
 func RemoveUser(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
+	me, err := utils.IsAuthenticated(r)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
 	u := &models.User{
 		ID: utils.ToInt(id),
 	}
 
-	err := u.Delete()
+	err = db.Users.Fetch(r.Context(), u)
 	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		}
 		view.ServerError(w, r, err)
 		return
 	}
 
-	err = flash.Add(w, r, utils.MsgSuccessfullyRemoved, "success")
+	if !canManage(me, u) {
+		notPermitted(w, r, "/admin/user?id="+id)
+		return
+	}
+
+	err = db.Users.Delete(r.Context(), u)
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = flash.Add(w, r, utils.MsgMovedToTrash, "success")
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
@@ -170,3 +387,71 @@ func RemoveUser(w http.ResponseWriter, r *http.Request) {
 
 	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
 }
+
+func RevokeUserSession(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	sid := vars["sid"]
+
+	s := &models.Session{
+		ID:     utils.ToInt(sid),
+		UserID: utils.ToInt(id),
+	}
+
+	err := db.Sessions.Revoke(r.Context(), s)
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = flash.Add(w, r, utils.MsgSessionRevoked, "success")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/user?id="+id, http.StatusSeeOther)
+}
+
+func RevokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	err := db.Sessions.RevokeAll(r.Context(), utils.ToInt(id))
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = flash.Add(w, r, utils.MsgSessionsRevoked, "success")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/user?id="+id, http.StatusSeeOther)
+}
+
+func isAdmin(u *models.User) bool {
+	return u != nil && u.Role.String == models.RoleAdmin
+}
+
+// canManage is whether me may change u's account. Only admins can touch an
+// admin's, so the users permission can't be used to take one over.
+func canManage(me *models.User, u *models.User) bool {
+	return me != nil && (u.Role.String != models.RoleAdmin || isAdmin(me))
+}
+
+func notPermitted(w http.ResponseWriter, r *http.Request, to string) {
+	err := flash.Add(w, r, utils.MsgNotPermitted, "warning")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, to, http.StatusSeeOther)
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"revelbus/cmd/web/utils"
+	"revelbus/internal/platform/domain/models"
+	"revelbus/internal/platform/domain/store"
+	"strconv"
+	"testing"
+)
+
+// newManager adds a user who can manage users without being an admin.
+func newManager(t *testing.T, s *store.Store) *models.User {
+	t.Helper()
+
+	role := &models.Role{
+		Name:        utils.NewNullStr("manager"),
+		Label:       utils.NewNullStr("Manager"),
+		Permissions: []string{models.PermUsers},
+	}
+
+	err := s.Roles.Create(context.Background(), role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newUser(t, s, "sam@example.com", "manager")
+}
+
+// postUser saves the user form for u as it stands, with changes on top.
+func postUser(t *testing.T, me *models.User, u *models.User, changes url.Values) *httptest.ResponseRecorder {
+	t.Helper()
+
+	form := url.Values{
+		"id":      {strconv.Itoa(u.ID)},
+		"version": {strconv.Itoa(u.Version)},
+		"name":    {u.Name.String},
+		"email":   {u.Email.String},
+		"role":    {u.Role.String},
+	}
+	for k, v := range changes {
+		form[k] = v
+	}
+
+	return request{
+		method:  "POST",
+		target:  "/admin/user?id=" + strconv.Itoa(u.ID),
+		form:    form,
+		cookies: signIn(t, me),
+	}.do(PostUser)
+}
+
+func TestPostUser(t *testing.T) {
+	s := newStore(t)
+	admin := newUser(t, s, "admin@example.com", models.RoleAdmin)
+
+	w := request{
+		method: "POST",
+		target: "/admin/user",
+		form: url.Values{
+			"name":     {"Pat"},
+			"email":    {"pat@example.com"},
+			"role":     {"user"},
+			"verified": {"on"},
+		},
+		cookies: signIn(t, admin),
+	}.do(PostUser)
+
+	u := &models.User{Email: utils.NewNullStr("pat@example.com")}
+	err := s.Users.Fetch(context.Background(), u)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantRedirect(t, w, "/admin/user?id="+strconv.Itoa(u.ID))
+
+	if !u.IsVerified() {
+		t.Fatal("the new user isn't verified")
+	}
+}
+
+func TestPostUserResetPassword(t *testing.T) {
+	s := newStore(t)
+	admin := newUser(t, s, "admin@example.com", models.RoleAdmin)
+	u := newUser(t, s, "pat@example.com", "user")
+
+	w := postUser(t, admin, u, url.Values{"reset_password": {"on"}})
+	wantRedirect(t, w, "/admin/user?id="+strconv.Itoa(u.ID))
+
+	err := s.Users.VerifyUser(context.Background(), &models.User{Email: utils.NewNullStr("pat@example.com")}, "secret")
+	if err == nil {
+		t.Fatal("the old password still works")
+	}
+
+	events, err := s.SecurityEvents.FetchAll(context.Background(), u.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*events) != 1 || (*events)[0].Kind != models.SecurityPasswordReset {
+		t.Fatalf("got security events %+v, want the reset", events)
+	}
+}
+
+func TestPostUserRole(t *testing.T) {
+	s := newStore(t)
+	manager := newManager(t, s)
+	u := newUser(t, s, "pat@example.com", "user")
+
+	w := postUser(t, manager, u, url.Values{"role": {models.RoleAdmin}})
+	wantBody(t, w, http.StatusOK, "Only admins can make someone an admin.")
+
+	// nor can they promote themselves, or anyone change their own role
+	w = postUser(t, manager, manager, url.Values{"role": {models.RoleAdmin}})
+	wantBody(t, w, http.StatusOK, "Only admins can make someone an admin.")
+
+	admin := newUser(t, s, "admin@example.com", models.RoleAdmin)
+	w = postUser(t, admin, admin, url.Values{"role": {"user"}})
+	wantBody(t, w, http.StatusOK, "change your own role")
+
+	for _, id := range []int{u.ID, manager.ID, admin.ID} {
+		got := &models.User{ID: id}
+		err := s.Users.Fetch(context.Background(), got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Role.String == models.RoleAdmin && id != admin.ID || got.Role.String != models.RoleAdmin && id == admin.ID {
+			t.Fatalf("user %d's role changed to %q", id, got.Role.String)
+		}
+	}
+
+	w = postUser(t, admin, u, url.Values{"role": {models.RoleAdmin}})
+	wantRedirect(t, w, "/admin/user?id="+strconv.Itoa(u.ID))
+}
+
+func TestPostUserAdminAccount(t *testing.T) {
+	s := newStore(t)
+	manager := newManager(t, s)
+	admin := newUser(t, s, "admin@example.com", models.RoleAdmin)
+
+	w := postUser(t, manager, admin, url.Values{
+		"email":          {"sam+admin@example.com"},
+		"reset_password": {"on"},
+	})
+	wantRedirect(t, w, "/admin/user?id="+strconv.Itoa(admin.ID))
+
+	got := &models.User{ID: admin.ID}
+	err := s.Users.Fetch(context.Background(), got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Email.String != "admin@example.com" {
+		t.Fatalf("the admin's email changed to %q", got.Email.String)
+	}
+
+	err = s.Users.VerifyUser(context.Background(), &models.User{Email: utils.NewNullStr("admin@example.com")}, "secret")
+	if err != nil {
+		t.Fatalf("got %v signing in as the admin, want their password unchanged", err)
+	}
+}
+
+func TestPostUserWithoutVersion(t *testing.T) {
+	s := newStore(t)
+	admin := newUser(t, s, "admin@example.com", models.RoleAdmin)
+	u := newUser(t, s, "pat@example.com", "user")
+
+	// a form that doesn't say which version it was read at can't be checked
+	w := postUser(t, admin, u, url.Values{"version": {""}, "name": {"Someone Else"}})
+	if w.Code != http.StatusConflict {
+		t.Fatalf("got status %d, want %d", w.Code, http.StatusConflict)
+	}
+
+	got := &models.User{ID: u.ID}
+	err := s.Users.Fetch(context.Background(), got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name.String != "Test User" {
+		t.Fatalf("the name changed to %q", got.Name.String)
+	}
+}
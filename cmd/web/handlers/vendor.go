@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"net/http"
 	"revelbus/cmd/web/utils"
@@ -28,7 +29,7 @@ func VendorForm(w http.ResponseWriter, r *http.Request) {
 		ID: utils.ToInt(id),
 	}
 
-	err := v.Fetch()
+	err := db.Vendors.Fetch(r.Context(), v)
 	if err != nil {
 		if err == domain.ErrNotFound {
 			view.NotFound(w, r)
@@ -38,28 +39,25 @@ func VendorForm(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	f := &models.VendorForm{
-		ID:      strconv.Itoa(v.ID),
-		Name:    v.Name.String,
-		Address: v.Address.String,
-		City:    v.City.String,
-		State:   v.State.String,
-		Zip:     v.Zip.String,
-		Phone:   v.Phone.String,
-		Email:   v.Email.String,
-		URL:     v.URL.String,
-		Notes:   v.Notes.String,
-		BrandID: int(v.BrandID.Int64),
-		Active:  v.Active,
+	f := vendorForm(v)
+
+	users, err := db.Partners.FetchVendorUsers(r.Context(), v.ID)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
 	}
 
-	if v.Brand != nil {
-		f.Brand = v.Brand.Thumb.String
+	c, err := db.VendorChanges.FindPending(r.Context(), v.ID)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
 	}
 
 	view.Render(w, r, "vendor", &view.View{
-		Title: f.Name,
-		Form:  f,
+		Change: c,
+		Title:  f.Name,
+		Form:   f,
+		Users:  &users,
 	})
 }
 
@@ -72,6 +70,7 @@ func PostVendor(w http.ResponseWriter, r *http.Request) {
 
 	f := &models.VendorForm{
 		ID:      r.PostForm.Get("id"),
+		Version: utils.ToInt(r.PostForm.Get("version")),
 		Name:    r.PostForm.Get("name"),
 		Address: r.PostForm.Get("address"),
 		City:    r.PostForm.Get("city"),
@@ -95,12 +94,14 @@ func PostVendor(w http.ResponseWriter, r *http.Request) {
 		}
 
 		view.Render(w, r, "vendor", v)
+		return
 	}
 
 	var msg string
 
 	v := models.Vendor{
 		ID:      utils.ToInt(f.ID),
+		Version: f.Version,
 		Name:    utils.NewNullStr(f.Name),
 		Address: utils.NewNullStr(f.Address),
 		City:    utils.NewNullStr(f.City),
@@ -119,46 +120,46 @@ func PostVendor(w http.ResponseWriter, r *http.Request) {
 		v.BrandID = sql.NullInt64{}
 	}
 
-	image, err := utils.UploadFile(w, r, "brand_image", "uploads/vendor", true)
-	if err != nil {
-		view.ServerError(w, r, err)
-		return
-	}
-
-	if len(image) > 0 {
-		v.BrandID = utils.NewNullInt(image[0].ID)
-	} else if (f.BrandID != 0) && (len(r.Form["deleteimg"]) == 1) {
-		image := &models.File{
-			ID: f.BrandID,
-		}
-
-		err = utils.DeleteFile(image)
+	// the upload, the old image's removal and the save stand or fall together
+	err = db.InTx(r.Context(), func(ctx context.Context) error {
+		image, err := utils.UploadFile(ctx, r, "brand_image", "uploads/vendor", true)
 		if err != nil {
-			view.ServerError(w, r, err)
-			return
+			return err
 		}
 
-		v.BrandID = sql.NullInt64{}
-	}
+		if len(image) > 0 {
+			v.BrandID = utils.NewNullInt(image[0].ID)
+		} else if (f.BrandID != 0) && (len(r.Form["deleteimg"]) == 1) {
+			image := &models.File{
+				ID: f.BrandID,
+			}
 
-	if v.ID != 0 {
-		err := v.Update()
-		if err != nil {
-			if err == domain.ErrNotFound {
-				view.NotFound(w, r)
-				return
+			err = db.Files.Delete(ctx, image)
+			if err != nil {
+				return err
 			}
-			view.ServerError(w, r, err)
-			return
+
+			v.BrandID = sql.NullInt64{}
 		}
-		msg = utils.MsgSuccessfullyUpdated
-	} else {
-		err := v.Create()
-		if err != nil {
-			view.ServerError(w, r, err)
-			return
+
+		if v.ID != 0 {
+			msg = utils.MsgSuccessfullyUpdated
+			return db.Vendors.Update(ctx, &v)
 		}
+
 		msg = utils.MsgSuccessfullyCreated
+		return db.Vendors.Create(ctx, &v)
+	})
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		} else if err == domain.ErrConflict {
+			vendorConflict(w, r, f)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
 	}
 
 	err = flash.Add(w, r, msg, "success")
@@ -172,8 +173,57 @@ func PostVendor(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/admin/vendor?id="+id, http.StatusSeeOther)
 }
 
+// vendorForm fills in the vendor form from v.
+func vendorForm(v *models.Vendor) *models.VendorForm {
+	f := &models.VendorForm{
+		ID:      strconv.Itoa(v.ID),
+		Version: v.Version,
+		Name:    v.Name.String,
+		Address: v.Address.String,
+		City:    v.City.String,
+		State:   v.State.String,
+		Zip:     v.Zip.String,
+		Phone:   v.Phone.String,
+		Email:   v.Email.String,
+		URL:     v.URL.String,
+		Notes:   v.Notes.String,
+		BrandID: int(v.BrandID.Int64),
+		Active:  v.Active,
+	}
+
+	if v.Brand != nil {
+		f.Brand = v.Brand.Thumb.String
+	}
+	return f
+}
+
+// vendorConflict shows f, which couldn't be saved, next to the vendor as
+// someone else has saved it since.
+func vendorConflict(w http.ResponseWriter, r *http.Request, f *models.VendorForm) {
+	v := &models.Vendor{
+		ID: utils.ToInt(f.ID),
+	}
+
+	err := db.Vendors.Fetch(r.Context(), v)
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
+	}
+
+	saved := vendorForm(v)
+
+	// the logo's thumbnail isn't posted back with the form
+	f.Brand = saved.Brand
+
+	view.Conflict(w, r, models.NewConflict(v.Name.String, "/admin/vendor?id="+f.ID, f, saved))
+}
+
 func ListVendors(w http.ResponseWriter, r *http.Request) {
-	vendors, err := models.FetchVendors(false)
+	vendors, err := db.Vendors.FetchAll(r.Context(), false)
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
@@ -193,46 +243,93 @@ func RemoveVendor(w http.ResponseWriter, r *http.Request) {
 		ID: utils.ToInt(id),
 	}
 
-	err := v.GetBase()
+	// the brand image stays with the vendor in the trash and goes when it's
+	// purged
+	err := db.Vendors.Delete(r.Context(), &v)
 	if err != nil {
 		if err == domain.ErrNotFound {
 			view.NotFound(w, r)
 			return
+		} else if err == domain.ErrCannotDelete {
+			err = flash.Add(w, r, utils.MsgCannotRemove, "warning")
+			if err != nil {
+				view.ServerError(w, r, err)
+				return
+			}
+
+			http.Redirect(w, r, "/admin/vendors", http.StatusSeeOther)
+			return
 		}
 		view.ServerError(w, r, err)
 		return
 	}
 
-	if int(v.BrandID.Int64) != 0 {
-		image := &models.File{
-			ID: int(v.BrandID.Int64),
-		}
+	err = flash.Add(w, r, utils.MsgMovedToTrash, "success")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
 
-		err = utils.DeleteFile(image)
-		if err != nil {
+	http.Redirect(w, r, "/admin/vendors", http.StatusSeeOther)
+}
+
+// LinkVendorUser lets an existing user manage the vendor from the partner
+// portal.
+func LinkVendorUser(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	err := r.ParseForm()
+	if err != nil {
+		view.ClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	u := &models.User{
+		Email: utils.NewNullStr(r.PostForm.Get("email")),
+	}
+
+	msg, status := utils.MsgUserLinked, "success"
+
+	err = db.Users.Fetch(r.Context(), u)
+	if err == domain.ErrNotFound {
+		msg, status = utils.MsgUserNotFound, "warning"
+	} else if err != nil {
+		view.ServerError(w, r, err)
+		return
+	} else {
+		err = db.Partners.Link(r.Context(), utils.ToInt(id), u.ID)
+		if err != nil && err != domain.ErrDuplicate {
 			view.ServerError(w, r, err)
 			return
 		}
 	}
 
-	err = v.Delete()
+	err = flash.Add(w, r, msg, status)
 	if err != nil {
-		if err == domain.ErrCannotDelete {
-			err = flash.Add(w, r, utils.MsgCannotRemove, "warning")
-			if err != nil {
-				view.ServerError(w, r, err)
-				return
-			}
-		}
 		view.ServerError(w, r, err)
 		return
 	}
 
-	err = flash.Add(w, r, utils.MsgSuccessfullyRemoved, "success")
+	http.Redirect(w, r, "/admin/vendor?id="+id, http.StatusSeeOther)
+}
+
+func UnlinkVendorUser(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	uid := vars["uid"]
+
+	err := db.Partners.Unlink(r.Context(), utils.ToInt(id), utils.ToInt(uid))
 	if err != nil {
 		view.ServerError(w, r, err)
 		return
 	}
 
-	http.Redirect(w, r, "/admin/vendors", http.StatusSeeOther)
+	err = flash.Add(w, r, utils.MsgUserUnlinked, "success")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/vendor?id="+id, http.StatusSeeOther)
 }
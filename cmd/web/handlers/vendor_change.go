@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"revelbus/cmd/web/utils"
+	"revelbus/cmd/web/view"
+	"revelbus/internal/platform/domain"
+	"revelbus/internal/platform/domain/models"
+	"revelbus/internal/platform/emails"
+	"revelbus/internal/platform/flash"
+
+	"github.com/gorilla/mux"
+)
+
+func ListVendorChanges(w http.ResponseWriter, r *http.Request) {
+	changes, err := db.VendorChanges.FetchPending(r.Context())
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	view.Render(w, r, "vendor-changes", &view.View{
+		Title:   "Pending Vendor Changes",
+		Changes: changes,
+	})
+}
+
+func ShowVendorChange(w http.ResponseWriter, r *http.Request) {
+	c, v, ok := vendorChange(w, r)
+	if !ok {
+		return
+	}
+
+	view.Render(w, r, "vendor-change", &view.View{
+		Change: c,
+		Title:  v.Name.String,
+		Vendor: v,
+	})
+}
+
+func ApproveVendorChange(w http.ResponseWriter, r *http.Request) {
+	c, v, ok := vendorChange(w, r)
+	if !ok {
+		return
+	}
+
+	err := db.InTx(r.Context(), func(ctx context.Context) error {
+		err := db.VendorChanges.Approve(ctx, c)
+		if err != nil {
+			return err
+		}
+
+		// the old logo can go once the new one is live, if nothing else
+		// shows it
+		if v.BrandID != c.BrandID {
+			return deleteUnused(ctx, v.BrandID)
+		}
+		return nil
+	})
+	if err != nil {
+		if err == domain.ErrNotFound {
+			stale(w, r)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
+	}
+
+	reviewed(w, r, c, utils.MsgVendorChangeApproved)
+}
+
+func RejectVendorChange(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		view.ClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	c, v, ok := vendorChange(w, r)
+	if !ok {
+		return
+	}
+
+	err = db.InTx(r.Context(), func(ctx context.Context) error {
+		err := db.VendorChanges.Reject(ctx, c, r.PostForm.Get("note"))
+		if err != nil {
+			return err
+		}
+
+		// a logo uploaded with the change was never published
+		if c.BrandID != v.BrandID {
+			return deleteUnused(ctx, c.BrandID)
+		}
+		return nil
+	})
+	if err != nil {
+		if err == domain.ErrNotFound {
+			stale(w, r)
+			return
+		}
+		view.ServerError(w, r, err)
+		return
+	}
+
+	reviewed(w, r, c, utils.MsgVendorChangeRejected)
+}
+
+// reviewed lets the partner know what happened to their change and sends the
+// admin back to the queue.
+func reviewed(w http.ResponseWriter, r *http.Request, c *models.VendorChange, msg string) {
+	if c.User.Email.String != "" {
+		err := emails.VendorChangeReviewed(r.Context(), c)
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+	}
+
+	err := flash.Add(w, r, msg, "success")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/vendor-changes", http.StatusSeeOther)
+}
+
+// stale sends the admin back to the queue when the change they looked at was
+// reviewed or replaced by the partner in the meantime.
+func stale(w http.ResponseWriter, r *http.Request) {
+	err := flash.Add(w, r, utils.MsgVendorChangeStale, "warning")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/vendor-changes", http.StatusSeeOther)
+}
+
+// vendorChange loads the change in the URL and the vendor it's for. It writes
+// the response itself when it fails.
+func vendorChange(w http.ResponseWriter, r *http.Request) (*models.VendorChange, *models.Vendor, bool) {
+	vars := mux.Vars(r)
+
+	c := &models.VendorChange{
+		ID: utils.ToInt(vars["id"]),
+	}
+
+	err := db.VendorChanges.Fetch(r.Context(), c)
+	if err == nil {
+		c.Vendor = &models.Vendor{
+			ID: c.VendorID,
+		}
+		err = db.Vendors.Fetch(r.Context(), c.Vendor)
+	}
+
+	if err != nil {
+		if err == domain.ErrNotFound {
+			view.NotFound(w, r)
+			return nil, nil, false
+		}
+		view.ServerError(w, r, err)
+		return nil, nil, false
+	}
+
+	return c, c.Vendor, true
+}
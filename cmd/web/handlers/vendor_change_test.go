@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"revelbus/cmd/web/utils"
+	"revelbus/internal/platform/domain/models"
+	"strconv"
+	"testing"
+)
+
+func TestApproveVendorChangeReplaced(t *testing.T) {
+	s := newStore(t)
+	admin := newUser(t, s, "admin@example.com", models.RoleAdmin)
+
+	v := &models.Vendor{Name: utils.NewNullStr("Acme")}
+	err := s.Vendors.Create(context.Background(), v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reviewed := &models.VendorChange{VendorID: v.ID, Name: utils.NewNullStr("Acme Tours")}
+	err = s.VendorChanges.Submit(context.Background(), reviewed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the partner submits again while the admin is looking at the first
+	newer := &models.VendorChange{VendorID: v.ID, Name: utils.NewNullStr("Not Acme")}
+	err = s.VendorChanges.Submit(context.Background(), newer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	approve := func(c *models.VendorChange) {
+		t.Helper()
+
+		w := request{
+			method:  "POST",
+			target:  "/admin/vendor-change/" + strconv.Itoa(c.ID) + "?approve",
+			vars:    map[string]string{"id": strconv.Itoa(c.ID)},
+			cookies: signIn(t, admin),
+		}.do(ApproveVendorChange)
+		wantRedirect(t, w, "/admin/vendor-changes")
+	}
+
+	approve(reviewed)
+
+	got := &models.Vendor{ID: v.ID}
+	err = s.Vendors.Fetch(context.Background(), got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name.String != "Acme" {
+		t.Fatalf("approving the replaced change published %q", got.Name.String)
+	}
+
+	approve(newer)
+
+	err = s.Vendors.Fetch(context.Background(), got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name.String != "Not Acme" {
+		t.Fatalf("got vendor %q, want the newer change published", got.Name.String)
+	}
+}
+
+func TestApproveVendorChangeSharedLogo(t *testing.T) {
+	s := newStore(t)
+	admin := newUser(t, s, "admin@example.com", models.RoleAdmin)
+
+	newFile := func(name string) *models.File {
+		t.Helper()
+
+		f := &models.File{Name: utils.NewNullStr(name)}
+		err := s.Files.Create(context.Background(), f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+
+	shared, old, logo := newFile("shared.png"), newFile("old.png"), newFile("logo.png")
+
+	approve := func(v *models.Vendor) {
+		t.Helper()
+
+		c := &models.VendorChange{VendorID: v.ID, Name: v.Name, BrandID: utils.NewNullInt(logo.ID)}
+		err := s.VendorChanges.Submit(context.Background(), c)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := request{
+			method:  "POST",
+			target:  "/admin/vendor-change/" + strconv.Itoa(c.ID) + "?approve",
+			vars:    map[string]string{"id": strconv.Itoa(c.ID)},
+			cookies: signIn(t, admin),
+		}.do(ApproveVendorChange)
+		wantRedirect(t, w, "/admin/vendor-changes")
+	}
+
+	// a trip also shows the first vendor's old logo
+	trip := &models.Trip{Title: utils.NewNullStr("Day Out"), ImageID: utils.NewNullInt(shared.ID)}
+	err := s.Trips.Create(context.Background(), trip)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range []*models.File{shared, old} {
+		v := &models.Vendor{Name: utils.NewNullStr(f.Name.String), BrandID: utils.NewNullInt(f.ID)}
+		err = s.Vendors.Create(context.Background(), v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		approve(v)
+	}
+
+	err = s.Files.Fetch(context.Background(), &models.File{ID: shared.ID})
+	if err != nil {
+		t.Fatalf("the logo the trip uses was deleted: %v", err)
+	}
+
+	err = s.Files.Fetch(context.Background(), &models.File{ID: old.ID})
+	if err == nil {
+		t.Fatal("the logo nothing uses was kept")
+	}
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"revelbus/cmd/web/utils"
+	"revelbus/cmd/web/view"
+	"revelbus/internal/platform/domain"
+	"revelbus/internal/platform/domain/models"
+	"revelbus/internal/platform/emails"
+	"revelbus/internal/platform/flash"
+	"revelbus/internal/platform/ratelimit"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+var (
+	verifyLimiter     *ratelimit.Limiter
+	verifyLimiterOnce sync.Once
+)
+
+// getVerifyLimiter caps how often a user can have the verification email
+// resent, by default 3 an hour.
+func getVerifyLimiter() *ratelimit.Limiter {
+	verifyLimiterOnce.Do(func() {
+		max := viper.GetInt("verification.per_hour")
+		if max <= 0 {
+			max = 3
+		}
+		verifyLimiter = ratelimit.New(max, time.Hour)
+	})
+	return verifyLimiter
+}
+
+func sendVerification(ctx context.Context, u *models.User) error {
+	v, token, err := db.Verifications.Create(ctx, u, u.Email.String)
+	if err != nil {
+		return err
+	}
+
+	err = emails.VerifyEmail(ctx, u, v.ID, token)
+	return err
+}
+
+func VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	v, err := db.Verifications.Find(r.Context(), utils.ToInt(r.FormValue("id")), r.FormValue("token"))
+	if err == domain.ErrInvalidCredentials {
+		invalidVerification(w, r, utils.MsgInvalidVerificationLink)
+		return
+	} else if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	owner := &models.User{
+		ID: v.UserID,
+	}
+
+	err = db.Users.Fetch(r.Context(), owner)
+	if err == domain.ErrNotFound {
+		invalidVerification(w, r, utils.MsgInvalidVerificationLink)
+		return
+	} else if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	old := owner.Email.String
+	change := v.IsChange(owner)
+
+	err = db.InTx(r.Context(), func(ctx context.Context) error {
+		err := db.Verifications.Redeem(ctx, v)
+		if err != nil || !change {
+			return err
+		}
+
+		// the alert goes to the old address, since that's the one that would
+		// want to know if this wasn't its owner
+		e, err := logSecurity(r.WithContext(ctx), owner, models.SecurityEmailChanged, old)
+		if err != nil {
+			return err
+		}
+
+		return emails.SecurityAlert(ctx, old, e)
+	})
+	if err == domain.ErrInvalidCredentials {
+		invalidVerification(w, r, utils.MsgInvalidVerificationLink)
+		return
+	} else if err == domain.ErrDuplicateEmail {
+		invalidVerification(w, r, utils.MsgEmailTaken)
+		return
+	} else if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	msg := utils.MsgEmailVerified
+	if change {
+		msg = utils.MsgEmailChanged
+	}
+
+	err = flash.Add(w, r, msg, "success")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	u, err := utils.IsAuthenticated(r)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	if u == nil {
+		http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, "/u/", http.StatusSeeOther)
+}
+
+func invalidVerification(w http.ResponseWriter, r *http.Request, msg string) {
+	err := flash.Add(w, r, msg, "danger")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func PostResendVerification(w http.ResponseWriter, r *http.Request) {
+	u, err := utils.IsAuthenticated(r)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	if u.IsVerified() {
+		http.Redirect(w, r, "/u/", http.StatusSeeOther)
+		return
+	}
+
+	if !getVerifyLimiter().Allow(strconv.Itoa(u.ID)) {
+		err = flash.Add(w, r, utils.MsgTooManyVerifications, "warning")
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+
+		http.Redirect(w, r, "/u/", http.StatusSeeOther)
+		return
+	}
+
+	err = sendVerification(r.Context(), u)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	err = flash.Add(w, r, utils.MsgVerificationSent, "success")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/u/", http.StatusSeeOther)
+}
@@ -4,9 +4,20 @@ import (
 	"net/http"
 	"revelbus/cmd/web/utils"
 	"revelbus/cmd/web/view"
+	"revelbus/internal/platform/audit"
+	"revelbus/internal/platform/domain/models"
 	"revelbus/internal/platform/flash"
+	"strings"
+
+	"github.com/urfave/negroni"
 )
 
+// LoadUser keeps the signed in user in the request's context, so the
+// middleware and handlers after it share one lookup.
+func LoadUser(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	next(w, utils.WithUser(r))
+}
+
 func RequireLogin(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 	u, err := utils.IsAuthenticated(r)
 	if err != nil {
@@ -24,9 +35,11 @@ func RequireLogin(w http.ResponseWriter, r *http.Request, next http.HandlerFunc)
 		http.Redirect(w, r, "/auth/login", 302)
 		return
 	}
-	next(w, r)
+	next(w, withActor(r, u))
 }
 
+// RequireAdmin lets anyone with a staff role into the admin. What they can do
+// once they're in is up to RequirePermission.
 func RequireAdmin(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 	u, err := utils.IsAuthenticated(r)
 	if err != nil {
@@ -43,19 +56,125 @@ func RequireAdmin(w http.ResponseWriter, r *http.Request, next http.HandlerFunc)
 
 		http.Redirect(w, r, "/auth/login", 302)
 		return
-	} else if u.Role.String != "admin" {
+	} else if !u.IsStaff() {
 		err = flash.Add(w, r, utils.MsgMustBeAdmin, "warning")
 		if err != nil {
 			view.ServerError(w, r, err)
 			return
 		}
 
+		http.Redirect(w, r, "/u", 302)
+		return
+	} else if !u.TOTPEnabled {
+		require, err := utils.RequireAdmin2FA(r)
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+
+		if require {
+			err = flash.Add(w, r, utils.MsgTwoFactorRequired, "warning")
+			if err != nil {
+				view.ServerError(w, r, err)
+				return
+			}
+
+			http.Redirect(w, r, "/u/two-factor", 302)
+			return
+		}
+	}
+	next(w, withActor(r, u))
+}
+
+// RequireVerified keeps users who haven't confirmed their email address away
+// from anything that would send them mail or commit them to a trip.
+func RequireVerified(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	u, err := utils.IsAuthenticated(r)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	if u != nil && !u.IsVerified() {
+		err = flash.Add(w, r, utils.MsgMustVerifyEmail, "warning")
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+
 		http.Redirect(w, r, "/u", 302)
 		return
 	}
 	next(w, r)
 }
 
+// RequirePartner lets in users linked to at least one vendor. Which vendors
+// they can see is checked by the partner handlers.
+func RequirePartner(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	u, err := utils.IsAuthenticated(r)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	if u == nil {
+		err = flash.Add(w, r, utils.MsgMustBeLoggedIn, "warning")
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+
+		http.Redirect(w, r, "/auth/login", 302)
+		return
+	}
+
+	if !u.Partner {
+		err = flash.Add(w, r, utils.MsgNotPermitted, "warning")
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+
+		http.Redirect(w, r, "/u", 302)
+		return
+	}
+	next(w, withActor(r, u))
+}
+
+// RequirePermission only lets through users whose role grants at least one
+// of perms. It goes after RequireAdmin, so the user is already known.
+func RequirePermission(perms ...string) negroni.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		u, err := utils.IsAuthenticated(r)
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+
+		if u != nil {
+			for _, p := range perms {
+				if u.Can(p) {
+					next(w, r)
+					return
+				}
+			}
+		}
+
+		err = flash.Add(w, r, utils.MsgNotPermitted, "warning")
+		if err != nil {
+			view.ServerError(w, r, err)
+			return
+		}
+
+		http.Redirect(w, r, "/admin", 302)
+	}
+}
+
+// withActor credits changes made while handling r to u in the audit log.
+func withActor(r *http.Request, u *models.User) *http.Request {
+	return r.WithContext(audit.WithActor(r.Context(), u, utils.ClientIP(r)))
+}
+
 func RequireGuest(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 	u, err := utils.IsAuthenticated(r)
 	if err != nil {
@@ -75,3 +194,55 @@ func RequireGuest(w http.ResponseWriter, r *http.Request, next http.HandlerFunc)
 	}
 	next(w, r)
 }
+
+// RestrictImpersonation keeps an admin viewing the site as someone else to
+// looking around. They can't change the user's account, book or cancel for
+// them, or use the admin until they go back to being themselves.
+func RestrictImpersonation(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	u, err := utils.IsAuthenticated(r)
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	if u == nil || u.Impersonator == nil || impersonationAllowed(r) {
+		next(w, r)
+		return
+	}
+
+	err = flash.Add(w, r, utils.MsgImpersonationRestricted, "warning")
+	if err != nil {
+		view.ServerError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/u", 302)
+}
+
+// impersonationAllowed is true for pages that only show things, and for
+// going back to being themselves. GET links that do something, like an
+// export, carry a query string.
+func impersonationAllowed(r *http.Request) bool {
+	if r.URL.Path == "/u/impersonate" {
+		return true
+	}
+
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return false
+	}
+
+	switch r.URL.Path {
+	case "/u/logout":
+		return true
+	case "/u/two-factor", "/u/two-factor/qr.png":
+		return false
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/admin") {
+		return false
+	}
+
+	q := r.URL.Query()
+	delete(q, "trips")
+	return len(q) == 0
+}
@@ -5,6 +5,7 @@ import (
 	"revelbus/cmd/web/handlers"
 	"revelbus/cmd/web/middleware"
 	"revelbus/cmd/web/view"
+	"revelbus/internal/platform/domain/models"
 
 	"github.com/spf13/viper"
 
@@ -22,10 +23,18 @@ func Routes() http.Handler {
 	r.HandleFunc("/contact-us", handlers.Contact).Methods("GET")
 	r.HandleFunc("/contact", handlers.ContactPost).Methods("POST")
 
+	r.HandleFunc("/subscribe", handlers.PostSubscribe).Methods("POST")
+	r.HandleFunc("/subscribe/confirm", handlers.ConfirmSubscription).Methods("GET")
+	r.HandleFunc("/subscribe/preferences", handlers.SubscriptionPreferences).Methods("GET")
+	r.HandleFunc("/subscribe/preferences", handlers.PostSubscriptionPreferences).Methods("POST")
+	r.HandleFunc("/unsubscribe", handlers.Unsubscribe).Methods("GET")
+
 	r.HandleFunc("/ical/{slug}.ics", handlers.Ical).Methods("GET")
+	r.HandleFunc("/health", handlers.Health).Methods("GET")
+	r.HandleFunc("/verify", handlers.VerifyEmail).Queries("id", "{id}").Queries("token", "{token}").Methods("GET")
 
 	auth := r.PathPrefix("/auth").Subrouter()
-	auth.HandleFunc("/recover", handlers.ResetPasswordForm).Queries("email", "{email}").Queries("hash", "{hash}").Methods("GET")
+	auth.HandleFunc("/recover", handlers.ResetPasswordForm).Queries("id", "{id}").Queries("token", "{token}").Methods("GET")
 	auth.HandleFunc("/reset", handlers.PostPasswordReset).Methods("POST")
 	auth.HandleFunc("/forgot", handlers.ForgotPasswordForm).Methods("GET")
 	auth.HandleFunc("/forgot", handlers.PostForgotPassword).Methods("POST")
@@ -33,6 +42,10 @@ func Routes() http.Handler {
 	auth.HandleFunc("/signup", handlers.PostSignup).Methods("POST")
 	auth.HandleFunc("/login", handlers.LoginForm).Methods("GET")
 	auth.HandleFunc("/login", handlers.PostLogin).Methods("POST")
+	auth.HandleFunc("/oidc/callback", handlers.OIDCCallback).Methods("GET")
+	auth.HandleFunc("/oidc", handlers.OIDCLogin).Methods("GET")
+	auth.HandleFunc("/two-factor", handlers.TwoFactorLoginForm).Methods("GET")
+	auth.HandleFunc("/two-factor", handlers.PostTwoFactorLogin).Methods("POST")
 
 	user := r.PathPrefix("/u").Subrouter()
 	user.HandleFunc("/", handlers.UserDashboard).Methods("GET")
@@ -40,62 +53,152 @@ func Routes() http.Handler {
 	user.HandleFunc("/profile", handlers.PostProfile).Methods("POST")
 	user.HandleFunc("/password", handlers.PasswordForm).Methods("GET")
 	user.HandleFunc("/password", handlers.PostPassword).Methods("POST")
+	user.HandleFunc("/two-factor/qr.png", handlers.TwoFactorQR).Methods("GET")
+	user.HandleFunc("/two-factor", handlers.PostRecoveryCodes).Queries("codes", "").Methods("POST")
+	user.HandleFunc("/two-factor", handlers.PostDisableTwoFactor).Queries("disable", "").Methods("POST")
+	user.HandleFunc("/two-factor", handlers.TwoFactorForm).Methods("GET")
+	user.HandleFunc("/two-factor", handlers.PostTwoFactor).Methods("POST")
+	user.HandleFunc("/security", handlers.SecurityLog).Methods("GET")
+	user.HandleFunc("/session/{id}", handlers.RevokeSession).Queries("revoke", "").Methods("POST")
+	user.HandleFunc("/booking/{id}", handlers.CancelBooking).Queries("cancel", "").Methods("GET")
+	user.Handle("/booking", verified(handlers.PostBooking)).Methods("POST")
+	user.HandleFunc("/verify", handlers.PostResendVerification).Methods("POST")
+	user.HandleFunc("/impersonate", handlers.StopImpersonating).Queries("stop", "").Methods("POST")
 	user.HandleFunc("/logout", handlers.Logout).Methods("GET")
 
+	partner := r.PathPrefix("/partner").Subrouter()
+	partner.HandleFunc("/", handlers.PartnerDashboard).Methods("GET")
+	partner.HandleFunc("/vendor/{id}", handlers.PartnerTrips).Queries("trips", "").Methods("GET")
+	partner.HandleFunc("/vendor/{id}", handlers.PartnerVendorForm).Methods("GET")
+	partner.HandleFunc("/vendor/{id}", handlers.PostPartnerVendor).Methods("POST")
+
 	admin := r.PathPrefix("/admin").Subrouter()
 	admin.HandleFunc("/", handlers.AdminDashboard).Methods("GET")
 
-	admin.HandleFunc("/settings", handlers.SettingsForm).Methods("GET")
-	admin.HandleFunc("/settings", handlers.PostSettings).Methods("POST")
+	settings := guard(admin, models.PermSettings)
+	settings.HandleFunc("/settings", handlers.SettingsForm).Methods("GET")
+	settings.HandleFunc("/settings", handlers.PostSettings).Methods("POST")
+
+	settings.HandleFunc("/mail/{id}", handlers.ResendEmail).Queries("resend", "").Methods("POST")
+	settings.HandleFunc("/mail", handlers.ListFailedEmails).Methods("GET")
+	settings.HandleFunc("/mailbox", handlers.Mailbox).Methods("GET")
+	settings.HandleFunc("/health", handlers.HealthDetails).Methods("GET")
+
+	content := guard(admin, models.PermContent)
+	content.HandleFunc("/file/{id}", handlers.RemoveFile).Queries("remove", "").Methods("POST")
+	content.HandleFunc("/files", handlers.ListFiles).Methods("GET")
+	content.HandleFunc("/upload", handlers.UploadForm).Methods("GET")
+	content.HandleFunc("/upload", handlers.PostUpload).Methods("POST")
+
+	// contact inbox
+	inbox := guard(admin, models.PermMessages)
+	inbox.HandleFunc("/message/{id}", handlers.ArchiveMessage).Queries("archive", "").Methods("POST")
+	inbox.HandleFunc("/message/{id}", handlers.UnreadMessage).Queries("unread", "").Methods("POST")
+	inbox.HandleFunc("/message/{id}", handlers.RestoreMessage).Queries("restore", "").Methods("POST")
+	inbox.HandleFunc("/message/{id}", handlers.RemoveMessage).Queries("remove", "").Methods("POST")
+	inbox.HandleFunc("/message/{id}", handlers.ShowMessage).Methods("GET")
+	inbox.HandleFunc("/message/{id}", handlers.PostReply).Methods("POST")
+	inbox.HandleFunc("/messages", handlers.ListMessages).Methods("GET")
+
+	// audit log
+	history := guard(admin, models.PermAudit)
+	history.HandleFunc("/trip/{id}", handlers.TripHistory).Queries("history", "").Methods("GET")
+	history.HandleFunc("/audit", handlers.ListAuditLog).Methods("GET")
 
-	admin.HandleFunc("/file/{id}", handlers.RemoveFile).Queries("remove", "").Methods("GET")
-	admin.HandleFunc("/files", handlers.ListFiles).Methods("GET")
-	admin.HandleFunc("/upload", handlers.UploadForm).Methods("GET")
-	admin.HandleFunc("/upload", handlers.PostUpload).Methods("POST")
+	// trash
+	bin := guard(admin, models.PermTrips, models.PermVendors, models.PermFAQs, models.PermContent, models.PermUsers)
+	bin.HandleFunc("/trash/{type}/{id}", handlers.RestoreTrash).Queries("restore", "").Methods("POST")
+	bin.HandleFunc("/trash/{type}/{id}", handlers.PurgeTrash).Queries("purge", "").Methods("POST")
+	bin.HandleFunc("/trash", handlers.ListTrash).Methods("GET")
+
+	// content revisions
+	revisions := guard(admin, models.PermTrips, models.PermFAQs, models.PermContent, models.PermSettings)
+	revisions.HandleFunc("/revision/{id}", handlers.RevertRevision).Queries("revert", "").Methods("POST")
+	revisions.HandleFunc("/revision/{id}", handlers.ShowRevision).Methods("GET")
+	revisions.HandleFunc("/revisions/{type}/{id}", handlers.ListRevisions).Methods("GET")
+
+	// rider manifests
+	manifests := guard(admin, models.PermManifests)
+	checkin := guard(admin, models.PermCheckIn)
+	checkin.HandleFunc("/trip/{id}", handlers.CheckInRider).Queries("checkin", "{bid}").Methods("GET")
+	checkin.HandleFunc("/trip/{id}", handlers.UndoCheckIn).Queries("uncheck", "{bid}").Methods("GET")
+	manifests.HandleFunc("/trip/{id}", handlers.TripRiders).Queries("riders", "").Methods("GET")
 
 	// trip funcs
-	admin.HandleFunc("/trip/{id}", handlers.UpdateVenueStatus).Queries("venue", "{vid}").Queries("is_primary", "{is_primary}").Methods("GET")
-	admin.HandleFunc("/trip/{id}", handlers.AttachVendor).Queries("vendor", "").Methods("POST")
-	admin.HandleFunc("/trip/{id}", handlers.DetachVendor).Queries("vendor", "{vid}").Queries("role", "{role}").Methods("GET")
-	admin.HandleFunc("/trip/{id}", handlers.TripVenues).Queries("venues", "").Methods("GET")
-	admin.HandleFunc("/trip/{id}", handlers.TripPartners).Queries("partners", "").Methods("GET")
+	trips := guard(admin, models.PermTrips)
+	trips.HandleFunc("/trip/{id}", handlers.UpdateVenueStatus).Queries("venue", "{vid}").Queries("is_primary", "{is_primary}").Methods("GET")
+	trips.HandleFunc("/trip/{id}", handlers.AttachVendor).Queries("vendor", "").Methods("POST")
+	trips.HandleFunc("/trip/{id}", handlers.DetachVendor).Queries("vendor", "{vid}").Queries("role", "{role}").Methods("GET")
+	trips.HandleFunc("/trip/{id}", handlers.TripVenues).Queries("venues", "").Methods("GET")
+	trips.HandleFunc("/trip/{id}", handlers.TripPartners).Queries("partners", "").Methods("GET")
 
 	// trip crud
-	admin.HandleFunc("/trip/{id}", handlers.RemoveTrip).Queries("remove", "").Methods("GET")
-	admin.HandleFunc("/trip", handlers.TripForm).Methods("GET")
-	admin.HandleFunc("/trip", handlers.PostTrip).Methods("POST")
-	admin.HandleFunc("/trips", handlers.ListTrips).Methods("GET")
+	trips.HandleFunc("/trip/{id}", handlers.RemoveTrip).Queries("remove", "").Methods("POST")
+	trips.HandleFunc("/trip", handlers.TripForm).Methods("GET")
+	trips.HandleFunc("/trip", handlers.PostTrip).Methods("POST")
+	guard(admin, models.PermTrips, models.PermManifests).HandleFunc("/trips", handlers.ListTrips).Methods("GET")
 
 	// vendor crud
-	admin.HandleFunc("/vendor/{id}", handlers.RemoveVendor).Queries("remove", "").Methods("GET")
-	admin.HandleFunc("/vendor", handlers.VendorForm).Methods("GET")
-	admin.HandleFunc("/vendor", handlers.PostVendor).Methods("POST")
-	admin.HandleFunc("/vendors", handlers.ListVendors).Methods("GET")
+	vendors := guard(admin, models.PermVendors)
+	vendors.HandleFunc("/vendor/{id}", handlers.LinkVendorUser).Queries("link", "").Methods("POST")
+	vendors.HandleFunc("/vendor/{id}", handlers.UnlinkVendorUser).Queries("unlink", "{uid}").Methods("GET")
+	vendors.HandleFunc("/vendor/{id}", handlers.RemoveVendor).Queries("remove", "").Methods("POST")
+	vendors.HandleFunc("/vendor", handlers.VendorForm).Methods("GET")
+	vendors.HandleFunc("/vendor", handlers.PostVendor).Methods("POST")
+	vendors.HandleFunc("/vendors", handlers.ListVendors).Methods("GET")
+
+	// partner edits waiting on review
+	vendors.HandleFunc("/vendor-change/{id}", handlers.ApproveVendorChange).Queries("approve", "").Methods("POST")
+	vendors.HandleFunc("/vendor-change/{id}", handlers.RejectVendorChange).Queries("reject", "").Methods("POST")
+	vendors.HandleFunc("/vendor-change/{id}", handlers.ShowVendorChange).Methods("GET")
+	vendors.HandleFunc("/vendor-changes", handlers.ListVendorChanges).Methods("GET")
 
 	// faq crud
-	admin.HandleFunc("/faq/{id}", handlers.RemoveFAQ).Queries("remove", "").Methods("GET")
-	admin.HandleFunc("/faq", handlers.FaqForm).Methods("GET")
-	admin.HandleFunc("/faq", handlers.PostFAQ).Methods("POST")
-	admin.HandleFunc("/faqs", handlers.ListFAQs).Methods("GET")
+	faqs := guard(admin, models.PermFAQs)
+	faqs.HandleFunc("/faq/{id}", handlers.RemoveFAQ).Queries("remove", "").Methods("POST")
+	faqs.HandleFunc("/faq", handlers.FaqForm).Methods("GET")
+	faqs.HandleFunc("/faq", handlers.PostFAQ).Methods("POST")
+	faqs.HandleFunc("/faqs", handlers.ListFAQs).Methods("GET")
 
 	// gallery crud
-	admin.HandleFunc("/gallery/{id}", handlers.DetachImage).Queries("file", "{fid}").Methods("GET")
-	admin.HandleFunc("/gallery/{id}", handlers.RemoveGallery).Queries("remove", "").Methods("GET")
-	admin.HandleFunc("/gallery", handlers.GalleryForm).Methods("GET")
-	admin.HandleFunc("/gallery", handlers.PostGallery).Methods("POST")
-	admin.HandleFunc("/galleries", handlers.ListGalleries).Methods("GET")
+	content.HandleFunc("/gallery/{id}", handlers.DetachImage).Queries("file", "{fid}").Methods("GET")
+	content.HandleFunc("/gallery/{id}", handlers.RemoveGallery).Queries("remove", "").Methods("POST")
+	content.HandleFunc("/gallery", handlers.GalleryForm).Methods("GET")
+	content.HandleFunc("/gallery", handlers.PostGallery).Methods("POST")
+	content.HandleFunc("/galleries", handlers.ListGalleries).Methods("GET")
 
 	// slide crud
-	admin.HandleFunc("/slide/{id}", handlers.RemoveSlide).Queries("remove", "").Methods("GET")
-	admin.HandleFunc("/slide", handlers.SlideForm).Methods("GET")
-	admin.HandleFunc("/slide", handlers.PostSlide).Methods("POST")
-	admin.HandleFunc("/slides", handlers.ListSlides).Methods("GET")
+	content.HandleFunc("/slide/{id}", handlers.RemoveSlide).Queries("remove", "").Methods("POST")
+	content.HandleFunc("/slide", handlers.SlideForm).Methods("GET")
+	content.HandleFunc("/slide", handlers.PostSlide).Methods("POST")
+	content.HandleFunc("/slides", handlers.ListSlides).Methods("GET")
+
+	// mailing list
+	subscribers := guard(admin, models.PermSubscribers)
+	subscribers.HandleFunc("/subscriber/{id}", handlers.RemoveSubscriber).Queries("remove", "").Methods("POST")
+	subscribers.HandleFunc("/subscribers", handlers.ExportSubscribers).Queries("export", "").Methods("GET")
+	subscribers.HandleFunc("/subscribers", handlers.ListSubscribers).Methods("GET")
+	subscribers.HandleFunc("/subscribers", handlers.ImportSubscribers).Methods("POST")
+	subscribers.HandleFunc("/newsletter", handlers.NewsletterForm).Methods("GET")
+	subscribers.HandleFunc("/newsletter", handlers.PostNewsletter).Methods("POST")
 
 	//user crud
-	admin.HandleFunc("/user/{id}", handlers.RemoveUser).Queries("remove", "").Methods("GET")
-	admin.HandleFunc("/user", handlers.UserForm).Methods("GET")
-	admin.HandleFunc("/user", handlers.PostUser).Methods("POST")
-	admin.HandleFunc("/users", handlers.ListUsers).Methods("GET")
+	users := guard(admin, models.PermUsers)
+	users.HandleFunc("/user/{id}", handlers.RevokeUserSession).Queries("session", "{sid}").Methods("POST")
+	users.HandleFunc("/user/{id}", handlers.RevokeUserSessions).Queries("signout", "").Methods("POST")
+	users.HandleFunc("/user/{id}", handlers.UnlockUser).Queries("unlock", "").Methods("POST")
+	users.HandleFunc("/user/{id}", handlers.Impersonate).Queries("impersonate", "").Methods("POST")
+	users.HandleFunc("/user/{id}", handlers.RemoveUser).Queries("remove", "").Methods("POST")
+	users.HandleFunc("/user", handlers.UserForm).Methods("GET")
+	users.HandleFunc("/user", handlers.PostUser).Methods("POST")
+	users.HandleFunc("/users", handlers.ListLockedUsers).Queries("locked", "").Methods("GET")
+	users.HandleFunc("/users", handlers.ListUsers).Methods("GET")
+
+	// role crud
+	users.HandleFunc("/role/{id}", handlers.RemoveRole).Queries("remove", "").Methods("POST")
+	users.HandleFunc("/role", handlers.RoleForm).Methods("GET")
+	users.HandleFunc("/role", handlers.PostRole).Methods("POST")
+	users.HandleFunc("/roles", handlers.ListRoles).Methods("GET")
 
 	fs := http.FileServer(http.Dir(viper.GetString("files.static")))
 	r.PathPrefix("/assets/").Handler(http.StripPrefix("/assets/", fs))
@@ -106,11 +209,19 @@ func Routes() http.Handler {
 	sirMuxalot.Handle("/", r)
 
 	sirMuxalot.Handle("/u/", negroni.New(
+		negroni.HandlerFunc(middleware.RestrictImpersonation),
 		negroni.HandlerFunc(middleware.RequireLogin),
 		negroni.Wrap(r),
 	))
 
+	sirMuxalot.Handle("/partner/", negroni.New(
+		negroni.HandlerFunc(middleware.RestrictImpersonation),
+		negroni.HandlerFunc(middleware.RequirePartner),
+		negroni.Wrap(r),
+	))
+
 	sirMuxalot.Handle("/admin/", negroni.New(
+		negroni.HandlerFunc(middleware.RestrictImpersonation),
 		negroni.HandlerFunc(middleware.RequireAdmin),
 		negroni.Wrap(r),
 	))
@@ -120,7 +231,33 @@ func Routes() http.Handler {
 		negroni.Wrap(r),
 	))
 
-	n := negroni.New()
+	n := negroni.New(negroni.HandlerFunc(middleware.LoadUser))
 	n.UseHandler(sirMuxalot)
 	return middleware.SecureHeaders(middleware.NoSurf(n))
 }
+
+// guarded registers routes that are only open to users with one of perms.
+type guarded struct {
+	router *mux.Router
+	perms  []string
+}
+
+func guard(router *mux.Router, perms ...string) guarded {
+	return guarded{router, perms}
+}
+
+func (g guarded) HandleFunc(path string, h http.HandlerFunc) *mux.Route {
+	return g.router.Handle(path, negroni.New(
+		negroni.HandlerFunc(middleware.RequirePermission(g.perms...)),
+		negroni.WrapFunc(h),
+	))
+}
+
+// verified wraps a handler for an action only users with a confirmed email
+// address can take.
+func verified(h http.HandlerFunc) http.Handler {
+	return negroni.New(
+		negroni.HandlerFunc(middleware.RequireVerified),
+		negroni.WrapFunc(h),
+	)
+}
@@ -1,40 +1,142 @@
 package utils
 
 import (
+	"context"
 	"net/http"
+	"revelbus/internal/platform/domain"
 	"revelbus/internal/platform/domain/models"
 	"revelbus/pkg/sessions"
+	"time"
 )
 
+const (
+	sessionKey      = "SessionToken"
+	pendingKey      = "PendingLogin"
+	pendingAtKey    = "PendingLoginAt"
+	pendingLifetime = 5 * time.Minute
+
+	impersonatingKey   = "Impersonating"
+	impersonationIDKey = "ImpersonationID"
+)
+
+type userKey struct{}
+
+// signedIn is who's signed in for the rest of a request, once it's been
+// looked up.
+type signedIn struct {
+	u      *models.User
+	loaded bool
+}
+
+// WithUser gives r somewhere to keep the signed in user, so they're only
+// looked up once however many times IsAuthenticated is called for it.
+func WithUser(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), userKey{}, &signedIn{}))
+}
+
+// forgetUser has the next IsAuthenticated look the user up again, after the
+// request has changed who's signed in.
+func forgetUser(r *http.Request) {
+	if s, ok := r.Context().Value(userKey{}).(*signedIn); ok {
+		*s = signedIn{}
+	}
+}
+
+// IsAuthenticated returns the signed in user, loaded fresh from the store for
+// each request so a revoked session or changed role applies straight away.
 func IsAuthenticated(r *http.Request) (*models.User, error) {
-	sesh := sessions.GetSession()
+	s, ok := r.Context().Value(userKey{}).(*signedIn)
+	if !ok {
+		return findUser(r)
+	}
 
-	s := sesh.Load(r)
-	loggedIn, err := s.Exists("AuthUser")
+	if !s.loaded {
+		u, err := findUser(r)
+		if err != nil {
+			return nil, err
+		}
+		*s = signedIn{u: u, loaded: true}
+	}
+	return s.u, nil
+}
+
+func findUser(r *http.Request) (*models.User, error) {
+	token, err := CurrentSessionToken(r)
 	if err != nil {
 		return nil, err
 	}
 
-	if !loggedIn {
+	if token == "" {
+		return nil, nil
+	}
+
+	u, err := db.Sessions.FindUser(r.Context(), token, ClientIP(r))
+	if err == domain.ErrNotFound {
 		return nil, nil
+	} else if err != nil {
+		return nil, err
 	}
 
-	u := &models.User{}
-	err = s.GetObject("AuthUser", u)
+	id, err := sessions.GetSession().Load(r).GetInt(impersonatingKey)
 	if err != nil {
 		return nil, err
 	}
-	return u, nil
+
+	// an admin who loses access to users stops seeing the site as one
+	if id == 0 || !u.Can(models.PermUsers) {
+		return u, nil
+	}
+
+	target, err := db.Impersonations.FindUser(r.Context(), id)
+	if err == domain.ErrNotFound {
+		return u, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	target.Impersonator = u
+	return target, nil
+}
+
+// RequireAdmin2FA reports whether the site settings make staff turn on 2FA
+// before they can use the admin.
+func RequireAdmin2FA(r *http.Request) (bool, error) {
+	return db.Settings.RequireAdmin2FA(r.Context())
+}
+
+func CurrentSessionToken(r *http.Request) (string, error) {
+	sesh := sessions.GetSession()
+
+	s := sesh.Load(r)
+	return s.GetString(sessionKey)
 }
 
 func SetUserSession(w http.ResponseWriter, r *http.Request, u *models.User) error {
 	sesh := sessions.GetSession()
 
 	s := sesh.Load(r)
-	err := s.PutObject(w, "AuthUser", u)
+	err := s.RenewToken(w)
 	if err != nil {
 		return err
 	}
+
+	us := &models.Session{
+		UserID:    u.ID,
+		UserAgent: NewNullStr(r.UserAgent()),
+		IP:        NewNullStr(ClientIP(r)),
+	}
+
+	err = db.Sessions.Create(r.Context(), us)
+	if err != nil {
+		return err
+	}
+
+	err = s.PutString(w, sessionKey, us.Token)
+	if err != nil {
+		return err
+	}
+
+	forgetUser(r)
 	return nil
 }
 
@@ -42,6 +144,139 @@ func RemoveUserSession(w http.ResponseWriter, r *http.Request) error {
 	sesh := sessions.GetSession()
 
 	s := sesh.Load(r)
-	err := s.Remove(w, "AuthUser")
+	token, err := s.GetString(sessionKey)
+	if err != nil {
+		return err
+	}
+
+	if token != "" {
+		err = db.Sessions.Delete(r.Context(), token)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = s.Remove(w, sessionKey)
+	forgetUser(r)
 	return err
 }
+
+// SetPendingLogin remembers a user who got their password right but still
+// has to pass the second factor.
+func SetPendingLogin(w http.ResponseWriter, r *http.Request, u *models.User) error {
+	sesh := sessions.GetSession()
+
+	s := sesh.Load(r)
+	err := s.RenewToken(w)
+	if err != nil {
+		return err
+	}
+
+	err = s.PutInt(w, pendingKey, u.ID)
+	if err != nil {
+		return err
+	}
+
+	err = s.PutTime(w, pendingAtKey, time.Now())
+	return err
+}
+
+// PendingLogin returns the user waiting on their second factor, or nil if
+// there isn't one or they took too long.
+func PendingLogin(r *http.Request) (*models.User, error) {
+	sesh := sessions.GetSession()
+
+	s := sesh.Load(r)
+	id, err := s.GetInt(pendingKey)
+	if err != nil || id == 0 {
+		return nil, err
+	}
+
+	at, err := s.GetTime(pendingAtKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if time.Since(at) > pendingLifetime {
+		return nil, nil
+	}
+
+	u := &models.User{
+		ID: id,
+	}
+
+	err = db.Users.Fetch(r.Context(), u)
+	if err == domain.ErrNotFound {
+		return nil, nil
+	}
+	return u, err
+}
+
+func ClearPendingLogin(w http.ResponseWriter, r *http.Request) error {
+	sesh := sessions.GetSession()
+
+	s := sesh.Load(r)
+	err := s.Remove(w, pendingKey)
+	if err != nil {
+		return err
+	}
+
+	err = s.Remove(w, pendingAtKey)
+	return err
+}
+
+// StartImpersonating has the admin see the site as the user until they stop.
+// The admin stays signed in as themselves underneath.
+func StartImpersonating(w http.ResponseWriter, r *http.Request, admin *models.User, u *models.User) error {
+	i := &models.Impersonation{
+		AdminID:   admin.ID,
+		UserID:    u.ID,
+		IP:        NewNullStr(ClientIP(r)),
+		UserAgent: NewNullStr(r.UserAgent()),
+	}
+
+	err := db.Impersonations.Start(r.Context(), i)
+	if err != nil {
+		return err
+	}
+
+	s := sessions.GetSession().Load(r)
+	err = s.PutInt(w, impersonationIDKey, i.ID)
+	if err != nil {
+		return err
+	}
+
+	err = s.PutInt(w, impersonatingKey, u.ID)
+	forgetUser(r)
+	return err
+}
+
+// StopImpersonating returns the admin to their own account. It returns the
+// user they were viewing as, or 0 if they weren't.
+func StopImpersonating(w http.ResponseWriter, r *http.Request) (int, error) {
+	s := sessions.GetSession().Load(r)
+
+	uid, err := s.GetInt(impersonatingKey)
+	if err != nil || uid == 0 {
+		return 0, err
+	}
+
+	id, err := s.GetInt(impersonationIDKey)
+	if err != nil {
+		return 0, err
+	}
+
+	err = db.Impersonations.End(r.Context(), id)
+	if err != nil {
+		return 0, err
+	}
+
+	err = s.Remove(w, impersonatingKey)
+	if err != nil {
+		return 0, err
+	}
+
+	err = s.Remove(w, impersonationIDKey)
+	forgetUser(r)
+	return uid, err
+}
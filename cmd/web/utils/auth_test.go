@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"context"
+	"net/http/httptest"
+	"revelbus/internal/platform/domain/models"
+	"revelbus/internal/platform/domain/store"
+	"revelbus/pkg/sessions"
+	"testing"
+
+	"github.com/alexedwards/scs"
+)
+
+// countingSessions counts the lookups of who's signed in.
+type countingSessions struct {
+	store.SessionStore
+	lookups int
+}
+
+func (c *countingSessions) FindUser(ctx context.Context, token string, ip string) (*models.User, error) {
+	c.lookups++
+	return c.SessionStore.FindUser(ctx, token, ip)
+}
+
+func TestIsAuthenticatedLooksUpOnce(t *testing.T) {
+	sessions.SetManager(scs.NewCookieManager("Yv3Rb8q0TzKc1mWp6LdS9nXe2HaJ4fUg"))
+
+	s := store.NewMemory()
+	c := &countingSessions{SessionStore: s.Sessions}
+	s.Sessions = c
+	UseStore(s)
+
+	u := &models.User{
+		Email:    NewNullStr("pat@example.com"),
+		Password: NewNullStr("secret"),
+		Role:     NewNullStr(models.RoleUser),
+	}
+	err := s.Users.Create(context.Background(), u)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	err = SetUserSession(w, httptest.NewRequest("GET", "/", nil), u)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("GET", "/u", nil)
+	for _, cookie := range w.Result().Cookies() {
+		r.AddCookie(cookie)
+	}
+	r = WithUser(r)
+
+	for i := 0; i < 3; i++ {
+		got, err := IsAuthenticated(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got == nil || got.ID != u.ID {
+			t.Fatalf("got user %+v, want %d", got, u.ID)
+		}
+	}
+	if c.lookups != 1 {
+		t.Fatalf("looked the user up %d times, want once", c.lookups)
+	}
+
+	// signing out during the request is seen by what runs after
+	err = RemoveUserSession(httptest.NewRecorder(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := IsAuthenticated(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != nil {
+		t.Fatalf("got user %d after signing out, want nobody", got.ID)
+	}
+}
@@ -1,14 +1,15 @@
 package utils
 
 import (
+	"crypto/rand"
 	"database/sql"
-	"math/rand"
+	"math/big"
+	"net"
+	"net/http"
 	"strconv"
-	"time"
-)
+	"strings"
 
-var (
-	seededRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	"github.com/spf13/viper"
 )
 
 func ToInt(s string) int {
@@ -16,13 +17,21 @@ func ToInt(s string) int {
 	return n
 }
 
-func RandomString(strlen int) string {
+// RandomString is n letters and digits from crypto/rand, random enough for a
+// password.
+func RandomString(n int) (string, error) {
 	const chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
-	result := make([]byte, strlen)
+	max := big.NewInt(int64(len(chars)))
+
+	result := make([]byte, n)
 	for i := range result {
-		result[i] = chars[seededRand.Intn(len(chars))]
+		c, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		result[i] = chars[c.Int64()]
 	}
-	return string(result)
+	return string(result), nil
 }
 
 func NewNullStr(s string) sql.NullString {
@@ -42,3 +51,24 @@ func NewNullInt(i int) sql.NullInt64 {
 		Valid: true,
 	}
 }
+
+// ClientIP is the address the request came from. Behind proxies, each one
+// appends the address it got the request from to X-Forwarded-For, so with
+// the number of them configured the client is that many entries from the
+// right. Anything further left was sent by the client and can't be trusted.
+func ClientIP(r *http.Request) string {
+	if n := viper.GetInt("proxies"); n > 0 {
+		hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+		if len(hops) >= n {
+			if ip := strings.TrimSpace(hops[len(hops)-n]); ip != "" {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxies int
+		fwd     []string
+		want    string
+	}{
+		{name: "no proxy", fwd: []string{"203.0.113.9"}, want: "192.0.2.1"},
+		{name: "one proxy", proxies: 1, fwd: []string{"203.0.113.9"}, want: "203.0.113.9"},
+		{name: "forged", proxies: 1, fwd: []string{"10.0.0.1, 203.0.113.9"}, want: "203.0.113.9"},
+		{name: "forged header", proxies: 1, fwd: []string{"10.0.0.1", "203.0.113.9"}, want: "203.0.113.9"},
+		{name: "two proxies", proxies: 2, fwd: []string{"10.0.0.1, 203.0.113.9, 198.51.100.7"}, want: "203.0.113.9"},
+		{name: "too few hops", proxies: 2, fwd: []string{"203.0.113.9"}, want: "192.0.2.1"},
+		{name: "missing", proxies: 1, want: "192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Set("proxies", tt.proxies)
+			defer viper.Set("proxies", 0)
+
+			r := httptest.NewRequest("GET", "/", nil)
+			for _, v := range tt.fwd {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+
+			if got := ClientIP(r); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -8,7 +8,7 @@ const (
 	MsgMustBeLoggedIn            = "Must be logged in to do that!"
 	MsgPasswordResetSuccessful   = "Reset successful. You may now login with your new password."
 	MsgRecoverySent              = "If an account exists with that email, a password recovery email will be sent."
-	MsgSuccessfulSignup          = "Your account has been successfully created!"
+	MsgSuccessfulSignup          = "Your account has been successfully created! Check your email for a link to verify your address."
 	MsgSuccessfullyAddedVendor   = "Vendor successfully added to trip."
 	MsgSuccessfullyRemovedVendor = "Vendor successfully removed from trip."
 	MsgSuccessfullyAddedImage    = "Image successfully added to gallery."
@@ -18,4 +18,57 @@ const (
 	MsgSuccessfullyUpdated       = "Successfully updated."
 	MsgUnsuccessfulLogin         = "Invalid login credentials."
 	MsgCannotRemove              = "Cannot delete because of association."
+	MsgEmailRequeued             = "Email queued to be sent again."
+	MsgSubscriptionPending       = "Thanks! Check your email to confirm your subscription."
+	MsgSubscriptionConfirmed     = "You're subscribed! Choose the trips you'd like to hear about."
+	MsgInvalidSubscriptionLink   = "That subscription link is invalid or has already been used."
+	MsgUnsubscribed              = "You have been unsubscribed."
+	MsgSubscribersImported       = "%d subscribers imported."
+	MsgNewsletterQueued          = "Newsletter queued for %d subscribers."
+	MsgTripBooked                = "You're booked! We'll send you a reminder before the trip."
+	MsgAlreadyBooked             = "You're already booked on this trip."
+	MsgBookingCancelled          = "Your booking has been cancelled."
+	MsgMessageSent               = "Your message has been sent!"
+	MsgTooManyMessages           = "You've sent a lot of messages recently. Please try again later."
+	MsgReplySent                 = "Reply queued to be sent."
+	MsgSessionRevoked            = "Session signed out."
+	MsgSessionsRevoked           = "Signed out of all sessions."
+	MsgLoginThrottled            = "Too many failed login attempts. Please try again in %s."
+	MsgAccountUnlocked           = "Account unlocked."
+	MsgInvalidCode               = "That code didn't work. Please try again."
+	MsgLoginExpired              = "Your login expired. Please sign in again."
+	MsgTwoFactorEnabled          = "Two-factor authentication is on. Save your recovery codes somewhere safe."
+	MsgTwoFactorDisabled         = "Two-factor authentication is off."
+	MsgTwoFactorRequired         = "Staff must use two-factor authentication. Please set it up to continue."
+	MsgRecoveryCodesRegenerated  = "New recovery codes generated. Your old codes no longer work."
+	MsgEmailVerified             = "Thanks! Your email address is verified."
+	MsgInvalidVerificationLink   = "That verification link is invalid or has expired."
+	MsgVerificationSent          = "Verification email sent. Check your inbox."
+	MsgTooManyVerifications      = "We've sent several verification emails recently. Please check your inbox or try again later."
+	MsgMustVerifyEmail           = "Please verify your email address first."
+	MsgEmailChangeSent           = "We've sent a confirmation link to %s. Your email address will change once you follow it."
+	MsgEmailChanged              = "Your email address has been changed."
+	MsgEmailTaken                = "That email address is already in use by another account."
+	MsgNotPermitted              = "You don't have permission to do that."
+	MsgRoleInUse                 = "That role can't be deleted while users have it."
+	MsgAdminOnly                 = "Only admins can make someone an admin."
+	MsgOwnRole                   = "You can't change your own role."
+	MsgRiderCheckedIn            = "Rider checked in."
+	MsgRiderCheckInUndone        = "Check-in undone."
+	MsgVendorChangeSubmitted     = "Thanks! Your changes will show once they've been approved."
+	MsgVendorChangeApproved      = "Changes approved and published."
+	MsgVendorChangeRejected      = "Changes rejected."
+	MsgVendorChangeStale         = "Those changes have already been reviewed or replaced by newer ones."
+	MsgUserNotFound              = "No user found with that email address."
+	MsgUserLinked                = "User can now manage this vendor."
+	MsgUserUnlinked              = "User removed from this vendor."
+	MsgSignInFailed              = "We couldn't sign you in with %s. Please try again."
+	MsgImpersonating             = "You're viewing the site as %s."
+	MsgCannotImpersonate         = "You can't view the site as that user."
+	MsgImpersonationRestricted   = "That isn't allowed while viewing the site as someone else."
+	MsgIdentityUnverified        = "%s hasn't confirmed your email address, so we can't use it to sign you in."
+	MsgMovedToTrash              = "Moved to the trash."
+	MsgRestored                  = "Restored from the trash."
+	MsgPurged                    = "Permanently deleted."
+	MsgReverted                  = "Reverted to the earlier revision."
 )
@@ -0,0 +1,11 @@
+package utils
+
+import "revelbus/internal/platform/domain/store"
+
+// db is where the helpers here load and save sessions, users and uploads.
+// handlers.UseStore swaps it along with the handlers' own.
+var db = store.Audited(store.NewMySQL())
+
+func UseStore(s *store.Store) {
+	db = s
+}
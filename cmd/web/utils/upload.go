@@ -1,23 +1,28 @@
 package utils
 
 import (
+	"context"
 	"image"
 	"image/jpeg"
 	"image/png"
 	"io"
-	"log"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
-	"revelbus/internal/platform/domain"
 	"revelbus/internal/platform/domain/models"
+	"revelbus/pkg/database"
 
 	"github.com/kennygrant/sanitize"
 	"github.com/nfnt/resize"
 	"github.com/spf13/viper"
 )
 
-func UploadFile(w http.ResponseWriter, r *http.Request, fieldName string, folder string, makeThumb bool) ([]*models.File, error) {
+// UploadFile saves the files posted in fieldName to folder and records them.
+// Each file is written under a temporary name first and only takes its real
+// one once ctx's transaction, if any, commits, so a rollback never touches a
+// file that was already there.
+func UploadFile(ctx context.Context, r *http.Request, fieldName string, folder string, makeThumb bool) ([]*models.File, error) {
 	uploaded := []*models.File{}
 	uploadDir := filepath.Join(viper.GetString("files.static"), folder)
 
@@ -52,56 +57,50 @@ func UploadFile(w http.ResponseWriter, r *http.Request, fieldName string, folder
 		// clean up file name
 		fn := sanitize.Name(files[i].Filename)
 
-		dst, err := os.Create(filepath.Join(uploadDir, fn))
+		tmp, err := saveTemp(uploadDir, file)
 		if err != nil {
 			return uploaded, err
 		}
 
-		if _, err := io.Copy(dst, file); err != nil {
-			return uploaded, err
+		// where each temporary file goes once the record is committed
+		moves := map[string]string{
+			tmp: filepath.Join(uploadDir, fn),
 		}
 
 		f.Name = NewNullStr(filepath.Join(folder, fn))
 
 		if makeThumb {
 			rn := "thumb_" + fn
-			ext := filepath.Ext(fn)
 
-			file, err := os.Open(filepath.Join(uploadDir, fn))
+			thumb, err := saveThumb(uploadDir, tmp, filepath.Ext(fn))
 			if err != nil {
-				log.Fatal(err)
+				os.Remove(tmp)
+				return uploaded, err
 			}
 
-			var img image.Image
+			moves[thumb] = filepath.Join(uploadDir, rn)
+			f.Thumb = NewNullStr(filepath.Join(folder, rn))
+		}
 
-			if ext == ".png" {
-				img, err = png.Decode(file)
-			} else {
-				img, err = jpeg.Decode(file)
-			}
-			if err != nil {
-				log.Fatal(err)
-			}
-			file.Close()
+		err = db.Files.Create(ctx, f)
+		if err != nil {
+			removeTemps(moves)
+			return uploaded, err
+		}
 
-			m := resize.Resize(500, 0, img, resize.Lanczos3)
+		database.OnRollback(ctx, func() {
+			removeTemps(moves)
+		})
 
-			out, err := os.Create(filepath.Join(uploadDir, rn))
-			if err != nil {
-				log.Fatal(err)
+		err = database.AfterCommit(ctx, func() error {
+			for from, to := range moves {
+				err := os.Rename(from, to)
+				if err != nil {
+					return err
+				}
 			}
-			defer out.Close()
-
-			if ext == ".png" {
-				png.Encode(out, m)
-			} else {
-				jpeg.Encode(out, m, &jpeg.Options{Quality: 100})
-			}
-
-			f.Thumb = NewNullStr(filepath.Join(folder, rn))
-		}
-
-		err = f.Create()
+			return nil
+		})
 		if err != nil {
 			return uploaded, err
 		}
@@ -112,37 +111,68 @@ func UploadFile(w http.ResponseWriter, r *http.Request, fieldName string, folder
 	return uploaded, err
 }
 
-func DeleteFile(f *models.File) error {
-	if f.Name.String == "" {
-		err := f.Fetch()
-		if err != nil {
-			if err == domain.ErrNotFound {
-				return nil
-			}
-			return err
-		}
+// saveTemp copies src to a new temporary file in dir, leaving nothing behind
+// if it can't.
+func saveTemp(dir string, src io.Reader) (string, error) {
+	dst, err := ioutil.TempFile(dir, ".upload-")
+	if err != nil {
+		return "", err
 	}
+	defer dst.Close()
 
-	err := f.Delete()
+	_, err = io.Copy(dst, src)
 	if err != nil {
-		return err
+		os.Remove(dst.Name())
+		return "", err
 	}
 
-	err = os.Remove(viper.GetString("files.static") + f.Name.String)
-	if err != nil && !os.IsNotExist(err) {
-		return err
+	return dst.Name(), nil
+}
+
+// saveThumb makes a 500px wide thumbnail of the image at path, in a
+// temporary file of its own.
+func saveThumb(dir string, path string, ext string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
 	}
+	defer file.Close()
 
-	if len(f.Thumb.String) > 0 {
-		err := os.Remove(viper.GetString("files.static") + f.Thumb.String)
-		if err != nil && !os.IsNotExist(err) {
-			return err
-		}
+	var img image.Image
+
+	if ext == ".png" {
+		img, err = png.Decode(file)
+	} else {
+		img, err = jpeg.Decode(file)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	m := resize.Resize(500, 0, img, resize.Lanczos3)
+
+	out, err := ioutil.TempFile(dir, ".upload-")
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+
+	if ext == ".png" {
+		err = png.Encode(out, m)
+	} else {
+		err = jpeg.Encode(out, m, &jpeg.Options{Quality: 100})
 	}
-	return nil
+	if err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+
+	return out.Name(), nil
 }
 
-func DeleteFolder(path string) error {
-	err := os.RemoveAll(filepath.Join(viper.GetString("files.static") + path))
-	return err
+// removeTemps cleans up the temporary files of an upload that won't be kept.
+func removeTemps(moves map[string]string) {
+	for tmp := range moves {
+		os.Remove(tmp)
+	}
 }
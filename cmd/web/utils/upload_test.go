@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"revelbus/internal/platform/domain/models"
+	"revelbus/internal/platform/domain/store"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// uploadRequest posts content as a file called name in the "files" field.
+func uploadRequest(t *testing.T, name string, content string) *http.Request {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+
+	fw, err := mw.CreateFormFile("files", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write([]byte(content))
+	mw.Close()
+
+	r := httptest.NewRequest("POST", "/admin/files", body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+// uploadDir points files.static at a new directory and returns the upload
+// folder inside it.
+func uploadDir(t *testing.T) string {
+	t.Helper()
+
+	static := t.TempDir()
+	viper.Set("files.static", static)
+	t.Cleanup(func() {
+		viper.Set("files.static", "")
+	})
+
+	dir := filepath.Join(static, "uploads")
+	err := os.Mkdir(dir, 0777)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func wantFiles(t *testing.T, dir string, want map[string]string) {
+	t.Helper()
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d files in %s, want %d", len(entries), dir, len(want))
+	}
+
+	for name, content := range want {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != content {
+			t.Fatalf("%s holds %q, want %q", name, b, content)
+		}
+	}
+}
+
+func TestUploadFile(t *testing.T) {
+	UseStore(store.NewMemory())
+	dir := uploadDir(t)
+
+	files, err := UploadFile(context.Background(), uploadRequest(t, "notes.txt", "hello"), "files", "uploads", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name.String != filepath.Join("uploads", "notes.txt") {
+		t.Fatalf("got files %+v, want uploads/notes.txt", files)
+	}
+
+	wantFiles(t, dir, map[string]string{"notes.txt": "hello"})
+}
+
+type failingFiles struct {
+	store.FileStore
+}
+
+func (failingFiles) Create(ctx context.Context, f *models.File) error {
+	return errors.New("no room")
+}
+
+func TestUploadFileNotRecorded(t *testing.T) {
+	s := store.NewMemory()
+	s.Files = failingFiles{s.Files}
+	UseStore(s)
+
+	dir := uploadDir(t)
+
+	err := ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("already here"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = UploadFile(context.Background(), uploadRequest(t, "notes.txt", "hello"), "files", "uploads", false)
+	if err == nil {
+		t.Fatal("the upload was saved without a record")
+	}
+
+	// the file that was there is left alone and nothing else is left behind
+	wantFiles(t, dir, map[string]string{"notes.txt": "already here"})
+}
@@ -3,6 +3,7 @@ package view
 import (
 	"log"
 	"net/http"
+	"revelbus/internal/platform/domain/models"
 	"runtime/debug"
 )
 
@@ -12,6 +13,7 @@ func ClientError(w http.ResponseWriter, r *http.Request, status int) {
 			Code:    status,
 			Message: http.StatusText(status),
 		},
+		status: status,
 	})
 }
 
@@ -23,6 +25,7 @@ func ServerError(w http.ResponseWriter, r *http.Request, err error) {
 			Code:    http.StatusInternalServerError,
 			Message: "Internal Server Error",
 		},
+		status: http.StatusInternalServerError,
 	})
 }
 
@@ -32,5 +35,16 @@ func NotFound(w http.ResponseWriter, r *http.Request) {
 			Code:    404,
 			Message: "Not found",
 		},
+		status: http.StatusNotFound,
+	})
+}
+
+// Conflict turns away an edit made to an out of date copy of a record,
+// showing it next to what's been saved since.
+func Conflict(w http.ResponseWriter, r *http.Request, c *models.Conflict) {
+	Render(w, r, "conflict", &View{
+		Title:    c.Title,
+		Conflict: c,
+		status:   http.StatusConflict,
 	})
 }
@@ -9,6 +9,7 @@ import (
 	"revelbus/internal/platform/domain/models"
 	"revelbus/internal/platform/flash"
 	"revelbus/internal/platform/forms"
+	"revelbus/pkg/email"
 	"strings"
 
 	"github.com/justinas/nosurf"
@@ -16,28 +17,61 @@ import (
 )
 
 type View struct {
-	ActiveKey    string
-	Blurb        string
-	Content      template.HTML
-	Err          appError
-	FAQs         *models.FAQs
-	Files        *models.Files
-	FAQGrouped   *models.GroupedFAQs
-	Gallery      *models.Gallery
-	Galleries    *models.Galleries
-	TripsGrouped *models.GroupedTrips
-	Flash        flash.Msg
-	Form         forms.Form
-	HeaderStyle  string
-	Me           *models.User
-	Path         string
-	Slides       *models.Slides
-	Title        string
-	Token        string
-	Trip         *models.Trip
-	Trips        *models.Trips
-	Vendors      *models.Vendors
-	Users        *models.Users
+	ActiveKey      string
+	Audit          *models.AuditEntries
+	AuditFilter    *models.AuditFilter
+	AuditActions   []string
+	AuditTypes     []string
+	Blurb          string
+	Booked         bool
+	Bookings       *models.Bookings
+	Categories     []string
+	Content        template.HTML
+	Emails         *models.OutboxEmails
+	Err            appError
+	FAQs           *models.FAQs
+	Files          *models.Files
+	FAQGrouped     *models.GroupedFAQs
+	Gallery        *models.Gallery
+	Galleries      *models.Galleries
+	TripsGrouped   *models.GroupedTrips
+	Flash          flash.Msg
+	Form           forms.Form
+	HeaderStyle    string
+	Impersonations *models.Impersonations
+	Mailbox        []email.Message
+	Me             *models.User
+	Message        *models.Message
+	Messages       *models.Messages
+	NextPage       string
+	PartnerTrips   *models.PartnerTrips
+	Path           string
+	Sessions       *models.Sessions
+	Events         *models.SecurityEvents
+	Roles          *models.Roles
+	SignInWith     string
+	Permissions    []models.Permission
+	PrevPage       string
+	Revision       *models.Revision
+	Revisions      *models.Revisions
+	RevisionDiff   []models.RevisionField
+	Slides         *models.Slides
+	Subscribers    *models.Subscribers
+	Title          string
+	Token          string
+	Trash          *models.TrashedItems
+	TrashTypes     []string
+	Trip           *models.Trip
+	Trips          *models.Trips
+	Vendor         *models.Vendor
+	Vendors        *models.Vendors
+	Change         *models.VendorChange
+	Changes        *models.VendorChanges
+	Conflict       *models.Conflict
+	Users          *models.Users
+
+	// status is the response code, if it isn't 200
+	status int
 }
 
 type appError struct {
@@ -49,6 +83,7 @@ func Render(w http.ResponseWriter, r *http.Request, tpl string, v *View) {
 	v.Path = r.URL.Path
 	v.Token = nosurf.Token(r)
 	v.HeaderStyle = getHeaderStyle(tpl)
+	v.SignInWith = getSignInWith()
 
 	flash, err := flash.Fetch(w, r)
 	if err != nil {
@@ -70,6 +105,10 @@ func Render(w http.ResponseWriter, r *http.Request, tpl string, v *View) {
 		return
 	}
 
+	if v.status != 0 {
+		w.WriteHeader(v.status)
+	}
+
 	err = t.ExecuteTemplate(w, tpl, v)
 	if err != nil {
 		ServerError(w, r, err)
@@ -86,6 +125,8 @@ func parseTemplates() (*template.Template, error) {
 		"blurb":         blurb,
 		"seoDate":       seoDate,
 		"notTrip":       notTrip,
+		"linkify":       linkify,
+		"nl2br":         nl2br,
 	}
 	templ := template.New("").Funcs(fm)
 	err := filepath.Walk(viper.GetString("files.tpl"), func(path string, info os.FileInfo, err error) error {
@@ -119,3 +160,16 @@ func getHeaderStyle(t string) string {
 		return ""
 	}
 }
+
+// getSignInWith names the outside provider users can sign in with, if one is
+// set up.
+func getSignInWith() string {
+	if viper.GetString("oidc.issuer") == "" || viper.GetString("oidc.client_id") == "" {
+		return ""
+	}
+
+	if l := viper.GetString("oidc.label"); l != "" {
+		return l
+	}
+	return "single sign-on"
+}
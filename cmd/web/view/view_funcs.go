@@ -1,6 +1,13 @@
 package view
 
-import "time"
+import (
+	"html/template"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var rxLink = regexp.MustCompile(`https?://[^\s<>"]+`)
 
 func humanDate(t time.Time) string {
 	return t.Format("Mon, Jan 2, 2006 at 3:04 PM")
@@ -65,3 +72,16 @@ func blurb(s string) string {
 	}
 	return s
 }
+
+// linkify escapes plain text and turns any URLs in it into links.
+func linkify(s string) template.HTML {
+	esc := template.HTMLEscapeString(s)
+	return template.HTML(rxLink.ReplaceAllStringFunc(esc, func(u string) string {
+		return `<a href="` + u + `" target="_blank">` + u + `</a>`
+	}))
+}
+
+// nl2br escapes plain text and keeps its line breaks.
+func nl2br(s string) template.HTML {
+	return template.HTML(strings.Replace(template.HTMLEscapeString(s), "\n", "<br>", -1))
+}
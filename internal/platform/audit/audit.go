@@ -0,0 +1,217 @@
+// Package audit records who changed what in the audit log, and keeps earlier
+// revisions of content. Middleware puts the signed in user on the request
+// context with WithActor, and Record and Revise pick them up from there, so
+// the models and stores don't need to know who's asking.
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"revelbus/internal/platform/domain"
+	"revelbus/internal/platform/domain/models"
+	"strings"
+)
+
+type actorKey struct{}
+
+type actor struct {
+	id   int
+	name string
+	ip   string
+}
+
+// hidden fields never make it into the log, being secret or just
+// bookkeeping.
+var hidden = map[string]bool{
+	"Password": true,
+	"Version":  true,
+}
+
+// WithActor returns a context that attributes changes to the user, made from
+// ip. An admin viewing the site as someone else is the one credited.
+func WithActor(ctx context.Context, u *models.User, ip string) context.Context {
+	if u.Impersonator != nil {
+		u = u.Impersonator
+	}
+
+	return context.WithValue(ctx, actorKey{}, &actor{
+		id:   u.ID,
+		name: u.Name.String,
+		ip:   ip,
+	})
+}
+
+// Record logs a change to an entity. before and after are the entity as it
+// was and as it is now, either of which can be nil; only the fields that
+// differ are kept. An update that didn't change anything isn't logged.
+func Record(ctx context.Context, action string, entityType string, entityID int, before interface{}, after interface{}) error {
+	b, err := snapshot(before)
+	if err != nil {
+		return err
+	}
+
+	a, err := snapshot(after)
+	if err != nil {
+		return err
+	}
+
+	if b != nil && a != nil {
+		for k, v := range b {
+			if reflect.DeepEqual(v, a[k]) {
+				delete(b, k)
+				delete(a, k)
+			}
+		}
+
+		if len(b) == 0 && len(a) == 0 && action == models.AuditUpdate {
+			return nil
+		}
+	}
+
+	e := &models.AuditEntry{
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+	}
+
+	if act, ok := ctx.Value(actorKey{}).(*actor); ok {
+		e.ActorID = act.id
+		e.ActorName = sql.NullString{String: act.name, Valid: true}
+		e.IP = sql.NullString{String: act.ip, Valid: act.ip != ""}
+	}
+
+	e.Before, err = encode(b)
+	if err != nil {
+		return err
+	}
+
+	e.After, err = encode(a)
+	if err != nil {
+		return err
+	}
+
+	return e.Create(ctx)
+}
+
+// Revise keeps after's content as a new revision of the entity, unless it's
+// the same as the last one. The first time an entity is revised, before is
+// kept too, so what it said until now isn't lost.
+func Revise(ctx context.Context, entityType string, entityID int, before interface{}, after interface{}) error {
+	if isNil(after) {
+		return nil
+	}
+
+	last, err := models.LatestRevision(ctx, entityType, entityID)
+	if err == domain.ErrNotFound {
+		if !isNil(before) {
+			last = models.NewRevision(entityType, entityID, before)
+			err = last.Create(ctx)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	r := models.NewRevision(entityType, entityID, after)
+	if last != nil && r.Same(last) {
+		return nil
+	}
+
+	if act, ok := ctx.Value(actorKey{}).(*actor); ok {
+		r.ActorID = act.id
+		r.ActorName = sql.NullString{String: act.name, Valid: true}
+	}
+
+	return r.Create(ctx)
+}
+
+// snapshot flattens v into its top level fields. Nullable columns become
+// their value or nil, and related records like a trip's vendors are left out
+// since they have their own entries.
+func snapshot(v interface{}) (map[string]interface{}, error) {
+	if isNil(v) {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]interface{}{}
+	err = json.Unmarshal(b, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, f := range fields {
+		if hidden[k] {
+			delete(fields, k)
+			continue
+		}
+
+		switch f := f.(type) {
+		case map[string]interface{}:
+			value, ok := nullValue(f)
+			if !ok {
+				delete(fields, k)
+				continue
+			}
+			fields[k] = value
+		case []interface{}:
+			delete(fields, k)
+		}
+	}
+
+	return fields, nil
+}
+
+// isNil is true for nil and for a typed nil, like a *models.Trip that
+// couldn't be fetched.
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Ptr, reflect.Map:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// nullValue unwraps a sql.NullString, mysql.NullTime and the like.
+func nullValue(m map[string]interface{}) (interface{}, bool) {
+	valid, ok := m["Valid"].(bool)
+	if !ok || len(m) != 2 {
+		return nil, false
+	}
+
+	for k, v := range m {
+		if k == "Valid" {
+			continue
+		}
+		if !strings.HasPrefix(k, "Int") && k != "String" && k != "Time" && k != "Bool" && k != "Float64" {
+			return nil, false
+		}
+		if !valid {
+			return nil, true
+		}
+		return v, true
+	}
+	return nil, false
+}
+
+func encode(m map[string]interface{}) (sql.NullString, error) {
+	if m == nil {
+		return sql.NullString{}, nil
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(b), Valid: true}, nil
+}
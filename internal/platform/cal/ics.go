@@ -8,14 +8,18 @@ import (
 )
 
 func GenerateICS(w http.ResponseWriter, t *models.Trip) error {
+	w.Header().Set("Content-Type", "text/calendar")
+	return WriteICS(w, t)
+}
+
+// WriteICS encodes the trip as a calendar, e.g. to attach it to an email.
+func WriteICS(w io.Writer, t *models.Trip) error {
 	cal := newCal()
 	e := tripToVEvent(t)
 
 	cal.vComponent = append(cal.vComponent, e)
 
-	w.Header().Set("Content-Type", "text/calendar")
-	err := cal.encode(w)
-	return err
+	return cal.encode(w)
 }
 
 // create new cal with my defaults
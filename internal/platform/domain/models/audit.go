@@ -0,0 +1,238 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"revelbus/pkg/database"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	AuditCreate       = "create"
+	AuditUpdate       = "update"
+	AuditDelete       = "delete"
+	AuditRestore      = "restore"
+	AuditPurge        = "purge"
+	AuditAttachImage  = "attach_image"
+	AuditDetachImage  = "detach_image"
+	AuditAttachVendor = "attach_vendor"
+	AuditDetachVendor = "detach_vendor"
+	AuditVenueStatus  = "venue_status"
+	AuditPassword     = "password"
+	AuditUnlock       = "unlock"
+	AuditEnable2FA    = "enable_2fa"
+	AuditDisable2FA   = "disable_2fa"
+	AuditRevoke       = "revoke_session"
+	AuditRevokeAll    = "revoke_sessions"
+
+	AuditTrip     = "trip"
+	AuditVendor   = "vendor"
+	AuditFAQ      = "faq"
+	AuditSlide    = "slide"
+	AuditGallery  = "gallery"
+	AuditFile     = "file"
+	AuditSettings = "settings"
+	AuditUser     = "user"
+	AuditRole     = "role"
+)
+
+// AuditActions and AuditTypes are what the audit log can be filtered by.
+var (
+	AuditActions = []string{AuditCreate, AuditUpdate, AuditDelete, AuditRestore, AuditPurge, AuditAttachImage, AuditDetachImage, AuditAttachVendor, AuditDetachVendor, AuditVenueStatus, AuditPassword, AuditUnlock, AuditEnable2FA, AuditDisable2FA, AuditRevoke, AuditRevokeAll}
+	AuditTypes   = []string{AuditTrip, AuditVendor, AuditFAQ, AuditSlide, AuditGallery, AuditFile, AuditSettings, AuditUser, AuditRole}
+)
+
+// AuditEntry records one change to the site's content: who made it, from
+// where, and the fields it changed. Before and After are JSON objects holding
+// only the fields that differ; a create has no Before and a delete no After.
+type AuditEntry struct {
+	ID         int
+	ActorID    int
+	ActorName  sql.NullString
+	Action     string
+	EntityType string
+	EntityID   int
+	IP         sql.NullString
+	Before     sql.NullString
+	After      sql.NullString
+	Created    time.Time
+}
+
+type AuditEntries []*AuditEntry
+
+// AuditChange is one field of an entry, for showing side by side.
+type AuditChange struct {
+	Field  string
+	Before string
+	After  string
+}
+
+// AuditFilter narrows the audit log. Empty fields match everything.
+type AuditFilter struct {
+	EntityType string
+	EntityID   int
+	Action     string
+	Actor      string
+}
+
+func (a *AuditEntry) Create(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	actor := sql.NullInt64{Int64: int64(a.ActorID), Valid: a.ActorID != 0}
+
+	stmt := `INSERT INTO audit_log (actor_id, actor_name, action, entity_type, entity_id, ip, before_data, after_data, created_at) VALUES(?, ?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP())`
+	result, err := conn.ExecContext(ctx, stmt, actor, a.ActorName, a.Action, a.EntityType, a.EntityID, a.IP, a.Before, a.After)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	a.ID = int(id)
+	a.Created = time.Now().UTC()
+	return nil
+}
+
+// FetchAuditLog returns the newest entries matching f, skipping the first
+// offset of them.
+func FetchAuditLog(ctx context.Context, f *AuditFilter, limit int, offset int) (*AuditEntries, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	where := []string{"1 = 1"}
+	args := []interface{}{}
+
+	if f.EntityType != "" {
+		where = append(where, "a.entity_type = ?")
+		args = append(args, f.EntityType)
+	}
+
+	if f.EntityID != 0 {
+		where = append(where, "a.entity_id = ?")
+		args = append(args, f.EntityID)
+	}
+
+	if f.Action != "" {
+		where = append(where, "a.action = ?")
+		args = append(args, f.Action)
+	}
+
+	if f.Actor != "" {
+		where = append(where, "(a.actor_name LIKE ? OR u.email LIKE ?)")
+		like := "%" + f.Actor + "%"
+		args = append(args, like, like)
+	}
+
+	args = append(args, limit, offset)
+
+	stmt := `SELECT a.id, IFNULL(a.actor_id, 0), a.actor_name, a.action, a.entity_type, a.entity_id, a.ip, a.before_data, a.after_data, a.created_at FROM audit_log a LEFT JOIN users u ON a.actor_id = u.id WHERE ` + strings.Join(where, " AND ") + ` ORDER BY a.id DESC LIMIT ? OFFSET ?`
+	rows, err := conn.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := AuditEntries{}
+	for rows.Next() {
+		a := &AuditEntry{}
+		err := rows.Scan(&a.ID, &a.ActorID, &a.ActorName, &a.Action, &a.EntityType, &a.EntityID, &a.IP, &a.Before, &a.After, &a.Created)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &entries, nil
+}
+
+// Changes lists the fields the entry touched, in name order.
+func (a *AuditEntry) Changes() []AuditChange {
+	before := map[string]interface{}{}
+	after := map[string]interface{}{}
+
+	json.Unmarshal([]byte(a.Before.String), &before)
+	json.Unmarshal([]byte(a.After.String), &after)
+
+	fields := []string{}
+	for k := range before {
+		fields = append(fields, k)
+	}
+	for k := range after {
+		if _, ok := before[k]; !ok {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+
+	changes := []AuditChange{}
+	for _, k := range fields {
+		changes = append(changes, AuditChange{
+			Field:  k,
+			Before: auditValue(before, k),
+			After:  auditValue(after, k),
+		})
+	}
+	return changes
+}
+
+// Link is the admin page for the changed entity, if it has one.
+func (a *AuditEntry) Link() string {
+	id := strconv.Itoa(a.EntityID)
+
+	switch a.EntityType {
+	case AuditTrip, AuditVendor, AuditFAQ, AuditSlide, AuditGallery, AuditUser, AuditRole:
+		return "/admin/" + a.EntityType + "?id=" + id
+	case AuditSettings:
+		return "/admin/settings"
+	}
+	return ""
+}
+
+// Query is the audit log's query string for page of f.
+func (f *AuditFilter) Query(page int) string {
+	q := url.Values{}
+	if f.EntityType != "" {
+		q.Set("type", f.EntityType)
+	}
+	if f.EntityID != 0 {
+		q.Set("id", strconv.Itoa(f.EntityID))
+	}
+	if f.Action != "" {
+		q.Set("action", f.Action)
+	}
+	if f.Actor != "" {
+		q.Set("actor", f.Actor)
+	}
+	if page > 1 {
+		q.Set("page", strconv.Itoa(page))
+	}
+	return q.Encode()
+}
+
+func auditValue(m map[string]interface{}, k string) string {
+	v, ok := m[k]
+	if !ok || v == nil {
+		return ""
+	}
+
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,234 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"revelbus/internal/platform/domain"
+	"revelbus/pkg/database"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	BookingBooked    = "booked"
+	BookingCancelled = "cancelled"
+)
+
+type Booking struct {
+	ID        int
+	TripID    int
+	UserID    int
+	Seats     int
+	Status    sql.NullString
+	CheckedIn mysql.NullTime
+	Created   time.Time
+
+	Trip *Trip
+	User *User
+
+	Notifications []string
+}
+
+type Bookings []*Booking
+
+// Create books the user on the trip. Booking again after cancelling puts the
+// same booking back.
+func (b *Booking) Create(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	if b.Seats < 1 {
+		b.Seats = 1
+	}
+
+	stmt := `INSERT INTO bookings (trip_id, user_id, seats, status, created_at, updated_at) VALUES(?, ?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP())`
+	result, err := conn.ExecContext(ctx, stmt, b.TripID, b.UserID, b.Seats, BookingBooked)
+	if err != nil {
+		merr, ok := err.(*mysql.MySQLError)
+
+		if ok && merr.Number == 1062 {
+			stmt = `UPDATE bookings SET seats = ?, status = ?, updated_at = UTC_TIMESTAMP() WHERE trip_id = ? AND user_id = ? AND status = ?`
+			result, err = conn.ExecContext(ctx, stmt, b.Seats, BookingBooked, b.TripID, b.UserID, BookingCancelled)
+			if err != nil {
+				return err
+			}
+
+			n, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				return domain.ErrDuplicate
+			}
+			return nil
+		}
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	b.ID = int(id)
+	b.Status = sql.NullString{String: BookingBooked, Valid: true}
+
+	return nil
+}
+
+// Cancel cancels the booking, as long as it belongs to b.UserID.
+func (b *Booking) Cancel(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `UPDATE bookings SET status = ?, updated_at = UTC_TIMESTAMP() WHERE id = ? AND user_id = ?`
+	result, err := conn.ExecContext(ctx, stmt, BookingCancelled, b.ID, b.UserID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// CheckIn marks the rider as on the bus, or takes the mark off again. The
+// booking must be on b.TripID.
+func (b *Booking) CheckIn(ctx context.Context, in bool) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `UPDATE bookings SET checked_in_at = IF(?, IFNULL(checked_in_at, UTC_TIMESTAMP()), NULL), updated_at = UTC_TIMESTAMP() WHERE id = ? AND trip_id = ? AND status = ?`
+	result, err := conn.ExecContext(ctx, stmt, in, b.ID, b.TripID, BookingBooked)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// IsBooked reports whether the user holds a booking on the trip.
+func IsBooked(ctx context.Context, tripID int, userID int) (bool, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	var id int
+
+	stmt := `SELECT id FROM bookings WHERE trip_id = ? AND user_id = ? AND status = ?`
+	err := conn.QueryRowContext(ctx, stmt, tripID, userID, BookingBooked).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// FindUserBookings returns the user's bookings on trips that haven't ended.
+func FindUserBookings(ctx context.Context, userID int) (*Bookings, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `SELECT b.id, b.trip_id, b.seats, b.status, b.created_at, t.title, t.slug, t.start, t.end FROM bookings b JOIN trips t ON b.trip_id = t.id WHERE b.user_id = ? AND b.status = ? AND t.end > UTC_TIMESTAMP() AND t.deleted_at IS NULL ORDER BY t.start`
+	rows, err := conn.QueryContext(ctx, stmt, userID, BookingBooked)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bookings := Bookings{}
+	for rows.Next() {
+		b := &Booking{
+			UserID: userID,
+			Trip:   &Trip{},
+		}
+		err := rows.Scan(&b.ID, &b.TripID, &b.Seats, &b.Status, &b.Created, &b.Trip.Title, &b.Trip.Slug, &b.Trip.Start, &b.Trip.End)
+		if err != nil {
+			return nil, err
+		}
+		b.Trip.ID = b.TripID
+		bookings = append(bookings, b)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &bookings, nil
+}
+
+// FetchTripRiders returns everyone booked on the trip along with the
+// notifications they've been sent about it.
+func FetchTripRiders(ctx context.Context, tripID int) (*Bookings, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `SELECT b.id, b.user_id, b.seats, b.status, b.checked_in_at, b.created_at, u.name, u.email FROM bookings b JOIN users u ON b.user_id = u.id WHERE b.trip_id = ? AND b.status = ? AND u.deleted_at IS NULL ORDER BY u.name`
+	rows, err := conn.QueryContext(ctx, stmt, tripID, BookingBooked)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bookings := Bookings{}
+	byUser := make(map[int]*Booking)
+	for rows.Next() {
+		b := &Booking{
+			TripID: tripID,
+			User:   &User{},
+		}
+		err := rows.Scan(&b.ID, &b.UserID, &b.Seats, &b.Status, &b.CheckedIn, &b.Created, &b.User.Name, &b.User.Email)
+		if err != nil {
+			return nil, err
+		}
+		b.User.ID = b.UserID
+		bookings = append(bookings, b)
+		byUser[b.UserID] = b
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	stmt = `SELECT user_id, kind FROM trip_notifications WHERE trip_id = ? ORDER BY sent_at`
+	nrows, err := conn.QueryContext(ctx, stmt, tripID)
+	if err != nil {
+		return nil, err
+	}
+	defer nrows.Close()
+
+	for nrows.Next() {
+		var uid int
+		var kind string
+		err := nrows.Scan(&uid, &kind)
+		if err != nil {
+			return nil, err
+		}
+		if b, ok := byUser[uid]; ok {
+			b.Notifications = append(b.Notifications, kind)
+		}
+	}
+
+	if err = nrows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &bookings, nil
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Conflict is an edit that was turned away because someone else saved the
+// record after it was opened. It lines up the form as it was submitted
+// against the form as it would be filled in now.
+type Conflict struct {
+	Title  string
+	Link   string
+	Fields []ConflictField
+}
+
+// ConflictField is one field of the form in both versions.
+type ConflictField struct {
+	Field   string
+	Yours   string
+	Theirs  string
+	Differs bool
+}
+
+// NewConflict compares the fields of two of the same kind of form. Link is
+// where to open the record again to start over from the current version.
+func NewConflict(title string, link string, yours interface{}, theirs interface{}) *Conflict {
+	c := &Conflict{
+		Title: title,
+		Link:  link,
+	}
+
+	y := reflect.Indirect(reflect.ValueOf(yours))
+	t := reflect.Indirect(reflect.ValueOf(theirs))
+
+	for i := 0; i < y.NumField(); i++ {
+		name := y.Type().Field(i).Name
+		if name == "ID" || name == "Version" || name == "Errors" || strings.Contains(name, "Password") {
+			continue
+		}
+
+		yv, ok := conflictValue(y.Field(i))
+		if !ok {
+			continue
+		}
+		tv, _ := conflictValue(t.Field(i))
+
+		c.Fields = append(c.Fields, ConflictField{
+			Field:   name,
+			Yours:   yv,
+			Theirs:  tv,
+			Differs: yv != tv,
+		})
+	}
+	return c
+}
+
+func conflictValue(v reflect.Value) (string, bool) {
+	switch v.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int:
+		return fmt.Sprint(v.Interface()), true
+	case reflect.Slice:
+		if s, ok := v.Interface().([]string); ok {
+			return strings.Join(s, ", "), true
+		}
+	}
+	return "", false
+}
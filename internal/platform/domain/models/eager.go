@@ -0,0 +1,190 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"revelbus/pkg/database"
+	"strings"
+)
+
+// in builds the placeholders and args for an IN clause over ids.
+func in(ids []int) (string, []interface{}) {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", "), args
+}
+
+// byID indexes the trips by id, leaving out ones not yet saved.
+func (ts Trips) byID() (map[int]*Trip, []int) {
+	trips := map[int]*Trip{}
+	ids := []int{}
+	for _, t := range ts {
+		if t.ID == 0 {
+			continue
+		}
+		if _, ok := trips[t.ID]; !ok {
+			ids = append(ids, t.ID)
+		}
+		trips[t.ID] = t
+	}
+	return trips, ids
+}
+
+// LoadImages fetches the image of every trip in one query. A trip without
+// one gets an empty file, as GetImage gives it.
+func (ts Trips) LoadImages(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	trips := map[int][]*Trip{}
+	ids := []int{}
+	for _, t := range ts {
+		t.Image = &File{}
+
+		id := int(t.ImageID.Int64)
+		if id == 0 {
+			continue
+		}
+		if _, ok := trips[id]; !ok {
+			ids = append(ids, id)
+		}
+		trips[id] = append(trips[id], t)
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	marks, args := in(ids)
+	stmt := `SELECT id, name, thumb FROM files WHERE id IN (` + marks + `)`
+	rows, err := conn.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		f := &File{}
+		err := rows.Scan(&f.ID, &f.Name, &f.Thumb)
+		if err != nil {
+			return err
+		}
+
+		for _, t := range trips[f.ID] {
+			t.Image = f
+		}
+	}
+
+	return rows.Err()
+}
+
+// LoadVendors fetches the partners, with their brands, and the venues of all
+// the trips in two queries.
+func (ts Trips) LoadVendors(ctx context.Context) error {
+	err := ts.LoadPartners(ctx)
+	if err != nil {
+		return err
+	}
+
+	return ts.LoadVenues(ctx)
+}
+
+// LoadPartners fetches the active partners of all the trips and their brand
+// images in one query.
+func (ts Trips) LoadPartners(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	for _, t := range ts {
+		t.Partners = Vendors{}
+	}
+
+	trips, ids := ts.byID()
+	if len(ids) == 0 {
+		return nil
+	}
+
+	marks, args := in(ids)
+	stmt := `SELECT tp.trip_id, v.id, v.name, v.brand_id, v.url, IFNULL(f.id, 0), f.name, f.thumb FROM trips_partners tp JOIN vendors v ON tp.partner_id = v.id LEFT JOIN files f ON v.brand_id = f.id WHERE tp.trip_id IN (` + marks + `) AND v.active = 1 AND v.deleted_at IS NULL ORDER BY v.name`
+	rows, err := conn.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tid int
+		p := &Vendor{Brand: &File{}}
+		err := rows.Scan(&tid, &p.ID, &p.Name, &p.BrandID, &p.URL, &p.Brand.ID, &p.Brand.Name, &p.Brand.Thumb)
+		if err != nil {
+			return err
+		}
+
+		if t, ok := trips[tid]; ok {
+			t.Partners = append(t.Partners, p)
+		}
+	}
+
+	return rows.Err()
+}
+
+// LoadVenues fetches the active venues of all the trips in one query.
+func (ts Trips) LoadVenues(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	for _, t := range ts {
+		t.Venues = Vendors{}
+	}
+
+	trips, ids := ts.byID()
+	if len(ids) == 0 {
+		return nil
+	}
+
+	marks, args := in(ids)
+	stmt := `SELECT tv.trip_id, v.id, v.name, v.address, v.city, v.state, v.zip, v.phone, tv.is_primary FROM trips_venues tv JOIN vendors v ON tv.venue_id = v.id WHERE tv.trip_id IN (` + marks + `) AND v.active = 1 AND v.deleted_at IS NULL ORDER BY v.name`
+	rows, err := conn.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tid int
+		v := &Vendor{}
+		err := rows.Scan(&tid, &v.ID, &v.Name, &v.Address, &v.City, &v.State, &v.Zip, &v.Phone, &v.Primary)
+		if err != nil {
+			return err
+		}
+
+		if t, ok := trips[tid]; ok {
+			t.Venues = append(t.Venues, v)
+		}
+	}
+
+	return rows.Err()
+}
+
+// scanTrips reads the rows of a listing query, which selects id, title, slug,
+// category, start, end, image_id and blurb.
+func scanTrips(rows *sql.Rows) (Trips, error) {
+	defer rows.Close()
+
+	trips := Trips{}
+	for rows.Next() {
+		t := &Trip{}
+		err := rows.Scan(&t.ID, &t.Title, &t.Slug, &t.Category, &t.Start, &t.End, &t.ImageID, &t.Blurb)
+		if err != nil {
+			return nil, err
+		}
+		trips = append(trips, t)
+	}
+
+	return trips, rows.Err()
+}
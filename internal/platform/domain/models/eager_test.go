@@ -0,0 +1,221 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"revelbus/pkg/database"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// queries counts what's run through the counting driver.
+var queries int64
+
+var useCounting sync.Once
+
+// counting points the models at a driver that answers the loaders' queries
+// with made up rows, and counts them, so how many queries a page costs can be
+// measured without MySQL.
+func counting(tb testing.TB) {
+	tb.Helper()
+
+	useCounting.Do(func() {
+		sql.Register("counting", countingDriver{})
+
+		conn, err := sql.Open("counting", "")
+		if err != nil {
+			tb.Fatal(err)
+		}
+		database.UseConnection(conn)
+	})
+
+	atomic.StoreInt64(&queries, 0)
+}
+
+type countingDriver struct{}
+
+func (countingDriver) Open(name string) (driver.Conn, error) {
+	return countingConn{}, nil
+}
+
+type countingConn struct{}
+
+func (countingConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("counting: prepared statements aren't supported")
+}
+
+func (countingConn) Close() error {
+	return nil
+}
+
+func (countingConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("counting: transactions aren't supported")
+}
+
+func (countingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	atomic.AddInt64(&queries, 1)
+
+	r := &countingRows{}
+
+	switch {
+	case strings.Contains(query, "FROM files WHERE id IN"):
+		r.columns = []string{"id", "name", "thumb"}
+		for _, a := range args {
+			r.data = append(r.data, []driver.Value{a.Value, "uploads/trip/trip.jpg", "uploads/trip/thumb_trip.jpg"})
+		}
+	case strings.Contains(query, "FROM trips t JOIN files f"):
+		r.columns = []string{"id", "name", "thumb"}
+		r.data = append(r.data, []driver.Value{args[0].Value, "uploads/trip/trip.jpg", "uploads/trip/thumb_trip.jpg"})
+	case strings.Contains(query, "FROM trips_partners"):
+		r.columns = []string{"trip_id", "id", "name", "brand_id", "url", "id", "name", "thumb"}
+		for _, a := range args {
+			r.data = append(r.data, []driver.Value{a.Value, int64(1), "Partner", int64(1), "https://example.com", int64(1), "uploads/vendor/brand.png", "uploads/vendor/thumb_brand.png"})
+		}
+	case strings.Contains(query, "FROM trips_venues"):
+		r.columns = []string{"trip_id", "id", "name", "address", "city", "state", "zip", "phone", "is_primary"}
+		for _, a := range args {
+			r.data = append(r.data, []driver.Value{a.Value, int64(2), "Venue", "1 Main St", "Springfield", "IL", "62701", "555-0100", true})
+		}
+	default:
+		return nil, errors.New("counting: no answer for " + query)
+	}
+
+	return r, nil
+}
+
+type countingRows struct {
+	columns []string
+	data    [][]driver.Value
+	next    int
+}
+
+func (r *countingRows) Columns() []string {
+	return r.columns
+}
+
+func (r *countingRows) Close() error {
+	return nil
+}
+
+func (r *countingRows) Next(dest []driver.Value) error {
+	if r.next == len(r.data) {
+		return io.EOF
+	}
+
+	copy(dest, r.data[r.next])
+	r.next++
+	return nil
+}
+
+// listing is n trips as a listing query reads them, each with an image.
+func listing(n int) Trips {
+	trips := make(Trips, n)
+	for i := range trips {
+		trips[i] = &Trip{
+			ID:      i + 1,
+			ImageID: sql.NullInt64{Int64: int64(i + 1), Valid: true},
+		}
+	}
+	return trips
+}
+
+func TestLoadQueries(t *testing.T) {
+	for _, n := range []int{1, 50} {
+		counting(t)
+
+		trips := listing(n)
+
+		err := trips.LoadImages(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = trips.LoadVendors(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := atomic.LoadInt64(&queries); got != 3 {
+			t.Fatalf("loading %d trips took %d queries, want 3", n, got)
+		}
+
+		for _, trip := range trips {
+			if trip.Image.ID != int(trip.ImageID.Int64) {
+				t.Fatalf("trip %d got image %d, want %d", trip.ID, trip.Image.ID, trip.ImageID.Int64)
+			}
+			if len(trip.Partners) != 1 || len(trip.Venues) != 1 {
+				t.Fatalf("trip %d got %d partners and %d venues, want 1 of each", trip.ID, len(trip.Partners), len(trip.Venues))
+			}
+		}
+	}
+}
+
+// reportQueries adds the number of queries each run of the benchmark made.
+func reportQueries(b *testing.B) {
+	b.ReportMetric(float64(atomic.LoadInt64(&queries))/float64(b.N), "queries/op")
+}
+
+func BenchmarkLoadImages(b *testing.B) {
+	trips := listing(50)
+
+	b.Run("batched", func(b *testing.B) {
+		counting(b)
+
+		for i := 0; i < b.N; i++ {
+			err := trips.LoadImages(context.Background())
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+		reportQueries(b)
+	})
+
+	b.Run("per trip", func(b *testing.B) {
+		counting(b)
+
+		for i := 0; i < b.N; i++ {
+			for _, t := range trips {
+				err := t.GetImage(context.Background())
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+		reportQueries(b)
+	})
+}
+
+func BenchmarkLoadVendors(b *testing.B) {
+	trips := listing(50)
+
+	b.Run("batched", func(b *testing.B) {
+		counting(b)
+
+		for i := 0; i < b.N; i++ {
+			err := trips.LoadVendors(context.Background())
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+		reportQueries(b)
+	})
+
+	b.Run("per trip", func(b *testing.B) {
+		counting(b)
+
+		for i := 0; i < b.N; i++ {
+			for _, t := range trips {
+				err := t.GetTripVendors(context.Background())
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+		reportQueries(b)
+	})
+}
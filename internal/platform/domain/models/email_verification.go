@@ -0,0 +1,177 @@
+package models
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"revelbus/internal/platform/domain"
+	"revelbus/pkg/database"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/spf13/viper"
+)
+
+// EmailVerification is an outstanding link proving the user owns Email. When
+// Email isn't the user's current address it's a change of address, which only
+// takes effect once the link is followed. Like password resets, only a hash
+// of the token is kept.
+type EmailVerification struct {
+	ID      int
+	UserID  int
+	Email   string
+	Hash    string
+	Expires time.Time
+}
+
+// CreateEmailVerification replaces any link already sent to the user with a
+// new one for email, and returns the token to send them.
+func CreateEmailVerification(ctx context.Context, u *User, email string) (*EmailVerification, string, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	token, err := domain.NewToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	v := &EmailVerification{
+		UserID:  u.ID,
+		Email:   email,
+		Hash:    hashToken(token),
+		Expires: time.Now().UTC().Add(verifyTTL()),
+	}
+
+	stmt := `DELETE FROM email_verifications WHERE user_id = ?`
+	_, err = conn.ExecContext(ctx, stmt, v.UserID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	stmt = `INSERT INTO email_verifications (user_id, email, token_hash, expires_at, created_at) VALUES(?, ?, ?, ?, UTC_TIMESTAMP())`
+	result, err := conn.ExecContext(ctx, stmt, v.UserID, v.Email, v.Hash, v.Expires)
+	if err != nil {
+		return nil, "", err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, "", err
+	}
+
+	v.ID = int(id)
+
+	return v, token, nil
+}
+
+// FindEmailVerification looks up the link and checks the token against it.
+// Anything wrong with it is ErrInvalidCredentials.
+func FindEmailVerification(ctx context.Context, id int, token string) (*EmailVerification, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	v := &EmailVerification{
+		ID: id,
+	}
+
+	stmt := `SELECT user_id, email, token_hash, expires_at FROM email_verifications WHERE id = ?`
+	err := conn.QueryRowContext(ctx, stmt, v.ID).Scan(&v.UserID, &v.Email, &v.Hash, &v.Expires)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrInvalidCredentials
+	} else if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(v.Hash), []byte(hashToken(token))) != 1 {
+		return nil, domain.ErrInvalidCredentials
+	}
+
+	if time.Now().After(v.Expires) {
+		return nil, domain.ErrInvalidCredentials
+	}
+
+	return v, nil
+}
+
+// Redeem uses up the link, moves the user to the address it was sent to and
+// marks them verified. Any other links sent to the user stop working with it.
+// It's ErrDuplicateEmail if someone else has taken the address in the
+// meantime.
+func (v *EmailVerification) Redeem(ctx context.Context) error {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	return database.InTx(ctx, func(ctx context.Context) error {
+		conn, _ := database.Conn(ctx)
+
+		stmt := `DELETE FROM email_verifications WHERE id = ? AND expires_at > UTC_TIMESTAMP()`
+		result, err := conn.ExecContext(ctx, stmt, v.ID)
+		if err != nil {
+			return err
+		}
+
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return domain.ErrInvalidCredentials
+		}
+
+		stmt = `UPDATE users SET email = ?, email_verified_at = UTC_TIMESTAMP(), updated_at = UTC_TIMESTAMP() WHERE id = ?`
+		_, err = conn.ExecContext(ctx, stmt, v.Email, v.UserID)
+		if err != nil {
+			merr, ok := err.(*mysql.MySQLError)
+
+			if ok && merr.Number == 1062 {
+				return domain.ErrDuplicateEmail
+			}
+
+			return err
+		}
+
+		// a link to an older address mustn't be able to move them back
+		stmt = `DELETE FROM email_verifications WHERE user_id = ?`
+		_, err = conn.ExecContext(ctx, stmt, v.UserID)
+		return err
+	})
+}
+
+// IsChange reports whether the link is for a new address rather than
+// confirming u's current one.
+func (v *EmailVerification) IsChange(u *User) bool {
+	return !strings.EqualFold(v.Email, u.Email.String)
+}
+
+// SetVerified lets an admin vouch for, or take back, the user's address.
+func (u *User) SetVerified(ctx context.Context, verified bool) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `UPDATE users SET email_verified_at = IF(?, IFNULL(email_verified_at, UTC_TIMESTAMP()), NULL), updated_at = UTC_TIMESTAMP() WHERE id = ?`
+	_, err := conn.ExecContext(ctx, stmt, verified, u.ID)
+	if err != nil {
+		return err
+	}
+
+	if verified {
+		stmt = `DELETE FROM email_verifications WHERE user_id = ?`
+		_, err = conn.ExecContext(ctx, stmt, u.ID)
+	}
+	return err
+}
+
+func (u *User) IsVerified() bool {
+	return u.VerifiedAt.Valid
+}
+
+func verifyTTL() time.Duration {
+	if d := viper.GetDuration("verification.ttl"); d > 0 {
+		return d
+	}
+	return 48 * time.Hour
+}
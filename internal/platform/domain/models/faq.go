@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"revelbus/internal/platform/domain"
 	"revelbus/internal/platform/forms"
@@ -9,6 +10,7 @@ import (
 
 type FAQ struct {
 	ID       int
+	Version  int
 	Question sql.NullString
 	Answer   sql.NullString
 	Category sql.NullString
@@ -22,6 +24,7 @@ type GroupedFAQs map[string][]*FAQ
 
 type FAQForm struct {
 	ID       string
+	Version  int
 	Question string
 	Answer   string
 	Category string
@@ -43,11 +46,13 @@ func (f *FAQForm) Valid() bool {
 	return len(f.Errors) == 0
 }
 
-func (f *FAQ) Create() error {
-	conn, _ := database.GetConnection()
+func (f *FAQ) Create(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
 	stmt := `INSERT INTO faqs (question, answer, category, sort_order, active, created_at, updated_at) VALUES(?, ?, ?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP())`
-	result, err := conn.Exec(stmt, f.Question, f.Answer, f.Category, f.Order, f.Active)
+	result, err := conn.ExecContext(ctx, stmt, f.Question, f.Answer, f.Category, f.Order, f.Active)
 	if err != nil {
 		return err
 	}
@@ -58,14 +63,17 @@ func (f *FAQ) Create() error {
 	}
 
 	f.ID = int(id)
+	f.Version = 1
 	return nil
 }
 
-func (f *FAQ) Fetch() error {
-	conn, _ := database.GetConnection()
+func (f *FAQ) Fetch(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
-	stmt := `SELECT question, answer, category, sort_order, active FROM faqs WHERE id = ?`
-	err := conn.QueryRow(stmt, f.ID).Scan(&f.Question, &f.Answer, &f.Category, &f.Order, &f.Active)
+	stmt := `SELECT question, answer, category, sort_order, active, version FROM faqs WHERE id = ? AND deleted_at IS NULL`
+	err := conn.QueryRowContext(ctx, stmt, f.ID).Scan(&f.Question, &f.Answer, &f.Category, &f.Order, &f.Active, &f.Version)
 	if err == sql.ErrNoRows {
 		return domain.ErrNotFound
 	}
@@ -73,33 +81,41 @@ func (f *FAQ) Fetch() error {
 	return err
 }
 
-func (f *FAQ) Update() error {
-	conn, _ := database.GetConnection()
+func (f *FAQ) Update(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
-	stmt := `UPDATE faqs SET question = ?, answer= ?, category = ?, sort_order = ?, active = ?, updated_at = UTC_TIMESTAMP() WHERE id = ?`
-	_, err := conn.Exec(stmt, f.Question, f.Answer, f.Category, f.Order, f.Active, f.ID)
-	if err == sql.ErrNoRows {
-		return domain.ErrNotFound
+	stmt := `UPDATE faqs SET question = ?, answer= ?, category = ?, sort_order = ?, active = ?, version = version + 1, updated_at = UTC_TIMESTAMP() WHERE id = ?` + versioned
+	result, err := conn.ExecContext(ctx, stmt, f.Question, f.Answer, f.Category, f.Order, f.Active, f.ID, f.Version)
+	if err != nil {
+		return err
 	}
-	return err
+	return bump(result, &f.Version)
 }
 
-func (f *FAQ) Delete() error {
-	conn, _ := database.GetConnection()
+// Delete moves the FAQ to the trash.
+func (f *FAQ) Delete(ctx context.Context) error {
+	return trash(ctx, "faqs", f.ID)
+}
 
-	stmt := `DELETE FROM faqs WHERE id = ?`
-	_, err := conn.Exec(stmt, f.ID)
-	if err == sql.ErrNoRows {
-		return nil
-	}
-	return err
+// Restore takes the FAQ back out of the trash.
+func (f *FAQ) Restore(ctx context.Context) error {
+	return restore(ctx, "faqs", f.ID)
+}
+
+// Purge deletes the FAQ for good. It has to be in the trash first.
+func (f *FAQ) Purge(ctx context.Context) error {
+	return purge(ctx, "faqs", f.ID)
 }
 
-func FetchFAQs() (*FAQs, error) {
-	conn, _ := database.GetConnection()
+func FetchFAQs(ctx context.Context) (*FAQs, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
-	stmt := `SELECT id, question, answer, category, sort_order, active FROM faqs ORDER BY sort_order`
-	rows, err := conn.Query(stmt)
+	stmt := `SELECT id, question, answer, category, sort_order, active FROM faqs WHERE deleted_at IS NULL ORDER BY sort_order`
+	rows, err := conn.QueryContext(ctx, stmt)
 	if err != nil {
 		return nil, err
 	}
@@ -122,13 +138,15 @@ func FetchFAQs() (*FAQs, error) {
 	return &faqs, nil
 }
 
-func FindActiveFAQs() (*GroupedFAQs, error) {
-	conn, _ := database.GetConnection()
+func FindActiveFAQs(ctx context.Context) (*GroupedFAQs, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
 	faqs := make(GroupedFAQs)
 
-	stmt := `SELECT id, question, answer, category, sort_order, active FROM faqs WHERE active = 1 ORDER BY sort_order`
-	rows, err := conn.Query(stmt)
+	stmt := `SELECT id, question, answer, category, sort_order, active FROM faqs WHERE active = 1 AND deleted_at IS NULL ORDER BY sort_order`
+	rows, err := conn.QueryContext(ctx, stmt)
 	if err != nil {
 		return nil, err
 	}
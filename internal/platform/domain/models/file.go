@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"revelbus/internal/platform/domain"
 	"revelbus/pkg/database"
@@ -18,11 +19,13 @@ type File struct {
 
 type Files []*File
 
-func (f *File) Create() error {
-	conn, _ := database.GetConnection()
+func (f *File) Create(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
 	stmt := `INSERT INTO files (name, thumb, created_at, updated_at) VALUES(?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP())`
-	result, err := conn.Exec(stmt, f.Name, f.Thumb)
+	result, err := conn.ExecContext(ctx, stmt, f.Name, f.Thumb)
 	if err != nil {
 		return err
 	}
@@ -36,11 +39,13 @@ func (f *File) Create() error {
 	return nil
 }
 
-func (f *File) Fetch() error {
-	conn, _ := database.GetConnection()
+func (f *File) Fetch(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
 	stmt := `SELECT name, thumb, created_at FROM files WHERE id = ?`
-	err := conn.QueryRow(stmt, f.ID).Scan(&f.Name, &f.Thumb, &f.Created)
+	err := conn.QueryRowContext(ctx, stmt, f.ID).Scan(&f.Name, &f.Thumb, &f.Created)
 	if err == sql.ErrNoRows {
 		return domain.ErrNotFound
 	}
@@ -48,11 +53,13 @@ func (f *File) Fetch() error {
 	return err
 }
 
-func (f *File) Delete() error {
-	conn, _ := database.GetConnection()
+func (f *File) Delete(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
 	stmt := `DELETE FROM files WHERE id = ?`
-	_, err := conn.Exec(stmt, f.ID)
+	_, err := conn.ExecContext(ctx, stmt, f.ID)
 	if err != nil {
 		merr, ok := err.(*mysql.MySQLError)
 
@@ -66,11 +73,27 @@ func (f *File) Delete() error {
 	return err
 }
 
-func FetchFiles() (*Files, error) {
-	conn, _ := database.GetConnection()
+// InUse reports whether anything still shows the file: a vendor or trip, even
+// one in the trash, a gallery, or a vendor change waiting to be reviewed.
+func (f *File) InUse(ctx context.Context) (bool, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	var used bool
+
+	stmt := `SELECT EXISTS(SELECT 1 FROM vendors WHERE brand_id = ?) OR EXISTS(SELECT 1 FROM trips WHERE image_id = ?) OR EXISTS(SELECT 1 FROM galleries_images WHERE file_id = ?) OR EXISTS(SELECT 1 FROM vendor_changes WHERE brand_id = ? AND status = ?)`
+	err := conn.QueryRowContext(ctx, stmt, f.ID, f.ID, f.ID, f.ID, ChangePending).Scan(&used)
+	return used, err
+}
+
+func FetchFiles(ctx context.Context) (*Files, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
 	stmt := `SELECT id, name, thumb, created_at FROM files ORDER BY name`
-	rows, err := conn.Query(stmt)
+	rows, err := conn.QueryContext(ctx, stmt)
 	if err != nil {
 		return nil, err
 	}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"revelbus/internal/platform/domain"
 	"revelbus/internal/platform/forms"
@@ -12,9 +13,10 @@ import (
 )
 
 type Gallery struct {
-	ID     int
-	Name   sql.NullString
-	Folder sql.NullString
+	ID      int
+	Version int
+	Name    sql.NullString
+	Folder  sql.NullString
 
 	Images Files
 }
@@ -22,9 +24,10 @@ type Gallery struct {
 type Galleries []*Gallery
 
 type GalleryForm struct {
-	ID     string
-	Name   string
-	Folder string
+	ID      string
+	Version int
+	Name    string
+	Folder  string
 
 	Errors map[string]string
 }
@@ -38,8 +41,10 @@ func (f *GalleryForm) Valid() bool {
 	return len(f.Errors) == 0
 }
 
-func (g *Gallery) Create() error {
-	conn, _ := database.GetConnection()
+func (g *Gallery) Create(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
 	if g.Folder.String == "" {
 		g.Folder = sql.NullString{
@@ -49,7 +54,7 @@ func (g *Gallery) Create() error {
 	}
 
 	stmt := `INSERT INTO galleries (name, folder, created_at, updated_at) VALUES(?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP())`
-	result, err := conn.Exec(stmt, g.Name, g.Folder)
+	result, err := conn.ExecContext(ctx, stmt, g.Name, g.Folder)
 	if err != nil {
 		return err
 	}
@@ -60,25 +65,32 @@ func (g *Gallery) Create() error {
 	}
 
 	g.ID = int(id)
+	g.Version = 1
 
 	return nil
 }
 
-func (g *Gallery) Fetch() error {
-	conn, _ := database.GetConnection()
+func (g *Gallery) Fetch(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
-	stmt := `SELECT name, folder FROM galleries WHERE id = ?`
-	err := conn.QueryRow(stmt, g.ID).Scan(&g.Name, &g.Folder)
+	stmt := `SELECT name, folder, version FROM galleries WHERE id = ? AND deleted_at IS NULL`
+	err := conn.QueryRowContext(ctx, stmt, g.ID).Scan(&g.Name, &g.Folder, &g.Version)
 	if err == sql.ErrNoRows {
 		return domain.ErrNotFound
+	} else if err != nil {
+		return err
 	}
 
-	err = g.GetImages()
+	err = g.GetImages(ctx)
 	return err
 }
 
-func (g *Gallery) Update() error {
-	conn, _ := database.GetConnection()
+func (g *Gallery) Update(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
 	if g.Folder.String == "" {
 		g.Folder = sql.NullString{
@@ -87,35 +99,58 @@ func (g *Gallery) Update() error {
 		}
 	}
 
-	stmt := `UPDATE galleries SET name = ?, updated_at = UTC_TIMESTAMP() WHERE id = ?`
-	_, err := conn.Exec(stmt, g.Name, g.ID)
-	if err == sql.ErrNoRows {
-		return domain.ErrNotFound
+	stmt := `UPDATE galleries SET name = ?, version = version + 1, updated_at = UTC_TIMESTAMP() WHERE id = ?` + versioned
+	result, err := conn.ExecContext(ctx, stmt, g.Name, g.ID, g.Version)
+	if err != nil {
+		return err
 	}
-	return err
+	return bump(result, &g.Version)
 }
 
-func (g *Gallery) Delete() error {
-	conn, _ := database.GetConnection()
+// Delete moves the gallery to the trash. Its images stay until it's purged.
+func (g *Gallery) Delete(ctx context.Context) error {
+	return trash(ctx, "galleries", g.ID)
+}
 
-	err := g.DeleteImages()
-	if err != nil && err != sql.ErrNoRows {
-		return err
-	}
+// Restore takes the gallery back out of the trash.
+func (g *Gallery) Restore(ctx context.Context) error {
+	return restore(ctx, "galleries", g.ID)
+}
 
-	stmt := `DELETE FROM galleries WHERE id = ?`
-	_, err = conn.Exec(stmt, g.ID)
-	if err == sql.ErrNoRows {
-		return nil
-	}
-	return err
+// Purge deletes the gallery and its images for good, together, so a failure
+// part way doesn't leave a gallery with half its images. It has to be in the
+// trash first. Folder is filled in so the caller can clear it off disk.
+func (g *Gallery) Purge(ctx context.Context) error {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	return database.InTx(ctx, func(ctx context.Context) error {
+		conn, _ := database.Conn(ctx)
+
+		stmt := `SELECT folder FROM galleries WHERE id = ? AND deleted_at IS NOT NULL`
+		err := conn.QueryRowContext(ctx, stmt, g.ID).Scan(&g.Folder)
+		if err == sql.ErrNoRows {
+			return domain.ErrNotFound
+		} else if err != nil {
+			return err
+		}
+
+		err = g.DeleteImages(ctx)
+		if err != nil {
+			return err
+		}
+
+		return purge(ctx, "galleries", g.ID)
+	})
 }
 
-func FetchGalleries() (*Galleries, error) {
-	conn, _ := database.GetConnection()
+func FetchGalleries(ctx context.Context) (*Galleries, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
-	stmt := `SELECT id, name FROM galleries`
-	rows, err := conn.Query(stmt)
+	stmt := `SELECT id, name FROM galleries WHERE deleted_at IS NULL`
+	rows, err := conn.QueryContext(ctx, stmt)
 	if err != nil {
 		return nil, err
 	}
@@ -138,11 +173,13 @@ func FetchGalleries() (*Galleries, error) {
 	return &galleries, nil
 }
 
-func (g *Gallery) GetImages() error {
-	conn, _ := database.GetConnection()
+func (g *Gallery) GetImages(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
 	stmt := `SELECT f.id, f.name, f.thumb FROM galleries_images gi JOIN files f ON gi.file_id = f.id WHERE gi.gallery_id = ?`
-	rows, err := conn.Query(stmt, g.ID)
+	rows, err := conn.QueryContext(ctx, stmt, g.ID)
 	if err != nil {
 		return err
 	}
@@ -167,22 +204,26 @@ func (g *Gallery) GetImages() error {
 	return nil
 }
 
-func (g *Gallery) DeleteImages() error {
-	conn, _ := database.GetConnection()
+func (g *Gallery) DeleteImages(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
 	stmt := `DELETE f, gi FROM files f JOIN galleries_images gi ON gi.file_id = f.id WHERE gi.gallery_id = ?`
-	_, err := conn.Exec(stmt, g.ID)
+	_, err := conn.ExecContext(ctx, stmt, g.ID)
 	if err == sql.ErrNoRows {
 		return nil
 	}
 	return err
 }
 
-func (g *Gallery) AttachImage(fid string) error {
-	conn, _ := database.GetConnection()
+func (g *Gallery) AttachImage(ctx context.Context, fid string) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
 	stmt := `INSERT INTO galleries_images (gallery_id, file_id, created_at, updated_at) VALUES(?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP())`
-	_, err := conn.Exec(stmt, g.ID, fid)
+	_, err := conn.ExecContext(ctx, stmt, g.ID, fid)
 	if err != nil {
 		merr, ok := err.(*mysql.MySQLError)
 
@@ -193,11 +234,13 @@ func (g *Gallery) AttachImage(fid string) error {
 	return err
 }
 
-func (g *Gallery) DetachImage(fid string) error {
-	conn, _ := database.GetConnection()
+func (g *Gallery) DetachImage(ctx context.Context, fid string) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
 	stmt := `DELETE FROM galleries_images WHERE gallery_id = ? AND file_id = ?`
-	_, err := conn.Exec(stmt, g.ID, fid)
+	_, err := conn.ExecContext(ctx, stmt, g.ID, fid)
 	if err != nil && err == sql.ErrNoRows {
 		return nil
 	}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"revelbus/internal/platform/domain"
+	"revelbus/pkg/database"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Identity links a user to an account at an outside OpenID Connect
+// provider, so they can sign in there instead of with a password. The
+// provider's subject is the only thing trusted to identify them; the email is
+// kept for reference.
+type Identity struct {
+	ID      int
+	UserID  int
+	Issuer  string
+	Subject string
+	Email   sql.NullString
+}
+
+func (i *Identity) Create(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `INSERT INTO user_identities (user_id, issuer, subject, email, created_at, last_login_at) VALUES(?, ?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP())`
+	result, err := conn.ExecContext(ctx, stmt, i.UserID, i.Issuer, i.Subject, i.Email)
+	if err != nil {
+		merr, ok := err.(*mysql.MySQLError)
+
+		if ok && merr.Number == 1062 {
+			return domain.ErrDuplicate
+		}
+
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	i.ID = int(id)
+
+	return nil
+}
+
+// FindIdentityUser returns the user linked to the provider account, and notes
+// that they've just used it.
+func FindIdentityUser(ctx context.Context, issuer string, subject string) (*User, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	var id int
+
+	stmt := `SELECT user_id FROM user_identities WHERE issuer = ? AND subject = ?`
+	err := conn.QueryRowContext(ctx, stmt, issuer, subject).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	stmt = `UPDATE user_identities SET last_login_at = UTC_TIMESTAMP() WHERE issuer = ? AND subject = ?`
+	_, err = conn.ExecContext(ctx, stmt, issuer, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &User{
+		ID: id,
+	}
+
+	err = u.Fetch(ctx)
+	return u, err
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"revelbus/internal/platform/domain"
+	"revelbus/pkg/database"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Impersonation records an admin viewing the site as another user, so
+// there's always a trail of who looked at what account and when.
+type Impersonation struct {
+	ID        int
+	AdminID   int
+	UserID    int
+	IP        sql.NullString
+	UserAgent sql.NullString
+	Started   time.Time
+	Ended     mysql.NullTime
+
+	Admin *User
+}
+
+type Impersonations []*Impersonation
+
+func (i *Impersonation) Start(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	if len(i.UserAgent.String) > 512 {
+		i.UserAgent.String = i.UserAgent.String[:512]
+	}
+
+	stmt := `INSERT INTO impersonations (admin_id, user_id, ip, user_agent, started_at) VALUES(?, ?, ?, ?, UTC_TIMESTAMP())`
+	result, err := conn.ExecContext(ctx, stmt, i.AdminID, i.UserID, i.IP, i.UserAgent)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	i.ID = int(id)
+
+	return nil
+}
+
+func EndImpersonation(ctx context.Context, id int) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `UPDATE impersonations SET ended_at = UTC_TIMESTAMP() WHERE id = ? AND ended_at IS NULL`
+	_, err := conn.ExecContext(ctx, stmt, id)
+	return err
+}
+
+// FetchImpersonations returns the most recent times admins viewed the site as
+// the user.
+func FetchImpersonations(ctx context.Context, userID int, limit int) (*Impersonations, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `SELECT i.id, IFNULL(i.admin_id, 0), i.user_id, i.ip, i.user_agent, i.started_at, i.ended_at, u.name FROM impersonations i LEFT JOIN users u ON i.admin_id = u.id WHERE i.user_id = ? ORDER BY i.started_at DESC LIMIT ?`
+	rows, err := conn.QueryContext(ctx, stmt, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := Impersonations{}
+	for rows.Next() {
+		i := &Impersonation{
+			Admin: &User{},
+		}
+		err := rows.Scan(&i.ID, &i.AdminID, &i.UserID, &i.IP, &i.UserAgent, &i.Started, &i.Ended, &i.Admin.Name)
+		if err != nil {
+			return nil, err
+		}
+		i.Admin.ID = i.AdminID
+		list = append(list, i)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+// FindImpersonatedUser loads the user an admin is viewing the site as, with
+// everything FindSessionUser fills in.
+func FindImpersonatedUser(ctx context.Context, id int) (*User, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	u := &User{}
+
+	stmt := `SELECT u.id, u.name, u.email, u.role, u.totp_enabled, u.email_verified_at, EXISTS(SELECT 1 FROM vendor_users vu WHERE vu.user_id = u.id) FROM users u WHERE u.id = ? AND u.deleted_at IS NULL`
+	err := conn.QueryRowContext(ctx, stmt, id).Scan(&u.ID, &u.Name, &u.Email, &u.Role, &u.TOTPEnabled, &u.VerifiedAt, &u.Partner)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = u.LoadPermissions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
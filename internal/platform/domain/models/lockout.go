@@ -0,0 +1,153 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"revelbus/pkg/database"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/spf13/viper"
+)
+
+const maxLoginDelay = time.Minute
+
+// LoginWait returns how long the account has to wait before the next login
+// attempt. After a few failures each attempt waits twice as long as the last,
+// and too many in a row lock the account for a while.
+func LoginWait(ctx context.Context, email string) (time.Duration, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	var failed int
+	var lastFailed, lockedUntil mysql.NullTime
+
+	stmt := `SELECT failed_logins, last_failed_at, locked_until FROM users WHERE email = ?`
+	err := conn.QueryRowContext(ctx, stmt, email).Scan(&failed, &lastFailed, &lockedUntil)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+
+	if lockedUntil.Valid && lockedUntil.Time.After(now) {
+		return lockedUntil.Time.Sub(now), nil
+	}
+
+	after := loginSetting("login.delay_after", 3)
+	if failed < after || !lastFailed.Valid {
+		return 0, nil
+	}
+
+	delay := maxLoginDelay
+	if n := uint(failed - after); n < 6 {
+		delay = time.Second << n
+	}
+
+	return lastFailed.Time.Add(delay).Sub(now), nil
+}
+
+// RecordFailedLogin counts a failed attempt against the account. It returns
+// the user when this failure locks the account, so they can be told about it.
+func RecordFailedLogin(ctx context.Context, email string) (*User, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `UPDATE users SET failed_logins = failed_logins + 1, last_failed_at = UTC_TIMESTAMP() WHERE email = ?`
+	_, err := conn.ExecContext(ctx, stmt, email)
+	if err != nil {
+		return nil, err
+	}
+
+	until := time.Now().UTC().Add(lockoutDuration())
+
+	stmt = `UPDATE users SET failed_logins = 0, locked_until = ? WHERE email = ? AND failed_logins >= ?`
+	result, err := conn.ExecContext(ctx, stmt, until, email, loginSetting("login.max_failures", 10))
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, nil
+	}
+
+	u := &User{
+		Email:       sql.NullString{String: email, Valid: true},
+		LockedUntil: mysql.NullTime{Time: until, Valid: true},
+	}
+
+	err = u.Fetch(ctx)
+	return u, err
+}
+
+// ResetFailedLogins clears the count after a successful login.
+func ResetFailedLogins(ctx context.Context, id int) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `UPDATE users SET failed_logins = 0, last_failed_at = NULL WHERE id = ? AND failed_logins > 0`
+	_, err := conn.ExecContext(ctx, stmt, id)
+	return err
+}
+
+func (u *User) Unlock(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `UPDATE users SET failed_logins = 0, last_failed_at = NULL, locked_until = NULL, updated_at = UTC_TIMESTAMP() WHERE id = ?`
+	_, err := conn.ExecContext(ctx, stmt, u.ID)
+	return err
+}
+
+func FetchLockedUsers(ctx context.Context) (Users, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `SELECT id, name, email, role, locked_until FROM users WHERE locked_until > UTC_TIMESTAMP() AND deleted_at IS NULL ORDER BY locked_until DESC`
+	rows, err := conn.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := Users{}
+	for rows.Next() {
+		u := &User{}
+		err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.Role, &u.LockedUntil)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func lockoutDuration() time.Duration {
+	if d := viper.GetDuration("login.lockout"); d > 0 {
+		return d
+	}
+	return 15 * time.Minute
+}
+
+func loginSetting(key string, def int) int {
+	if n := viper.GetInt(key); n > 0 {
+		return n
+	}
+	return def
+}
@@ -0,0 +1,175 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"revelbus/internal/platform/domain"
+	"revelbus/internal/platform/forms"
+	"revelbus/pkg/database"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	MessageUnread   = "unread"
+	MessageRead     = "read"
+	MessageArchived = "archived"
+	MessageSpam     = "spam"
+)
+
+// Message is a submission from the contact form.
+type Message struct {
+	ID      int
+	Name    sql.NullString
+	Email   sql.NullString
+	Phone   sql.NullString
+	Body    sql.NullString
+	IP      sql.NullString
+	Score   int
+	Status  sql.NullString
+	Replied mysql.NullTime
+	Created time.Time
+}
+
+type Messages []*Message
+
+type ReplyForm struct {
+	Subject string
+	Body    string
+
+	Errors map[string]string
+}
+
+func (f *ReplyForm) Valid() bool {
+	v := forms.NewValidator()
+
+	v.Required("Subject", f.Subject)
+	v.Required("Body", f.Body)
+
+	f.Errors = v.Errors
+	return len(f.Errors) == 0
+}
+
+func (m *Message) Create(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	if !m.Status.Valid {
+		m.Status = sql.NullString{String: MessageUnread, Valid: true}
+	}
+
+	stmt := `INSERT INTO messages (name, email, phone, body, ip, score, status, created_at, updated_at) VALUES(?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP())`
+	result, err := conn.ExecContext(ctx, stmt, m.Name, m.Email, m.Phone, m.Body, m.IP, m.Score, m.Status)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	m.ID = int(id)
+
+	return nil
+}
+
+func (m *Message) Fetch(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `SELECT name, email, phone, body, ip, score, status, replied_at, created_at FROM messages WHERE id = ?`
+	err := conn.QueryRowContext(ctx, stmt, m.ID).Scan(&m.Name, &m.Email, &m.Phone, &m.Body, &m.IP, &m.Score, &m.Status, &m.Replied, &m.Created)
+	if err == sql.ErrNoRows {
+		return domain.ErrNotFound
+	}
+
+	return err
+}
+
+func (m *Message) SetStatus(ctx context.Context, status string) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `UPDATE messages SET status = ?, updated_at = UTC_TIMESTAMP() WHERE id = ?`
+	result, err := conn.ExecContext(ctx, stmt, status, m.ID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return domain.ErrNotFound
+	}
+
+	m.Status = sql.NullString{String: status, Valid: true}
+	return nil
+}
+
+func (m *Message) MarkReplied(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `UPDATE messages SET status = IF(status = ?, ?, status), replied_at = UTC_TIMESTAMP(), updated_at = UTC_TIMESTAMP() WHERE id = ?`
+	_, err := conn.ExecContext(ctx, stmt, MessageUnread, MessageRead, m.ID)
+	return err
+}
+
+func (m *Message) Delete(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `DELETE FROM messages WHERE id = ?`
+	_, err := conn.ExecContext(ctx, stmt, m.ID)
+	return err
+}
+
+// FetchMessages lists messages in a folder. The inbox is everything that's
+// neither archived nor spam.
+func FetchMessages(ctx context.Context, folder string) (*Messages, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	var rows *sql.Rows
+	var err error
+
+	switch folder {
+	case MessageArchived, MessageSpam:
+		stmt := `SELECT id, name, email, body, score, status, replied_at, created_at FROM messages WHERE status = ? ORDER BY created_at DESC`
+		rows, err = conn.QueryContext(ctx, stmt, folder)
+	default:
+		stmt := `SELECT id, name, email, body, score, status, replied_at, created_at FROM messages WHERE status IN (?, ?) ORDER BY created_at DESC`
+		rows, err = conn.QueryContext(ctx, stmt, MessageUnread, MessageRead)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := Messages{}
+	for rows.Next() {
+		m := &Message{}
+		err := rows.Scan(&m.ID, &m.Name, &m.Email, &m.Body, &m.Score, &m.Status, &m.Replied, &m.Created)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &messages, nil
+}
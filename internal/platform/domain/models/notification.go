@@ -0,0 +1,119 @@
+package models
+
+import (
+	"context"
+	"revelbus/pkg/database"
+	"time"
+)
+
+const (
+	NotifyReminderDays  = "reminder_days"
+	NotifyReminderHours = "reminder_hours"
+	NotifyFollowUp      = "follow_up"
+)
+
+// RecordNotification claims the notification for the rider. It returns false
+// if it was already recorded, so a notification is only ever sent once even
+// across restarts.
+func RecordNotification(ctx context.Context, tripID int, userID int, kind string) (bool, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `INSERT IGNORE INTO trip_notifications (trip_id, user_id, kind, sent_at) VALUES(?, ?, ?, UTC_TIMESTAMP())`
+	result, err := conn.ExecContext(ctx, stmt, tripID, userID, kind)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// ForgetNotification removes a claim when the notification couldn't be
+// queued, so the next run tries again.
+func ForgetNotification(ctx context.Context, tripID int, userID int, kind string) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `DELETE FROM trip_notifications WHERE trip_id = ? AND user_id = ? AND kind = ?`
+	_, err := conn.ExecContext(ctx, stmt, tripID, userID, kind)
+	return err
+}
+
+// FindTripsToRemind returns published trips with reminders on that start
+// between from and to.
+func FindTripsToRemind(ctx context.Context, from time.Time, to time.Time) (Trips, error) {
+	stmt := `SELECT id FROM trips WHERE status = 'published' AND remind = 1 AND start > ? AND start <= ? AND deleted_at IS NULL ORDER BY start`
+	return findTripIDs(ctx, stmt, from.UTC(), to.UTC())
+}
+
+// FindTripsToFollowUp returns trips with follow-ups on that ended between
+// from and to.
+func FindTripsToFollowUp(ctx context.Context, from time.Time, to time.Time) (Trips, error) {
+	stmt := `SELECT id FROM trips WHERE status IN ('published', 'complete') AND follow_up = 1 AND end > ? AND end <= ? AND deleted_at IS NULL ORDER BY end`
+	return findTripIDs(ctx, stmt, from.UTC(), to.UTC())
+}
+
+func findTripIDs(ctx context.Context, stmt string, args ...interface{}) (Trips, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	rows, err := conn.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	trips := Trips{}
+	for rows.Next() {
+		t := &Trip{}
+		err := rows.Scan(&t.ID)
+		if err != nil {
+			return nil, err
+		}
+		trips = append(trips, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return trips, nil
+}
+
+// FindRidersToNotify returns riders booked on the trip who haven't been sent
+// the kind of notification yet.
+func FindRidersToNotify(ctx context.Context, tripID int, kind string) (Users, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `SELECT u.id, u.name, u.email FROM bookings b JOIN users u ON b.user_id = u.id LEFT JOIN trip_notifications n ON n.trip_id = b.trip_id AND n.user_id = b.user_id AND n.kind = ? WHERE b.trip_id = ? AND b.status = ? AND n.user_id IS NULL AND u.deleted_at IS NULL`
+	rows, err := conn.QueryContext(ctx, stmt, kind, tripID, BookingBooked)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := Users{}
+	for rows.Next() {
+		u := &User{}
+		err := rows.Scan(&u.ID, &u.Name, &u.Email)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
@@ -0,0 +1,221 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"revelbus/internal/platform/domain"
+	"revelbus/pkg/database"
+	"strings"
+	"time"
+)
+
+const (
+	OutboxPending = "pending"
+	OutboxSending = "sending"
+	OutboxSent    = "sent"
+	OutboxFailed  = "failed"
+)
+
+// claimTimeout is how long a worker has to send what it claimed before the
+// mail is handed to another, in case the first one died sending it.
+const claimTimeout = 15 * time.Minute
+
+type OutboxEmail struct {
+	ID          int
+	To          sql.NullString
+	Subject     sql.NullString
+	Text        sql.NullString
+	HTML        sql.NullString
+	Attachments sql.NullString
+	Status      sql.NullString
+	Attempts    int
+	LastError   sql.NullString
+	NextAttempt time.Time
+	Created     time.Time
+}
+
+type OutboxEmails []*OutboxEmail
+
+func (e *OutboxEmail) Recipients() []string {
+	if e.To.String == "" {
+		return []string{}
+	}
+	return strings.Split(e.To.String, ",")
+}
+
+func (e *OutboxEmail) Create(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `INSERT INTO email_outbox (recipients, subject, text_body, html_body, attachments, status, attempts, next_attempt_at, created_at, updated_at) VALUES(?, ?, ?, ?, ?, ?, 0, UTC_TIMESTAMP(), UTC_TIMESTAMP(), UTC_TIMESTAMP())`
+	result, err := conn.ExecContext(ctx, stmt, e.To, e.Subject, e.Text, e.HTML, e.Attachments, OutboxPending)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	e.ID = int(id)
+	e.Status = sql.NullString{String: OutboxPending, Valid: true}
+
+	return nil
+}
+
+func (e *OutboxEmail) Fetch(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `SELECT recipients, subject, text_body, html_body, status, attempts, last_error, next_attempt_at, created_at FROM email_outbox WHERE id = ?`
+	err := conn.QueryRowContext(ctx, stmt, e.ID).Scan(&e.To, &e.Subject, &e.Text, &e.HTML, &e.Status, &e.Attempts, &e.LastError, &e.NextAttempt, &e.Created)
+	if err == sql.ErrNoRows {
+		return domain.ErrNotFound
+	}
+
+	return err
+}
+
+// MarkSent records the delivery. The bodies and attachments are dropped
+// then, since password resets and the like shouldn't outlive being sent.
+func (e *OutboxEmail) MarkSent(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `UPDATE email_outbox SET status = ?, attempts = attempts + 1, last_error = NULL, text_body = NULL, html_body = NULL, attachments = NULL, claimed_by = NULL, sent_at = UTC_TIMESTAMP(), updated_at = UTC_TIMESTAMP() WHERE id = ?`
+	_, err := conn.ExecContext(ctx, stmt, OutboxSent, e.ID)
+	return err
+}
+
+// MarkAttemptFailed records a failed delivery. The message is retried at
+// next unless it has used up its attempts, in which case it is marked failed.
+func (e *OutboxEmail) MarkAttemptFailed(ctx context.Context, cause error, next time.Time, maxAttempts int) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	e.Attempts++
+	status := OutboxPending
+	if e.Attempts >= maxAttempts {
+		status = OutboxFailed
+	}
+
+	stmt := `UPDATE email_outbox SET status = ?, attempts = ?, last_error = ?, next_attempt_at = ?, claimed_by = NULL, updated_at = UTC_TIMESTAMP() WHERE id = ?`
+	_, err := conn.ExecContext(ctx, stmt, status, e.Attempts, cause.Error(), next.UTC(), e.ID)
+	return err
+}
+
+// Resend puts a failed message back in the queue with a fresh set of attempts.
+func (e *OutboxEmail) Resend(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	// only failed mail, so a message the worker is sending isn't sent twice
+	stmt := `UPDATE email_outbox SET status = ?, attempts = 0, next_attempt_at = UTC_TIMESTAMP(), updated_at = UTC_TIMESTAMP() WHERE id = ? AND status = ?`
+	result, err := conn.ExecContext(ctx, stmt, OutboxPending, e.ID, OutboxFailed)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// PurgeSentEmails deletes what's left of mail sent before the given time and
+// returns how many were removed.
+func PurgeSentEmails(ctx context.Context, before time.Time) (int64, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `DELETE FROM email_outbox WHERE status = ? AND sent_at < ?`
+	result, err := conn.ExecContext(ctx, stmt, OutboxSent, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// ClaimDueEmails marks up to limit messages that are due as being sent by
+// this worker and returns them, so two workers never pick up the same one.
+func ClaimDueEmails(ctx context.Context, limit int) (OutboxEmails, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	claim, err := domain.NewToken()
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := `UPDATE email_outbox SET status = ?, claimed_by = ?, claimed_at = UTC_TIMESTAMP() WHERE (status = ? AND next_attempt_at <= UTC_TIMESTAMP()) OR (status = ? AND claimed_at < ?) ORDER BY next_attempt_at, id LIMIT ?`
+	_, err = conn.ExecContext(ctx, stmt, OutboxSending, claim, OutboxPending, OutboxSending, time.Now().Add(-claimTimeout).UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt = `SELECT id, recipients, subject, text_body, html_body, attachments, status, attempts FROM email_outbox WHERE status = ? AND claimed_by = ? ORDER BY next_attempt_at, id`
+	rows, err := conn.QueryContext(ctx, stmt, OutboxSending, claim)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	emails := OutboxEmails{}
+	for rows.Next() {
+		e := &OutboxEmail{}
+		err := rows.Scan(&e.ID, &e.To, &e.Subject, &e.Text, &e.HTML, &e.Attachments, &e.Status, &e.Attempts)
+		if err != nil {
+			return nil, err
+		}
+		emails = append(emails, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return emails, nil
+}
+
+func FetchFailedEmails(ctx context.Context) (*OutboxEmails, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `SELECT id, recipients, subject, status, attempts, last_error, created_at FROM email_outbox WHERE status = ? ORDER BY created_at DESC`
+	rows, err := conn.QueryContext(ctx, stmt, OutboxFailed)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	emails := OutboxEmails{}
+	for rows.Next() {
+		e := &OutboxEmail{}
+		err := rows.Scan(&e.ID, &e.To, &e.Subject, &e.Status, &e.Attempts, &e.LastError, &e.Created)
+		if err != nil {
+			return nil, err
+		}
+		emails = append(emails, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &emails, nil
+}
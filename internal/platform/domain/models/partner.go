@@ -0,0 +1,156 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"revelbus/internal/platform/domain"
+	"revelbus/pkg/database"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// PartnerTrip is a trip a vendor is on, as the partner portal shows it.
+type PartnerTrip struct {
+	ID     int
+	Title  sql.NullString
+	Slug   sql.NullString
+	Status sql.NullString
+	Start  time.Time
+	End    time.Time
+	Role   string
+	Booked int
+}
+
+type PartnerTrips []*PartnerTrip
+
+// LinkVendorUser gives the user access to the vendor in the partner portal.
+func LinkVendorUser(ctx context.Context, vendorID int, userID int) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `INSERT INTO vendor_users (vendor_id, user_id, created_at) VALUES(?, ?, UTC_TIMESTAMP())`
+	_, err := conn.ExecContext(ctx, stmt, vendorID, userID)
+	if err != nil {
+		merr, ok := err.(*mysql.MySQLError)
+
+		if ok && merr.Number == 1062 {
+			return domain.ErrDuplicate
+		}
+	}
+	return err
+}
+
+func UnlinkVendorUser(ctx context.Context, vendorID int, userID int) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `DELETE FROM vendor_users WHERE vendor_id = ? AND user_id = ?`
+	_, err := conn.ExecContext(ctx, stmt, vendorID, userID)
+	return err
+}
+
+// IsVendorUser reports whether the user can manage the vendor in the portal.
+func IsVendorUser(ctx context.Context, vendorID int, userID int) (bool, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	var n int
+
+	stmt := `SELECT COUNT(*) FROM vendor_users WHERE vendor_id = ? AND user_id = ?`
+	err := conn.QueryRowContext(ctx, stmt, vendorID, userID).Scan(&n)
+	return n > 0, err
+}
+
+func FetchVendorUsers(ctx context.Context, vendorID int) (Users, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `SELECT u.id, u.name, u.email FROM vendor_users vu JOIN users u ON vu.user_id = u.id WHERE vu.vendor_id = ? AND u.deleted_at IS NULL ORDER BY u.name`
+	rows, err := conn.QueryContext(ctx, stmt, vendorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := Users{}
+	for rows.Next() {
+		u := &User{}
+		err := rows.Scan(&u.ID, &u.Name, &u.Email)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// FetchUserVendors returns the vendors the user is linked to.
+func FetchUserVendors(ctx context.Context, userID int) (*Vendors, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `SELECT v.id, v.name, v.active FROM vendor_users vu JOIN vendors v ON vu.vendor_id = v.id WHERE vu.user_id = ? AND v.deleted_at IS NULL ORDER BY v.name`
+	rows, err := conn.QueryContext(ctx, stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	vendors := Vendors{}
+	for rows.Next() {
+		v := &Vendor{}
+		err := rows.Scan(&v.ID, &v.Name, &v.Active)
+		if err != nil {
+			return nil, err
+		}
+		vendors = append(vendors, v)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &vendors, nil
+}
+
+// FetchVendorTrips returns the trips the vendor is a partner or venue on,
+// soonest first, with how many seats are booked on each.
+func FetchVendorTrips(ctx context.Context, vendorID int) (*PartnerTrips, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `SELECT t.id, t.title, t.slug, t.status, t.start, t.end, x.role, (SELECT IFNULL(SUM(b.seats), 0) FROM bookings b WHERE b.trip_id = t.id AND b.status = ?) FROM (SELECT trip_id, 'partner' AS role FROM trips_partners WHERE partner_id = ? UNION SELECT trip_id, 'venue' AS role FROM trips_venues WHERE venue_id = ?) x JOIN trips t ON x.trip_id = t.id WHERE t.deleted_at IS NULL ORDER BY t.start DESC`
+	rows, err := conn.QueryContext(ctx, stmt, BookingBooked, vendorID, vendorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	trips := PartnerTrips{}
+	for rows.Next() {
+		t := &PartnerTrip{}
+		err := rows.Scan(&t.ID, &t.Title, &t.Slug, &t.Status, &t.Start, &t.End, &t.Role, &t.Booked)
+		if err != nil {
+			return nil, err
+		}
+		trips = append(trips, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &trips, nil
+}
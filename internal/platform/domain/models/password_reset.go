@@ -0,0 +1,154 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"revelbus/internal/platform/domain"
+	"revelbus/pkg/database"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/spf13/viper"
+)
+
+// PasswordReset is an outstanding password recovery request. Only a hash of
+// the token is stored; the token itself only ever goes out in the email.
+type PasswordReset struct {
+	ID      int
+	UserID  int
+	Hash    string
+	Expires time.Time
+	Used    mysql.NullTime
+}
+
+// CreatePasswordReset starts a recovery for the user and returns the token to
+// send them.
+func CreatePasswordReset(ctx context.Context, userID int) (*PasswordReset, string, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	token, err := domain.NewToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	p := &PasswordReset{
+		UserID:  userID,
+		Hash:    hashToken(token),
+		Expires: time.Now().UTC().Add(resetTTL()),
+	}
+
+	stmt := `INSERT INTO password_resets (user_id, token_hash, expires_at, created_at) VALUES(?, ?, ?, UTC_TIMESTAMP())`
+	result, err := conn.ExecContext(ctx, stmt, p.UserID, p.Hash, p.Expires)
+	if err != nil {
+		return nil, "", err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, "", err
+	}
+
+	p.ID = int(id)
+
+	return p, token, nil
+}
+
+// FindPasswordReset looks up the reset and checks the token against it. Unknown,
+// expired, used and mismatched resets are all ErrInvalidCredentials so a
+// caller can't tell them apart.
+func FindPasswordReset(ctx context.Context, id int, token string) (*PasswordReset, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	p := &PasswordReset{
+		ID: id,
+	}
+
+	stmt := `SELECT user_id, token_hash, expires_at, used_at FROM password_resets WHERE id = ?`
+	err := conn.QueryRowContext(ctx, stmt, p.ID).Scan(&p.UserID, &p.Hash, &p.Expires, &p.Used)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrInvalidCredentials
+	} else if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(p.Hash), []byte(hashToken(token))) != 1 {
+		return nil, domain.ErrInvalidCredentials
+	}
+
+	if p.Used.Valid || time.Now().After(p.Expires) {
+		return nil, domain.ErrInvalidCredentials
+	}
+
+	return p, nil
+}
+
+// Redeem uses up the reset and sets the new password. Claiming the reset
+// happens first so two requests racing with the same link can't both win, and
+// both happen together so a failure saving the password leaves the link
+// usable.
+func (p *PasswordReset) Redeem(ctx context.Context, pw string) error {
+	return database.InTx(ctx, func(ctx context.Context) error {
+		err := p.use(ctx)
+		if err != nil {
+			return err
+		}
+
+		u := &User{
+			ID: p.UserID,
+		}
+
+		return u.UpdatePassword(ctx, pw)
+	})
+}
+
+func (p *PasswordReset) use(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `UPDATE password_resets SET used_at = UTC_TIMESTAMP() WHERE id = ? AND used_at IS NULL AND expires_at > UTC_TIMESTAMP()`
+	result, err := conn.ExecContext(ctx, stmt, p.ID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return domain.ErrInvalidCredentials
+	}
+
+	return nil
+}
+
+// InvalidatePasswordResets throws away every unused reset for the user.
+func InvalidatePasswordResets(ctx context.Context, userID int) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `DELETE FROM password_resets WHERE user_id = ? AND used_at IS NULL`
+	_, err := conn.ExecContext(ctx, stmt, userID)
+	return err
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func resetTTL() time.Duration {
+	if d := viper.GetDuration("recovery.ttl"); d > 0 {
+		return d
+	}
+	return time.Hour
+}
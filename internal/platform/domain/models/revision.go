@@ -0,0 +1,212 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"revelbus/internal/platform/domain"
+	"revelbus/pkg/database"
+	"revelbus/pkg/diff"
+	"strconv"
+	"time"
+)
+
+// RevisionFields are the fields of each kind of content that are kept when
+// it's saved, in the order a diff shows them.
+var RevisionFields = map[string][]string{
+	AuditTrip:     {"Title", "Blurb", "Description", "Price", "Pickup", "Notes"},
+	AuditFAQ:      {"Question", "Answer", "Category"},
+	AuditSlide:    {"Title", "Blurb"},
+	AuditSettings: {"ContactBlurb", "AboutBlurb", "AboutContent"},
+}
+
+// Revision is a copy of a page's or trip's content as it was saved. A
+// revision with no actor is the content from before revisions were kept.
+type Revision struct {
+	ID         int
+	EntityType string
+	EntityID   int
+	Content    map[string]string
+	ActorID    int
+	ActorName  sql.NullString
+	Created    time.Time
+}
+
+type Revisions []*Revision
+
+// RevisionField is one field of a revision lined up against the revision
+// before it.
+type RevisionField struct {
+	Field   string
+	Lines   []diff.Line
+	Changed bool
+}
+
+// NewRevision copies the revisioned fields of v, which is the entity of type
+// t with the given id.
+func NewRevision(t string, id int, v interface{}) *Revision {
+	r := &Revision{
+		EntityType: t,
+		EntityID:   id,
+		Content:    map[string]string{},
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	for _, name := range RevisionFields[t] {
+		if s, ok := rv.FieldByName(name).Interface().(sql.NullString); ok {
+			r.Content[name] = s.String
+		}
+	}
+	return r
+}
+
+// Apply puts the revision's content back on v, leaving its other fields be.
+func (r *Revision) Apply(v interface{}) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	for _, name := range RevisionFields[r.EntityType] {
+		f := rv.FieldByName(name)
+		if _, ok := f.Interface().(sql.NullString); !ok {
+			continue
+		}
+
+		s := r.Content[name]
+		f.Set(reflect.ValueOf(sql.NullString{String: s, Valid: s != ""}))
+	}
+}
+
+// Same is true when r and o hold the same content.
+func (r *Revision) Same(o *Revision) bool {
+	return reflect.DeepEqual(r.Content, o.Content)
+}
+
+// Diff lines up each field of r against prev, which is nil for the first
+// revision.
+func (r *Revision) Diff(prev *Revision) []RevisionField {
+	fields := []RevisionField{}
+	for _, name := range RevisionFields[r.EntityType] {
+		old := ""
+		if prev != nil {
+			old = prev.Content[name]
+		}
+
+		lines := diff.Lines(old, r.Content[name])
+		fields = append(fields, RevisionField{
+			Field:   name,
+			Lines:   lines,
+			Changed: diff.Changed(lines),
+		})
+	}
+	return fields
+}
+
+// Link is the admin page for the revised entity.
+func (r *Revision) Link() string {
+	if r.EntityType == AuditSettings {
+		return "/admin/settings"
+	}
+	return "/admin/" + r.EntityType + "?id=" + strconv.Itoa(r.EntityID)
+}
+
+func (r *Revision) Create(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	content, err := json.Marshal(r.Content)
+	if err != nil {
+		return err
+	}
+
+	actor := sql.NullInt64{Int64: int64(r.ActorID), Valid: r.ActorID != 0}
+
+	stmt := `INSERT INTO revisions (entity_type, entity_id, content, actor_id, actor_name, created_at) VALUES(?, ?, ?, ?, ?, UTC_TIMESTAMP())`
+	result, err := conn.ExecContext(ctx, stmt, r.EntityType, r.EntityID, string(content), actor, r.ActorName)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	r.ID = int(id)
+	r.Created = time.Now().UTC()
+	return nil
+}
+
+func (r *Revision) Fetch(ctx context.Context) error {
+	return r.fetch(ctx, `WHERE id = ?`, r.ID)
+}
+
+// Previous returns the revision saved before r, or ErrNotFound if r is the
+// first.
+func (r *Revision) Previous(ctx context.Context) (*Revision, error) {
+	prev := &Revision{}
+	err := prev.fetch(ctx, `WHERE entity_type = ? AND entity_id = ? AND id < ? ORDER BY id DESC LIMIT 1`, r.EntityType, r.EntityID, r.ID)
+	if err != nil {
+		return nil, err
+	}
+	return prev, nil
+}
+
+// LatestRevision returns the last revision of the entity, or ErrNotFound if
+// it has none.
+func LatestRevision(ctx context.Context, t string, id int) (*Revision, error) {
+	r := &Revision{}
+	err := r.fetch(ctx, `WHERE entity_type = ? AND entity_id = ? ORDER BY id DESC LIMIT 1`, t, id)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Revision) fetch(ctx context.Context, where string, args ...interface{}) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	var content string
+
+	stmt := `SELECT id, entity_type, entity_id, content, IFNULL(actor_id, 0), actor_name, created_at FROM revisions ` + where
+	err := conn.QueryRowContext(ctx, stmt, args...).Scan(&r.ID, &r.EntityType, &r.EntityID, &content, &r.ActorID, &r.ActorName, &r.Created)
+	if err == sql.ErrNoRows {
+		return domain.ErrNotFound
+	} else if err != nil {
+		return err
+	}
+
+	r.Content = map[string]string{}
+	return json.Unmarshal([]byte(content), &r.Content)
+}
+
+// FetchRevisions lists the revisions of the entity, newest first.
+func FetchRevisions(ctx context.Context, t string, id int) (*Revisions, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `SELECT id, entity_type, entity_id, IFNULL(actor_id, 0), actor_name, created_at FROM revisions WHERE entity_type = ? AND entity_id = ? ORDER BY id DESC`
+	rows, err := conn.QueryContext(ctx, stmt, t, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := Revisions{}
+	for rows.Next() {
+		r := &Revision{}
+		err := rows.Scan(&r.ID, &r.EntityType, &r.EntityID, &r.ActorID, &r.ActorName, &r.Created)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &revisions, nil
+}
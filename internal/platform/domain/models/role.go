@@ -0,0 +1,331 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"revelbus/internal/platform/domain"
+	"revelbus/internal/platform/forms"
+	"revelbus/pkg/database"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	// RoleAdmin can do everything, whatever permissions are stored for it, so
+	// there's always someone who can fix the other roles.
+	RoleAdmin = "admin"
+	// RoleUser is the role riders sign up with. It has no admin access.
+	RoleUser = "user"
+)
+
+const (
+	PermTrips       = "trips.manage"
+	PermManifests   = "manifests.view"
+	PermCheckIn     = "riders.checkin"
+	PermFAQs        = "faqs.manage"
+	PermContent     = "content.manage"
+	PermVendors     = "vendors.manage"
+	PermMessages    = "messages.manage"
+	PermSubscribers = "subscribers.manage"
+	PermUsers       = "users.manage"
+	PermSettings    = "settings.manage"
+	PermAudit       = "audit.view"
+)
+
+type Permission struct {
+	Name  string
+	Label string
+}
+
+// Permissions is every permission a role can be granted, in the order the
+// role editor lists them.
+var Permissions = []Permission{
+	{PermTrips, "Manage trips"},
+	{PermManifests, "View rider manifests"},
+	{PermCheckIn, "Check in riders"},
+	{PermFAQs, "Manage FAQs"},
+	{PermContent, "Manage slides, galleries and files"},
+	{PermVendors, "Manage vendors"},
+	{PermMessages, "Read and reply to contact messages"},
+	{PermSubscribers, "Manage the mailing list and send newsletters"},
+	{PermUsers, "Manage users and roles"},
+	{PermSettings, "Manage site settings and outgoing mail"},
+	{PermAudit, "View the audit log"},
+}
+
+type Role struct {
+	ID          int
+	Version     int
+	Name        sql.NullString
+	Label       sql.NullString
+	Permissions []string
+	Users       int
+}
+
+type Roles []*Role
+
+type RoleForm struct {
+	ID          string
+	Version     int
+	Name        string
+	Label       string
+	Permissions []string
+
+	Errors map[string]string
+}
+
+func (f *RoleForm) Valid() bool {
+	v := forms.NewValidator()
+
+	v.Required("Name", f.Name)
+	v.ValidSlug("Name", f.Name)
+	v.Required("Label", f.Label)
+
+	f.Errors = v.Errors
+	return len(f.Errors) == 0
+}
+
+// Has reports whether the permission is ticked on the form.
+func (f *RoleForm) Has(p string) bool {
+	return hasPermission(f.Permissions, p)
+}
+
+// IsBuiltin is true for the admin and user roles, which can't be renamed or
+// deleted.
+func (f *RoleForm) IsBuiltin() bool {
+	return f.Name == RoleAdmin || f.Name == RoleUser
+}
+
+func (r *Role) Has(p string) bool {
+	return r.Name.String == RoleAdmin || hasPermission(r.Permissions, p)
+}
+
+func (r *Role) IsBuiltin() bool {
+	return r.Name.String == RoleAdmin || r.Name.String == RoleUser
+}
+
+func (r *Role) Create(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `INSERT INTO roles (name, label, created_at, updated_at) VALUES(?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP())`
+	result, err := conn.ExecContext(ctx, stmt, r.Name, r.Label)
+	if err != nil {
+		merr, ok := err.(*mysql.MySQLError)
+
+		if ok && merr.Number == 1062 {
+			return domain.ErrDuplicate
+		}
+
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	r.ID = int(id)
+	r.Version = 1
+
+	err = r.savePermissions(ctx)
+	return err
+}
+
+// Fetch loads the role by ID, or by name if there's no ID.
+func (r *Role) Fetch(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	snippet := `SELECT id, name, label, version FROM roles WHERE`
+
+	var err error
+
+	if r.ID != 0 {
+		stmt := snippet + ` id = ?`
+		err = conn.QueryRowContext(ctx, stmt, r.ID).Scan(&r.ID, &r.Name, &r.Label, &r.Version)
+	} else {
+		stmt := snippet + ` name = ?`
+		err = conn.QueryRowContext(ctx, stmt, r.Name).Scan(&r.ID, &r.Name, &r.Label, &r.Version)
+	}
+
+	if err == sql.ErrNoRows {
+		return domain.ErrNotFound
+	} else if err != nil {
+		return err
+	}
+
+	stmt := `SELECT permission FROM role_permissions WHERE role_id = ? ORDER BY permission`
+	rows, err := conn.QueryContext(ctx, stmt, r.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	r.Permissions = []string{}
+	for rows.Next() {
+		var p string
+		err := rows.Scan(&p)
+		if err != nil {
+			return err
+		}
+		r.Permissions = append(r.Permissions, p)
+	}
+
+	return rows.Err()
+}
+
+// Update saves the label and permissions. A role's name is how users refer to
+// it, so it never changes.
+func (r *Role) Update(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `UPDATE roles SET label = ?, version = version + 1, updated_at = UTC_TIMESTAMP() WHERE id = ?` + versioned
+	result, err := conn.ExecContext(ctx, stmt, r.Label, r.ID, r.Version)
+	if err != nil {
+		return err
+	}
+
+	err = bump(result, &r.Version)
+	if err != nil {
+		return err
+	}
+
+	err = r.savePermissions(ctx)
+	return err
+}
+
+// Delete removes the role. The built in roles and roles anyone still has
+// are ErrCannotDelete.
+func (r *Role) Delete(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	err := r.Fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	if r.IsBuiltin() {
+		return domain.ErrCannotDelete
+	}
+
+	var n int
+
+	stmt := `SELECT COUNT(*) FROM users WHERE role = ?`
+	err = conn.QueryRowContext(ctx, stmt, r.Name).Scan(&n)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return domain.ErrCannotDelete
+	}
+
+	stmt = `DELETE FROM roles WHERE id = ?`
+	_, err = conn.ExecContext(ctx, stmt, r.ID)
+	return err
+}
+
+// savePermissions replaces the role's permissions, skipping any that aren't
+// in Permissions. The admin role keeps none since it has them all anyway.
+func (r *Role) savePermissions(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `DELETE FROM role_permissions WHERE role_id = ?`
+	_, err := conn.ExecContext(ctx, stmt, r.ID)
+	if err != nil {
+		return err
+	}
+
+	if r.Name.String == RoleAdmin {
+		return nil
+	}
+
+	stmt = `INSERT INTO role_permissions (role_id, permission) VALUES(?, ?)`
+	for _, p := range Permissions {
+		if !hasPermission(r.Permissions, p.Name) {
+			continue
+		}
+
+		_, err = conn.ExecContext(ctx, stmt, r.ID, p.Name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func FetchRoles(ctx context.Context) (*Roles, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `SELECT r.id, r.name, r.label, COUNT(u.id) FROM roles r LEFT JOIN users u ON u.role = r.name GROUP BY r.id, r.name, r.label ORDER BY r.label`
+	rows, err := conn.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := Roles{}
+	for rows.Next() {
+		r := &Role{}
+		err := rows.Scan(&r.ID, &r.Name, &r.Label, &r.Users)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &roles, nil
+}
+
+// RolePermissions is the set of permissions the named role grants.
+func RolePermissions(ctx context.Context, name string) (map[string]bool, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `SELECT p.permission FROM role_permissions p JOIN roles r ON p.role_id = r.id WHERE r.name = ?`
+	rows, err := conn.QueryContext(ctx, stmt, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	perms := make(map[string]bool)
+	for rows.Next() {
+		var p string
+		err := rows.Scan(&p)
+		if err != nil {
+			return nil, err
+		}
+		perms[p] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return perms, nil
+}
+
+func hasPermission(list []string, p string) bool {
+	for _, l := range list {
+		if l == p {
+			return true
+		}
+	}
+	return false
+}
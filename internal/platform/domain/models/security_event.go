@@ -0,0 +1,154 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"revelbus/pkg/database"
+	"time"
+)
+
+const (
+	SecurityLogin                = "login"
+	SecurityNewDevice            = "new_device"
+	SecurityLocked               = "locked"
+	SecurityPasswordChanged      = "password_changed"
+	SecurityPasswordReset        = "password_reset"
+	SecurityEmailChangeRequested = "email_change_requested"
+	SecurityEmailChanged         = "email_changed"
+	SecurityTwoFactorEnabled     = "two_factor_enabled"
+	SecurityTwoFactorDisabled    = "two_factor_disabled"
+	SecurityRecoveryCodes        = "recovery_codes"
+	SecurityIdentityLinked       = "identity_linked"
+
+	// ResetByAdmin is the detail on a password reset done from the user admin.
+	ResetByAdmin = "admin"
+)
+
+// SecurityEvent is an entry in a user's security activity log. Detail holds
+// whatever the kind needs to describe itself, like the address for an email
+// change.
+type SecurityEvent struct {
+	ID        int
+	UserID    int
+	Kind      string
+	Detail    sql.NullString
+	IP        sql.NullString
+	UserAgent sql.NullString
+	Created   time.Time
+}
+
+type SecurityEvents []*SecurityEvent
+
+func (e *SecurityEvent) Create(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	if len(e.UserAgent.String) > 512 {
+		e.UserAgent.String = e.UserAgent.String[:512]
+	}
+
+	stmt := `INSERT INTO security_events (user_id, kind, detail, ip, user_agent, created_at) VALUES(?, ?, ?, ?, ?, UTC_TIMESTAMP())`
+	result, err := conn.ExecContext(ctx, stmt, e.UserID, e.Kind, e.Detail, e.IP, e.UserAgent)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	e.ID = int(id)
+	e.Created = time.Now().UTC()
+
+	return nil
+}
+
+// Summary describes the event for the activity log and alert emails.
+func (e *SecurityEvent) Summary() string {
+	switch e.Kind {
+	case SecurityLogin:
+		return "Signed in"
+	case SecurityNewDevice:
+		return "Signed in from a new device"
+	case SecurityLocked:
+		return "Account locked after too many failed logins"
+	case SecurityPasswordChanged:
+		return "Password changed"
+	case SecurityPasswordReset:
+		if e.Detail.String == ResetByAdmin {
+			return "Password reset by an admin"
+		}
+		return "Password reset"
+	case SecurityEmailChangeRequested:
+		return "Asked to change email address to " + e.Detail.String
+	case SecurityEmailChanged:
+		return "Email address changed from " + e.Detail.String
+	case SecurityTwoFactorEnabled:
+		return "Two-factor authentication turned on"
+	case SecurityTwoFactorDisabled:
+		return "Two-factor authentication turned off"
+	case SecurityRecoveryCodes:
+		return "New recovery codes generated"
+	case SecurityIdentityLinked:
+		return "Linked sign in with " + e.Detail.String
+	}
+	return e.Kind
+}
+
+func (e *SecurityEvent) Device() string {
+	return describeDevice(e.UserAgent.String)
+}
+
+// KnownDevice reports whether the user has signed in with this user agent
+// before. A user who has never signed in has no devices to compare against,
+// so their first login counts as known.
+func KnownDevice(ctx context.Context, userID int, ua string) (bool, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	if len(ua) > 512 {
+		ua = ua[:512]
+	}
+
+	var logins, matches int
+
+	stmt := `SELECT COUNT(*), IFNULL(SUM(user_agent = ?), 0) FROM security_events WHERE user_id = ? AND kind IN (?, ?)`
+	err := conn.QueryRowContext(ctx, stmt, ua, userID, SecurityLogin, SecurityNewDevice).Scan(&logins, &matches)
+	if err != nil {
+		return false, err
+	}
+
+	return logins == 0 || matches > 0, nil
+}
+
+func FetchSecurityEvents(ctx context.Context, userID int, limit int) (*SecurityEvents, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `SELECT id, user_id, kind, detail, ip, user_agent, created_at FROM security_events WHERE user_id = ? ORDER BY created_at DESC, id DESC LIMIT ?`
+	rows, err := conn.QueryContext(ctx, stmt, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := SecurityEvents{}
+	for rows.Next() {
+		e := &SecurityEvent{}
+		err := rows.Scan(&e.ID, &e.UserID, &e.Kind, &e.Detail, &e.IP, &e.UserAgent, &e.Created)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &events, nil
+}
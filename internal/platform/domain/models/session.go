@@ -0,0 +1,232 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"revelbus/internal/platform/domain"
+	"revelbus/pkg/database"
+	"revelbus/pkg/sessions"
+	"strings"
+	"time"
+)
+
+// touchInterval limits how often a session's last seen time is written.
+const touchInterval = time.Minute
+
+// Session is a signed in device. The token lives in the server-side session
+// data, never in the cookie, and deleting the row signs the device out.
+type Session struct {
+	ID        int
+	Token     string
+	UserID    int
+	UserAgent sql.NullString
+	IP        sql.NullString
+	LastSeen  time.Time
+	Created   time.Time
+
+	Current bool
+}
+
+type Sessions []*Session
+
+func (s *Session) Create(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	token, err := domain.NewToken()
+	if err != nil {
+		return err
+	}
+	s.Token = token
+
+	if len(s.UserAgent.String) > 512 {
+		s.UserAgent.String = s.UserAgent.String[:512]
+	}
+
+	cutoff := time.Now().UTC().Add(-sessions.Lifetime())
+
+	stmt := `DELETE FROM user_sessions WHERE created_at < ?`
+	_, err = conn.ExecContext(ctx, stmt, cutoff)
+	if err != nil {
+		return err
+	}
+
+	stmt = `INSERT INTO user_sessions (token, user_id, user_agent, ip, last_seen_at, created_at) VALUES(?, ?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP())`
+	result, err := conn.ExecContext(ctx, stmt, s.Token, s.UserID, s.UserAgent, s.IP)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	s.ID = int(id)
+
+	return nil
+}
+
+// Revoke signs the session out. If UserID is set the session must belong to
+// that user.
+func (s *Session) Revoke(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	var result sql.Result
+	var err error
+
+	if s.UserID != 0 {
+		stmt := `DELETE FROM user_sessions WHERE id = ? AND user_id = ?`
+		result, err = conn.ExecContext(ctx, stmt, s.ID, s.UserID)
+	} else {
+		stmt := `DELETE FROM user_sessions WHERE id = ?`
+		result, err = conn.ExecContext(ctx, stmt, s.ID)
+	}
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// Device is a short description of the browser and platform the session was
+// started from.
+func (s *Session) Device() string {
+	return describeDevice(s.UserAgent.String)
+}
+
+func describeDevice(ua string) string {
+	browser := "Unknown browser"
+	switch {
+	case strings.Contains(ua, "Edg"):
+		browser = "Edge"
+	case strings.Contains(ua, "OPR") || strings.Contains(ua, "Opera"):
+		browser = "Opera"
+	case strings.Contains(ua, "Chrome") || strings.Contains(ua, "CriOS"):
+		browser = "Chrome"
+	case strings.Contains(ua, "Firefox") || strings.Contains(ua, "FxiOS"):
+		browser = "Firefox"
+	case strings.Contains(ua, "Safari"):
+		browser = "Safari"
+	}
+
+	platform := "unknown device"
+	switch {
+	case strings.Contains(ua, "iPhone"):
+		platform = "iPhone"
+	case strings.Contains(ua, "iPad"):
+		platform = "iPad"
+	case strings.Contains(ua, "Android"):
+		platform = "Android"
+	case strings.Contains(ua, "Windows"):
+		platform = "Windows"
+	case strings.Contains(ua, "Mac OS X"):
+		platform = "Mac"
+	case strings.Contains(ua, "Linux"):
+		platform = "Linux"
+	}
+
+	return browser + " on " + platform
+}
+
+// FindSessionUser loads the user signed in with the session token, so role
+// changes and deleted accounts take effect on the next request.
+func FindSessionUser(ctx context.Context, token string, ip string) (*User, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	u := &User{}
+	var id int
+	var lastSeen time.Time
+
+	cutoff := time.Now().UTC().Add(-sessions.Lifetime())
+
+	stmt := `SELECT s.id, s.last_seen_at, u.id, u.name, u.email, u.role, u.totp_enabled, u.email_verified_at, u.version, EXISTS(SELECT 1 FROM vendor_users vu WHERE vu.user_id = u.id) FROM user_sessions s JOIN users u ON s.user_id = u.id WHERE s.token = ? AND s.created_at > ? AND u.deleted_at IS NULL`
+	err := conn.QueryRowContext(ctx, stmt, token, cutoff).Scan(&id, &lastSeen, &u.ID, &u.Name, &u.Email, &u.Role, &u.TOTPEnabled, &u.VerifiedAt, &u.Version, &u.Partner)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = u.LoadPermissions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if time.Since(lastSeen) > touchInterval {
+		stmt = `UPDATE user_sessions SET last_seen_at = UTC_TIMESTAMP(), ip = ? WHERE id = ?`
+		_, err = conn.ExecContext(ctx, stmt, ip, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return u, nil
+}
+
+func DeleteSession(ctx context.Context, token string) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `DELETE FROM user_sessions WHERE token = ?`
+	_, err := conn.ExecContext(ctx, stmt, token)
+	return err
+}
+
+// RevokeUserSessions signs the user out everywhere.
+func RevokeUserSessions(ctx context.Context, userID int) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `DELETE FROM user_sessions WHERE user_id = ?`
+	_, err := conn.ExecContext(ctx, stmt, userID)
+	return err
+}
+
+func FetchUserSessions(ctx context.Context, userID int) (*Sessions, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	cutoff := time.Now().UTC().Add(-sessions.Lifetime())
+
+	stmt := `SELECT id, token, user_agent, ip, last_seen_at, created_at FROM user_sessions WHERE user_id = ? AND created_at > ? ORDER BY last_seen_at DESC`
+	rows, err := conn.QueryContext(ctx, stmt, userID, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := Sessions{}
+	for rows.Next() {
+		s := &Session{
+			UserID: userID,
+		}
+		err := rows.Scan(&s.ID, &s.Token, &s.UserAgent, &s.IP, &s.LastSeen, &s.Created)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
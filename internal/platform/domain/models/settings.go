@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"revelbus/internal/platform/domain"
 	"revelbus/internal/platform/forms"
@@ -10,20 +11,24 @@ import (
 
 type Settings struct {
 	ID                int
+	Version           int
 	ContactBlurb      sql.NullString
 	AboutBlurb        sql.NullString
 	AboutContent      sql.NullString
 	HomeGalleryID     sql.NullInt64
 	HomeGalleryActive bool
+	RequireAdmin2FA   bool
 }
 
 type SettingsForm struct {
 	ID                string
+	Version           int
 	ContactBlurb      string
 	AboutBlurb        string
 	AboutContent      string
 	HomeGalleryID     int
 	HomeGalleryActive bool
+	RequireAdmin2FA   bool
 
 	Errors map[string]string
 }
@@ -35,11 +40,13 @@ func (f *SettingsForm) Valid() bool {
 	return len(f.Errors) == 0
 }
 
-func (s *Settings) Create() error {
-	conn, _ := database.GetConnection()
+func (s *Settings) Create(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
-	stmt := `INSERT INTO settings (contact_blurb, about_blurb, about_content, home_gallery, home_gallery_active, created_at, updated_at) VALUES(?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP())`
-	result, err := conn.Exec(stmt, s.ContactBlurb, s.AboutBlurb, s.AboutContent, s.HomeGalleryID, s.HomeGalleryActive)
+	stmt := `INSERT INTO settings (contact_blurb, about_blurb, about_content, home_gallery, home_gallery_active, require_admin_2fa, created_at, updated_at) VALUES(?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP())`
+	result, err := conn.ExecContext(ctx, stmt, s.ContactBlurb, s.AboutBlurb, s.AboutContent, s.HomeGalleryID, s.HomeGalleryActive, s.RequireAdmin2FA)
 	if err != nil {
 		return err
 	}
@@ -50,15 +57,18 @@ func (s *Settings) Create() error {
 	}
 
 	s.ID = int(id)
+	s.Version = 1
 
 	return nil
 }
 
-func (s *Settings) Fetch() error {
-	conn, _ := database.GetConnection()
+func (s *Settings) Fetch(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
-	stmt := `SELECT id, contact_blurb, about_blurb, about_content, home_gallery, home_gallery_active FROM settings WHERE id = ?`
-	err := conn.QueryRow(stmt, s.ID).Scan(&s.ID, &s.ContactBlurb, &s.AboutBlurb, &s.AboutContent, &s.HomeGalleryID, &s.HomeGalleryActive)
+	stmt := `SELECT id, contact_blurb, about_blurb, about_content, home_gallery, home_gallery_active, require_admin_2fa, version FROM settings WHERE id = ?`
+	err := conn.QueryRowContext(ctx, stmt, s.ID).Scan(&s.ID, &s.ContactBlurb, &s.AboutBlurb, &s.AboutContent, &s.HomeGalleryID, &s.HomeGalleryActive, &s.RequireAdmin2FA, &s.Version)
 	if err == sql.ErrNoRows {
 		return domain.ErrNotFound
 	}
@@ -66,13 +76,31 @@ func (s *Settings) Fetch() error {
 	return err
 }
 
-func (s *Settings) Update() error {
-	conn, _ := database.GetConnection()
+func (s *Settings) Update(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
-	stmt := `UPDATE settings SET contact_blurb = ?, about_blurb = ?, about_content = ?, home_gallery = ?, home_gallery_active = ?, updated_at = UTC_TIMESTAMP() WHERE id = ?`
-	_, err := conn.Exec(stmt, s.ContactBlurb, s.AboutBlurb, s.AboutContent, s.HomeGalleryID, s.HomeGalleryActive, s.ID)
+	stmt := `UPDATE settings SET contact_blurb = ?, about_blurb = ?, about_content = ?, home_gallery = ?, home_gallery_active = ?, require_admin_2fa = ?, version = version + 1, updated_at = UTC_TIMESTAMP() WHERE id = ?` + versioned
+	result, err := conn.ExecContext(ctx, stmt, s.ContactBlurb, s.AboutBlurb, s.AboutContent, s.HomeGalleryID, s.HomeGalleryActive, s.RequireAdmin2FA, s.ID, s.Version)
+	if err != nil {
+		return err
+	}
+	return bump(result, &s.Version)
+}
+
+// RequireAdmin2FA reports whether admins must have two-factor auth on.
+func RequireAdmin2FA(ctx context.Context) (bool, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	var require bool
+
+	stmt := `SELECT require_admin_2fa FROM settings WHERE id = 1`
+	err := conn.QueryRowContext(ctx, stmt).Scan(&require)
 	if err == sql.ErrNoRows {
-		return s.Create()
+		return false, nil
 	}
-	return err
+	return require, err
 }
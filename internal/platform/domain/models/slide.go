@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"revelbus/internal/platform/domain"
 	"revelbus/internal/platform/forms"
@@ -9,23 +10,25 @@ import (
 )
 
 type Slide struct {
-	ID     int
-	Title  sql.NullString
-	Blurb  sql.NullString
-	Style  sql.NullString
-	Order  sql.NullInt64
-	Active bool
+	ID      int
+	Version int
+	Title   sql.NullString
+	Blurb   sql.NullString
+	Style   sql.NullString
+	Order   sql.NullInt64
+	Active  bool
 }
 
 type Slides []*Slide
 
 type SlideForm struct {
-	ID     string
-	Title  string
-	Blurb  string
-	Style  string
-	Order  string
-	Active bool
+	ID      string
+	Version int
+	Title   string
+	Blurb   string
+	Style   string
+	Order   string
+	Active  bool
 
 	Errors map[string]string
 }
@@ -42,11 +45,13 @@ func (f *SlideForm) Valid() bool {
 	return len(f.Errors) == 0
 }
 
-func (s *Slide) Create() error {
-	conn, _ := database.GetConnection()
+func (s *Slide) Create(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
 	stmt := `INSERT INTO slides (title, blurb, style, sort_order, active, created_at, updated_at) VALUES(?, ?, ?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP())`
-	result, err := conn.Exec(stmt, s.Title, s.Blurb, s.Style, s.Order, s.Active)
+	result, err := conn.ExecContext(ctx, stmt, s.Title, s.Blurb, s.Style, s.Order, s.Active)
 	if err != nil {
 		return err
 	}
@@ -57,15 +62,18 @@ func (s *Slide) Create() error {
 	}
 
 	s.ID = int(id)
+	s.Version = 1
 
 	return nil
 }
 
-func (s *Slide) Fetch() error {
-	conn, _ := database.GetConnection()
+func (s *Slide) Fetch(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
-	stmt := `SELECT id, title, blurb, style, sort_order, active FROM slides WHERE id = ?`
-	err := conn.QueryRow(stmt, s.ID).Scan(&s.ID, &s.Title, &s.Blurb, &s.Style, &s.Order, &s.Active)
+	stmt := `SELECT id, title, blurb, style, sort_order, active, version FROM slides WHERE id = ?`
+	err := conn.QueryRowContext(ctx, stmt, s.ID).Scan(&s.ID, &s.Title, &s.Blurb, &s.Style, &s.Order, &s.Active, &s.Version)
 	if err == sql.ErrNoRows {
 		return domain.ErrNotFound
 	}
@@ -73,33 +81,39 @@ func (s *Slide) Fetch() error {
 	return err
 }
 
-func (s *Slide) Update() error {
-	conn, _ := database.GetConnection()
+func (s *Slide) Update(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
-	stmt := `UPDATE slides SET title = ?, blurb= ?, style = ?, sort_order = ?, active = ?, updated_at = UTC_TIMESTAMP() WHERE id = ?`
-	_, err := conn.Exec(stmt, s.Title, s.Blurb, s.Style, s.Order, s.Active, s.ID)
-	if err == sql.ErrNoRows {
-		return domain.ErrNotFound
+	stmt := `UPDATE slides SET title = ?, blurb= ?, style = ?, sort_order = ?, active = ?, version = version + 1, updated_at = UTC_TIMESTAMP() WHERE id = ?` + versioned
+	result, err := conn.ExecContext(ctx, stmt, s.Title, s.Blurb, s.Style, s.Order, s.Active, s.ID, s.Version)
+	if err != nil {
+		return err
 	}
-	return err
+	return bump(result, &s.Version)
 }
 
-func (s *Slide) Delete() error {
-	conn, _ := database.GetConnection()
+func (s *Slide) Delete(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
 	stmt := `DELETE FROM slides WHERE id = ?`
-	_, err := conn.Exec(stmt, s.ID)
+	_, err := conn.ExecContext(ctx, stmt, s.ID)
 	if err == sql.ErrNoRows {
 		return nil
 	}
 	return err
 }
 
-func FetchSlides() (*Slides, error) {
-	conn, _ := database.GetConnection()
+func FetchSlides(ctx context.Context) (*Slides, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
 	stmt := `SELECT id, title, blurb, style, sort_order, active FROM slides ORDER BY sort_order`
-	rows, err := conn.Query(stmt)
+	rows, err := conn.QueryContext(ctx, stmt)
 	if err != nil {
 		return nil, err
 	}
@@ -122,11 +136,13 @@ func FetchSlides() (*Slides, error) {
 	return &slides, nil
 }
 
-func FindActiveSlides() (*Slides, error) {
-	conn, _ := database.GetConnection()
+func FindActiveSlides(ctx context.Context) (*Slides, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
 	stmt := `SELECT id, title, blurb, style, sort_order FROM slides WHERE active = 1 ORDER BY sort_order`
-	rows, err := conn.Query(stmt)
+	rows, err := conn.QueryContext(ctx, stmt)
 	if err != nil {
 		return nil, err
 	}
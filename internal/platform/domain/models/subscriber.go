@@ -0,0 +1,397 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"revelbus/internal/platform/domain"
+	"revelbus/internal/platform/forms"
+	"revelbus/pkg/database"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	SubscriberPending      = "pending"
+	SubscriberActive       = "active"
+	SubscriberUnsubscribed = "unsubscribed"
+)
+
+type Subscriber struct {
+	ID               int
+	Email            sql.NullString
+	Name             sql.NullString
+	Status           sql.NullString
+	ConfirmToken     sql.NullString
+	UnsubscribeToken sql.NullString
+	Created          time.Time
+
+	Interests []string
+}
+
+type Subscribers []*Subscriber
+
+type SubscriberForm struct {
+	Email     string
+	Token     string
+	Interests []string
+
+	Errors map[string]string
+}
+
+func (f *SubscriberForm) Valid() bool {
+	v := forms.NewValidator()
+
+	v.Required("Email", f.Email)
+	v.ValidEmail("Email", f.Email)
+
+	f.Errors = v.Errors
+	return len(f.Errors) == 0
+}
+
+// HasInterest is used by the preferences form to check boxes.
+func (f *SubscriberForm) HasInterest(c string) bool {
+	for _, i := range f.Interests {
+		if i == c {
+			return true
+		}
+	}
+	return false
+}
+
+type NewsletterForm struct {
+	Subject  string
+	Body     string
+	Category string
+
+	Errors map[string]string
+}
+
+func (f *NewsletterForm) Valid() bool {
+	v := forms.NewValidator()
+
+	v.Required("Subject", f.Subject)
+	v.Required("Body", f.Body)
+
+	f.Errors = v.Errors
+	return len(f.Errors) == 0
+}
+
+// Create adds the subscriber with fresh confirm and unsubscribe tokens. The
+// status defaults to pending until the confirmation link is followed.
+func (s *Subscriber) Create(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	if s.Status.String == "" {
+		s.Status = sql.NullString{String: SubscriberPending, Valid: true}
+	}
+
+	err := s.newTokens()
+	if err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO subscribers (email, name, status, confirm_token, unsubscribe_token, created_at, updated_at) VALUES(?, ?, ?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP())`
+	result, err := conn.ExecContext(ctx, stmt, s.Email, s.Name, s.Status, s.ConfirmToken, s.UnsubscribeToken)
+	if err != nil {
+		merr, ok := err.(*mysql.MySQLError)
+
+		if ok && merr.Number == 1062 {
+			return domain.ErrDuplicateEmail
+		}
+
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	s.ID = int(id)
+
+	return nil
+}
+
+func (s *Subscriber) Fetch(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	snippet := `SELECT id, email, name, status, confirm_token, unsubscribe_token, created_at FROM subscribers WHERE`
+
+	var err error
+
+	if s.ID != 0 {
+		stmt := snippet + ` id = ?`
+		err = conn.QueryRowContext(ctx, stmt, s.ID).Scan(&s.ID, &s.Email, &s.Name, &s.Status, &s.ConfirmToken, &s.UnsubscribeToken, &s.Created)
+	} else {
+		stmt := snippet + ` email = ?`
+		err = conn.QueryRowContext(ctx, stmt, s.Email).Scan(&s.ID, &s.Email, &s.Name, &s.Status, &s.ConfirmToken, &s.UnsubscribeToken, &s.Created)
+	}
+
+	if err == sql.ErrNoRows {
+		return domain.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.GetInterests(ctx)
+}
+
+// FindSubscriberByToken looks up a subscriber by the token in an
+// unsubscribe or preferences link.
+func FindSubscriberByToken(ctx context.Context, token string) (*Subscriber, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	s := &Subscriber{}
+
+	if token == "" {
+		return nil, domain.ErrNotFound
+	}
+
+	stmt := `SELECT id, email, name, status, confirm_token, unsubscribe_token, created_at FROM subscribers WHERE unsubscribe_token = ?`
+	err := conn.QueryRowContext(ctx, stmt, token).Scan(&s.ID, &s.Email, &s.Name, &s.Status, &s.ConfirmToken, &s.UnsubscribeToken, &s.Created)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.GetInterests(ctx)
+	return s, err
+}
+
+// ConfirmSubscriber activates the pending subscriber holding the token.
+func ConfirmSubscriber(ctx context.Context, token string) (*Subscriber, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	s := &Subscriber{}
+
+	if token == "" {
+		return nil, domain.ErrNotFound
+	}
+
+	stmt := `SELECT id, email, name, unsubscribe_token, created_at FROM subscribers WHERE confirm_token = ? AND status = ?`
+	err := conn.QueryRowContext(ctx, stmt, token, SubscriberPending).Scan(&s.ID, &s.Email, &s.Name, &s.UnsubscribeToken, &s.Created)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stmt = `UPDATE subscribers SET status = ?, confirm_token = NULL, confirmed_at = UTC_TIMESTAMP(), updated_at = UTC_TIMESTAMP() WHERE id = ?`
+	_, err = conn.ExecContext(ctx, stmt, SubscriberActive, s.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Status = sql.NullString{String: SubscriberActive, Valid: true}
+	return s, nil
+}
+
+// Resubscribe puts an existing subscriber back to pending with a new
+// confirmation token so they can opt in again.
+func (s *Subscriber) Resubscribe(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	token, err := domain.NewToken()
+	if err != nil {
+		return err
+	}
+	s.ConfirmToken = sql.NullString{String: token, Valid: true}
+	s.Status = sql.NullString{String: SubscriberPending, Valid: true}
+
+	stmt := `UPDATE subscribers SET status = ?, confirm_token = ?, updated_at = UTC_TIMESTAMP() WHERE id = ?`
+	_, err = conn.ExecContext(ctx, stmt, s.Status, s.ConfirmToken, s.ID)
+	return err
+}
+
+func (s *Subscriber) Unsubscribe(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `UPDATE subscribers SET status = ?, confirm_token = NULL, updated_at = UTC_TIMESTAMP() WHERE id = ?`
+	_, err := conn.ExecContext(ctx, stmt, SubscriberUnsubscribed, s.ID)
+	if err != nil {
+		return err
+	}
+
+	s.Status = sql.NullString{String: SubscriberUnsubscribed, Valid: true}
+	return nil
+}
+
+func (s *Subscriber) Delete(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `DELETE FROM subscribers WHERE id = ?`
+	_, err := conn.ExecContext(ctx, stmt, s.ID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	return err
+}
+
+func (s *Subscriber) GetInterests(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `SELECT category FROM subscribers_interests WHERE subscriber_id = ? ORDER BY category`
+	rows, err := conn.QueryContext(ctx, stmt, s.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	interests := []string{}
+	for rows.Next() {
+		var c string
+		err := rows.Scan(&c)
+		if err != nil {
+			return err
+		}
+		interests = append(interests, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	s.Interests = interests
+
+	return nil
+}
+
+// SetInterests replaces the subscriber's trip categories. No categories means
+// they hear about everything.
+func (s *Subscriber) SetInterests(ctx context.Context, categories []string) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `DELETE FROM subscribers_interests WHERE subscriber_id = ?`
+	_, err := conn.ExecContext(ctx, stmt, s.ID)
+	if err != nil {
+		return err
+	}
+
+	stmt = `INSERT INTO subscribers_interests (subscriber_id, category, created_at, updated_at) VALUES(?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP())`
+	for _, c := range categories {
+		if c == "" {
+			continue
+		}
+
+		_, err := conn.ExecContext(ctx, stmt, s.ID, c)
+		if err != nil {
+			merr, ok := err.(*mysql.MySQLError)
+
+			if ok && merr.Number == 1062 {
+				continue
+			}
+			return err
+		}
+	}
+
+	s.Interests = categories
+	return nil
+}
+
+func (s *Subscriber) newTokens() error {
+	ct, err := domain.NewToken()
+	if err != nil {
+		return err
+	}
+
+	ut, err := domain.NewToken()
+	if err != nil {
+		return err
+	}
+
+	if s.Status.String == SubscriberPending {
+		s.ConfirmToken = sql.NullString{String: ct, Valid: true}
+	}
+	s.UnsubscribeToken = sql.NullString{String: ut, Valid: true}
+	return nil
+}
+
+func FetchSubscribers(ctx context.Context) (*Subscribers, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `SELECT id, email, name, status, unsubscribe_token, created_at FROM subscribers ORDER BY created_at DESC`
+	rows, err := conn.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subscribers := Subscribers{}
+	for rows.Next() {
+		s := &Subscriber{}
+		err := rows.Scan(&s.ID, &s.Email, &s.Name, &s.Status, &s.UnsubscribeToken, &s.Created)
+		if err != nil {
+			return nil, err
+		}
+		subscribers = append(subscribers, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &subscribers, nil
+}
+
+// FindActiveSubscribers returns confirmed subscribers interested in the
+// category, including everyone who hasn't narrowed their interests. An empty
+// category returns every confirmed subscriber.
+func FindActiveSubscribers(ctx context.Context, category string) (Subscribers, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	var rows *sql.Rows
+	var err error
+
+	if category == "" {
+		stmt := `SELECT id, email, name, unsubscribe_token FROM subscribers WHERE status = ? ORDER BY id`
+		rows, err = conn.QueryContext(ctx, stmt, SubscriberActive)
+	} else {
+		stmt := `SELECT s.id, s.email, s.name, s.unsubscribe_token FROM subscribers s WHERE s.status = ? AND (NOT EXISTS (SELECT 1 FROM subscribers_interests si WHERE si.subscriber_id = s.id) OR EXISTS (SELECT 1 FROM subscribers_interests si WHERE si.subscriber_id = s.id AND si.category = ?)) ORDER BY s.id`
+		rows, err = conn.QueryContext(ctx, stmt, SubscriberActive, category)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subscribers := Subscribers{}
+	for rows.Next() {
+		s := &Subscriber{}
+		err := rows.Scan(&s.ID, &s.Email, &s.Name, &s.UnsubscribeToken)
+		if err != nil {
+			return nil, err
+		}
+		subscribers = append(subscribers, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return subscribers, nil
+}
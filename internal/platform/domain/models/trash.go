@@ -0,0 +1,129 @@
+package models
+
+import (
+	"context"
+	"revelbus/internal/platform/domain"
+	"revelbus/pkg/database"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// TrashTypes are the kinds of record that go to the trash when deleted, in
+// the order the trash page lists them.
+var TrashTypes = []string{AuditTrip, AuditVendor, AuditFAQ, AuditGallery, AuditUser}
+
+// trashTables says where each kind of record lives and what it's called.
+var trashTables = map[string]struct {
+	table string
+	name  string
+}{
+	AuditTrip:    {"trips", "title"},
+	AuditVendor:  {"vendors", "name"},
+	AuditFAQ:     {"faqs", "question"},
+	AuditGallery: {"galleries", "name"},
+	AuditUser:    {"users", "IFNULL(name, email)"},
+}
+
+// Trashed is a deleted record waiting in the trash to be restored or purged.
+type Trashed struct {
+	ID      int
+	Type    string
+	Name    string
+	Deleted time.Time
+}
+
+type TrashedItems []*Trashed
+
+// FetchTrash lists the records of type t in the trash, most recently deleted
+// first.
+func FetchTrash(ctx context.Context, t string) (*TrashedItems, error) {
+	return fetchTrash(ctx, t, "")
+}
+
+// FetchExpiredTrash lists everything that went in the trash before cutoff.
+func FetchExpiredTrash(ctx context.Context, cutoff time.Time) (*TrashedItems, error) {
+	items := TrashedItems{}
+	for _, t := range TrashTypes {
+		found, err := fetchTrash(ctx, t, " AND deleted_at < ?", cutoff)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *found...)
+	}
+	return &items, nil
+}
+
+func fetchTrash(ctx context.Context, t string, where string, args ...interface{}) (*TrashedItems, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	tt, ok := trashTables[t]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+
+	stmt := `SELECT id, IFNULL(` + tt.name + `, ''), deleted_at FROM ` + tt.table + ` WHERE deleted_at IS NOT NULL` + where + ` ORDER BY deleted_at DESC`
+	rows, err := conn.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := TrashedItems{}
+	for rows.Next() {
+		i := &Trashed{Type: t}
+		err := rows.Scan(&i.ID, &i.Name, &i.Deleted)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &items, nil
+}
+
+// trash, restore and purge move a record into the trash, back out of it, and
+// out of the database for good. Each is a no-op on a record that isn't where
+// it's being moved from, which is reported as not found.
+func trash(ctx context.Context, table string, id int) error {
+	return moveTrash(ctx, `UPDATE `+table+` SET deleted_at = UTC_TIMESTAMP() WHERE id = ? AND deleted_at IS NULL`, id)
+}
+
+func restore(ctx context.Context, table string, id int) error {
+	return moveTrash(ctx, `UPDATE `+table+` SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL`, id)
+}
+
+func purge(ctx context.Context, table string, id int) error {
+	err := moveTrash(ctx, `DELETE FROM `+table+` WHERE id = ? AND deleted_at IS NOT NULL`, id)
+	if merr, ok := err.(*mysql.MySQLError); ok && merr.Number == 1451 {
+		return domain.ErrCannotDelete
+	}
+	return err
+}
+
+func moveTrash(ctx context.Context, stmt string, id int) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := conn.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"revelbus/internal/platform/domain"
 	"revelbus/internal/platform/forms"
@@ -14,9 +15,11 @@ import (
 
 type Trip struct {
 	ID           int
+	Version      int
 	Status       sql.NullString
 	Slug         sql.NullString
 	Title        sql.NullString
+	Category     sql.NullString
 	Blurb        sql.NullString
 	Description  sql.NullString
 	Start        time.Time
@@ -24,6 +27,9 @@ type Trip struct {
 	Price        sql.NullString
 	TicketingURL sql.NullString
 	Notes        sql.NullString
+	Pickup       sql.NullString
+	Remind       bool
+	FollowUp     bool
 
 	ImageID   sql.NullInt64
 	GalleryID sql.NullInt64
@@ -42,9 +48,11 @@ type GroupedTrips map[string][]*Trip
 
 type TripForm struct {
 	ID           string
+	Version      int
 	Title        string
 	Slug         string
 	Status       string
+	Category     string
 	Blurb        string
 	Description  string
 	Start        string
@@ -52,6 +60,9 @@ type TripForm struct {
 	Price        string
 	TicketingURL string
 	Notes        string
+	Pickup       string
+	Remind       bool
+	FollowUp     bool
 	ImageID      int
 	GalleryID    int
 
@@ -74,18 +85,25 @@ func (f *TripForm) Valid() bool {
 	return len(f.Errors) == 0
 }
 
-func (t *Trip) Create() error {
-	conn, _ := database.GetConnection()
+func (t *Trip) Create(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
 	if t.Slug.String == "" {
+		sl, err := domain.GetSlug(ctx, t.Title.String, "trips")
+		if err != nil {
+			return err
+		}
+
 		t.Slug = sql.NullString{
-			String: domain.GetSlug(t.Title.String, "trips"),
+			String: sl,
 			Valid:  true,
 		}
 	}
 
-	stmt := `INSERT INTO trips (title, slug, status, blurb, description, start, end, price, ticketing_url, notes, gallery_id, image_id, created_at, updated_at) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP())`
-	result, err := conn.Exec(stmt, t.Title, t.Slug, t.Status, t.Blurb, t.Description, t.Start, t.End, t.Price, t.TicketingURL, t.Notes, t.GalleryID, t.ImageID)
+	stmt := `INSERT INTO trips (title, slug, status, category, blurb, description, start, end, price, ticketing_url, notes, pickup, remind, follow_up, gallery_id, image_id, created_at, updated_at) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP())`
+	result, err := conn.ExecContext(ctx, stmt, t.Title, t.Slug, t.Status, t.Category, t.Blurb, t.Description, t.Start, t.End, t.Price, t.TicketingURL, t.Notes, t.Pickup, t.Remind, t.FollowUp, t.GalleryID, t.ImageID)
 	if err != nil {
 		return err
 	}
@@ -96,15 +114,18 @@ func (t *Trip) Create() error {
 	}
 
 	t.ID = int(id)
+	t.Version = 1
 
 	return nil
 }
 
-func (t *Trip) Fetch() error {
-	conn, _ := database.GetConnection()
+func (t *Trip) Fetch(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
-	stmt := `SELECT title, slug, status, blurb, description, start, end, price, ticketing_url, notes, image_id, gallery_id FROM trips WHERE id = ?`
-	err := conn.QueryRow(stmt, t.ID).Scan(&t.Title, &t.Slug, &t.Status, &t.Blurb, &t.Description, &t.Start, &t.End, &t.Price, &t.TicketingURL, &t.Notes, &t.ImageID, &t.GalleryID)
+	stmt := `SELECT title, slug, status, category, blurb, description, start, end, price, ticketing_url, notes, pickup, remind, follow_up, image_id, gallery_id, version FROM trips WHERE id = ? AND deleted_at IS NULL`
+	err := conn.QueryRowContext(ctx, stmt, t.ID).Scan(&t.Title, &t.Slug, &t.Status, &t.Category, &t.Blurb, &t.Description, &t.Start, &t.End, &t.Price, &t.TicketingURL, &t.Notes, &t.Pickup, &t.Remind, &t.FollowUp, &t.ImageID, &t.GalleryID, &t.Version)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return domain.ErrNotFound
@@ -112,84 +133,114 @@ func (t *Trip) Fetch() error {
 		return err
 	}
 
-	err = t.GetImage()
+	err = t.GetImage(ctx)
 	if err != nil {
 		return err
 	}
 
-	err = t.GetTripVendors()
+	err = t.GetTripVendors(ctx)
 	return err
 }
 
-func FindBySlug(s string) (*Trip, error) {
-	conn, _ := database.GetConnection()
+func FindBySlug(ctx context.Context, s string) (*Trip, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 	t := &Trip{}
 
-	stmt := `SELECT id, title, slug, status, blurb, description, start, end, price, ticketing_url, image_id, gallery_id FROM trips WHERE slug = ?`
-	err := conn.QueryRow(stmt, s).Scan(&t.ID, &t.Title, &t.Slug, &t.Status, &t.Blurb, &t.Description, &t.Start, &t.End, &t.Price, &t.TicketingURL, &t.ImageID, &t.GalleryID)
+	stmt := `SELECT id, title, slug, status, category, blurb, description, start, end, price, ticketing_url, pickup, image_id, gallery_id FROM trips WHERE slug = ? AND deleted_at IS NULL`
+	err := conn.QueryRowContext(ctx, stmt, s).Scan(&t.ID, &t.Title, &t.Slug, &t.Status, &t.Category, &t.Blurb, &t.Description, &t.Start, &t.End, &t.Price, &t.TicketingURL, &t.Pickup, &t.ImageID, &t.GalleryID)
 	if err == sql.ErrNoRows {
 		return nil, domain.ErrNotFound
 	}
 
-	err = t.GetImage()
+	err = t.GetImage(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	err = t.GetGallery()
+	err = t.GetGallery(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	err = t.GetTripVendors()
+	err = t.GetTripVendors(ctx)
 	return t, err
 }
 
-func (t *Trip) Update() error {
-	conn, _ := database.GetConnection()
+func (t *Trip) Update(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
 	if t.Slug.String == "" {
+		sl, err := domain.GetSlug(ctx, t.Title.String, "trips")
+		if err != nil {
+			return err
+		}
+
 		t.Slug = sql.NullString{
-			String: domain.GetSlug(t.Title.String, "trips"),
+			String: sl,
 			Valid:  true,
 		}
 	}
 
-	stmt := `UPDATE trips SET title = ?, slug = ?, status = ?, blurb = ?, description = ?, start = ?, end = ?, price = ?, ticketing_url = ?, notes = ?, image_id = ?, gallery_id = ?, updated_at = UTC_TIMESTAMP() WHERE id = ?`
-	_, err := conn.Exec(stmt, t.Title, t.Slug, t.Status, t.Blurb, t.Description, t.Start, t.End, t.Price, t.TicketingURL, t.Notes, t.ImageID, t.GalleryID, t.ID)
-	if err == sql.ErrNoRows {
-		return domain.ErrNotFound
+	stmt := `UPDATE trips SET title = ?, slug = ?, status = ?, category = ?, blurb = ?, description = ?, start = ?, end = ?, price = ?, ticketing_url = ?, notes = ?, pickup = ?, remind = ?, follow_up = ?, image_id = ?, gallery_id = ?, version = version + 1, updated_at = UTC_TIMESTAMP() WHERE id = ?` + versioned
+	result, err := conn.ExecContext(ctx, stmt, t.Title, t.Slug, t.Status, t.Category, t.Blurb, t.Description, t.Start, t.End, t.Price, t.TicketingURL, t.Notes, t.Pickup, t.Remind, t.FollowUp, t.ImageID, t.GalleryID, t.ID, t.Version)
+	if err != nil {
+		return err
 	}
-	return err
+	return bump(result, &t.Version)
 }
 
-func (t *Trip) Delete() error {
-	conn, _ := database.GetConnection()
+// Delete moves the trip to the trash. Its image stays until it's purged.
+func (t *Trip) Delete(ctx context.Context) error {
+	return trash(ctx, "trips", t.ID)
+}
 
-	stmt := `DELETE FROM trips WHERE id = ?`
-	_, err := conn.Exec(stmt, t.ID)
+// Restore takes the trip back out of the trash.
+func (t *Trip) Restore(ctx context.Context) error {
+	return restore(ctx, "trips", t.ID)
+}
+
+// Purge deletes the trip for good. It has to be in the trash first. ImageID is
+// filled in so the caller can remove the image too.
+func (t *Trip) Purge(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `SELECT image_id FROM trips WHERE id = ? AND deleted_at IS NOT NULL`
+	err := conn.QueryRowContext(ctx, stmt, t.ID).Scan(&t.ImageID)
 	if err == sql.ErrNoRows {
-		return nil
+		return domain.ErrNotFound
+	} else if err != nil {
+		return err
 	}
-	return err
+
+	return purge(ctx, "trips", t.ID)
 }
 
-func (t *Trip) GetBase() error {
-	conn, _ := database.GetConnection()
+func (t *Trip) GetBase(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
-	stmt := `SELECT image_id FROM trips WHERE id = ?`
-	err := conn.QueryRow(stmt, t.ID).Scan(&t.ImageID)
+	stmt := `SELECT image_id FROM trips WHERE id = ? AND deleted_at IS NULL`
+	err := conn.QueryRowContext(ctx, stmt, t.ID).Scan(&t.ImageID)
 	if err == sql.ErrNoRows {
 		return domain.ErrNotFound
 	}
 	return err
 }
 
-func FetchTrips() (*Trips, error) {
-	conn, _ := database.GetConnection()
+func FetchTrips(ctx context.Context) (*Trips, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
-	stmt := `SELECT id, title, status, start, end FROM trips ORDER BY start, end`
-	rows, err := conn.Query(stmt)
+	stmt := `SELECT id, title, status, start, end FROM trips WHERE deleted_at IS NULL ORDER BY start, end`
+	rows, err := conn.QueryContext(ctx, stmt)
 	if err != nil {
 		return nil, err
 	}
@@ -212,151 +263,112 @@ func FetchTrips() (*Trips, error) {
 	return &trips, nil
 }
 
-func FindUpcomingTrips(limit int) (*Trips, error) {
-	conn, _ := database.GetConnection()
+func FindUpcomingTrips(ctx context.Context, limit int) (*Trips, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
-	stmt := `SELECT id, title, slug, start, end, image_id, blurb FROM trips WHERE (start > NOW() - INTERVAL 1 DAY) AND status = 'published' ORDER BY start, end`
+	stmt := `SELECT id, title, slug, category, start, end, image_id, blurb FROM trips WHERE (start > NOW() - INTERVAL 1 DAY) AND status = 'published' AND deleted_at IS NULL ORDER BY start, end`
 
 	if limit > 0 {
 		stmt = stmt + ` LIMIT ` + strconv.Itoa(limit)
 	}
 
-	rows, err := conn.Query(stmt)
+	rows, err := conn.QueryContext(ctx, stmt)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	trips := Trips{}
-	for rows.Next() {
-		t := &Trip{}
-		err := rows.Scan(&t.ID, &t.Title, &t.Slug, &t.Start, &t.End, &t.ImageID, &t.Blurb)
-		if err != nil {
-			return nil, err
-		}
-
-		err = t.GetImage()
-		if err != nil {
-			return nil, err
-		}
-
-		trips = append(trips, t)
+	trips, err := scanTrips(rows)
+	if err != nil {
+		return nil, err
 	}
 
-	if err = rows.Err(); err != nil {
+	err = trips.LoadImages(ctx)
+	if err != nil {
 		return nil, err
 	}
 	return &trips, nil
 }
 
-func FindUpcomingTripsByMonth() (*GroupedTrips, error) {
-	conn, _ := database.GetConnection()
-
-	trips := make(GroupedTrips)
+func FindUpcomingTripsByMonth(ctx context.Context) (*GroupedTrips, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
-	stmt := `SELECT id, title, slug, start, end, image_id, blurb FROM trips WHERE (start > NOW() - INTERVAL 1 DAY) AND status = 'published' ORDER BY start, end`
+	stmt := `SELECT id, title, slug, category, start, end, image_id, blurb FROM trips WHERE (start > NOW() - INTERVAL 1 DAY) AND status = 'published' AND deleted_at IS NULL ORDER BY start, end`
 
-	rows, err := conn.Query(stmt)
+	rows, err := conn.QueryContext(ctx, stmt)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		t := &Trip{}
-		err := rows.Scan(&t.ID, &t.Title, &t.Slug, &t.Start, &t.End, &t.ImageID, &t.Blurb)
-		if err != nil {
-			return nil, err
-		}
-		month := t.Start.Format("01")
-
-		err = t.GetImage()
-		if err != nil {
-			return nil, err
-		}
 
-		trips[month] = append(trips[month], t)
-	}
-
-	if err = rows.Err(); err != nil {
+	list, err := scanTrips(rows)
+	if err != nil {
 		return nil, err
 	}
 
-	return &trips, nil
-}
-
-func (t *Trip) GetTripPartners() error {
-	conn, _ := database.GetConnection()
-
-	stmt := `SELECT v.id, v.name, v.brand_id, v.url FROM trips_partners tp JOIN vendors v ON tp.partner_id = v.id WHERE tp.trip_id = ? AND v.active = 1 ORDER BY name`
-	rows, err := conn.Query(stmt, t.ID)
+	err = list.LoadImages(ctx)
 	if err != nil {
-		return err
-	}
-	defer rows.Close()
-
-	partners := Vendors{}
-	for rows.Next() {
-		p := &Vendor{}
-		err := rows.Scan(&p.ID, &p.Name, &p.BrandID, &p.URL)
-		if err != nil {
-			return err
-		}
-
-		err = p.GetImage()
-		if err != nil {
-			return err
-		}
-		partners = append(partners, p)
+		return nil, err
 	}
 
-	if err = rows.Err(); err != nil {
-		return err
+	trips := make(GroupedTrips)
+	for _, t := range list {
+		month := t.Start.Format("01")
+		trips[month] = append(trips[month], t)
 	}
 
-	t.Partners = partners
-
-	return nil
+	return &trips, nil
 }
 
-func (t *Trip) GetTripVenues() error {
-	conn, _ := database.GetConnection()
+func FetchTripCategories(ctx context.Context) ([]string, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
-	stmt := `SELECT v.id, v.name, v.address, v.city, v.state, v.zip, v.phone, tv.is_primary FROM trips_venues tv JOIN vendors v ON tv.venue_id = v.id WHERE tv.trip_id = ? AND v.active = 1 ORDER BY name`
-	rows, err := conn.Query(stmt, t.ID)
+	stmt := `SELECT DISTINCT category FROM trips WHERE category IS NOT NULL AND category != '' AND deleted_at IS NULL ORDER BY category`
+	rows, err := conn.QueryContext(ctx, stmt)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer rows.Close()
 
-	venues := Vendors{}
+	categories := []string{}
 	for rows.Next() {
-		v := &Vendor{}
-		err := rows.Scan(&v.ID, &v.Name, &v.Address, &v.City, &v.State, &v.Zip, &v.Phone, &v.Primary)
+		var c string
+		err := rows.Scan(&c)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		venues = append(venues, v)
+		categories = append(categories, c)
 	}
 
 	if err = rows.Err(); err != nil {
-		return err
+		return nil, err
 	}
 
-	t.Venues = venues
+	return categories, nil
+}
 
-	return nil
+func (t *Trip) GetTripPartners(ctx context.Context) error {
+	return Trips{t}.LoadPartners(ctx)
+}
+
+func (t *Trip) GetTripVenues(ctx context.Context) error {
+	return Trips{t}.LoadVenues(ctx)
 }
 
-func (t *Trip) GetImage() error {
-	conn, _ := database.GetConnection()
-	defer conn.Close()
+func (t *Trip) GetImage(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
 	f := &File{}
 
 	stmt := `SELECT f.id, f.name, f.thumb FROM trips t JOIN files f ON t.image_id = f.id WHERE t.id = ?`
 
-	err := conn.QueryRow(stmt, t.ID).Scan(&f.ID, &f.Name, &f.Thumb)
+	err := conn.QueryRowContext(ctx, stmt, t.ID).Scan(&f.ID, &f.Name, &f.Thumb)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			err = nil
@@ -370,14 +382,17 @@ func (t *Trip) GetImage() error {
 	return nil
 }
 
-func (t *Trip) GetGallery() error {
+func (t *Trip) GetGallery(ctx context.Context) error {
 	if int(t.GalleryID.Int64) != 0 {
 		g := &Gallery{
 			ID: int(t.GalleryID.Int64),
 		}
 
-		err := g.Fetch()
-		if err != nil {
+		// a gallery in the trash just isn't shown
+		err := g.Fetch(ctx)
+		if err == domain.ErrNotFound {
+			return nil
+		} else if err != nil {
 			return err
 		}
 
@@ -386,21 +401,17 @@ func (t *Trip) GetGallery() error {
 	return nil
 }
 
-func (t *Trip) GetTripVendors() error {
-	err := t.GetTripPartners()
-	if err != nil {
-		return err
-	}
-
-	err = t.GetTripVenues()
-	return err
+func (t *Trip) GetTripVendors(ctx context.Context) error {
+	return Trips{t}.LoadVendors(ctx)
 }
 
-func (t *Trip) AttachVendor(r string, vid string) error {
-	conn, _ := database.GetConnection()
+func (t *Trip) AttachVendor(ctx context.Context, r string, vid string) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
 	stmt := `INSERT INTO trips_` + r + `s (trip_id, ` + r + `_id, created_at, updated_at) VALUES(?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP())`
-	_, err := conn.Exec(stmt, t.ID, vid)
+	_, err := conn.ExecContext(ctx, stmt, t.ID, vid)
 	if err != nil {
 		merr, ok := err.(*mysql.MySQLError)
 
@@ -411,29 +422,39 @@ func (t *Trip) AttachVendor(r string, vid string) error {
 	return err
 }
 
-func (t *Trip) DetachVendor(r string, vid string) error {
-	conn, _ := database.GetConnection()
+func (t *Trip) DetachVendor(ctx context.Context, r string, vid string) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
 	stmt := `DELETE FROM trips_` + r + `s WHERE trip_id = ? AND ` + r + `_id = ?`
-	_, err := conn.Exec(stmt, t.ID, vid)
+	_, err := conn.ExecContext(ctx, stmt, t.ID, vid)
 	if err != nil && err == sql.ErrNoRows {
 		return nil
 	}
 	return err
 }
 
-func (t *Trip) SetVenueStatus(vid string, isPrimary bool) error {
-	conn, _ := database.GetConnection()
-
-	if isPrimary {
-		stmt := `UPDATE trips_venues SET is_primary = false, updated_at = UTC_TIMESTAMP() WHERE trip_id = ? AND is_primary = true`
-		_, err := conn.Exec(stmt, t.ID)
-		if err != nil {
-			return err
+// SetVenueStatus marks the venue as the trip's primary one or not. Clearing
+// the old primary and setting the new one happen together, so the trip is
+// never left without one.
+func (t *Trip) SetVenueStatus(ctx context.Context, vid string, isPrimary bool) error {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	return database.InTx(ctx, func(ctx context.Context) error {
+		conn, _ := database.Conn(ctx)
+
+		if isPrimary {
+			stmt := `UPDATE trips_venues SET is_primary = false, updated_at = UTC_TIMESTAMP() WHERE trip_id = ? AND is_primary = true`
+			_, err := conn.ExecContext(ctx, stmt, t.ID)
+			if err != nil {
+				return err
+			}
 		}
-	}
 
-	stmt := `UPDATE trips_venues SET is_primary = ?, updated_at = UTC_TIMESTAMP() WHERE venue_id = ? AND trip_id = ?`
-	_, err := conn.Exec(stmt, isPrimary, vid, t.ID)
-	return err
+		stmt := `UPDATE trips_venues SET is_primary = ?, updated_at = UTC_TIMESTAMP() WHERE venue_id = ? AND trip_id = ?`
+		_, err := conn.ExecContext(ctx, stmt, isPrimary, vid, t.ID)
+		return err
+	})
 }
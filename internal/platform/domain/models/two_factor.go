@@ -0,0 +1,189 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"revelbus/internal/platform/domain"
+	"revelbus/internal/platform/forms"
+	"revelbus/pkg/database"
+	"revelbus/pkg/totp"
+	"strings"
+	"time"
+)
+
+const recoveryCodeCount = 10
+
+type TwoFactorForm struct {
+	Secret        string
+	Code          string
+	Remaining     int
+	RecoveryCodes []string
+
+	Errors map[string]string
+}
+
+func (f *TwoFactorForm) Valid() bool {
+	v := forms.NewValidator()
+
+	v.Required("Code", f.Code)
+
+	f.Errors = v.Errors
+	return len(f.Errors) == 0
+}
+
+// EnableTwoFactor turns on 2FA with a secret the user has proven they set up
+// by entering a code for step.
+func (u *User) EnableTwoFactor(ctx context.Context, secret string, step int64) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `UPDATE users SET totp_secret = ?, totp_enabled = 1, totp_last_step = ?, updated_at = UTC_TIMESTAMP() WHERE id = ?`
+	_, err := conn.ExecContext(ctx, stmt, secret, step, u.ID)
+	if err != nil {
+		return err
+	}
+
+	u.TOTPEnabled = true
+	return nil
+}
+
+func (u *User) DisableTwoFactor(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `UPDATE users SET totp_secret = NULL, totp_enabled = 0, totp_last_step = NULL, updated_at = UTC_TIMESTAMP() WHERE id = ?`
+	_, err := conn.ExecContext(ctx, stmt, u.ID)
+	if err != nil {
+		return err
+	}
+
+	stmt = `DELETE FROM recovery_codes WHERE user_id = ?`
+	_, err = conn.ExecContext(ctx, stmt, u.ID)
+	if err != nil {
+		return err
+	}
+
+	u.TOTPEnabled = false
+	return nil
+}
+
+// VerifyTOTP checks a code from the user's authenticator app. Each code is
+// only good once.
+func (u *User) VerifyTOTP(ctx context.Context, code string) (bool, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	var secret sql.NullString
+	var last sql.NullInt64
+
+	stmt := `SELECT totp_secret, totp_last_step FROM users WHERE id = ? AND totp_enabled = 1 AND deleted_at IS NULL`
+	err := conn.QueryRowContext(ctx, stmt, u.ID).Scan(&secret, &last)
+	if err == sql.ErrNoRows {
+		return false, domain.ErrNotFound
+	} else if err != nil {
+		return false, err
+	}
+
+	step, ok := totp.Validate(secret.String, code, time.Now(), last.Int64)
+	if !ok {
+		return false, nil
+	}
+
+	stmt = `UPDATE users SET totp_last_step = ? WHERE id = ? AND (totp_last_step IS NULL OR totp_last_step < ?)`
+	result, err := conn.ExecContext(ctx, stmt, step, u.ID, step)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// NewRecoveryCodes replaces the user's recovery codes. The codes are returned
+// to show once; only their hashes are kept.
+func (u *User) NewRecoveryCodes(ctx context.Context) ([]string, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	codes := make([]string, recoveryCodeCount)
+
+	// the old codes only go if the new ones are all saved
+	err := database.InTx(ctx, func(ctx context.Context) error {
+		conn, _ := database.Conn(ctx)
+
+		stmt := `DELETE FROM recovery_codes WHERE user_id = ?`
+		_, err := conn.ExecContext(ctx, stmt, u.ID)
+		if err != nil {
+			return err
+		}
+
+		for i := range codes {
+			b := make([]byte, 5)
+			_, err := rand.Read(b)
+			if err != nil {
+				return err
+			}
+
+			c := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+			codes[i] = c[:4] + "-" + c[4:]
+
+			stmt = `INSERT INTO recovery_codes (user_id, code_hash, created_at) VALUES(?, ?, UTC_TIMESTAMP())`
+			_, err = conn.ExecContext(ctx, stmt, u.ID, hashToken(normalizeRecoveryCode(codes[i])))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// UseRecoveryCode signs off one of the user's recovery codes. It reports
+// false if the code is wrong or already used.
+func (u *User) UseRecoveryCode(ctx context.Context, code string) (bool, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `UPDATE recovery_codes SET used_at = UTC_TIMESTAMP() WHERE user_id = ? AND code_hash = ? AND used_at IS NULL`
+	result, err := conn.ExecContext(ctx, stmt, u.ID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (u *User) RemainingRecoveryCodes(ctx context.Context) (int, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	var n int
+
+	stmt := `SELECT COUNT(*) FROM recovery_codes WHERE user_id = ? AND used_at IS NULL`
+	err := conn.QueryRowContext(ctx, stmt, u.ID).Scan(&n)
+	return n, err
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.Replace(code, "-", "", -1)
+	return strings.Replace(code, " ", "", -1)
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"revelbus/internal/platform/domain"
 	"revelbus/internal/platform/forms"
@@ -13,23 +14,59 @@ import (
 
 type User struct {
 	ID       int
+	Version  int
 	Name     sql.NullString
 	Email    sql.NullString
 	Password sql.NullString
 	Role     sql.NullString
+
+	TOTPEnabled bool
+	VerifiedAt  mysql.NullTime
+	LockedUntil mysql.NullTime
+
+	Permissions map[string]bool
+	Partner     bool
+
+	// Impersonator is the admin viewing the site as this user, if any.
+	Impersonator *User
 }
 
 type Users []*User
 
+// Can reports whether the user's role grants the permission. Admins can do
+// everything.
+func (u *User) Can(perm string) bool {
+	return u.Role.String == RoleAdmin || u.Permissions[perm]
+}
+
+// IsStaff is true for anyone whose role lets them into the admin.
+func (u *User) IsStaff() bool {
+	return u.Role.String == RoleAdmin || len(u.Permissions) > 0
+}
+
+// LoadPermissions fills in what the user's role lets them do.
+func (u *User) LoadPermissions(ctx context.Context) error {
+	perms, err := RolePermissions(ctx, u.Role.String)
+	if err != nil {
+		return err
+	}
+
+	u.Permissions = perms
+	return nil
+}
+
 type UserForm struct {
 	ID              string
+	Version         int
 	Name            string
 	Email           string
 	OldPassword     string
 	Password        string
 	ConfirmPassword string
 	Role            string
-	RecoveryHash    string
+	Verified        bool
+	ResetID         string
+	ResetToken      string
 
 	Errors map[string]string
 }
@@ -106,16 +143,20 @@ func (f *UserForm) ValidPassword() bool {
 	return len(f.Errors) == 0
 }
 
-func (u *User) Create() error {
-	conn, _ := database.GetConnection()
+func (u *User) Create(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
 
 	hp, err := bcrypt.GenerateFromPassword([]byte(u.Password.String), viper.GetInt("cost"))
 	if err != nil {
 		return err
 	}
 
+	// hashing is slow on purpose, so the deadline starts after it
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
 	stmt := `INSERT INTO users (email, name, role, password, created_at, updated_at) VALUES(?, ?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP())`
-	result, err := conn.Exec(stmt, u.Email, u.Name, u.Role, string(hp))
+	result, err := conn.ExecContext(ctx, stmt, u.Email, u.Name, u.Role, string(hp))
 	if err != nil {
 		merr, ok := err.(*mysql.MySQLError)
 
@@ -132,23 +173,26 @@ func (u *User) Create() error {
 	}
 
 	u.ID = int(id)
+	u.Version = 1
 
 	return err
 }
 
-func (u *User) Fetch() error {
-	conn, _ := database.GetConnection()
+func (u *User) Fetch(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
-	snippet := `SELECT id, name, email, role FROM users WHERE`
+	snippet := `SELECT id, name, email, role, totp_enabled, email_verified_at, version FROM users WHERE deleted_at IS NULL AND`
 
 	var err error
 
 	if u.ID != 0 {
 		stmt := snippet + ` id = ?`
-		err = conn.QueryRow(stmt, u.ID).Scan(&u.ID, &u.Name, &u.Email, &u.Role)
+		err = conn.QueryRowContext(ctx, stmt, u.ID).Scan(&u.ID, &u.Name, &u.Email, &u.Role, &u.TOTPEnabled, &u.VerifiedAt, &u.Version)
 	} else {
 		stmt := snippet + ` email = ?`
-		err = conn.QueryRow(stmt, u.Email).Scan(&u.ID, &u.Name, &u.Email, &u.Role)
+		err = conn.QueryRowContext(ctx, stmt, u.Email).Scan(&u.ID, &u.Name, &u.Email, &u.Role, &u.TOTPEnabled, &u.VerifiedAt, &u.Version)
 	}
 
 	if err == sql.ErrNoRows {
@@ -158,42 +202,92 @@ func (u *User) Fetch() error {
 	return err
 }
 
-func (u *User) Update() error {
-	conn, _ := database.GetConnection()
+// EmailInUse reports whether an account already has the address. Accounts in
+// the trash count, since restoring one would otherwise clash.
+func EmailInUse(ctx context.Context, email string) (bool, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
-	stmt := `UPDATE users SET name = ?, email = ?, role = ?, updated_at = UTC_TIMESTAMP() WHERE id = ?`
-	_, err := conn.Exec(stmt, u.Name, u.Email, u.Role, u.ID)
-	if err == sql.ErrNoRows {
-		return domain.ErrNotFound
+	var n int
+
+	stmt := `SELECT COUNT(*) FROM users WHERE email = ?`
+	err := conn.QueryRowContext(ctx, stmt, email).Scan(&n)
+	return n > 0, err
+}
+
+func (u *User) Update(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	// a new address has to be verified again
+	stmt := `UPDATE users SET email_verified_at = IF(email = ?, email_verified_at, NULL), name = ?, email = ?, role = ?, version = version + 1, updated_at = UTC_TIMESTAMP() WHERE id = ?` + versioned
+	result, err := conn.ExecContext(ctx, stmt, u.Email, u.Name, u.Email, u.Role, u.ID, u.Version)
+	if err != nil {
+		return err
 	}
-	return err
+	return bump(result, &u.Version)
 }
 
-func (u *User) UpdatePassword(pw string) error {
-	conn, _ := database.GetConnection()
+// UpdatePassword sets a new password, throws away any password resets still
+// outstanding for the user and signs them out everywhere, all or none of it.
+func (u *User) UpdatePassword(ctx context.Context, pw string) error {
 	hp, err := bcrypt.GenerateFromPassword([]byte(pw), viper.GetInt("cost"))
+	if err != nil {
+		return err
+	}
 
-	stmt := `UPDATE users SET password = ?, updated_at = UTC_TIMESTAMP() WHERE id = ?`
-	_, err = conn.Exec(stmt, string(hp), u.ID)
-	return err
+	return database.InTx(ctx, func(ctx context.Context) error {
+		conn, _ := database.Conn(ctx)
+
+		// hashing is slow on purpose, so the deadline starts after it
+		tctx, cancel := database.WithTimeout(ctx)
+		defer cancel()
+
+		stmt := `UPDATE users SET password = ?, updated_at = UTC_TIMESTAMP() WHERE id = ?`
+		_, err := conn.ExecContext(tctx, stmt, string(hp), u.ID)
+		if err != nil {
+			return err
+		}
+
+		err = InvalidatePasswordResets(ctx, u.ID)
+		if err != nil {
+			return err
+		}
+
+		return RevokeUserSessions(ctx, u.ID)
+	})
 }
 
-func (u *User) Delete() error {
-	conn, _ := database.GetConnection()
+// Delete moves the user to the trash and signs them out everywhere.
+func (u *User) Delete(ctx context.Context) error {
+	return database.InTx(ctx, func(ctx context.Context) error {
+		err := trash(ctx, "users", u.ID)
+		if err != nil {
+			return err
+		}
+		return RevokeUserSessions(ctx, u.ID)
+	})
+}
 
-	stmt := `DELETE FROM users WHERE id = ?`
-	_, err := conn.Exec(stmt, u.ID)
-	if err == sql.ErrNoRows {
-		return nil
-	}
-	return err
+// Restore takes the user back out of the trash.
+func (u *User) Restore(ctx context.Context) error {
+	return restore(ctx, "users", u.ID)
 }
 
-func FetchUsers() (Users, error) {
-	conn, _ := database.GetConnection()
+// Purge deletes the user for good. It has to be in the trash first.
+func (u *User) Purge(ctx context.Context) error {
+	return purge(ctx, "users", u.ID)
+}
 
-	stmt := `SELECT id, name, role FROM users ORDER BY name`
-	rows, err := conn.Query(stmt)
+func FetchUsers(ctx context.Context) (Users, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `SELECT id, name, role FROM users WHERE deleted_at IS NULL ORDER BY name`
+	rows, err := conn.QueryContext(ctx, stmt)
 	if err != nil {
 		return nil, err
 	}
@@ -216,14 +310,16 @@ func FetchUsers() (Users, error) {
 	return users, nil
 }
 
-func (u *User) VerifyUser(pw string) error {
-	conn, _ := database.GetConnection()
+func (u *User) VerifyUser(ctx context.Context, pw string) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
 	var hp []byte
 
-	stmt := `SELECT id, name, email, role, password FROM users WHERE email = ?`
+	stmt := `SELECT id, name, email, role, totp_enabled, email_verified_at, password FROM users WHERE email = ? AND deleted_at IS NULL`
 
-	err := conn.QueryRow(stmt, u.Email).Scan(&u.ID, &u.Name, &u.Email, &u.Role, &hp)
+	err := conn.QueryRowContext(ctx, stmt, u.Email).Scan(&u.ID, &u.Name, &u.Email, &u.Role, &u.TOTPEnabled, &u.VerifiedAt, &hp)
 	if err != nil && err != sql.ErrNoRows {
 		return err
 	}
@@ -238,57 +334,15 @@ func (u *User) VerifyUser(pw string) error {
 	return nil
 }
 
-func (u *User) VerifyAndUpdatePassword(old string, new string) error {
-	err := u.VerifyUser(old)
-	if err != nil {
-		return err
-	}
-
-	err = u.UpdatePassword(new)
-	if err != nil {
-		return err
-	}
-	return err
-}
-
-func (u *User) SetRecover(h string) error {
-	conn, _ := database.GetConnection()
-
-	stmt := `UPDATE users SET recovery_hash = ?, updated_at = UTC_TIMESTAMP() WHERE email = ?`
-	_, err := conn.Exec(stmt, h, u.Email)
-	return err
-}
-
-func (u *User) CheckRecover(h string) error {
-	conn, _ := database.GetConnection()
-
-	stmt := `SELECT email FROM users WHERE email = ? AND recovery_hash = ?`
-	err := conn.QueryRow(stmt, u.Email, h).Scan(&u.Email)
-	if err != nil && err == sql.ErrNoRows {
-		return domain.ErrNotFound
-	}
-
-	return err
-}
-
-func (u *User) Recover(h string, pw string) error {
-	err := u.Fetch()
+func (u *User) VerifyAndUpdatePassword(ctx context.Context, old string, new string) error {
+	err := u.VerifyUser(ctx, old)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return domain.ErrNotFound
-		}
 		return err
 	}
 
-	err = u.CheckRecover(h)
+	err = u.UpdatePassword(ctx, new)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return domain.ErrNotFound
-		}
 		return err
 	}
-
-	u.SetRecover("")
-	err = u.UpdatePassword(pw)
 	return err
 }
@@ -1,16 +1,16 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"revelbus/internal/platform/domain"
 	"revelbus/internal/platform/forms"
 	"revelbus/pkg/database"
-
-	"github.com/go-sql-driver/mysql"
 )
 
 type Vendor struct {
 	ID      int
+	Version int
 	Name    sql.NullString
 	Address sql.NullString
 	City    sql.NullString
@@ -32,6 +32,7 @@ type Vendors []*Vendor
 
 type VendorForm struct {
 	ID      string
+	Version int
 	Name    string
 	Address string
 	City    string
@@ -59,11 +60,13 @@ func (f *VendorForm) Valid() bool {
 	return len(f.Errors) == 0
 }
 
-func (v *Vendor) Create() error {
-	conn, _ := database.GetConnection()
+func (v *Vendor) Create(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
 	stmt := `INSERT INTO vendors (name, address, city, state, zip, phone, email, url, notes, brand_id, active, created_at, updated_at) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP())`
-	result, err := conn.Exec(stmt, v.Name, v.Address, v.City, v.State, v.Zip, v.Phone, v.Email, v.URL, v.Notes, v.BrandID, v.Active)
+	result, err := conn.ExecContext(ctx, stmt, v.Name, v.Address, v.City, v.State, v.Zip, v.Phone, v.Email, v.URL, v.Notes, v.BrandID, v.Active)
 	if err != nil {
 		return err
 	}
@@ -74,20 +77,25 @@ func (v *Vendor) Create() error {
 	}
 
 	v.ID = int(id)
+	v.Version = 1
 
 	return nil
 }
 
-func (v *Vendor) Fetch() error {
-	conn, _ := database.GetConnection()
+func (v *Vendor) Fetch(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
-	stmt := `SELECT id, name, address, city, state, zip, phone, email, url, notes, brand_id, active FROM vendors WHERE id = ?`
-	err := conn.QueryRow(stmt, v.ID).Scan(&v.ID, &v.Name, &v.Address, &v.City, &v.State, &v.Zip, &v.Phone, &v.Email, &v.URL, &v.Notes, &v.BrandID, &v.Active)
+	stmt := `SELECT id, name, address, city, state, zip, phone, email, url, notes, brand_id, active, version FROM vendors WHERE id = ? AND deleted_at IS NULL`
+	err := conn.QueryRowContext(ctx, stmt, v.ID).Scan(&v.ID, &v.Name, &v.Address, &v.City, &v.State, &v.Zip, &v.Phone, &v.Email, &v.URL, &v.Notes, &v.BrandID, &v.Active, &v.Version)
 	if err == sql.ErrNoRows {
 		return domain.ErrNotFound
+	} else if err != nil {
+		return err
 	}
 
-	err = v.GetImage()
+	err = v.GetImage(ctx)
 	if err != nil {
 		return err
 	}
@@ -95,53 +103,87 @@ func (v *Vendor) Fetch() error {
 	return err
 }
 
-func (v *Vendor) Update() error {
-	conn, _ := database.GetConnection()
+func (v *Vendor) Update(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
-	stmt := `UPDATE vendors SET name = ?, address = ?, city = ?, state = ?, zip = ?, phone = ?, email = ?, url = ?, notes = ?, brand_id = ?, active = ?, updated_at = UTC_TIMESTAMP() WHERE id = ?`
-	_, err := conn.Exec(stmt, v.Name, v.Address, v.City, v.State, v.Zip, v.Phone, v.Email, v.URL, v.Notes, v.BrandID, v.Active, v.ID)
-	if err == sql.ErrNoRows {
-		return domain.ErrNotFound
+	stmt := `UPDATE vendors SET name = ?, address = ?, city = ?, state = ?, zip = ?, phone = ?, email = ?, url = ?, notes = ?, brand_id = ?, active = ?, version = version + 1, updated_at = UTC_TIMESTAMP() WHERE id = ?` + versioned
+	result, err := conn.ExecContext(ctx, stmt, v.Name, v.Address, v.City, v.State, v.Zip, v.Phone, v.Email, v.URL, v.Notes, v.BrandID, v.Active, v.ID, v.Version)
+	if err != nil {
+		return err
 	}
-	return err
+	return bump(result, &v.Version)
 }
 
-func (v *Vendor) Delete() error {
-	conn, _ := database.GetConnection()
+// Delete moves the vendor to the trash, unless it's still on a trip.
+func (v *Vendor) Delete(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	var used bool
 
-	stmt := `DELETE FROM vendors WHERE id = ?`
-	_, err := conn.Exec(stmt, v.ID)
+	stmt := `SELECT EXISTS(SELECT 1 FROM trips_partners tp JOIN trips t ON tp.trip_id = t.id WHERE tp.partner_id = ? AND t.deleted_at IS NULL) OR EXISTS(SELECT 1 FROM trips_venues tv JOIN trips t ON tv.trip_id = t.id WHERE tv.venue_id = ? AND t.deleted_at IS NULL)`
+	err := conn.QueryRowContext(ctx, stmt, v.ID, v.ID).Scan(&used)
 	if err != nil {
-		merr, ok := err.(*mysql.MySQLError)
+		return err
+	}
 
-		if ok && merr.Number == 1451 {
-			return domain.ErrCannotDelete
-		} else if err == sql.ErrNoRows {
-			return domain.ErrNotFound
-		}
+	if used {
+		return domain.ErrCannotDelete
 	}
-	return err
+
+	return trash(ctx, "vendors", v.ID)
+}
+
+// Restore takes the vendor back out of the trash.
+func (v *Vendor) Restore(ctx context.Context) error {
+	return restore(ctx, "vendors", v.ID)
+}
+
+// Purge deletes the vendor for good. It has to be in the trash first, and
+// can't go while a trip in the trash still lists it. BrandID is filled in so
+// the caller can remove the logo too.
+func (v *Vendor) Purge(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `SELECT brand_id FROM vendors WHERE id = ? AND deleted_at IS NOT NULL`
+	err := conn.QueryRowContext(ctx, stmt, v.ID).Scan(&v.BrandID)
+	if err == sql.ErrNoRows {
+		return domain.ErrNotFound
+	} else if err != nil {
+		return err
+	}
+
+	return purge(ctx, "vendors", v.ID)
 }
 
-func (v *Vendor) GetBase() error {
-	conn, _ := database.GetConnection()
+func (v *Vendor) GetBase(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
-	stmt := `SELECT brand_id FROM vendors WHERE id = ?`
-	err := conn.QueryRow(stmt, v.ID).Scan(&v.BrandID)
+	stmt := `SELECT brand_id FROM vendors WHERE id = ? AND deleted_at IS NULL`
+	err := conn.QueryRowContext(ctx, stmt, v.ID).Scan(&v.BrandID)
 	if err == sql.ErrNoRows {
 		return domain.ErrNotFound
 	}
 	return err
 }
 
-func (v *Vendor) GetImage() error {
-	conn, _ := database.GetConnection()
+func (v *Vendor) GetImage(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
 	f := &File{}
 
 	stmt := `SELECT f.id, f.name, f.thumb FROM vendors v JOIN files f ON v.brand_id = f.id WHERE v.id = ?`
 
-	err := conn.QueryRow(stmt, v.ID).Scan(&f.ID, &f.Name, &f.Thumb)
+	err := conn.QueryRowContext(ctx, stmt, v.ID).Scan(&f.ID, &f.Name, &f.Thumb)
 	if err != sql.ErrNoRows && err != nil {
 		return err
 	}
@@ -151,19 +193,21 @@ func (v *Vendor) GetImage() error {
 	return nil
 }
 
-func FetchVendors(oa bool) (*Vendors, error) {
-	conn, _ := database.GetConnection()
+func FetchVendors(ctx context.Context, oa bool) (*Vendors, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
 	var stmt string
 
 	//only active
 	if oa {
-		stmt = `SELECT id, name, active FROM vendors WHERE active = 1 ORDER BY active DESC, name`
+		stmt = `SELECT id, name, active FROM vendors WHERE active = 1 AND deleted_at IS NULL ORDER BY active DESC, name`
 	} else {
-		stmt = `SELECT id, name, active FROM vendors ORDER BY active DESC, name`
+		stmt = `SELECT id, name, active FROM vendors WHERE deleted_at IS NULL ORDER BY active DESC, name`
 	}
 
-	rows, err := conn.Query(stmt)
+	rows, err := conn.QueryContext(ctx, stmt)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,223 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"revelbus/internal/platform/domain"
+	"revelbus/pkg/database"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	ChangePending  = "pending"
+	ChangeApproved = "approved"
+	ChangeRejected = "rejected"
+
+	// ChangeSuperseded is a change the partner replaced before it was
+	// reviewed.
+	ChangeSuperseded = "superseded"
+)
+
+// VendorChange is an edit a partner has made to their vendor listing. It only
+// shows on the site once an admin approves it.
+type VendorChange struct {
+	ID       int
+	VendorID int
+	UserID   int
+	Name     sql.NullString
+	Address  sql.NullString
+	City     sql.NullString
+	State    sql.NullString
+	Zip      sql.NullString
+	Phone    sql.NullString
+	Email    sql.NullString
+	URL      sql.NullString
+	BrandID  sql.NullInt64
+	Status   sql.NullString
+	Note     sql.NullString
+	Reviewed mysql.NullTime
+	Created  time.Time
+
+	Vendor *Vendor
+	User   *User
+	Brand  *File
+}
+
+type VendorChanges []*VendorChange
+
+// Submit saves the change for review. A partner only ever has one change
+// waiting per vendor; submitting again supersedes it with a new one, so an
+// admin approving what they looked at can't publish something they didn't.
+func (c *VendorChange) Submit(ctx context.Context) error {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	return database.InTx(ctx, func(ctx context.Context) error {
+		conn, _ := database.Conn(ctx)
+
+		stmt := `UPDATE vendor_changes SET status = ?, reviewed_at = UTC_TIMESTAMP() WHERE vendor_id = ? AND status = ?`
+		_, err := conn.ExecContext(ctx, stmt, ChangeSuperseded, c.VendorID, ChangePending)
+		if err != nil {
+			return err
+		}
+
+		stmt = `INSERT INTO vendor_changes (vendor_id, user_id, name, address, city, state, zip, phone, email, url, brand_id, status, created_at) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP())`
+		result, err := conn.ExecContext(ctx, stmt, c.VendorID, c.UserID, c.Name, c.Address, c.City, c.State, c.Zip, c.Phone, c.Email, c.URL, c.BrandID, ChangePending)
+		if err != nil {
+			return err
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		c.ID = int(id)
+		c.Status = sql.NullString{String: ChangePending, Valid: true}
+
+		return nil
+	})
+}
+
+func (c *VendorChange) Fetch(ctx context.Context) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	c.User = &User{}
+
+	stmt := `SELECT c.vendor_id, IFNULL(c.user_id, 0), c.name, c.address, c.city, c.state, c.zip, c.phone, c.email, c.url, c.brand_id, c.status, c.note, c.reviewed_at, c.created_at, u.name, u.email FROM vendor_changes c LEFT JOIN users u ON c.user_id = u.id WHERE c.id = ?`
+	err := conn.QueryRowContext(ctx, stmt, c.ID).Scan(&c.VendorID, &c.UserID, &c.Name, &c.Address, &c.City, &c.State, &c.Zip, &c.Phone, &c.Email, &c.URL, &c.BrandID, &c.Status, &c.Note, &c.Reviewed, &c.Created, &c.User.Name, &c.User.Email)
+	if err == sql.ErrNoRows {
+		return domain.ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	c.User.ID = c.UserID
+
+	return c.getImage(ctx)
+}
+
+// FindPendingVendorChange returns the change waiting on the vendor, or nil if
+// there isn't one.
+func FindPendingVendorChange(ctx context.Context, vendorID int) (*VendorChange, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	var id int
+
+	stmt := `SELECT id FROM vendor_changes WHERE vendor_id = ? AND status = ?`
+	err := conn.QueryRowContext(ctx, stmt, vendorID, ChangePending).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	c := &VendorChange{
+		ID: id,
+	}
+
+	err = c.Fetch(ctx)
+	return c, err
+}
+
+// Approve copies the change onto the vendor. Claiming the change first means
+// two admins approving at once can't apply it twice.
+func (c *VendorChange) Approve(ctx context.Context) error {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	return database.InTx(ctx, func(ctx context.Context) error {
+		conn, _ := database.Conn(ctx)
+
+		err := c.review(ctx, ChangeApproved, "")
+		if err != nil {
+			return err
+		}
+
+		stmt := `UPDATE vendors SET name = ?, address = ?, city = ?, state = ?, zip = ?, phone = ?, email = ?, url = ?, brand_id = ?, version = version + 1, updated_at = UTC_TIMESTAMP() WHERE id = ?`
+		_, err = conn.ExecContext(ctx, stmt, c.Name, c.Address, c.City, c.State, c.Zip, c.Phone, c.Email, c.URL, c.BrandID, c.VendorID)
+		return err
+	})
+}
+
+func (c *VendorChange) Reject(ctx context.Context, note string) error {
+	return c.review(ctx, ChangeRejected, note)
+}
+
+func (c *VendorChange) review(ctx context.Context, status string, note string) error {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `UPDATE vendor_changes SET status = ?, note = ?, reviewed_at = UTC_TIMESTAMP() WHERE id = ? AND status = ?`
+	result, err := conn.ExecContext(ctx, stmt, status, sql.NullString{String: note, Valid: note != ""}, c.ID, ChangePending)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return domain.ErrNotFound
+	}
+
+	c.Status = sql.NullString{String: status, Valid: true}
+	return nil
+}
+
+func (c *VendorChange) getImage(ctx context.Context) error {
+	c.Brand = &File{}
+
+	if !c.BrandID.Valid {
+		return nil
+	}
+
+	c.Brand.ID = int(c.BrandID.Int64)
+
+	err := c.Brand.Fetch(ctx)
+	if err == domain.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+func FetchPendingVendorChanges(ctx context.Context) (*VendorChanges, error) {
+	conn, _ := database.Conn(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	stmt := `SELECT c.id, c.vendor_id, IFNULL(c.user_id, 0), c.created_at, v.name, u.name FROM vendor_changes c JOIN vendors v ON c.vendor_id = v.id LEFT JOIN users u ON c.user_id = u.id WHERE c.status = ? AND v.deleted_at IS NULL ORDER BY c.created_at`
+	rows, err := conn.QueryContext(ctx, stmt, ChangePending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := VendorChanges{}
+	for rows.Next() {
+		c := &VendorChange{
+			Vendor: &Vendor{},
+			User:   &User{},
+		}
+		err := rows.Scan(&c.ID, &c.VendorID, &c.UserID, &c.Created, &c.Vendor.Name, &c.User.Name)
+		if err != nil {
+			return nil, err
+		}
+		c.Vendor.ID = c.VendorID
+		c.User.ID = c.UserID
+		changes = append(changes, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &changes, nil
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"database/sql"
+	"revelbus/internal/platform/domain"
+)
+
+// versioned is added to an update's WHERE clause, with the version the
+// record was read at, so the update only goes through if no one has saved the
+// record since. Versions start at 1, so an update that doesn't say which one
+// it read is a conflict too.
+const versioned = ` AND version = ?`
+
+// bump checks a versioned update went through and moves version on to match
+// the record. It's ErrConflict if the record was changed or has gone.
+func bump(result sql.Result, version *int) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return domain.ErrConflict
+	}
+
+	*version++
+	return nil
+}
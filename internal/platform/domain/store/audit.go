@@ -0,0 +1,744 @@
+package store
+
+import (
+	"context"
+	"revelbus/internal/platform/audit"
+	"revelbus/internal/platform/domain"
+	"revelbus/internal/platform/domain/models"
+)
+
+// Audited wraps s so every change made through it is written to the audit
+// log in the same transaction as the change itself. Each update or delete
+// reads the record first so the log can show what it was. Saving a trip, FAQ,
+// slide or the site settings also keeps a revision of its content.
+func Audited(s *Store) *Store {
+	a := *s
+	a.FAQs = auditFAQs{s.FAQs, s.InTx}
+	a.Files = auditFiles{s.Files, s.InTx}
+	a.Galleries = auditGalleries{s.Galleries, s.InTx}
+	a.Roles = auditRoles{s.Roles, s.InTx}
+	a.Sessions = auditSessions{s.Sessions, s.InTx}
+	a.Settings = auditSettings{s.Settings, s.InTx}
+	a.Slides = auditSlides{s.Slides, s.InTx}
+	a.Trips = auditTrips{s.Trips, s.InTx}
+	a.Users = auditUsers{s.Users, s.InTx}
+	a.VendorChanges = auditVendorChanges{s.VendorChanges, s.Vendors, s.InTx}
+	a.Vendors = auditVendors{s.Vendors, s.InTx}
+	return &a
+}
+
+type txFunc func(ctx context.Context, fn func(ctx context.Context) error) error
+
+type auditFAQs struct {
+	FAQStore
+	inTx txFunc
+}
+
+func (a auditFAQs) Create(ctx context.Context, f *models.FAQ) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		err := a.FAQStore.Create(ctx, f)
+		if err != nil {
+			return err
+		}
+
+		after := a.fetch(ctx, f.ID)
+
+		err = audit.Record(ctx, models.AuditCreate, models.AuditFAQ, f.ID, nil, after)
+		if err != nil {
+			return err
+		}
+		return audit.Revise(ctx, models.AuditFAQ, f.ID, nil, after)
+	})
+}
+
+func (a auditFAQs) Update(ctx context.Context, f *models.FAQ) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		before := a.fetch(ctx, f.ID)
+
+		err := a.FAQStore.Update(ctx, f)
+		if err != nil {
+			return err
+		}
+
+		after := a.fetch(ctx, f.ID)
+
+		err = audit.Record(ctx, models.AuditUpdate, models.AuditFAQ, f.ID, before, after)
+		if err != nil {
+			return err
+		}
+		return audit.Revise(ctx, models.AuditFAQ, f.ID, before, after)
+	})
+}
+
+func (a auditFAQs) Delete(ctx context.Context, f *models.FAQ) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		before := a.fetch(ctx, f.ID)
+
+		err := a.FAQStore.Delete(ctx, f)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditDelete, models.AuditFAQ, f.ID, before, nil)
+	})
+}
+
+func (a auditFAQs) Restore(ctx context.Context, f *models.FAQ) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		err := a.FAQStore.Restore(ctx, f)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditRestore, models.AuditFAQ, f.ID, nil, a.fetch(ctx, f.ID))
+	})
+}
+
+func (a auditFAQs) Purge(ctx context.Context, f *models.FAQ) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		err := a.FAQStore.Purge(ctx, f)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditPurge, models.AuditFAQ, f.ID, nil, nil)
+	})
+}
+
+func (a auditFAQs) fetch(ctx context.Context, id int) *models.FAQ {
+	f := &models.FAQ{ID: id}
+	if a.FAQStore.Fetch(ctx, f) != nil {
+		return nil
+	}
+	return f
+}
+
+type auditFiles struct {
+	FileStore
+	inTx txFunc
+}
+
+func (a auditFiles) Create(ctx context.Context, f *models.File) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		err := a.FileStore.Create(ctx, f)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditCreate, models.AuditFile, f.ID, nil, f)
+	})
+}
+
+func (a auditFiles) Delete(ctx context.Context, f *models.File) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		before := &models.File{ID: f.ID}
+		err := a.FileStore.Fetch(ctx, before)
+		if err != nil {
+			// there's nothing to delete
+			if err == domain.ErrNotFound {
+				return nil
+			}
+			return err
+		}
+
+		err = a.FileStore.Delete(ctx, before)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditDelete, models.AuditFile, f.ID, before, nil)
+	})
+}
+
+type auditGalleries struct {
+	GalleryStore
+	inTx txFunc
+}
+
+func (a auditGalleries) Create(ctx context.Context, g *models.Gallery) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		err := a.GalleryStore.Create(ctx, g)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditCreate, models.AuditGallery, g.ID, nil, a.fetch(ctx, g.ID))
+	})
+}
+
+func (a auditGalleries) Update(ctx context.Context, g *models.Gallery) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		before := a.fetch(ctx, g.ID)
+
+		err := a.GalleryStore.Update(ctx, g)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditUpdate, models.AuditGallery, g.ID, before, a.fetch(ctx, g.ID))
+	})
+}
+
+func (a auditGalleries) Delete(ctx context.Context, g *models.Gallery) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		before := a.fetch(ctx, g.ID)
+
+		err := a.GalleryStore.Delete(ctx, g)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditDelete, models.AuditGallery, g.ID, before, nil)
+	})
+}
+
+func (a auditGalleries) AttachImage(ctx context.Context, g *models.Gallery, fid string) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		err := a.GalleryStore.AttachImage(ctx, g, fid)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditAttachImage, models.AuditGallery, g.ID, nil, map[string]interface{}{"File": fid})
+	})
+}
+
+func (a auditGalleries) DetachImage(ctx context.Context, g *models.Gallery, fid string) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		err := a.GalleryStore.DetachImage(ctx, g, fid)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditDetachImage, models.AuditGallery, g.ID, map[string]interface{}{"File": fid}, nil)
+	})
+}
+
+func (a auditGalleries) Restore(ctx context.Context, g *models.Gallery) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		err := a.GalleryStore.Restore(ctx, g)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditRestore, models.AuditGallery, g.ID, nil, a.fetch(ctx, g.ID))
+	})
+}
+
+func (a auditGalleries) Purge(ctx context.Context, g *models.Gallery) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		err := a.GalleryStore.Purge(ctx, g)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditPurge, models.AuditGallery, g.ID, nil, nil)
+	})
+}
+
+func (a auditGalleries) fetch(ctx context.Context, id int) *models.Gallery {
+	g := &models.Gallery{ID: id}
+	if a.GalleryStore.Fetch(ctx, g) != nil {
+		return nil
+	}
+	return g
+}
+
+type auditRoles struct {
+	RoleStore
+	inTx txFunc
+}
+
+func (a auditRoles) Create(ctx context.Context, r *models.Role) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		err := a.RoleStore.Create(ctx, r)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditCreate, models.AuditRole, r.ID, nil, a.fetch(ctx, r.ID))
+	})
+}
+
+func (a auditRoles) Update(ctx context.Context, r *models.Role) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		before := a.fetch(ctx, r.ID)
+
+		err := a.RoleStore.Update(ctx, r)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditUpdate, models.AuditRole, r.ID, before, a.fetch(ctx, r.ID))
+	})
+}
+
+func (a auditRoles) Delete(ctx context.Context, r *models.Role) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		before := a.fetch(ctx, r.ID)
+
+		err := a.RoleStore.Delete(ctx, r)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditDelete, models.AuditRole, r.ID, before, nil)
+	})
+}
+
+func (a auditRoles) fetch(ctx context.Context, id int) *models.Role {
+	r := &models.Role{ID: id}
+	if a.RoleStore.Fetch(ctx, r) != nil {
+		return nil
+	}
+	return r
+}
+
+// auditSessions logs signing a device out against the user it belonged to.
+type auditSessions struct {
+	SessionStore
+	inTx txFunc
+}
+
+func (a auditSessions) Revoke(ctx context.Context, s *models.Session) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		err := a.SessionStore.Revoke(ctx, s)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditRevoke, models.AuditUser, s.UserID, map[string]interface{}{"Session": s.ID}, nil)
+	})
+}
+
+func (a auditSessions) RevokeAll(ctx context.Context, userID int) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		err := a.SessionStore.RevokeAll(ctx, userID)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditRevokeAll, models.AuditUser, userID, nil, nil)
+	})
+}
+
+type auditSettings struct {
+	SettingsStore
+	inTx txFunc
+}
+
+func (a auditSettings) Create(ctx context.Context, s *models.Settings) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		err := a.SettingsStore.Create(ctx, s)
+		if err != nil {
+			return err
+		}
+
+		after := a.fetch(ctx, s.ID)
+
+		err = audit.Record(ctx, models.AuditCreate, models.AuditSettings, s.ID, nil, after)
+		if err != nil {
+			return err
+		}
+		return audit.Revise(ctx, models.AuditSettings, s.ID, nil, after)
+	})
+}
+
+func (a auditSettings) Update(ctx context.Context, s *models.Settings) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		before := a.fetch(ctx, s.ID)
+
+		err := a.SettingsStore.Update(ctx, s)
+		if err != nil {
+			return err
+		}
+
+		after := a.fetch(ctx, s.ID)
+
+		err = audit.Record(ctx, models.AuditUpdate, models.AuditSettings, s.ID, before, after)
+		if err != nil {
+			return err
+		}
+		return audit.Revise(ctx, models.AuditSettings, s.ID, before, after)
+	})
+}
+
+func (a auditSettings) fetch(ctx context.Context, id int) *models.Settings {
+	s := &models.Settings{ID: id}
+	if a.SettingsStore.Fetch(ctx, s) != nil {
+		return nil
+	}
+	return s
+}
+
+type auditSlides struct {
+	SlideStore
+	inTx txFunc
+}
+
+func (a auditSlides) Create(ctx context.Context, s *models.Slide) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		err := a.SlideStore.Create(ctx, s)
+		if err != nil {
+			return err
+		}
+
+		after := a.fetch(ctx, s.ID)
+
+		err = audit.Record(ctx, models.AuditCreate, models.AuditSlide, s.ID, nil, after)
+		if err != nil {
+			return err
+		}
+		return audit.Revise(ctx, models.AuditSlide, s.ID, nil, after)
+	})
+}
+
+func (a auditSlides) Update(ctx context.Context, s *models.Slide) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		before := a.fetch(ctx, s.ID)
+
+		err := a.SlideStore.Update(ctx, s)
+		if err != nil {
+			return err
+		}
+
+		after := a.fetch(ctx, s.ID)
+
+		err = audit.Record(ctx, models.AuditUpdate, models.AuditSlide, s.ID, before, after)
+		if err != nil {
+			return err
+		}
+		return audit.Revise(ctx, models.AuditSlide, s.ID, before, after)
+	})
+}
+
+func (a auditSlides) Delete(ctx context.Context, s *models.Slide) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		before := a.fetch(ctx, s.ID)
+
+		err := a.SlideStore.Delete(ctx, s)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditDelete, models.AuditSlide, s.ID, before, nil)
+	})
+}
+
+func (a auditSlides) fetch(ctx context.Context, id int) *models.Slide {
+	s := &models.Slide{ID: id}
+	if a.SlideStore.Fetch(ctx, s) != nil {
+		return nil
+	}
+	return s
+}
+
+type auditTrips struct {
+	TripStore
+	inTx txFunc
+}
+
+func (a auditTrips) Create(ctx context.Context, t *models.Trip) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		err := a.TripStore.Create(ctx, t)
+		if err != nil {
+			return err
+		}
+
+		after := a.fetch(ctx, t.ID)
+
+		err = audit.Record(ctx, models.AuditCreate, models.AuditTrip, t.ID, nil, after)
+		if err != nil {
+			return err
+		}
+		return audit.Revise(ctx, models.AuditTrip, t.ID, nil, after)
+	})
+}
+
+func (a auditTrips) Update(ctx context.Context, t *models.Trip) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		before := a.fetch(ctx, t.ID)
+
+		err := a.TripStore.Update(ctx, t)
+		if err != nil {
+			return err
+		}
+
+		after := a.fetch(ctx, t.ID)
+
+		err = audit.Record(ctx, models.AuditUpdate, models.AuditTrip, t.ID, before, after)
+		if err != nil {
+			return err
+		}
+		return audit.Revise(ctx, models.AuditTrip, t.ID, before, after)
+	})
+}
+
+func (a auditTrips) Delete(ctx context.Context, t *models.Trip) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		before := a.fetch(ctx, t.ID)
+
+		err := a.TripStore.Delete(ctx, t)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditDelete, models.AuditTrip, t.ID, before, nil)
+	})
+}
+
+func (a auditTrips) AttachVendor(ctx context.Context, t *models.Trip, role string, vid string) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		err := a.TripStore.AttachVendor(ctx, t, role, vid)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditAttachVendor, models.AuditTrip, t.ID, nil, map[string]interface{}{"Vendor": vid, "Role": role})
+	})
+}
+
+func (a auditTrips) DetachVendor(ctx context.Context, t *models.Trip, role string, vid string) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		err := a.TripStore.DetachVendor(ctx, t, role, vid)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditDetachVendor, models.AuditTrip, t.ID, map[string]interface{}{"Vendor": vid, "Role": role}, nil)
+	})
+}
+
+func (a auditTrips) SetVenueStatus(ctx context.Context, t *models.Trip, vid string, isPrimary bool) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		err := a.TripStore.SetVenueStatus(ctx, t, vid, isPrimary)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditVenueStatus, models.AuditTrip, t.ID, nil, map[string]interface{}{"Venue": vid, "Primary": isPrimary})
+	})
+}
+
+func (a auditTrips) Restore(ctx context.Context, t *models.Trip) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		err := a.TripStore.Restore(ctx, t)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditRestore, models.AuditTrip, t.ID, nil, a.fetch(ctx, t.ID))
+	})
+}
+
+func (a auditTrips) Purge(ctx context.Context, t *models.Trip) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		err := a.TripStore.Purge(ctx, t)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditPurge, models.AuditTrip, t.ID, nil, nil)
+	})
+}
+
+func (a auditTrips) fetch(ctx context.Context, id int) *models.Trip {
+	t := &models.Trip{ID: id}
+	if a.TripStore.Fetch(ctx, t) != nil {
+		return nil
+	}
+	return t
+}
+
+type auditUsers struct {
+	UserStore
+	inTx txFunc
+}
+
+func (a auditUsers) Create(ctx context.Context, u *models.User) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		err := a.UserStore.Create(ctx, u)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditCreate, models.AuditUser, u.ID, nil, a.fetch(ctx, u.ID))
+	})
+}
+
+func (a auditUsers) Update(ctx context.Context, u *models.User) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		before := a.fetch(ctx, u.ID)
+
+		err := a.UserStore.Update(ctx, u)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditUpdate, models.AuditUser, u.ID, before, a.fetch(ctx, u.ID))
+	})
+}
+
+// UpdatePassword is logged without any values, just that it happened.
+func (a auditUsers) UpdatePassword(ctx context.Context, u *models.User, pw string) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		err := a.UserStore.UpdatePassword(ctx, u, pw)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditPassword, models.AuditUser, u.ID, nil, nil)
+	})
+}
+
+func (a auditUsers) SetVerified(ctx context.Context, u *models.User, verified bool) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		before := a.fetch(ctx, u.ID)
+
+		err := a.UserStore.SetVerified(ctx, u, verified)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditUpdate, models.AuditUser, u.ID, before, a.fetch(ctx, u.ID))
+	})
+}
+
+func (a auditUsers) Unlock(ctx context.Context, u *models.User) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		err := a.UserStore.Unlock(ctx, u)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditUnlock, models.AuditUser, u.ID, nil, nil)
+	})
+}
+
+// EnableTwoFactor and DisableTwoFactor are logged without the secret, just
+// that it was set up or taken away.
+func (a auditUsers) EnableTwoFactor(ctx context.Context, u *models.User, secret string, step int64) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		err := a.UserStore.EnableTwoFactor(ctx, u, secret, step)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditEnable2FA, models.AuditUser, u.ID, nil, nil)
+	})
+}
+
+func (a auditUsers) DisableTwoFactor(ctx context.Context, u *models.User) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		err := a.UserStore.DisableTwoFactor(ctx, u)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditDisable2FA, models.AuditUser, u.ID, nil, nil)
+	})
+}
+
+func (a auditUsers) Delete(ctx context.Context, u *models.User) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		before := a.fetch(ctx, u.ID)
+
+		err := a.UserStore.Delete(ctx, u)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditDelete, models.AuditUser, u.ID, before, nil)
+	})
+}
+
+func (a auditUsers) Restore(ctx context.Context, u *models.User) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		err := a.UserStore.Restore(ctx, u)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditRestore, models.AuditUser, u.ID, nil, a.fetch(ctx, u.ID))
+	})
+}
+
+func (a auditUsers) Purge(ctx context.Context, u *models.User) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		err := a.UserStore.Purge(ctx, u)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditPurge, models.AuditUser, u.ID, nil, nil)
+	})
+}
+
+func (a auditUsers) fetch(ctx context.Context, id int) *models.User {
+	u := &models.User{ID: id}
+	if a.UserStore.Fetch(ctx, u) != nil {
+		return nil
+	}
+	return u
+}
+
+// auditVendorChanges logs an approved change as an update to the vendor,
+// since that's what approving one does.
+type auditVendorChanges struct {
+	VendorChangeStore
+	vendors VendorStore
+	inTx    txFunc
+}
+
+func (a auditVendorChanges) Approve(ctx context.Context, c *models.VendorChange) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		before := a.fetch(ctx, c.VendorID)
+
+		err := a.VendorChangeStore.Approve(ctx, c)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditUpdate, models.AuditVendor, c.VendorID, before, a.fetch(ctx, c.VendorID))
+	})
+}
+
+func (a auditVendorChanges) fetch(ctx context.Context, id int) *models.Vendor {
+	v := &models.Vendor{ID: id}
+	if a.vendors.Fetch(ctx, v) != nil {
+		return nil
+	}
+	return v
+}
+
+type auditVendors struct {
+	VendorStore
+	inTx txFunc
+}
+
+func (a auditVendors) Create(ctx context.Context, v *models.Vendor) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		err := a.VendorStore.Create(ctx, v)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditCreate, models.AuditVendor, v.ID, nil, a.fetch(ctx, v.ID))
+	})
+}
+
+func (a auditVendors) Update(ctx context.Context, v *models.Vendor) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		before := a.fetch(ctx, v.ID)
+
+		err := a.VendorStore.Update(ctx, v)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditUpdate, models.AuditVendor, v.ID, before, a.fetch(ctx, v.ID))
+	})
+}
+
+func (a auditVendors) Delete(ctx context.Context, v *models.Vendor) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		before := a.fetch(ctx, v.ID)
+
+		err := a.VendorStore.Delete(ctx, v)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditDelete, models.AuditVendor, v.ID, before, nil)
+	})
+}
+
+func (a auditVendors) Restore(ctx context.Context, v *models.Vendor) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		err := a.VendorStore.Restore(ctx, v)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditRestore, models.AuditVendor, v.ID, nil, a.fetch(ctx, v.ID))
+	})
+}
+
+func (a auditVendors) Purge(ctx context.Context, v *models.Vendor) error {
+	return a.inTx(ctx, func(ctx context.Context) error {
+		err := a.VendorStore.Purge(ctx, v)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, models.AuditPurge, models.AuditVendor, v.ID, nil, nil)
+	})
+}
+
+func (a auditVendors) fetch(ctx context.Context, id int) *models.Vendor {
+	v := &models.Vendor{ID: id}
+	if a.VendorStore.Fetch(ctx, v) != nil {
+		return nil
+	}
+	return v
+}
@@ -0,0 +1,1231 @@
+package store
+
+import (
+	"context"
+	"revelbus/internal/platform/domain"
+	"revelbus/internal/platform/domain/models"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// NewMemory returns empty stores that keep everything in memory. They behave
+// like the MySQL stores as far as handlers can tell, minus uploaded files on
+// disk, which aren't tracked, and the audit log and revisions, which stay
+// empty since nothing writes them.
+func NewMemory() *Store {
+	vendors := &memoryVendors{
+		vendors: map[int]*models.Vendor{},
+		trash:   map[int]*models.Vendor{},
+	}
+
+	trips := &memoryTrips{
+		trips:    map[int]*models.Trip{},
+		partners: map[int]map[int]bool{},
+		venues:   map[int]map[int]bool{},
+		trash:    map[int]*models.Trip{},
+		vendors:  vendors,
+	}
+	vendors.trips = trips
+
+	users := &memoryUsers{
+		users:     map[int]*models.User{},
+		passwords: map[int][]byte{},
+		guards:    map[int]*memoryGuard{},
+		trash:     map[int]*models.User{},
+	}
+
+	faqs := &memoryFAQs{
+		faqs:  map[int]*models.FAQ{},
+		trash: map[int]*models.FAQ{},
+	}
+
+	galleries := &memoryGalleries{
+		galleries: map[int]*models.Gallery{},
+		images:    map[int][]int{},
+		trash:     map[int]*models.Gallery{},
+	}
+
+	roles := newMemoryRoles(users)
+
+	partners := &memoryPartners{
+		links:   map[int]map[int]bool{},
+		users:   users,
+		vendors: vendors,
+		trips:   trips,
+	}
+
+	sessions := &memorySessions{
+		sessions: map[int]*models.Session{},
+		users:    users,
+		roles:    roles,
+		partners: partners,
+	}
+
+	verifications := &memoryVerifications{
+		verifications: map[int]*models.EmailVerification{},
+		users:         users,
+	}
+	users.verifications = verifications
+
+	resets := &memoryPasswordResets{
+		resets: map[int]*models.PasswordReset{},
+		users:  users,
+	}
+	users.resets = resets
+	users.sessions = sessions
+
+	bookings := &memoryBookings{
+		bookings: map[int]*models.Booking{},
+		users:    users,
+		trips:    trips,
+	}
+	partners.bookings = bookings
+
+	changes := &memoryVendorChanges{
+		changes: map[int]*models.VendorChange{},
+		vendors: vendors,
+	}
+
+	return &Store{
+		AuditLog: memoryAuditLog{},
+		Bookings: bookings,
+		FAQs:     faqs,
+		Files: &memoryFiles{
+			files:     map[int]*models.File{},
+			vendors:   vendors,
+			trips:     trips,
+			galleries: galleries,
+			changes:   changes,
+		},
+		Galleries: galleries,
+		Identities: &memoryIdentities{
+			identities: map[int]*models.Identity{},
+			users:      users,
+		},
+		Impersonations: &memoryImpersonations{
+			impersonations: map[int]*models.Impersonation{},
+			sessions:       sessions,
+		},
+		Messages: &memoryMessages{
+			messages: map[int]*models.Message{},
+		},
+		Outbox: &memoryOutbox{
+			emails: map[int]*models.OutboxEmail{},
+		},
+		Partners:       partners,
+		PasswordResets: resets,
+		Revisions:      memoryRevisions{},
+		Roles:          roles,
+		SecurityEvents: &memorySecurityEvents{},
+		Sessions:       sessions,
+		Settings:       &memorySettings{},
+		Slides: &memorySlides{
+			slides: map[int]*models.Slide{},
+		},
+		Subscribers: &memorySubscribers{
+			subscribers: map[int]*models.Subscriber{},
+		},
+		Trash: &memoryTrash{
+			faqs:      faqs,
+			galleries: galleries,
+			trips:     trips,
+			users:     users,
+			vendors:   vendors,
+		},
+		Trips:         trips,
+		Users:         users,
+		VendorChanges: changes,
+		Vendors:       vendors,
+		Verifications: verifications,
+		InTx:          memoryInTx,
+	}
+}
+
+// memoryInTx just runs fn; there's nothing to roll back to in memory.
+func memoryInTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+type memoryFAQs struct {
+	sync.Mutex
+	faqs   map[int]*models.FAQ
+	trash  map[int]*models.FAQ
+	nextID int
+}
+
+func (m *memoryFAQs) Create(ctx context.Context, f *models.FAQ) error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.nextID++
+	f.ID = m.nextID
+	f.Version = 1
+
+	c := *f
+	m.faqs[f.ID] = &c
+	return nil
+}
+
+func (m *memoryFAQs) Fetch(ctx context.Context, f *models.FAQ) error {
+	m.Lock()
+	defer m.Unlock()
+
+	c, ok := m.faqs[f.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	*f = *c
+	return nil
+}
+
+func (m *memoryFAQs) Update(ctx context.Context, f *models.FAQ) error {
+	m.Lock()
+	defer m.Unlock()
+
+	old, ok := m.faqs[f.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	if stale(f.Version, old.Version) {
+		return domain.ErrConflict
+	}
+	f.Version = old.Version + 1
+
+	c := *f
+	m.faqs[f.ID] = &c
+	return nil
+}
+
+func (m *memoryFAQs) Delete(ctx context.Context, f *models.FAQ) error {
+	m.Lock()
+	defer m.Unlock()
+
+	c, ok := m.faqs[f.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	delete(m.faqs, f.ID)
+	m.trash[f.ID] = c
+	return nil
+}
+
+func (m *memoryFAQs) Restore(ctx context.Context, f *models.FAQ) error {
+	m.Lock()
+	defer m.Unlock()
+
+	c, ok := m.trash[f.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	delete(m.trash, f.ID)
+	m.faqs[f.ID] = c
+	return nil
+}
+
+func (m *memoryFAQs) Purge(ctx context.Context, f *models.FAQ) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if _, ok := m.trash[f.ID]; !ok {
+		return domain.ErrNotFound
+	}
+
+	delete(m.trash, f.ID)
+	return nil
+}
+
+func (m *memoryFAQs) FetchAll(ctx context.Context) (*models.FAQs, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	faqs := models.FAQs{}
+	for _, f := range m.faqs {
+		c := *f
+		faqs = append(faqs, &c)
+	}
+
+	sort.SliceStable(faqs, func(i, j int) bool {
+		return faqs[i].Order.Int64 < faqs[j].Order.Int64
+	})
+
+	return &faqs, nil
+}
+
+func (m *memoryFAQs) FindActive(ctx context.Context) (*models.GroupedFAQs, error) {
+	all, err := m.FetchAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	faqs := make(models.GroupedFAQs)
+	for _, f := range *all {
+		if f.Active {
+			faqs[f.Category.String] = append(faqs[f.Category.String], f)
+		}
+	}
+
+	return &faqs, nil
+}
+
+type memoryGalleries struct {
+	sync.Mutex
+	galleries map[int]*models.Gallery
+	images    map[int][]int
+	trash     map[int]*models.Gallery
+	nextID    int
+}
+
+func (m *memoryGalleries) Create(ctx context.Context, g *models.Gallery) error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.nextID++
+	g.ID = m.nextID
+	g.Version = 1
+
+	c := *g
+	c.Images = nil
+	m.galleries[g.ID] = &c
+	return nil
+}
+
+func (m *memoryGalleries) Fetch(ctx context.Context, g *models.Gallery) error {
+	m.Lock()
+	defer m.Unlock()
+
+	c, ok := m.galleries[g.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	*g = *c
+
+	g.Images = models.Files{}
+	for _, fid := range m.images[g.ID] {
+		g.Images = append(g.Images, &models.File{ID: fid})
+	}
+	return nil
+}
+
+func (m *memoryGalleries) Update(ctx context.Context, g *models.Gallery) error {
+	m.Lock()
+	defer m.Unlock()
+
+	c, ok := m.galleries[g.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	if stale(g.Version, c.Version) {
+		return domain.ErrConflict
+	}
+	c.Version++
+	g.Version = c.Version
+
+	// like the table, the folder never changes once made
+	c.Name = g.Name
+	return nil
+}
+
+func (m *memoryGalleries) Delete(ctx context.Context, g *models.Gallery) error {
+	m.Lock()
+	defer m.Unlock()
+
+	c, ok := m.galleries[g.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	delete(m.galleries, g.ID)
+	m.trash[g.ID] = c
+	return nil
+}
+
+func (m *memoryGalleries) Restore(ctx context.Context, g *models.Gallery) error {
+	m.Lock()
+	defer m.Unlock()
+
+	c, ok := m.trash[g.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	delete(m.trash, g.ID)
+	m.galleries[g.ID] = c
+	return nil
+}
+
+func (m *memoryGalleries) Purge(ctx context.Context, g *models.Gallery) error {
+	m.Lock()
+	defer m.Unlock()
+
+	c, ok := m.trash[g.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	g.Folder = c.Folder
+	delete(m.trash, g.ID)
+	delete(m.images, g.ID)
+	return nil
+}
+
+func (m *memoryGalleries) FetchAll(ctx context.Context) (*models.Galleries, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	galleries := models.Galleries{}
+	for _, g := range m.galleries {
+		galleries = append(galleries, &models.Gallery{
+			ID:   g.ID,
+			Name: g.Name,
+		})
+	}
+
+	sort.Slice(galleries, func(i, j int) bool {
+		return galleries[i].ID < galleries[j].ID
+	})
+
+	return &galleries, nil
+}
+
+func (m *memoryGalleries) AttachImage(ctx context.Context, g *models.Gallery, fid string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	id, _ := strconv.Atoi(fid)
+	for _, existing := range m.images[g.ID] {
+		if existing == id {
+			return domain.ErrDuplicate
+		}
+	}
+
+	m.images[g.ID] = append(m.images[g.ID], id)
+	return nil
+}
+
+func (m *memoryGalleries) DetachImage(ctx context.Context, g *models.Gallery, fid string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	id, _ := strconv.Atoi(fid)
+	images := []int{}
+	for _, existing := range m.images[g.ID] {
+		if existing != id {
+			images = append(images, existing)
+		}
+	}
+
+	m.images[g.ID] = images
+	return nil
+}
+
+type memorySlides struct {
+	sync.Mutex
+	slides map[int]*models.Slide
+	nextID int
+}
+
+func (m *memorySlides) Create(ctx context.Context, s *models.Slide) error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.nextID++
+	s.ID = m.nextID
+	s.Version = 1
+
+	c := *s
+	m.slides[s.ID] = &c
+	return nil
+}
+
+func (m *memorySlides) Fetch(ctx context.Context, s *models.Slide) error {
+	m.Lock()
+	defer m.Unlock()
+
+	c, ok := m.slides[s.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	*s = *c
+	return nil
+}
+
+func (m *memorySlides) Update(ctx context.Context, s *models.Slide) error {
+	m.Lock()
+	defer m.Unlock()
+
+	old, ok := m.slides[s.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	if stale(s.Version, old.Version) {
+		return domain.ErrConflict
+	}
+	s.Version = old.Version + 1
+
+	c := *s
+	m.slides[s.ID] = &c
+	return nil
+}
+
+func (m *memorySlides) Delete(ctx context.Context, s *models.Slide) error {
+	m.Lock()
+	defer m.Unlock()
+
+	delete(m.slides, s.ID)
+	return nil
+}
+
+func (m *memorySlides) FetchAll(ctx context.Context) (*models.Slides, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	slides := models.Slides{}
+	for _, s := range m.slides {
+		c := *s
+		slides = append(slides, &c)
+	}
+
+	sort.SliceStable(slides, func(i, j int) bool {
+		return slides[i].Order.Int64 < slides[j].Order.Int64
+	})
+
+	return &slides, nil
+}
+
+func (m *memorySlides) FindActive(ctx context.Context) (*models.Slides, error) {
+	all, err := m.FetchAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	slides := models.Slides{}
+	for _, s := range *all {
+		if s.Active {
+			slides = append(slides, s)
+		}
+	}
+
+	return &slides, nil
+}
+
+type memoryTrips struct {
+	sync.Mutex
+	trips    map[int]*models.Trip
+	partners map[int]map[int]bool
+	venues   map[int]map[int]bool
+	trash    map[int]*models.Trip
+	vendors  *memoryVendors
+	nextID   int
+}
+
+func (m *memoryTrips) Create(ctx context.Context, t *models.Trip) error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.nextID++
+	t.ID = m.nextID
+	t.Version = 1
+
+	m.trips[t.ID] = m.copy(t)
+	m.partners[t.ID] = map[int]bool{}
+	m.venues[t.ID] = map[int]bool{}
+	return nil
+}
+
+func (m *memoryTrips) Fetch(ctx context.Context, t *models.Trip) error {
+	m.Lock()
+	defer m.Unlock()
+
+	c, ok := m.trips[t.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	*t = *m.copy(c)
+	t.Image = &models.File{}
+	t.Partners = m.vendors.find(m.partners[t.ID])
+	t.Venues = m.vendors.find(m.venues[t.ID])
+	return nil
+}
+
+func (m *memoryTrips) GetBase(ctx context.Context, t *models.Trip) error {
+	m.Lock()
+	defer m.Unlock()
+
+	c, ok := m.trips[t.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	t.ImageID = c.ImageID
+	return nil
+}
+
+func (m *memoryTrips) Update(ctx context.Context, t *models.Trip) error {
+	m.Lock()
+	defer m.Unlock()
+
+	old, ok := m.trips[t.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	if stale(t.Version, old.Version) {
+		return domain.ErrConflict
+	}
+	t.Version = old.Version + 1
+
+	m.trips[t.ID] = m.copy(t)
+	return nil
+}
+
+func (m *memoryTrips) Delete(ctx context.Context, t *models.Trip) error {
+	m.Lock()
+	defer m.Unlock()
+
+	c, ok := m.trips[t.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	delete(m.trips, t.ID)
+	m.trash[t.ID] = c
+	return nil
+}
+
+func (m *memoryTrips) Restore(ctx context.Context, t *models.Trip) error {
+	m.Lock()
+	defer m.Unlock()
+
+	c, ok := m.trash[t.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	delete(m.trash, t.ID)
+	m.trips[t.ID] = c
+	return nil
+}
+
+func (m *memoryTrips) Purge(ctx context.Context, t *models.Trip) error {
+	m.Lock()
+	defer m.Unlock()
+
+	c, ok := m.trash[t.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	t.ImageID = c.ImageID
+	delete(m.trash, t.ID)
+	delete(m.partners, t.ID)
+	delete(m.venues, t.ID)
+	return nil
+}
+
+// uses reports whether a trip outside the trash has the vendor, and whether
+// one in the trash does.
+func (m *memoryTrips) uses(vid int) (live bool, trashed bool) {
+	m.Lock()
+	defer m.Unlock()
+
+	for id := range m.partners {
+		if m.partners[id][vid] || m.venues[id][vid] {
+			if _, ok := m.trips[id]; ok {
+				live = true
+			} else {
+				trashed = true
+			}
+		}
+	}
+	return live, trashed
+}
+
+func (m *memoryTrips) FindBySlug(ctx context.Context, slug string) (*models.Trip, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, t := range m.trips {
+		if t.Slug.String == slug {
+			c := m.copy(t)
+			c.Image = &models.File{}
+			c.Partners = m.vendors.find(m.partners[t.ID])
+			c.Venues = m.vendors.find(m.venues[t.ID])
+			return c, nil
+		}
+	}
+
+	return nil, domain.ErrNotFound
+}
+
+func (m *memoryTrips) FetchAll(ctx context.Context) (*models.Trips, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	trips := m.sorted(func(t *models.Trip) bool {
+		return true
+	})
+
+	return &trips, nil
+}
+
+func (m *memoryTrips) FindUpcoming(ctx context.Context, limit int) (*models.Trips, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	trips := m.sorted(upcoming)
+	if limit > 0 && len(trips) > limit {
+		trips = trips[:limit]
+	}
+
+	return &trips, nil
+}
+
+func (m *memoryTrips) FindUpcomingByMonth(ctx context.Context) (*models.GroupedTrips, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	trips := make(models.GroupedTrips)
+	for _, t := range m.sorted(upcoming) {
+		month := t.Start.Format("01")
+		trips[month] = append(trips[month], t)
+	}
+
+	return &trips, nil
+}
+
+func (m *memoryTrips) AttachVendor(ctx context.Context, t *models.Trip, role string, vid string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	links := m.links(t.ID, role)
+	id, _ := strconv.Atoi(vid)
+
+	if _, ok := links[id]; ok {
+		return domain.ErrDuplicate
+	}
+
+	// the tables' foreign keys turn away vendors that don't exist
+	if m.vendors.get(id) == nil {
+		return &mysql.MySQLError{Number: 1452, Message: "vendor does not exist"}
+	}
+
+	links[id] = false
+	return nil
+}
+
+func (m *memoryTrips) DetachVendor(ctx context.Context, t *models.Trip, role string, vid string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	id, _ := strconv.Atoi(vid)
+	delete(m.links(t.ID, role), id)
+	return nil
+}
+
+func (m *memoryTrips) SetVenueStatus(ctx context.Context, t *models.Trip, vid string, isPrimary bool) error {
+	m.Lock()
+	defer m.Unlock()
+
+	venues := m.links(t.ID, "venue")
+	id, _ := strconv.Atoi(vid)
+
+	if isPrimary {
+		for v := range venues {
+			venues[v] = false
+		}
+	}
+
+	if _, ok := venues[id]; ok {
+		venues[id] = isPrimary
+	}
+	return nil
+}
+
+func (m *memoryTrips) FetchCategories(ctx context.Context) ([]string, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	seen := map[string]bool{}
+	categories := []string{}
+	for _, t := range m.trips {
+		if c := t.Category.String; c != "" && !seen[c] {
+			seen[c] = true
+			categories = append(categories, c)
+		}
+	}
+
+	sort.Strings(categories)
+	return categories, nil
+}
+
+func (m *memoryTrips) links(tripID int, role string) map[int]bool {
+	all := m.partners
+	if role == "venue" {
+		all = m.venues
+	}
+
+	if all[tripID] == nil {
+		all[tripID] = map[int]bool{}
+	}
+	return all[tripID]
+}
+
+// copy keeps only the trip's own columns, the way they'd come back from the
+// table.
+func (m *memoryTrips) copy(t *models.Trip) *models.Trip {
+	c := *t
+	c.Image = nil
+	c.Gallery = nil
+	c.Partners = nil
+	c.Venues = nil
+	c.CalendarLinks = nil
+	return &c
+}
+
+func (m *memoryTrips) sorted(include func(*models.Trip) bool) models.Trips {
+	trips := models.Trips{}
+	for _, t := range m.trips {
+		if include(t) {
+			c := m.copy(t)
+			c.Image = &models.File{}
+			trips = append(trips, c)
+		}
+	}
+
+	sort.Slice(trips, func(i, j int) bool {
+		if trips[i].Start.Equal(trips[j].Start) {
+			return trips[i].End.Before(trips[j].End)
+		}
+		return trips[i].Start.Before(trips[j].Start)
+	})
+
+	return trips
+}
+
+func upcoming(t *models.Trip) bool {
+	return t.Status.String == "published" && t.Start.After(time.Now().Add(-24*time.Hour))
+}
+
+type memoryUsers struct {
+	// mu is named since the store has an Unlock method of its own
+	mu        sync.Mutex
+	users     map[int]*models.User
+	passwords map[int][]byte
+	guards    map[int]*memoryGuard
+	trash     map[int]*models.User
+	nextID    int
+
+	verifications *memoryVerifications
+	resets        *memoryPasswordResets
+	sessions      *memorySessions
+}
+
+func (m *memoryUsers) Create(ctx context.Context, u *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.emailTaken(u.Email.String) {
+		return domain.ErrDuplicateEmail
+	}
+
+	// the lowest cost keeps tests quick; nothing here is stored for real
+	hp, err := bcrypt.GenerateFromPassword([]byte(u.Password.String), bcrypt.MinCost)
+	if err != nil {
+		return err
+	}
+
+	m.nextID++
+	u.ID = m.nextID
+	u.Version = 1
+
+	m.users[u.ID] = m.copy(u)
+	m.passwords[u.ID] = hp
+	return nil
+}
+
+func (m *memoryUsers) Fetch(ctx context.Context, u *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c := m.users[u.ID]
+	if u.ID == 0 {
+		c = m.byEmail(u.Email.String)
+	}
+
+	if c == nil {
+		return domain.ErrNotFound
+	}
+
+	*u = *m.copy(c)
+	return nil
+}
+
+func (m *memoryUsers) Update(ctx context.Context, u *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.users[u.ID]
+	if !ok {
+		return nil
+	}
+
+	if stale(u.Version, c.Version) {
+		return domain.ErrConflict
+	}
+
+	if other := m.byEmail(u.Email.String); other != nil && other.ID != u.ID {
+		return &mysql.MySQLError{Number: 1062, Message: "duplicate email"}
+	}
+
+	// a new address has to be verified again
+	if c.Email.String != u.Email.String {
+		c.VerifiedAt = mysql.NullTime{}
+	}
+
+	c.Name = u.Name
+	c.Email = u.Email
+	c.Role = u.Role
+	c.Version++
+	u.Version = c.Version
+	return nil
+}
+
+func (m *memoryUsers) UpdatePassword(ctx context.Context, u *models.User, pw string) error {
+	hp, err := bcrypt.GenerateFromPassword([]byte(pw), bcrypt.MinCost)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	if _, ok := m.users[u.ID]; ok {
+		m.passwords[u.ID] = hp
+	}
+	m.mu.Unlock()
+
+	m.resets.invalidate(u.ID)
+	return m.sessions.RevokeAll(ctx, u.ID)
+}
+
+func (m *memoryUsers) Delete(ctx context.Context, u *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.users[u.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	delete(m.users, u.ID)
+	m.trash[u.ID] = c
+	return nil
+}
+
+func (m *memoryUsers) Restore(ctx context.Context, u *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.trash[u.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	delete(m.trash, u.ID)
+	m.users[u.ID] = c
+	return nil
+}
+
+func (m *memoryUsers) Purge(ctx context.Context, u *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.trash[u.ID]; !ok {
+		return domain.ErrNotFound
+	}
+
+	delete(m.trash, u.ID)
+	delete(m.passwords, u.ID)
+	delete(m.guards, u.ID)
+	return nil
+}
+
+func (m *memoryUsers) FetchAll(ctx context.Context) (models.Users, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	users := models.Users{}
+	for _, u := range m.users {
+		users = append(users, m.copy(u))
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Name.String < users[j].Name.String
+	})
+
+	return users, nil
+}
+
+func (m *memoryUsers) VerifyUser(ctx context.Context, u *models.User, pw string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var hp []byte
+
+	c := m.byEmail(u.Email.String)
+	if c != nil {
+		*u = *m.copy(c)
+		hp = m.passwords[c.ID]
+	}
+
+	err := bcrypt.CompareHashAndPassword(hp, []byte(pw))
+	if err == bcrypt.ErrMismatchedHashAndPassword || err == bcrypt.ErrHashTooShort {
+		return domain.ErrInvalidCredentials
+	}
+	return err
+}
+
+func (m *memoryUsers) EmailInUse(ctx context.Context, email string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.emailTaken(email), nil
+}
+
+// emailTaken counts users in the trash too, like the unique index does.
+func (m *memoryUsers) emailTaken(email string) bool {
+	if m.byEmail(email) != nil {
+		return true
+	}
+
+	for _, u := range m.trash {
+		if u.Email.String == email {
+			return true
+		}
+	}
+	return false
+}
+
+// get returns a copy of the user, or nil if there's no such user outside
+// the trash.
+func (m *memoryUsers) get(id int) *models.User {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[id]
+	if !ok {
+		return nil
+	}
+	return m.copy(u)
+}
+
+func (m *memoryUsers) byEmail(email string) *models.User {
+	for _, u := range m.users {
+		if u.Email.String == email {
+			return u
+		}
+	}
+	return nil
+}
+
+// copy leaves out the password, which is only ever kept hashed, and anything
+// loaded per request.
+func (m *memoryUsers) copy(u *models.User) *models.User {
+	c := *u
+	c.Password.String = ""
+	c.Password.Valid = false
+	c.Permissions = nil
+	c.Impersonator = nil
+	return &c
+}
+
+type memoryVendors struct {
+	sync.Mutex
+	vendors map[int]*models.Vendor
+	trash   map[int]*models.Vendor
+	trips   *memoryTrips
+	nextID  int
+}
+
+func (m *memoryVendors) Create(ctx context.Context, v *models.Vendor) error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.nextID++
+	v.ID = m.nextID
+	v.Version = 1
+
+	c := *v
+	c.Brand = nil
+	m.vendors[v.ID] = &c
+	return nil
+}
+
+func (m *memoryVendors) Fetch(ctx context.Context, v *models.Vendor) error {
+	c := m.get(v.ID)
+	if c == nil {
+		return domain.ErrNotFound
+	}
+
+	*v = *c
+	v.Brand = &models.File{}
+	return nil
+}
+
+func (m *memoryVendors) GetBase(ctx context.Context, v *models.Vendor) error {
+	c := m.get(v.ID)
+	if c == nil {
+		return domain.ErrNotFound
+	}
+
+	v.BrandID = c.BrandID
+	return nil
+}
+
+func (m *memoryVendors) Update(ctx context.Context, v *models.Vendor) error {
+	m.Lock()
+	defer m.Unlock()
+
+	old, ok := m.vendors[v.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	if stale(v.Version, old.Version) {
+		return domain.ErrConflict
+	}
+	v.Version = old.Version + 1
+
+	c := *v
+	c.Brand = nil
+	m.vendors[v.ID] = &c
+	return nil
+}
+
+func (m *memoryVendors) Delete(ctx context.Context, v *models.Vendor) error {
+	// checked before locking, since the trips lock the vendors the other way
+	if live, _ := m.trips.uses(v.ID); live {
+		return domain.ErrCannotDelete
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	c, ok := m.vendors[v.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	delete(m.vendors, v.ID)
+	m.trash[v.ID] = c
+	return nil
+}
+
+func (m *memoryVendors) Restore(ctx context.Context, v *models.Vendor) error {
+	m.Lock()
+	defer m.Unlock()
+
+	c, ok := m.trash[v.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	delete(m.trash, v.ID)
+	m.vendors[v.ID] = c
+	return nil
+}
+
+func (m *memoryVendors) Purge(ctx context.Context, v *models.Vendor) error {
+	if _, trashed := m.trips.uses(v.ID); trashed {
+		return domain.ErrCannotDelete
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	c, ok := m.trash[v.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	v.BrandID = c.BrandID
+	delete(m.trash, v.ID)
+	return nil
+}
+
+func (m *memoryVendors) FetchAll(ctx context.Context, activeOnly bool) (*models.Vendors, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	vendors := models.Vendors{}
+	for _, v := range m.vendors {
+		if v.Active || !activeOnly {
+			vendors = append(vendors, &models.Vendor{
+				ID:     v.ID,
+				Name:   v.Name,
+				Active: v.Active,
+			})
+		}
+	}
+
+	sort.Slice(vendors, func(i, j int) bool {
+		if vendors[i].Active != vendors[j].Active {
+			return vendors[i].Active
+		}
+		return vendors[i].Name.String < vendors[j].Name.String
+	})
+
+	return &vendors, nil
+}
+
+func (m *memoryVendors) get(id int) *models.Vendor {
+	m.Lock()
+	defer m.Unlock()
+
+	v, ok := m.vendors[id]
+	if !ok {
+		return nil
+	}
+
+	c := *v
+	return &c
+}
+
+// find returns the active vendors among ids, by name, with whether each is
+// the trip's primary venue.
+func (m *memoryVendors) find(ids map[int]bool) models.Vendors {
+	vendors := models.Vendors{}
+	for id, primary := range ids {
+		v := m.get(id)
+		if v != nil && v.Active {
+			v.Primary = primary
+			v.Brand = &models.File{}
+			vendors = append(vendors, v)
+		}
+	}
+
+	sort.Slice(vendors, func(i, j int) bool {
+		return vendors[i].Name.String < vendors[j].Name.String
+	})
+
+	return vendors
+}
+
+// stale is true when an update made to the copy read at version has been
+// overtaken by another, or doesn't say which version it read, as the
+// versioned updates in MySQL would find.
+func stale(version int, current int) bool {
+	return version == 0 || version != current
+}
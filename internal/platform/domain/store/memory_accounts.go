@@ -0,0 +1,938 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/hex"
+	"revelbus/internal/platform/domain"
+	"revelbus/internal/platform/domain/models"
+	"revelbus/pkg/sessions"
+	"revelbus/pkg/totp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// memoryGuard is what the users table keeps about logging in besides the
+// password: failed attempts and the second factor.
+type memoryGuard struct {
+	failed     int
+	lastFailed time.Time
+	secret     string
+	step       int64
+	codes      map[string]bool
+}
+
+// guard returns the user's guard, creating it. m must be locked.
+func (m *memoryUsers) guard(id int) *memoryGuard {
+	g, ok := m.guards[id]
+	if !ok {
+		g = &memoryGuard{codes: map[string]bool{}}
+		m.guards[id] = g
+	}
+	return g
+}
+
+func (m *memoryUsers) VerifyAndUpdatePassword(ctx context.Context, u *models.User, old string, pw string) error {
+	m.mu.Lock()
+	hp := m.passwords[u.ID]
+	m.mu.Unlock()
+
+	err := bcrypt.CompareHashAndPassword(hp, []byte(old))
+	if err == bcrypt.ErrMismatchedHashAndPassword || err == bcrypt.ErrHashTooShort {
+		return domain.ErrInvalidCredentials
+	} else if err != nil {
+		return err
+	}
+
+	return m.UpdatePassword(ctx, u, pw)
+}
+
+func (m *memoryUsers) SetVerified(ctx context.Context, u *models.User, verified bool) error {
+	m.mu.Lock()
+	c, ok := m.users[u.ID]
+	if ok {
+		if !verified {
+			c.VerifiedAt = mysql.NullTime{}
+		} else if !c.VerifiedAt.Valid {
+			c.VerifiedAt = mysql.NullTime{Time: time.Now().UTC(), Valid: true}
+		}
+	}
+	m.mu.Unlock()
+
+	if verified {
+		m.verifications.clear(u.ID)
+	}
+	return nil
+}
+
+// setEmail moves the user to a verified address, as following a
+// verification link does.
+func (m *memoryUsers) setEmail(id int, email string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if other := m.byEmail(email); other != nil && other.ID != id {
+		return domain.ErrDuplicateEmail
+	}
+
+	c, ok := m.users[id]
+	if ok {
+		c.Email = sql.NullString{String: email, Valid: true}
+		c.VerifiedAt = mysql.NullTime{Time: time.Now().UTC(), Valid: true}
+	}
+	return nil
+}
+
+func (m *memoryUsers) LoginWait(ctx context.Context, email string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c := m.byEmail(email)
+	if c == nil {
+		return 0, nil
+	}
+
+	now := time.Now()
+
+	if c.LockedUntil.Valid && c.LockedUntil.Time.After(now) {
+		return c.LockedUntil.Time.Sub(now), nil
+	}
+
+	g := m.guard(c.ID)
+
+	after := loginSetting("login.delay_after", 3)
+	if g.failed < after || g.lastFailed.IsZero() {
+		return 0, nil
+	}
+
+	delay := time.Minute
+	if n := uint(g.failed - after); n < 6 {
+		delay = time.Second << n
+	}
+
+	return g.lastFailed.Add(delay).Sub(now), nil
+}
+
+func (m *memoryUsers) RecordFailedLogin(ctx context.Context, email string) (*models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c := m.byEmail(email)
+	if c == nil {
+		return nil, nil
+	}
+
+	g := m.guard(c.ID)
+	g.failed++
+	g.lastFailed = time.Now()
+
+	if g.failed < loginSetting("login.max_failures", 10) {
+		return nil, nil
+	}
+
+	lockout := viper.GetDuration("login.lockout")
+	if lockout <= 0 {
+		lockout = 15 * time.Minute
+	}
+
+	g.failed = 0
+	c.LockedUntil = mysql.NullTime{Time: time.Now().UTC().Add(lockout), Valid: true}
+	return m.copy(c), nil
+}
+
+func (m *memoryUsers) ResetFailedLogins(ctx context.Context, u *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g := m.guard(u.ID)
+	g.failed = 0
+	g.lastFailed = time.Time{}
+	return nil
+}
+
+func (m *memoryUsers) Unlock(ctx context.Context, u *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g := m.guard(u.ID)
+	g.failed = 0
+	g.lastFailed = time.Time{}
+
+	if c, ok := m.users[u.ID]; ok {
+		c.LockedUntil = mysql.NullTime{}
+	}
+	return nil
+}
+
+func (m *memoryUsers) FetchLocked(ctx context.Context) (models.Users, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+
+	users := models.Users{}
+	for _, u := range m.users {
+		if u.LockedUntil.Valid && u.LockedUntil.Time.After(now) {
+			users = append(users, m.copy(u))
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].LockedUntil.Time.After(users[j].LockedUntil.Time)
+	})
+
+	return users, nil
+}
+
+func (m *memoryUsers) EnableTwoFactor(ctx context.Context, u *models.User, secret string, step int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if c, ok := m.users[u.ID]; ok {
+		g := m.guard(u.ID)
+		g.secret = secret
+		g.step = step
+		c.TOTPEnabled = true
+	}
+
+	u.TOTPEnabled = true
+	return nil
+}
+
+func (m *memoryUsers) DisableTwoFactor(ctx context.Context, u *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if c, ok := m.users[u.ID]; ok {
+		m.guards[u.ID] = &memoryGuard{codes: map[string]bool{}}
+		c.TOTPEnabled = false
+	}
+
+	u.TOTPEnabled = false
+	return nil
+}
+
+func (m *memoryUsers) VerifyTOTP(ctx context.Context, u *models.User, code string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.users[u.ID]
+	if !ok || !c.TOTPEnabled {
+		return false, domain.ErrNotFound
+	}
+
+	g := m.guard(u.ID)
+
+	step, ok := totp.Validate(g.secret, code, time.Now(), g.step)
+	if !ok || step <= g.step {
+		return false, nil
+	}
+
+	g.step = step
+	return true, nil
+}
+
+func (m *memoryUsers) NewRecoveryCodes(ctx context.Context, u *models.User) ([]string, error) {
+	codes := make([]string, 10)
+	for i := range codes {
+		b := make([]byte, 5)
+		_, err := rand.Read(b)
+		if err != nil {
+			return nil, err
+		}
+
+		c := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+		codes[i] = c[:4] + "-" + c[4:]
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g := m.guard(u.ID)
+	g.codes = map[string]bool{}
+	for _, c := range codes {
+		g.codes[recoveryCode(c)] = true
+	}
+
+	return codes, nil
+}
+
+func (m *memoryUsers) UseRecoveryCode(ctx context.Context, u *models.User, code string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g := m.guard(u.ID)
+
+	c := recoveryCode(code)
+	if !g.codes[c] {
+		return false, nil
+	}
+
+	delete(g.codes, c)
+	return true, nil
+}
+
+func (m *memoryUsers) RemainingRecoveryCodes(ctx context.Context, u *models.User) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.guard(u.ID).codes), nil
+}
+
+// recoveryCode normalizes a recovery code the way users might type it.
+func recoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.Replace(code, "-", "", -1)
+	return strings.Replace(code, " ", "", -1)
+}
+
+func loginSetting(key string, def int) int {
+	if n := viper.GetInt(key); n > 0 {
+		return n
+	}
+	return def
+}
+
+type memoryRoles struct {
+	sync.Mutex
+	roles  map[int]*models.Role
+	users  *memoryUsers
+	nextID int
+}
+
+// newMemoryRoles starts with the roles the schema seeds.
+func newMemoryRoles(users *memoryUsers) *memoryRoles {
+	m := &memoryRoles{
+		roles: map[int]*models.Role{},
+		users: users,
+	}
+
+	seed := []struct {
+		name  string
+		label string
+		perms []string
+	}{
+		{models.RoleAdmin, "Admin", nil},
+		{models.RoleUser, "User", nil},
+		{"editor", "Editor", []string{models.PermTrips, models.PermManifests, models.PermFAQs, models.PermContent}},
+		{"driver", "Driver", []string{models.PermManifests, models.PermCheckIn}},
+		{"vendor-manager", "Vendor Manager", []string{models.PermVendors}},
+	}
+
+	for _, r := range seed {
+		m.nextID++
+		m.roles[m.nextID] = m.copy(&models.Role{
+			ID:          m.nextID,
+			Version:     1,
+			Name:        sql.NullString{String: r.name, Valid: true},
+			Label:       sql.NullString{String: r.label, Valid: true},
+			Permissions: r.perms,
+		})
+	}
+	return m
+}
+
+func (m *memoryRoles) Create(ctx context.Context, r *models.Role) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.byName(r.Name.String) != nil {
+		return domain.ErrDuplicate
+	}
+
+	m.nextID++
+	r.ID = m.nextID
+	r.Version = 1
+
+	m.roles[r.ID] = m.copy(r)
+	return nil
+}
+
+func (m *memoryRoles) Fetch(ctx context.Context, r *models.Role) error {
+	m.Lock()
+	defer m.Unlock()
+
+	c := m.roles[r.ID]
+	if r.ID == 0 {
+		c = m.byName(r.Name.String)
+	}
+
+	if c == nil {
+		return domain.ErrNotFound
+	}
+
+	*r = *m.copy(c)
+	return nil
+}
+
+func (m *memoryRoles) Update(ctx context.Context, r *models.Role) error {
+	m.Lock()
+	defer m.Unlock()
+
+	c, ok := m.roles[r.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	if stale(r.Version, c.Version) {
+		return domain.ErrConflict
+	}
+
+	c.Label = r.Label
+	c.Permissions = m.copy(&models.Role{Name: c.Name, Permissions: r.Permissions}).Permissions
+	c.Version++
+	r.Version = c.Version
+	return nil
+}
+
+func (m *memoryRoles) Delete(ctx context.Context, r *models.Role) error {
+	err := m.Fetch(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	if r.IsBuiltin() || m.users.withRole(r.Name.String) > 0 {
+		return domain.ErrCannotDelete
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	delete(m.roles, r.ID)
+	return nil
+}
+
+func (m *memoryRoles) FetchAll(ctx context.Context) (*models.Roles, error) {
+	m.Lock()
+	roles := models.Roles{}
+	for _, r := range m.roles {
+		roles = append(roles, &models.Role{
+			ID:    r.ID,
+			Name:  r.Name,
+			Label: r.Label,
+		})
+	}
+	m.Unlock()
+
+	for _, r := range roles {
+		r.Users = m.users.withRole(r.Name.String)
+	}
+
+	sort.Slice(roles, func(i, j int) bool {
+		return roles[i].Label.String < roles[j].Label.String
+	})
+
+	return &roles, nil
+}
+
+// permissions is the set of permissions the named role grants.
+func (m *memoryRoles) permissions(name string) map[string]bool {
+	m.Lock()
+	defer m.Unlock()
+
+	perms := map[string]bool{}
+	if r := m.byName(name); r != nil {
+		for _, p := range r.Permissions {
+			perms[p] = true
+		}
+	}
+	return perms
+}
+
+func (m *memoryRoles) byName(name string) *models.Role {
+	for _, r := range m.roles {
+		if r.Name.String == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// copy keeps only the permissions that exist, in order, and none for the
+// admin role, like savePermissions.
+func (m *memoryRoles) copy(r *models.Role) *models.Role {
+	c := *r
+	c.Permissions = []string{}
+	if r.Name.String == models.RoleAdmin {
+		return &c
+	}
+
+	for _, p := range models.Permissions {
+		if r.Has(p.Name) {
+			c.Permissions = append(c.Permissions, p.Name)
+		}
+	}
+	return &c
+}
+
+func (m *memoryUsers) withRole(name string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := 0
+	for _, u := range m.users {
+		if u.Role.String == name {
+			n++
+		}
+	}
+	return n
+}
+
+type memorySessions struct {
+	sync.Mutex
+	sessions map[int]*models.Session
+	users    *memoryUsers
+	roles    *memoryRoles
+	partners *memoryPartners
+	nextID   int
+}
+
+func (m *memorySessions) Create(ctx context.Context, s *models.Session) error {
+	token, err := domain.NewToken()
+	if err != nil {
+		return err
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	m.nextID++
+	s.ID = m.nextID
+	s.Token = token
+	s.Created = time.Now().UTC()
+	s.LastSeen = s.Created
+
+	c := *s
+	m.sessions[s.ID] = &c
+	return nil
+}
+
+func (m *memorySessions) Revoke(ctx context.Context, s *models.Session) error {
+	m.Lock()
+	defer m.Unlock()
+
+	c, ok := m.sessions[s.ID]
+	if !ok || (s.UserID != 0 && c.UserID != s.UserID) {
+		return domain.ErrNotFound
+	}
+
+	delete(m.sessions, s.ID)
+	return nil
+}
+
+func (m *memorySessions) RevokeAll(ctx context.Context, userID int) error {
+	m.Lock()
+	defer m.Unlock()
+
+	for id, s := range m.sessions {
+		if s.UserID == userID {
+			delete(m.sessions, id)
+		}
+	}
+	return nil
+}
+
+func (m *memorySessions) Delete(ctx context.Context, token string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	for id, s := range m.sessions {
+		if s.Token == token {
+			delete(m.sessions, id)
+		}
+	}
+	return nil
+}
+
+func (m *memorySessions) FindUser(ctx context.Context, token string, ip string) (*models.User, error) {
+	m.Lock()
+	var userID int
+	for _, s := range m.sessions {
+		if s.Token == token && s.Created.After(m.cutoff()) {
+			userID = s.UserID
+			s.LastSeen = time.Now().UTC()
+			s.IP = sql.NullString{String: ip, Valid: true}
+		}
+	}
+	m.Unlock()
+
+	u := m.user(userID)
+	if u == nil {
+		return nil, domain.ErrNotFound
+	}
+	return u, nil
+}
+
+func (m *memorySessions) FetchAll(ctx context.Context, userID int) (*models.Sessions, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	list := models.Sessions{}
+	for _, s := range m.sessions {
+		if s.UserID == userID && s.Created.After(m.cutoff()) {
+			c := *s
+			list = append(list, &c)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].LastSeen.After(list[j].LastSeen)
+	})
+
+	return &list, nil
+}
+
+// user loads the user with everything FindSessionUser fills in, or nil.
+func (m *memorySessions) user(id int) *models.User {
+	u := m.users.get(id)
+	if u == nil {
+		return nil
+	}
+
+	u.Permissions = m.roles.permissions(u.Role.String)
+	u.Partner = m.partners.isPartner(u.ID)
+	return u
+}
+
+func (m *memorySessions) cutoff() time.Time {
+	return time.Now().UTC().Add(-sessions.Lifetime())
+}
+
+type memoryPasswordResets struct {
+	sync.Mutex
+	resets map[int]*models.PasswordReset
+	users  *memoryUsers
+	nextID int
+}
+
+func (m *memoryPasswordResets) Create(ctx context.Context, userID int) (*models.PasswordReset, string, error) {
+	token, err := domain.NewToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	ttl := viper.GetDuration("recovery.ttl")
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	m.nextID++
+	p := &models.PasswordReset{
+		ID:      m.nextID,
+		UserID:  userID,
+		Hash:    hashToken(token),
+		Expires: time.Now().UTC().Add(ttl),
+	}
+
+	c := *p
+	m.resets[p.ID] = &c
+	return p, token, nil
+}
+
+func (m *memoryPasswordResets) Find(ctx context.Context, id int, token string) (*models.PasswordReset, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	p, ok := m.resets[id]
+	if !ok || p.Hash != hashToken(token) || !usable(p) {
+		return nil, domain.ErrInvalidCredentials
+	}
+
+	c := *p
+	return &c, nil
+}
+
+func (m *memoryPasswordResets) Redeem(ctx context.Context, p *models.PasswordReset, pw string) error {
+	m.Lock()
+	c, ok := m.resets[p.ID]
+	if !ok || !usable(c) {
+		m.Unlock()
+		return domain.ErrInvalidCredentials
+	}
+	c.Used = mysql.NullTime{Time: time.Now().UTC(), Valid: true}
+	m.Unlock()
+
+	return m.users.UpdatePassword(ctx, &models.User{ID: p.UserID}, pw)
+}
+
+// invalidate throws away the user's unused resets.
+func (m *memoryPasswordResets) invalidate(userID int) {
+	m.Lock()
+	defer m.Unlock()
+
+	for id, p := range m.resets {
+		if p.UserID == userID && !p.Used.Valid {
+			delete(m.resets, id)
+		}
+	}
+}
+
+func usable(p *models.PasswordReset) bool {
+	return !p.Used.Valid && time.Now().Before(p.Expires)
+}
+
+type memoryVerifications struct {
+	sync.Mutex
+	verifications map[int]*models.EmailVerification
+	users         *memoryUsers
+	nextID        int
+}
+
+func (m *memoryVerifications) Create(ctx context.Context, u *models.User, email string) (*models.EmailVerification, string, error) {
+	token, err := domain.NewToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	ttl := viper.GetDuration("verification.ttl")
+	if ttl <= 0 {
+		ttl = 48 * time.Hour
+	}
+
+	m.clear(u.ID)
+
+	m.Lock()
+	defer m.Unlock()
+
+	m.nextID++
+	v := &models.EmailVerification{
+		ID:      m.nextID,
+		UserID:  u.ID,
+		Email:   email,
+		Hash:    hashToken(token),
+		Expires: time.Now().UTC().Add(ttl),
+	}
+
+	c := *v
+	m.verifications[v.ID] = &c
+	return v, token, nil
+}
+
+func (m *memoryVerifications) Find(ctx context.Context, id int, token string) (*models.EmailVerification, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	v, ok := m.verifications[id]
+	if !ok || v.Hash != hashToken(token) || time.Now().After(v.Expires) {
+		return nil, domain.ErrInvalidCredentials
+	}
+
+	c := *v
+	return &c, nil
+}
+
+func (m *memoryVerifications) Redeem(ctx context.Context, v *models.EmailVerification) error {
+	m.Lock()
+	c, ok := m.verifications[v.ID]
+	if !ok || time.Now().After(c.Expires) {
+		m.Unlock()
+		return domain.ErrInvalidCredentials
+	}
+	m.Unlock()
+
+	err := m.users.setEmail(c.UserID, c.Email)
+	if err != nil {
+		return err
+	}
+
+	m.clear(c.UserID)
+	return nil
+}
+
+// clear throws away any link sent to the user.
+func (m *memoryVerifications) clear(userID int) {
+	m.Lock()
+	defer m.Unlock()
+
+	for id, v := range m.verifications {
+		if v.UserID == userID {
+			delete(m.verifications, id)
+		}
+	}
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+type memoryIdentities struct {
+	sync.Mutex
+	identities map[int]*models.Identity
+	users      *memoryUsers
+	nextID     int
+}
+
+func (m *memoryIdentities) Create(ctx context.Context, i *models.Identity) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.find(i.Issuer, i.Subject) != nil {
+		return domain.ErrDuplicate
+	}
+
+	m.nextID++
+	i.ID = m.nextID
+
+	c := *i
+	m.identities[i.ID] = &c
+	return nil
+}
+
+func (m *memoryIdentities) FindUser(ctx context.Context, issuer string, subject string) (*models.User, error) {
+	m.Lock()
+	i := m.find(issuer, subject)
+	m.Unlock()
+
+	if i == nil {
+		return nil, domain.ErrNotFound
+	}
+
+	u := &models.User{ID: i.UserID}
+	err := m.users.Fetch(ctx, u)
+	return u, err
+}
+
+func (m *memoryIdentities) find(issuer string, subject string) *models.Identity {
+	for _, i := range m.identities {
+		if i.Issuer == issuer && i.Subject == subject {
+			return i
+		}
+	}
+	return nil
+}
+
+type memoryImpersonations struct {
+	sync.Mutex
+	impersonations map[int]*models.Impersonation
+	sessions       *memorySessions
+	nextID         int
+}
+
+func (m *memoryImpersonations) Start(ctx context.Context, i *models.Impersonation) error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.nextID++
+	i.ID = m.nextID
+	i.Started = time.Now().UTC()
+
+	c := *i
+	c.Admin = nil
+	m.impersonations[i.ID] = &c
+	return nil
+}
+
+func (m *memoryImpersonations) End(ctx context.Context, id int) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if i, ok := m.impersonations[id]; ok && !i.Ended.Valid {
+		i.Ended = mysql.NullTime{Time: time.Now().UTC(), Valid: true}
+	}
+	return nil
+}
+
+func (m *memoryImpersonations) FetchAll(ctx context.Context, userID int, limit int) (*models.Impersonations, error) {
+	m.Lock()
+	list := models.Impersonations{}
+	for _, i := range m.impersonations {
+		if i.UserID == userID {
+			c := *i
+			list = append(list, &c)
+		}
+	}
+	m.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID > list[j].ID
+	})
+
+	if len(list) > limit {
+		list = list[:limit]
+	}
+
+	for _, i := range list {
+		i.Admin = &models.User{ID: i.AdminID}
+		if a := m.sessions.users.get(i.AdminID); a != nil {
+			i.Admin.Name = a.Name
+		}
+	}
+
+	return &list, nil
+}
+
+func (m *memoryImpersonations) FindUser(ctx context.Context, id int) (*models.User, error) {
+	u := m.sessions.user(id)
+	if u == nil {
+		return nil, domain.ErrNotFound
+	}
+	return u, nil
+}
+
+type memorySecurityEvents struct {
+	sync.Mutex
+	events []*models.SecurityEvent
+}
+
+func (m *memorySecurityEvents) Create(ctx context.Context, e *models.SecurityEvent) error {
+	m.Lock()
+	defer m.Unlock()
+
+	e.ID = len(m.events) + 1
+	e.Created = time.Now().UTC()
+
+	c := *e
+	m.events = append(m.events, &c)
+	return nil
+}
+
+func (m *memorySecurityEvents) KnownDevice(ctx context.Context, userID int, ua string) (bool, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	if len(ua) > 512 {
+		ua = ua[:512]
+	}
+
+	logins, matches := 0, 0
+	for _, e := range m.events {
+		if e.UserID != userID || (e.Kind != models.SecurityLogin && e.Kind != models.SecurityNewDevice) {
+			continue
+		}
+
+		logins++
+		if e.UserAgent.String == ua {
+			matches++
+		}
+	}
+
+	return logins == 0 || matches > 0, nil
+}
+
+func (m *memorySecurityEvents) FetchAll(ctx context.Context, userID int, limit int) (*models.SecurityEvents, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	events := models.SecurityEvents{}
+	for i := len(m.events) - 1; i >= 0 && len(events) < limit; i-- {
+		if m.events[i].UserID == userID {
+			c := *m.events[i]
+			events = append(events, &c)
+		}
+	}
+
+	return &events, nil
+}
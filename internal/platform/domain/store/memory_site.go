@@ -0,0 +1,1027 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"revelbus/internal/platform/domain"
+	"revelbus/internal/platform/domain/models"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// memoryAuditLog is always empty, since the memory stores aren't audited.
+type memoryAuditLog struct{}
+
+func (memoryAuditLog) Fetch(ctx context.Context, f *models.AuditFilter, limit int, offset int) (*models.AuditEntries, error) {
+	return &models.AuditEntries{}, nil
+}
+
+type memoryBookings struct {
+	sync.Mutex
+	bookings map[int]*models.Booking
+	users    *memoryUsers
+	trips    *memoryTrips
+	nextID   int
+}
+
+func (m *memoryBookings) Create(ctx context.Context, b *models.Booking) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if b.Seats < 1 {
+		b.Seats = 1
+	}
+
+	// booking again after cancelling puts the same booking back
+	for _, c := range m.bookings {
+		if c.TripID != b.TripID || c.UserID != b.UserID {
+			continue
+		}
+		if c.Status.String != models.BookingCancelled {
+			return domain.ErrDuplicate
+		}
+
+		c.Seats = b.Seats
+		c.Status = sql.NullString{String: models.BookingBooked, Valid: true}
+		return nil
+	}
+
+	m.nextID++
+	b.ID = m.nextID
+	b.Status = sql.NullString{String: models.BookingBooked, Valid: true}
+	b.Created = time.Now().UTC()
+
+	c := *b
+	c.Trip = nil
+	c.User = nil
+	m.bookings[b.ID] = &c
+	return nil
+}
+
+func (m *memoryBookings) Cancel(ctx context.Context, b *models.Booking) error {
+	m.Lock()
+	defer m.Unlock()
+
+	c, ok := m.bookings[b.ID]
+	if !ok || c.UserID != b.UserID {
+		return domain.ErrNotFound
+	}
+
+	c.Status = sql.NullString{String: models.BookingCancelled, Valid: true}
+	return nil
+}
+
+func (m *memoryBookings) CheckIn(ctx context.Context, b *models.Booking, in bool) error {
+	m.Lock()
+	defer m.Unlock()
+
+	c, ok := m.bookings[b.ID]
+	if !ok || c.TripID != b.TripID || c.Status.String != models.BookingBooked {
+		return domain.ErrNotFound
+	}
+
+	if !in {
+		c.CheckedIn = mysql.NullTime{}
+	} else if !c.CheckedIn.Valid {
+		c.CheckedIn = mysql.NullTime{Time: time.Now().UTC(), Valid: true}
+	}
+	return nil
+}
+
+func (m *memoryBookings) IsBooked(ctx context.Context, tripID int, userID int) (bool, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, b := range m.bookings {
+		if b.TripID == tripID && b.UserID == userID && b.Status.String == models.BookingBooked {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *memoryBookings) FindByUser(ctx context.Context, userID int) (*models.Bookings, error) {
+	bookings := models.Bookings{}
+	for _, b := range m.booked(func(b *models.Booking) bool { return b.UserID == userID }) {
+		t := &models.Trip{ID: b.TripID}
+		if m.trips.Fetch(ctx, t) != nil || !t.End.After(time.Now()) {
+			continue
+		}
+
+		b.Trip = t
+		bookings = append(bookings, b)
+	}
+
+	sort.Slice(bookings, func(i, j int) bool {
+		return bookings[i].Trip.Start.Before(bookings[j].Trip.Start)
+	})
+
+	return &bookings, nil
+}
+
+func (m *memoryBookings) FetchRiders(ctx context.Context, tripID int) (*models.Bookings, error) {
+	bookings := models.Bookings{}
+	for _, b := range m.booked(func(b *models.Booking) bool { return b.TripID == tripID }) {
+		u := m.users.get(b.UserID)
+		if u == nil {
+			continue
+		}
+
+		b.User = u
+		bookings = append(bookings, b)
+	}
+
+	sort.Slice(bookings, func(i, j int) bool {
+		return bookings[i].User.Name.String < bookings[j].User.Name.String
+	})
+
+	return &bookings, nil
+}
+
+// booked returns copies of the bookings that aren't cancelled and match.
+func (m *memoryBookings) booked(match func(*models.Booking) bool) models.Bookings {
+	m.Lock()
+	defer m.Unlock()
+
+	bookings := models.Bookings{}
+	for _, b := range m.bookings {
+		if b.Status.String == models.BookingBooked && match(b) {
+			c := *b
+			bookings = append(bookings, &c)
+		}
+	}
+	return bookings
+}
+
+// seats counts the seats booked on the trip.
+func (m *memoryBookings) seats(tripID int) int {
+	n := 0
+	for _, b := range m.booked(func(b *models.Booking) bool { return b.TripID == tripID }) {
+		n += b.Seats
+	}
+	return n
+}
+
+type memoryFiles struct {
+	sync.Mutex
+	files     map[int]*models.File
+	vendors   *memoryVendors
+	trips     *memoryTrips
+	galleries *memoryGalleries
+	changes   *memoryVendorChanges
+	nextID    int
+}
+
+func (m *memoryFiles) Create(ctx context.Context, f *models.File) error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.nextID++
+	f.ID = m.nextID
+	f.Created = time.Now().UTC()
+
+	c := *f
+	m.files[f.ID] = &c
+	return nil
+}
+
+func (m *memoryFiles) Fetch(ctx context.Context, f *models.File) error {
+	m.Lock()
+	defer m.Unlock()
+
+	c, ok := m.files[f.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	*f = *c
+	return nil
+}
+
+func (m *memoryFiles) Delete(ctx context.Context, f *models.File) error {
+	m.Lock()
+	defer m.Unlock()
+
+	delete(m.files, f.ID)
+	return nil
+}
+
+func (m *memoryFiles) FetchAll(ctx context.Context) (*models.Files, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	files := models.Files{}
+	for _, f := range m.files {
+		c := *f
+		files = append(files, &c)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ID > files[j].ID
+	})
+
+	return &files, nil
+}
+
+func (m *memoryFiles) InUse(ctx context.Context, f *models.File) (bool, error) {
+	id := sql.NullInt64{Int64: int64(f.ID), Valid: true}
+
+	m.vendors.Lock()
+	for _, all := range []map[int]*models.Vendor{m.vendors.vendors, m.vendors.trash} {
+		for _, v := range all {
+			if v.BrandID == id {
+				m.vendors.Unlock()
+				return true, nil
+			}
+		}
+	}
+	m.vendors.Unlock()
+
+	m.trips.Lock()
+	for _, all := range []map[int]*models.Trip{m.trips.trips, m.trips.trash} {
+		for _, t := range all {
+			if t.ImageID == id {
+				m.trips.Unlock()
+				return true, nil
+			}
+		}
+	}
+	m.trips.Unlock()
+
+	m.galleries.Lock()
+	for _, images := range m.galleries.images {
+		for _, fid := range images {
+			if fid == f.ID {
+				m.galleries.Unlock()
+				return true, nil
+			}
+		}
+	}
+	m.galleries.Unlock()
+
+	m.changes.Lock()
+	defer m.changes.Unlock()
+
+	for _, c := range m.changes.changes {
+		if c.BrandID == id && c.Status.String == models.ChangePending {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type memoryMessages struct {
+	sync.Mutex
+	messages map[int]*models.Message
+	nextID   int
+}
+
+func (m *memoryMessages) Create(ctx context.Context, msg *models.Message) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if msg.Status.String == "" {
+		msg.Status = sql.NullString{String: models.MessageUnread, Valid: true}
+	}
+
+	m.nextID++
+	msg.ID = m.nextID
+	msg.Created = time.Now().UTC()
+
+	c := *msg
+	m.messages[msg.ID] = &c
+	return nil
+}
+
+func (m *memoryMessages) Fetch(ctx context.Context, msg *models.Message) error {
+	m.Lock()
+	defer m.Unlock()
+
+	c, ok := m.messages[msg.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	*msg = *c
+	return nil
+}
+
+func (m *memoryMessages) SetStatus(ctx context.Context, msg *models.Message, status string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	c, ok := m.messages[msg.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	c.Status = sql.NullString{String: status, Valid: true}
+	msg.Status = c.Status
+	return nil
+}
+
+func (m *memoryMessages) MarkReplied(ctx context.Context, msg *models.Message) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if c, ok := m.messages[msg.ID]; ok {
+		c.Replied = mysql.NullTime{Time: time.Now().UTC(), Valid: true}
+	}
+	return nil
+}
+
+func (m *memoryMessages) Delete(ctx context.Context, msg *models.Message) error {
+	m.Lock()
+	defer m.Unlock()
+
+	delete(m.messages, msg.ID)
+	return nil
+}
+
+func (m *memoryMessages) FetchAll(ctx context.Context, folder string) (*models.Messages, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	messages := models.Messages{}
+	for _, msg := range m.messages {
+		status := msg.Status.String
+
+		switch folder {
+		case models.MessageArchived, models.MessageSpam:
+			if status != folder {
+				continue
+			}
+		default:
+			if status != models.MessageUnread && status != models.MessageRead {
+				continue
+			}
+		}
+
+		c := *msg
+		messages = append(messages, &c)
+	}
+
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].ID > messages[j].ID
+	})
+
+	return &messages, nil
+}
+
+// memoryOutbox keeps what's queued without ever sending it.
+type memoryOutbox struct {
+	sync.Mutex
+	emails map[int]*models.OutboxEmail
+	nextID int
+}
+
+func (m *memoryOutbox) Create(ctx context.Context, e *models.OutboxEmail) error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.nextID++
+	e.ID = m.nextID
+	e.Status = sql.NullString{String: models.OutboxPending, Valid: true}
+	e.Created = time.Now().UTC()
+	e.NextAttempt = e.Created
+
+	c := *e
+	m.emails[e.ID] = &c
+	return nil
+}
+
+func (m *memoryOutbox) Resend(ctx context.Context, e *models.OutboxEmail) error {
+	m.Lock()
+	defer m.Unlock()
+
+	c, ok := m.emails[e.ID]
+	if !ok || c.Status.String != models.OutboxFailed {
+		return domain.ErrNotFound
+	}
+
+	c.Status = sql.NullString{String: models.OutboxPending, Valid: true}
+	c.Attempts = 0
+	c.NextAttempt = time.Now().UTC()
+	return nil
+}
+
+func (m *memoryOutbox) FetchFailed(ctx context.Context) (*models.OutboxEmails, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	emails := models.OutboxEmails{}
+	for _, e := range m.emails {
+		if e.Status.String == models.OutboxFailed {
+			c := *e
+			emails = append(emails, &c)
+		}
+	}
+
+	sort.Slice(emails, func(i, j int) bool {
+		return emails[i].ID > emails[j].ID
+	})
+
+	return &emails, nil
+}
+
+type memoryPartners struct {
+	sync.Mutex
+	links    map[int]map[int]bool
+	users    *memoryUsers
+	vendors  *memoryVendors
+	trips    *memoryTrips
+	bookings *memoryBookings
+}
+
+func (m *memoryPartners) Link(ctx context.Context, vendorID int, userID int) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.links[vendorID][userID] {
+		return domain.ErrDuplicate
+	}
+
+	if m.links[vendorID] == nil {
+		m.links[vendorID] = map[int]bool{}
+	}
+	m.links[vendorID][userID] = true
+	return nil
+}
+
+func (m *memoryPartners) Unlink(ctx context.Context, vendorID int, userID int) error {
+	m.Lock()
+	defer m.Unlock()
+
+	delete(m.links[vendorID], userID)
+	return nil
+}
+
+func (m *memoryPartners) IsVendorUser(ctx context.Context, vendorID int, userID int) (bool, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	return m.links[vendorID][userID], nil
+}
+
+func (m *memoryPartners) FetchVendorUsers(ctx context.Context, vendorID int) (models.Users, error) {
+	m.Lock()
+	ids := []int{}
+	for id := range m.links[vendorID] {
+		ids = append(ids, id)
+	}
+	m.Unlock()
+
+	users := models.Users{}
+	for _, id := range ids {
+		if u := m.users.get(id); u != nil {
+			users = append(users, u)
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Name.String < users[j].Name.String
+	})
+
+	return users, nil
+}
+
+func (m *memoryPartners) FetchUserVendors(ctx context.Context, userID int) (*models.Vendors, error) {
+	m.Lock()
+	ids := map[int]bool{}
+	for vid, users := range m.links {
+		if users[userID] {
+			ids[vid] = false
+		}
+	}
+	m.Unlock()
+
+	vendors := models.Vendors{}
+	for id := range ids {
+		if v := m.vendors.get(id); v != nil {
+			vendors = append(vendors, v)
+		}
+	}
+
+	sort.Slice(vendors, func(i, j int) bool {
+		return vendors[i].Name.String < vendors[j].Name.String
+	})
+
+	return &vendors, nil
+}
+
+func (m *memoryPartners) FetchVendorTrips(ctx context.Context, vendorID int) (*models.PartnerTrips, error) {
+	m.trips.Lock()
+	found := models.PartnerTrips{}
+	for _, t := range m.trips.trips {
+		for _, role := range []string{"partner", "venue"} {
+			if _, ok := m.trips.links(t.ID, role)[vendorID]; ok {
+				found = append(found, &models.PartnerTrip{
+					ID:     t.ID,
+					Title:  t.Title,
+					Slug:   t.Slug,
+					Status: t.Status,
+					Start:  t.Start,
+					End:    t.End,
+					Role:   role,
+				})
+			}
+		}
+	}
+	m.trips.Unlock()
+
+	for _, t := range found {
+		t.Booked = m.bookings.seats(t.ID)
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].Start.After(found[j].Start)
+	})
+
+	return &found, nil
+}
+
+// isPartner reports whether the user looks after any vendor.
+func (m *memoryPartners) isPartner(userID int) bool {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, users := range m.links {
+		if users[userID] {
+			return true
+		}
+	}
+	return false
+}
+
+// memoryRevisions is always empty, since the memory stores aren't audited.
+type memoryRevisions struct{}
+
+func (memoryRevisions) Fetch(ctx context.Context, r *models.Revision) error {
+	return domain.ErrNotFound
+}
+func (memoryRevisions) Previous(ctx context.Context, r *models.Revision) (*models.Revision, error) {
+	return nil, nil
+}
+func (memoryRevisions) FetchAll(ctx context.Context, entityType string, id int) (*models.Revisions, error) {
+	return &models.Revisions{}, nil
+}
+
+// memorySettings holds the one row of site settings, once created.
+type memorySettings struct {
+	sync.Mutex
+	settings *models.Settings
+}
+
+func (m *memorySettings) Create(ctx context.Context, s *models.Settings) error {
+	m.Lock()
+	defer m.Unlock()
+
+	s.ID = 1
+	s.Version = 1
+
+	c := *s
+	m.settings = &c
+	return nil
+}
+
+func (m *memorySettings) Fetch(ctx context.Context, s *models.Settings) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.settings == nil || (s.ID != 0 && s.ID != m.settings.ID) {
+		return domain.ErrNotFound
+	}
+
+	*s = *m.settings
+	return nil
+}
+
+func (m *memorySettings) Update(ctx context.Context, s *models.Settings) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.settings == nil || s.ID != m.settings.ID {
+		return domain.ErrNotFound
+	}
+
+	if stale(s.Version, m.settings.Version) {
+		return domain.ErrConflict
+	}
+	s.Version = m.settings.Version + 1
+
+	c := *s
+	m.settings = &c
+	return nil
+}
+
+func (m *memorySettings) RequireAdmin2FA(ctx context.Context) (bool, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	return m.settings != nil && m.settings.RequireAdmin2FA, nil
+}
+
+type memorySubscribers struct {
+	sync.Mutex
+	subscribers map[int]*models.Subscriber
+	nextID      int
+}
+
+func (m *memorySubscribers) Create(ctx context.Context, s *models.Subscriber) error {
+	ct, err := domain.NewToken()
+	if err != nil {
+		return err
+	}
+
+	ut, err := domain.NewToken()
+	if err != nil {
+		return err
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	if m.byEmail(s.Email.String) != nil {
+		return domain.ErrDuplicateEmail
+	}
+
+	if s.Status.String == "" {
+		s.Status = sql.NullString{String: models.SubscriberPending, Valid: true}
+	}
+	if s.Status.String == models.SubscriberPending {
+		s.ConfirmToken = sql.NullString{String: ct, Valid: true}
+	}
+	s.UnsubscribeToken = sql.NullString{String: ut, Valid: true}
+
+	m.nextID++
+	s.ID = m.nextID
+	s.Created = time.Now().UTC()
+
+	m.subscribers[s.ID] = m.copy(s)
+	return nil
+}
+
+func (m *memorySubscribers) Fetch(ctx context.Context, s *models.Subscriber) error {
+	m.Lock()
+	defer m.Unlock()
+
+	c := m.subscribers[s.ID]
+	if s.ID == 0 {
+		c = m.byEmail(s.Email.String)
+	}
+
+	if c == nil {
+		return domain.ErrNotFound
+	}
+
+	*s = *m.copy(c)
+	return nil
+}
+
+func (m *memorySubscribers) Resubscribe(ctx context.Context, s *models.Subscriber) error {
+	token, err := domain.NewToken()
+	if err != nil {
+		return err
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	s.ConfirmToken = sql.NullString{String: token, Valid: true}
+	s.Status = sql.NullString{String: models.SubscriberPending, Valid: true}
+
+	if c, ok := m.subscribers[s.ID]; ok {
+		c.ConfirmToken = s.ConfirmToken
+		c.Status = s.Status
+	}
+	return nil
+}
+
+func (m *memorySubscribers) Unsubscribe(ctx context.Context, s *models.Subscriber) error {
+	m.Lock()
+	defer m.Unlock()
+
+	s.Status = sql.NullString{String: models.SubscriberUnsubscribed, Valid: true}
+
+	if c, ok := m.subscribers[s.ID]; ok {
+		c.ConfirmToken = sql.NullString{}
+		c.Status = s.Status
+	}
+	return nil
+}
+
+func (m *memorySubscribers) SetInterests(ctx context.Context, s *models.Subscriber, categories []string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	interests := []string{}
+	seen := map[string]bool{}
+	for _, c := range categories {
+		if c != "" && !seen[c] {
+			interests = append(interests, c)
+			seen[c] = true
+		}
+	}
+	sort.Strings(interests)
+
+	if c, ok := m.subscribers[s.ID]; ok {
+		c.Interests = interests
+	}
+
+	s.Interests = categories
+	return nil
+}
+
+func (m *memorySubscribers) Delete(ctx context.Context, s *models.Subscriber) error {
+	m.Lock()
+	defer m.Unlock()
+
+	delete(m.subscribers, s.ID)
+	return nil
+}
+
+func (m *memorySubscribers) Confirm(ctx context.Context, token string) (*models.Subscriber, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, s := range m.subscribers {
+		if token != "" && s.ConfirmToken.String == token && s.Status.String == models.SubscriberPending {
+			s.Status = sql.NullString{String: models.SubscriberActive, Valid: true}
+			s.ConfirmToken = sql.NullString{}
+			return m.copy(s), nil
+		}
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (m *memorySubscribers) FindByToken(ctx context.Context, token string) (*models.Subscriber, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, s := range m.subscribers {
+		if token != "" && s.UnsubscribeToken.String == token {
+			return m.copy(s), nil
+		}
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (m *memorySubscribers) FetchAll(ctx context.Context) (*models.Subscribers, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	subscribers := models.Subscribers{}
+	for _, s := range m.subscribers {
+		subscribers = append(subscribers, m.copy(s))
+	}
+
+	sort.Slice(subscribers, func(i, j int) bool {
+		return subscribers[i].ID > subscribers[j].ID
+	})
+
+	return &subscribers, nil
+}
+
+func (m *memorySubscribers) FindActive(ctx context.Context, category string) (models.Subscribers, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	subscribers := models.Subscribers{}
+	for _, s := range m.subscribers {
+		if s.Status.String != models.SubscriberActive {
+			continue
+		}
+
+		interested := category == "" || len(s.Interests) == 0
+		for _, c := range s.Interests {
+			interested = interested || c == category
+		}
+
+		if interested {
+			subscribers = append(subscribers, m.copy(s))
+		}
+	}
+
+	sort.Slice(subscribers, func(i, j int) bool {
+		return subscribers[i].ID < subscribers[j].ID
+	})
+
+	return subscribers, nil
+}
+
+func (m *memorySubscribers) byEmail(email string) *models.Subscriber {
+	for _, s := range m.subscribers {
+		if s.Email.String == email {
+			return s
+		}
+	}
+	return nil
+}
+
+func (m *memorySubscribers) copy(s *models.Subscriber) *models.Subscriber {
+	c := *s
+	c.Interests = append([]string{}, s.Interests...)
+	return &c
+}
+
+// memoryTrash lists what the other memory stores have in their trash. They
+// don't note when things were deleted, so nothing ever expires.
+type memoryTrash struct {
+	faqs      *memoryFAQs
+	galleries *memoryGalleries
+	trips     *memoryTrips
+	users     *memoryUsers
+	vendors   *memoryVendors
+}
+
+func (m *memoryTrash) Fetch(ctx context.Context, t string) (*models.TrashedItems, error) {
+	items := models.TrashedItems{}
+	add := func(id int, name string) {
+		items = append(items, &models.Trashed{ID: id, Type: t, Name: name})
+	}
+
+	switch t {
+	case models.AuditFAQ:
+		m.faqs.Lock()
+		for _, f := range m.faqs.trash {
+			add(f.ID, f.Question.String)
+		}
+		m.faqs.Unlock()
+	case models.AuditGallery:
+		m.galleries.Lock()
+		for _, g := range m.galleries.trash {
+			add(g.ID, g.Name.String)
+		}
+		m.galleries.Unlock()
+	case models.AuditTrip:
+		m.trips.Lock()
+		for _, tr := range m.trips.trash {
+			add(tr.ID, tr.Title.String)
+		}
+		m.trips.Unlock()
+	case models.AuditUser:
+		m.users.mu.Lock()
+		for _, u := range m.users.trash {
+			name := u.Name.String
+			if !u.Name.Valid {
+				name = u.Email.String
+			}
+			add(u.ID, name)
+		}
+		m.users.mu.Unlock()
+	case models.AuditVendor:
+		m.vendors.Lock()
+		for _, v := range m.vendors.trash {
+			add(v.ID, v.Name.String)
+		}
+		m.vendors.Unlock()
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ID > items[j].ID
+	})
+
+	return &items, nil
+}
+
+func (m *memoryTrash) FetchExpired(ctx context.Context, cutoff time.Time) (*models.TrashedItems, error) {
+	return &models.TrashedItems{}, nil
+}
+
+type memoryVendorChanges struct {
+	sync.Mutex
+	changes map[int]*models.VendorChange
+	vendors *memoryVendors
+	nextID  int
+}
+
+func (m *memoryVendorChanges) Submit(ctx context.Context, c *models.VendorChange) error {
+	m.Lock()
+	defer m.Unlock()
+
+	c.Status = sql.NullString{String: models.ChangePending, Valid: true}
+	c.Created = time.Now().UTC()
+
+	if p := m.pending(c.VendorID); p != nil {
+		p.Status = sql.NullString{String: models.ChangeSuperseded, Valid: true}
+		p.Reviewed = mysql.NullTime{Time: time.Now().UTC(), Valid: true}
+	}
+
+	m.nextID++
+	c.ID = m.nextID
+
+	m.changes[c.ID] = m.copy(c)
+	return nil
+}
+
+func (m *memoryVendorChanges) Fetch(ctx context.Context, c *models.VendorChange) error {
+	m.Lock()
+	defer m.Unlock()
+
+	found, ok := m.changes[c.ID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	*c = *m.copy(found)
+	c.User = &models.User{ID: c.UserID}
+	c.Brand = &models.File{}
+	return nil
+}
+
+func (m *memoryVendorChanges) FindPending(ctx context.Context, vendorID int) (*models.VendorChange, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	p := m.pending(vendorID)
+	if p == nil {
+		return nil, nil
+	}
+	return m.copy(p), nil
+}
+
+func (m *memoryVendorChanges) Approve(ctx context.Context, c *models.VendorChange) error {
+	err := m.review(c, models.ChangeApproved, "")
+	if err != nil {
+		return err
+	}
+
+	m.vendors.Lock()
+	defer m.vendors.Unlock()
+
+	v, ok := m.vendors.vendors[c.VendorID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+
+	v.Name = c.Name
+	v.Address = c.Address
+	v.City = c.City
+	v.State = c.State
+	v.Zip = c.Zip
+	v.Phone = c.Phone
+	v.Email = c.Email
+	v.URL = c.URL
+	v.BrandID = c.BrandID
+	v.Version++
+	return nil
+}
+
+func (m *memoryVendorChanges) Reject(ctx context.Context, c *models.VendorChange, note string) error {
+	return m.review(c, models.ChangeRejected, note)
+}
+
+func (m *memoryVendorChanges) FetchPending(ctx context.Context) (*models.VendorChanges, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	changes := models.VendorChanges{}
+	for _, c := range m.changes {
+		if c.Status.String == models.ChangePending {
+			changes = append(changes, m.copy(c))
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].ID < changes[j].ID
+	})
+
+	return &changes, nil
+}
+
+// review settles a pending change, or is domain.ErrNotFound if it isn't
+// pending any more.
+func (m *memoryVendorChanges) review(c *models.VendorChange, status string, note string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	found, ok := m.changes[c.ID]
+	if !ok || found.Status.String != models.ChangePending {
+		return domain.ErrNotFound
+	}
+
+	found.Status = sql.NullString{String: status, Valid: true}
+	found.Note = sql.NullString{String: note, Valid: note != ""}
+	found.Reviewed = mysql.NullTime{Time: time.Now().UTC(), Valid: true}
+	return nil
+}
+
+func (m *memoryVendorChanges) pending(vendorID int) *models.VendorChange {
+	for _, c := range m.changes {
+		if c.VendorID == vendorID && c.Status.String == models.ChangePending {
+			return c
+		}
+	}
+	return nil
+}
+
+func (m *memoryVendorChanges) copy(c *models.VendorChange) *models.VendorChange {
+	cp := *c
+	cp.Vendor = nil
+	cp.User = nil
+	cp.Brand = nil
+	return &cp
+}
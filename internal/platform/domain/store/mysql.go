@@ -0,0 +1,424 @@
+package store
+
+import (
+	"context"
+	"revelbus/internal/platform/domain/models"
+	"revelbus/internal/platform/uploads"
+	"revelbus/pkg/database"
+	"time"
+)
+
+// NewMySQL returns stores backed by the site's database, using the queries
+// on the models themselves.
+func NewMySQL() *Store {
+	return &Store{
+		AuditLog:       mysqlAuditLog{},
+		Bookings:       mysqlBookings{},
+		FAQs:           mysqlFAQs{},
+		Files:          mysqlFiles{},
+		Galleries:      mysqlGalleries{},
+		Identities:     mysqlIdentities{},
+		Impersonations: mysqlImpersonations{},
+		Messages:       mysqlMessages{},
+		Outbox:         mysqlOutbox{},
+		Partners:       mysqlPartners{},
+		PasswordResets: mysqlPasswordResets{},
+		Revisions:      mysqlRevisions{},
+		Roles:          mysqlRoles{},
+		SecurityEvents: mysqlSecurityEvents{},
+		Sessions:       mysqlSessions{},
+		Settings:       mysqlSettings{},
+		Slides:         mysqlSlides{},
+		Subscribers:    mysqlSubscribers{},
+		Trash:          mysqlTrash{},
+		Trips:          mysqlTrips{},
+		Users:          mysqlUsers{},
+		VendorChanges:  mysqlVendorChanges{},
+		Vendors:        mysqlVendors{},
+		Verifications:  mysqlVerifications{},
+		InTx:           database.InTx,
+	}
+}
+
+type mysqlAuditLog struct{}
+
+func (mysqlAuditLog) Fetch(ctx context.Context, f *models.AuditFilter, limit int, offset int) (*models.AuditEntries, error) {
+	return models.FetchAuditLog(ctx, f, limit, offset)
+}
+
+type mysqlBookings struct{}
+
+func (mysqlBookings) Create(ctx context.Context, b *models.Booking) error { return b.Create(ctx) }
+func (mysqlBookings) Cancel(ctx context.Context, b *models.Booking) error { return b.Cancel(ctx) }
+func (mysqlBookings) CheckIn(ctx context.Context, b *models.Booking, in bool) error {
+	return b.CheckIn(ctx, in)
+}
+func (mysqlBookings) IsBooked(ctx context.Context, tripID int, userID int) (bool, error) {
+	return models.IsBooked(ctx, tripID, userID)
+}
+func (mysqlBookings) FindByUser(ctx context.Context, userID int) (*models.Bookings, error) {
+	return models.FindUserBookings(ctx, userID)
+}
+func (mysqlBookings) FetchRiders(ctx context.Context, tripID int) (*models.Bookings, error) {
+	return models.FetchTripRiders(ctx, tripID)
+}
+
+type mysqlFAQs struct{}
+
+func (mysqlFAQs) Create(ctx context.Context, f *models.FAQ) error    { return f.Create(ctx) }
+func (mysqlFAQs) Fetch(ctx context.Context, f *models.FAQ) error     { return f.Fetch(ctx) }
+func (mysqlFAQs) Update(ctx context.Context, f *models.FAQ) error    { return f.Update(ctx) }
+func (mysqlFAQs) Delete(ctx context.Context, f *models.FAQ) error    { return f.Delete(ctx) }
+func (mysqlFAQs) Restore(ctx context.Context, f *models.FAQ) error   { return f.Restore(ctx) }
+func (mysqlFAQs) Purge(ctx context.Context, f *models.FAQ) error     { return f.Purge(ctx) }
+func (mysqlFAQs) FetchAll(ctx context.Context) (*models.FAQs, error) { return models.FetchFAQs(ctx) }
+func (mysqlFAQs) FindActive(ctx context.Context) (*models.GroupedFAQs, error) {
+	return models.FindActiveFAQs(ctx)
+}
+
+type mysqlFiles struct{}
+
+func (mysqlFiles) Create(ctx context.Context, f *models.File) error { return f.Create(ctx) }
+func (mysqlFiles) Fetch(ctx context.Context, f *models.File) error  { return f.Fetch(ctx) }
+func (mysqlFiles) Delete(ctx context.Context, f *models.File) error { return uploads.Delete(ctx, f) }
+func (mysqlFiles) FetchAll(ctx context.Context) (*models.Files, error) {
+	return models.FetchFiles(ctx)
+}
+func (mysqlFiles) InUse(ctx context.Context, f *models.File) (bool, error) { return f.InUse(ctx) }
+
+type mysqlGalleries struct{}
+
+func (mysqlGalleries) Create(ctx context.Context, g *models.Gallery) error  { return g.Create(ctx) }
+func (mysqlGalleries) Fetch(ctx context.Context, g *models.Gallery) error   { return g.Fetch(ctx) }
+func (mysqlGalleries) Update(ctx context.Context, g *models.Gallery) error  { return g.Update(ctx) }
+func (mysqlGalleries) Delete(ctx context.Context, g *models.Gallery) error  { return g.Delete(ctx) }
+func (mysqlGalleries) Restore(ctx context.Context, g *models.Gallery) error { return g.Restore(ctx) }
+func (mysqlGalleries) Purge(ctx context.Context, g *models.Gallery) error   { return g.Purge(ctx) }
+func (mysqlGalleries) FetchAll(ctx context.Context) (*models.Galleries, error) {
+	return models.FetchGalleries(ctx)
+}
+func (mysqlGalleries) AttachImage(ctx context.Context, g *models.Gallery, fid string) error {
+	return g.AttachImage(ctx, fid)
+}
+func (mysqlGalleries) DetachImage(ctx context.Context, g *models.Gallery, fid string) error {
+	return g.DetachImage(ctx, fid)
+}
+
+type mysqlIdentities struct{}
+
+func (mysqlIdentities) Create(ctx context.Context, i *models.Identity) error { return i.Create(ctx) }
+func (mysqlIdentities) FindUser(ctx context.Context, issuer string, subject string) (*models.User, error) {
+	return models.FindIdentityUser(ctx, issuer, subject)
+}
+
+type mysqlImpersonations struct{}
+
+func (mysqlImpersonations) Start(ctx context.Context, i *models.Impersonation) error {
+	return i.Start(ctx)
+}
+func (mysqlImpersonations) End(ctx context.Context, id int) error {
+	return models.EndImpersonation(ctx, id)
+}
+func (mysqlImpersonations) FetchAll(ctx context.Context, userID int, limit int) (*models.Impersonations, error) {
+	return models.FetchImpersonations(ctx, userID, limit)
+}
+func (mysqlImpersonations) FindUser(ctx context.Context, id int) (*models.User, error) {
+	return models.FindImpersonatedUser(ctx, id)
+}
+
+type mysqlMessages struct{}
+
+func (mysqlMessages) Create(ctx context.Context, m *models.Message) error { return m.Create(ctx) }
+func (mysqlMessages) Fetch(ctx context.Context, m *models.Message) error  { return m.Fetch(ctx) }
+func (mysqlMessages) SetStatus(ctx context.Context, m *models.Message, status string) error {
+	return m.SetStatus(ctx, status)
+}
+func (mysqlMessages) MarkReplied(ctx context.Context, m *models.Message) error {
+	return m.MarkReplied(ctx)
+}
+func (mysqlMessages) Delete(ctx context.Context, m *models.Message) error { return m.Delete(ctx) }
+func (mysqlMessages) FetchAll(ctx context.Context, folder string) (*models.Messages, error) {
+	return models.FetchMessages(ctx, folder)
+}
+
+type mysqlOutbox struct{}
+
+func (mysqlOutbox) Create(ctx context.Context, e *models.OutboxEmail) error { return e.Create(ctx) }
+func (mysqlOutbox) Resend(ctx context.Context, e *models.OutboxEmail) error { return e.Resend(ctx) }
+func (mysqlOutbox) FetchFailed(ctx context.Context) (*models.OutboxEmails, error) {
+	return models.FetchFailedEmails(ctx)
+}
+
+type mysqlPartners struct{}
+
+func (mysqlPartners) Link(ctx context.Context, vendorID int, userID int) error {
+	return models.LinkVendorUser(ctx, vendorID, userID)
+}
+func (mysqlPartners) Unlink(ctx context.Context, vendorID int, userID int) error {
+	return models.UnlinkVendorUser(ctx, vendorID, userID)
+}
+func (mysqlPartners) IsVendorUser(ctx context.Context, vendorID int, userID int) (bool, error) {
+	return models.IsVendorUser(ctx, vendorID, userID)
+}
+func (mysqlPartners) FetchVendorUsers(ctx context.Context, vendorID int) (models.Users, error) {
+	return models.FetchVendorUsers(ctx, vendorID)
+}
+func (mysqlPartners) FetchUserVendors(ctx context.Context, userID int) (*models.Vendors, error) {
+	return models.FetchUserVendors(ctx, userID)
+}
+func (mysqlPartners) FetchVendorTrips(ctx context.Context, vendorID int) (*models.PartnerTrips, error) {
+	return models.FetchVendorTrips(ctx, vendorID)
+}
+
+type mysqlPasswordResets struct{}
+
+func (mysqlPasswordResets) Create(ctx context.Context, userID int) (*models.PasswordReset, string, error) {
+	return models.CreatePasswordReset(ctx, userID)
+}
+func (mysqlPasswordResets) Find(ctx context.Context, id int, token string) (*models.PasswordReset, error) {
+	return models.FindPasswordReset(ctx, id, token)
+}
+func (mysqlPasswordResets) Redeem(ctx context.Context, p *models.PasswordReset, pw string) error {
+	return p.Redeem(ctx, pw)
+}
+
+type mysqlRevisions struct{}
+
+func (mysqlRevisions) Fetch(ctx context.Context, r *models.Revision) error { return r.Fetch(ctx) }
+func (mysqlRevisions) Previous(ctx context.Context, r *models.Revision) (*models.Revision, error) {
+	return r.Previous(ctx)
+}
+func (mysqlRevisions) FetchAll(ctx context.Context, entityType string, id int) (*models.Revisions, error) {
+	return models.FetchRevisions(ctx, entityType, id)
+}
+
+type mysqlRoles struct{}
+
+func (mysqlRoles) Create(ctx context.Context, r *models.Role) error { return r.Create(ctx) }
+func (mysqlRoles) Fetch(ctx context.Context, r *models.Role) error  { return r.Fetch(ctx) }
+func (mysqlRoles) Update(ctx context.Context, r *models.Role) error { return r.Update(ctx) }
+func (mysqlRoles) Delete(ctx context.Context, r *models.Role) error { return r.Delete(ctx) }
+func (mysqlRoles) FetchAll(ctx context.Context) (*models.Roles, error) {
+	return models.FetchRoles(ctx)
+}
+
+type mysqlSecurityEvents struct{}
+
+func (mysqlSecurityEvents) Create(ctx context.Context, e *models.SecurityEvent) error {
+	return e.Create(ctx)
+}
+func (mysqlSecurityEvents) KnownDevice(ctx context.Context, userID int, ua string) (bool, error) {
+	return models.KnownDevice(ctx, userID, ua)
+}
+func (mysqlSecurityEvents) FetchAll(ctx context.Context, userID int, limit int) (*models.SecurityEvents, error) {
+	return models.FetchSecurityEvents(ctx, userID, limit)
+}
+
+type mysqlSessions struct{}
+
+func (mysqlSessions) Create(ctx context.Context, s *models.Session) error { return s.Create(ctx) }
+func (mysqlSessions) Revoke(ctx context.Context, s *models.Session) error { return s.Revoke(ctx) }
+func (mysqlSessions) RevokeAll(ctx context.Context, userID int) error {
+	return models.RevokeUserSessions(ctx, userID)
+}
+func (mysqlSessions) Delete(ctx context.Context, token string) error {
+	return models.DeleteSession(ctx, token)
+}
+func (mysqlSessions) FindUser(ctx context.Context, token string, ip string) (*models.User, error) {
+	return models.FindSessionUser(ctx, token, ip)
+}
+func (mysqlSessions) FetchAll(ctx context.Context, userID int) (*models.Sessions, error) {
+	return models.FetchUserSessions(ctx, userID)
+}
+
+type mysqlSettings struct{}
+
+func (mysqlSettings) Create(ctx context.Context, s *models.Settings) error { return s.Create(ctx) }
+func (mysqlSettings) Fetch(ctx context.Context, s *models.Settings) error  { return s.Fetch(ctx) }
+func (mysqlSettings) Update(ctx context.Context, s *models.Settings) error { return s.Update(ctx) }
+func (mysqlSettings) RequireAdmin2FA(ctx context.Context) (bool, error) {
+	return models.RequireAdmin2FA(ctx)
+}
+
+type mysqlSlides struct{}
+
+func (mysqlSlides) Create(ctx context.Context, s *models.Slide) error { return s.Create(ctx) }
+func (mysqlSlides) Fetch(ctx context.Context, s *models.Slide) error  { return s.Fetch(ctx) }
+func (mysqlSlides) Update(ctx context.Context, s *models.Slide) error { return s.Update(ctx) }
+func (mysqlSlides) Delete(ctx context.Context, s *models.Slide) error { return s.Delete(ctx) }
+func (mysqlSlides) FetchAll(ctx context.Context) (*models.Slides, error) {
+	return models.FetchSlides(ctx)
+}
+func (mysqlSlides) FindActive(ctx context.Context) (*models.Slides, error) {
+	return models.FindActiveSlides(ctx)
+}
+
+type mysqlSubscribers struct{}
+
+func (mysqlSubscribers) Create(ctx context.Context, s *models.Subscriber) error { return s.Create(ctx) }
+func (mysqlSubscribers) Fetch(ctx context.Context, s *models.Subscriber) error  { return s.Fetch(ctx) }
+func (mysqlSubscribers) Resubscribe(ctx context.Context, s *models.Subscriber) error {
+	return s.Resubscribe(ctx)
+}
+func (mysqlSubscribers) Unsubscribe(ctx context.Context, s *models.Subscriber) error {
+	return s.Unsubscribe(ctx)
+}
+func (mysqlSubscribers) SetInterests(ctx context.Context, s *models.Subscriber, categories []string) error {
+	return s.SetInterests(ctx, categories)
+}
+func (mysqlSubscribers) Delete(ctx context.Context, s *models.Subscriber) error { return s.Delete(ctx) }
+func (mysqlSubscribers) Confirm(ctx context.Context, token string) (*models.Subscriber, error) {
+	return models.ConfirmSubscriber(ctx, token)
+}
+func (mysqlSubscribers) FindByToken(ctx context.Context, token string) (*models.Subscriber, error) {
+	return models.FindSubscriberByToken(ctx, token)
+}
+func (mysqlSubscribers) FetchAll(ctx context.Context) (*models.Subscribers, error) {
+	return models.FetchSubscribers(ctx)
+}
+func (mysqlSubscribers) FindActive(ctx context.Context, category string) (models.Subscribers, error) {
+	return models.FindActiveSubscribers(ctx, category)
+}
+
+type mysqlTrash struct{}
+
+func (mysqlTrash) Fetch(ctx context.Context, t string) (*models.TrashedItems, error) {
+	return models.FetchTrash(ctx, t)
+}
+func (mysqlTrash) FetchExpired(ctx context.Context, cutoff time.Time) (*models.TrashedItems, error) {
+	return models.FetchExpiredTrash(ctx, cutoff)
+}
+
+type mysqlTrips struct{}
+
+func (mysqlTrips) Create(ctx context.Context, t *models.Trip) error  { return t.Create(ctx) }
+func (mysqlTrips) Fetch(ctx context.Context, t *models.Trip) error   { return t.Fetch(ctx) }
+func (mysqlTrips) GetBase(ctx context.Context, t *models.Trip) error { return t.GetBase(ctx) }
+func (mysqlTrips) Update(ctx context.Context, t *models.Trip) error  { return t.Update(ctx) }
+func (mysqlTrips) Delete(ctx context.Context, t *models.Trip) error  { return t.Delete(ctx) }
+func (mysqlTrips) Restore(ctx context.Context, t *models.Trip) error { return t.Restore(ctx) }
+func (mysqlTrips) Purge(ctx context.Context, t *models.Trip) error   { return t.Purge(ctx) }
+func (mysqlTrips) FindBySlug(ctx context.Context, slug string) (*models.Trip, error) {
+	return models.FindBySlug(ctx, slug)
+}
+func (mysqlTrips) FetchAll(ctx context.Context) (*models.Trips, error) { return models.FetchTrips(ctx) }
+func (mysqlTrips) FindUpcoming(ctx context.Context, limit int) (*models.Trips, error) {
+	return models.FindUpcomingTrips(ctx, limit)
+}
+func (mysqlTrips) FindUpcomingByMonth(ctx context.Context) (*models.GroupedTrips, error) {
+	return models.FindUpcomingTripsByMonth(ctx)
+}
+func (mysqlTrips) AttachVendor(ctx context.Context, t *models.Trip, role string, vid string) error {
+	return t.AttachVendor(ctx, role, vid)
+}
+func (mysqlTrips) DetachVendor(ctx context.Context, t *models.Trip, role string, vid string) error {
+	return t.DetachVendor(ctx, role, vid)
+}
+func (mysqlTrips) SetVenueStatus(ctx context.Context, t *models.Trip, vid string, isPrimary bool) error {
+	return t.SetVenueStatus(ctx, vid, isPrimary)
+}
+func (mysqlTrips) FetchCategories(ctx context.Context) ([]string, error) {
+	return models.FetchTripCategories(ctx)
+}
+
+type mysqlUsers struct{}
+
+func (mysqlUsers) Create(ctx context.Context, u *models.User) error { return u.Create(ctx) }
+func (mysqlUsers) Fetch(ctx context.Context, u *models.User) error  { return u.Fetch(ctx) }
+func (mysqlUsers) Update(ctx context.Context, u *models.User) error { return u.Update(ctx) }
+func (mysqlUsers) UpdatePassword(ctx context.Context, u *models.User, pw string) error {
+	return u.UpdatePassword(ctx, pw)
+}
+func (mysqlUsers) Delete(ctx context.Context, u *models.User) error   { return u.Delete(ctx) }
+func (mysqlUsers) Restore(ctx context.Context, u *models.User) error  { return u.Restore(ctx) }
+func (mysqlUsers) Purge(ctx context.Context, u *models.User) error    { return u.Purge(ctx) }
+func (mysqlUsers) FetchAll(ctx context.Context) (models.Users, error) { return models.FetchUsers(ctx) }
+func (mysqlUsers) VerifyUser(ctx context.Context, u *models.User, pw string) error {
+	return u.VerifyUser(ctx, pw)
+}
+func (mysqlUsers) EmailInUse(ctx context.Context, email string) (bool, error) {
+	return models.EmailInUse(ctx, email)
+}
+func (mysqlUsers) VerifyAndUpdatePassword(ctx context.Context, u *models.User, old string, pw string) error {
+	return u.VerifyAndUpdatePassword(ctx, old, pw)
+}
+func (mysqlUsers) SetVerified(ctx context.Context, u *models.User, verified bool) error {
+	return u.SetVerified(ctx, verified)
+}
+func (mysqlUsers) LoginWait(ctx context.Context, email string) (time.Duration, error) {
+	return models.LoginWait(ctx, email)
+}
+func (mysqlUsers) RecordFailedLogin(ctx context.Context, email string) (*models.User, error) {
+	return models.RecordFailedLogin(ctx, email)
+}
+func (mysqlUsers) ResetFailedLogins(ctx context.Context, u *models.User) error {
+	return models.ResetFailedLogins(ctx, u.ID)
+}
+func (mysqlUsers) Unlock(ctx context.Context, u *models.User) error { return u.Unlock(ctx) }
+func (mysqlUsers) FetchLocked(ctx context.Context) (models.Users, error) {
+	return models.FetchLockedUsers(ctx)
+}
+func (mysqlUsers) EnableTwoFactor(ctx context.Context, u *models.User, secret string, step int64) error {
+	return u.EnableTwoFactor(ctx, secret, step)
+}
+func (mysqlUsers) DisableTwoFactor(ctx context.Context, u *models.User) error {
+	return u.DisableTwoFactor(ctx)
+}
+func (mysqlUsers) VerifyTOTP(ctx context.Context, u *models.User, code string) (bool, error) {
+	return u.VerifyTOTP(ctx, code)
+}
+func (mysqlUsers) NewRecoveryCodes(ctx context.Context, u *models.User) ([]string, error) {
+	return u.NewRecoveryCodes(ctx)
+}
+func (mysqlUsers) UseRecoveryCode(ctx context.Context, u *models.User, code string) (bool, error) {
+	return u.UseRecoveryCode(ctx, code)
+}
+func (mysqlUsers) RemainingRecoveryCodes(ctx context.Context, u *models.User) (int, error) {
+	return u.RemainingRecoveryCodes(ctx)
+}
+
+type mysqlVendorChanges struct{}
+
+func (mysqlVendorChanges) Submit(ctx context.Context, c *models.VendorChange) error {
+	return c.Submit(ctx)
+}
+func (mysqlVendorChanges) Fetch(ctx context.Context, c *models.VendorChange) error {
+	return c.Fetch(ctx)
+}
+func (mysqlVendorChanges) FindPending(ctx context.Context, vendorID int) (*models.VendorChange, error) {
+	return models.FindPendingVendorChange(ctx, vendorID)
+}
+func (mysqlVendorChanges) Approve(ctx context.Context, c *models.VendorChange) error {
+	return c.Approve(ctx)
+}
+func (mysqlVendorChanges) Reject(ctx context.Context, c *models.VendorChange, note string) error {
+	return c.Reject(ctx, note)
+}
+func (mysqlVendorChanges) FetchPending(ctx context.Context) (*models.VendorChanges, error) {
+	return models.FetchPendingVendorChanges(ctx)
+}
+
+type mysqlVendors struct{}
+
+func (mysqlVendors) Create(ctx context.Context, v *models.Vendor) error  { return v.Create(ctx) }
+func (mysqlVendors) Fetch(ctx context.Context, v *models.Vendor) error   { return v.Fetch(ctx) }
+func (mysqlVendors) GetBase(ctx context.Context, v *models.Vendor) error { return v.GetBase(ctx) }
+func (mysqlVendors) Update(ctx context.Context, v *models.Vendor) error  { return v.Update(ctx) }
+func (mysqlVendors) Delete(ctx context.Context, v *models.Vendor) error  { return v.Delete(ctx) }
+func (mysqlVendors) Restore(ctx context.Context, v *models.Vendor) error { return v.Restore(ctx) }
+func (mysqlVendors) Purge(ctx context.Context, v *models.Vendor) error   { return v.Purge(ctx) }
+func (mysqlVendors) FetchAll(ctx context.Context, activeOnly bool) (*models.Vendors, error) {
+	return models.FetchVendors(ctx, activeOnly)
+}
+
+type mysqlVerifications struct{}
+
+func (mysqlVerifications) Create(ctx context.Context, u *models.User, email string) (*models.EmailVerification, string, error) {
+	return models.CreateEmailVerification(ctx, u, email)
+}
+func (mysqlVerifications) Find(ctx context.Context, id int, token string) (*models.EmailVerification, error) {
+	return models.FindEmailVerification(ctx, id, token)
+}
+func (mysqlVerifications) Redeem(ctx context.Context, v *models.EmailVerification) error {
+	return v.Redeem(ctx)
+}
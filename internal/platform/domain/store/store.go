@@ -0,0 +1,295 @@
+// Package store is how handlers load and save everything the site keeps.
+// Each aggregate gets its own interface, with a MySQL implementation for the
+// running site and an in-memory one so handlers can be exercised without a
+// database.
+package store
+
+import (
+	"context"
+	"revelbus/internal/platform/domain/models"
+	"time"
+)
+
+// Store groups a store for each aggregate. Deleting a trip, vendor, FAQ,
+// gallery or user moves it to the trash, where Restore brings it back and
+// Purge removes it for good. Update only saves a record whose Version is the
+// one it was read at, and is domain.ErrConflict otherwise.
+type Store struct {
+	AuditLog       AuditLogStore
+	Bookings       BookingStore
+	FAQs           FAQStore
+	Files          FileStore
+	Galleries      GalleryStore
+	Identities     IdentityStore
+	Impersonations ImpersonationStore
+	Messages       MessageStore
+	Outbox         OutboxStore
+	Partners       PartnerStore
+	PasswordResets PasswordResetStore
+	Revisions      RevisionStore
+	Roles          RoleStore
+	SecurityEvents SecurityEventStore
+	Sessions       SessionStore
+	Settings       SettingsStore
+	Slides         SlideStore
+	Subscribers    SubscriberStore
+	Trash          TrashStore
+	Trips          TripStore
+	Users          UserStore
+	VendorChanges  VendorChangeStore
+	Vendors        VendorStore
+	Verifications  VerificationStore
+
+	// InTx runs fn so the writes made with the context it's given all happen
+	// or none do.
+	InTx func(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// AuditLogStore reads the log the Audited stores write.
+type AuditLogStore interface {
+	Fetch(ctx context.Context, f *models.AuditFilter, limit int, offset int) (*models.AuditEntries, error)
+}
+
+type BookingStore interface {
+	Create(ctx context.Context, b *models.Booking) error
+	Cancel(ctx context.Context, b *models.Booking) error
+	CheckIn(ctx context.Context, b *models.Booking, in bool) error
+	IsBooked(ctx context.Context, tripID int, userID int) (bool, error)
+	FindByUser(ctx context.Context, userID int) (*models.Bookings, error)
+	FetchRiders(ctx context.Context, tripID int) (*models.Bookings, error)
+}
+
+type FAQStore interface {
+	Create(ctx context.Context, f *models.FAQ) error
+	Fetch(ctx context.Context, f *models.FAQ) error
+	Update(ctx context.Context, f *models.FAQ) error
+	Delete(ctx context.Context, f *models.FAQ) error
+	Restore(ctx context.Context, f *models.FAQ) error
+	Purge(ctx context.Context, f *models.FAQ) error
+	FetchAll(ctx context.Context) (*models.FAQs, error)
+	FindActive(ctx context.Context) (*models.GroupedFAQs, error)
+}
+
+// FileStore keeps the records of uploaded files. Deleting one takes the file
+// off disk too, once the transaction it's part of commits.
+type FileStore interface {
+	Create(ctx context.Context, f *models.File) error
+	Fetch(ctx context.Context, f *models.File) error
+	Delete(ctx context.Context, f *models.File) error
+	FetchAll(ctx context.Context) (*models.Files, error)
+	InUse(ctx context.Context, f *models.File) (bool, error)
+}
+
+type GalleryStore interface {
+	Create(ctx context.Context, g *models.Gallery) error
+	Fetch(ctx context.Context, g *models.Gallery) error
+	Update(ctx context.Context, g *models.Gallery) error
+	Delete(ctx context.Context, g *models.Gallery) error
+	Restore(ctx context.Context, g *models.Gallery) error
+	Purge(ctx context.Context, g *models.Gallery) error
+	FetchAll(ctx context.Context) (*models.Galleries, error)
+	AttachImage(ctx context.Context, g *models.Gallery, fid string) error
+	DetachImage(ctx context.Context, g *models.Gallery, fid string) error
+}
+
+// IdentityStore links accounts to the users of an OpenID Connect provider.
+type IdentityStore interface {
+	Create(ctx context.Context, i *models.Identity) error
+	FindUser(ctx context.Context, issuer string, subject string) (*models.User, error)
+}
+
+type ImpersonationStore interface {
+	Start(ctx context.Context, i *models.Impersonation) error
+	End(ctx context.Context, id int) error
+	FetchAll(ctx context.Context, userID int, limit int) (*models.Impersonations, error)
+	FindUser(ctx context.Context, id int) (*models.User, error)
+}
+
+type MessageStore interface {
+	Create(ctx context.Context, m *models.Message) error
+	Fetch(ctx context.Context, m *models.Message) error
+	SetStatus(ctx context.Context, m *models.Message, status string) error
+	MarkReplied(ctx context.Context, m *models.Message) error
+	Delete(ctx context.Context, m *models.Message) error
+	FetchAll(ctx context.Context, folder string) (*models.Messages, error)
+}
+
+// OutboxStore is where outgoing mail waits to be delivered. Resend is
+// domain.ErrNotFound unless the message is one that failed.
+type OutboxStore interface {
+	Create(ctx context.Context, e *models.OutboxEmail) error
+	Resend(ctx context.Context, e *models.OutboxEmail) error
+	FetchFailed(ctx context.Context) (*models.OutboxEmails, error)
+}
+
+// PartnerStore links users to the vendors they look after in the partner
+// portal.
+type PartnerStore interface {
+	Link(ctx context.Context, vendorID int, userID int) error
+	Unlink(ctx context.Context, vendorID int, userID int) error
+	IsVendorUser(ctx context.Context, vendorID int, userID int) (bool, error)
+	FetchVendorUsers(ctx context.Context, vendorID int) (models.Users, error)
+	FetchUserVendors(ctx context.Context, userID int) (*models.Vendors, error)
+	FetchVendorTrips(ctx context.Context, vendorID int) (*models.PartnerTrips, error)
+}
+
+// PasswordResetStore keeps account recovery links. Create returns the token
+// to send, which isn't kept; Find and Redeem are
+// domain.ErrInvalidCredentials for any link that can't be used.
+type PasswordResetStore interface {
+	Create(ctx context.Context, userID int) (*models.PasswordReset, string, error)
+	Find(ctx context.Context, id int, token string) (*models.PasswordReset, error)
+	Redeem(ctx context.Context, p *models.PasswordReset, pw string) error
+}
+
+type RevisionStore interface {
+	Fetch(ctx context.Context, r *models.Revision) error
+	Previous(ctx context.Context, r *models.Revision) (*models.Revision, error)
+	FetchAll(ctx context.Context, entityType string, id int) (*models.Revisions, error)
+}
+
+// RoleStore keeps the staff roles. Delete is domain.ErrCannotDelete for the
+// built in roles and any role someone still has.
+type RoleStore interface {
+	Create(ctx context.Context, r *models.Role) error
+	Fetch(ctx context.Context, r *models.Role) error
+	Update(ctx context.Context, r *models.Role) error
+	Delete(ctx context.Context, r *models.Role) error
+	FetchAll(ctx context.Context) (*models.Roles, error)
+}
+
+type SecurityEventStore interface {
+	Create(ctx context.Context, e *models.SecurityEvent) error
+	KnownDevice(ctx context.Context, userID int, ua string) (bool, error)
+	FetchAll(ctx context.Context, userID int, limit int) (*models.SecurityEvents, error)
+}
+
+// SessionStore keeps the devices users are signed in on. FindUser loads the
+// user behind a token, with their permissions, or is domain.ErrNotFound once
+// the session has ended.
+type SessionStore interface {
+	Create(ctx context.Context, s *models.Session) error
+	Revoke(ctx context.Context, s *models.Session) error
+	RevokeAll(ctx context.Context, userID int) error
+	Delete(ctx context.Context, token string) error
+	FindUser(ctx context.Context, token string, ip string) (*models.User, error)
+	FetchAll(ctx context.Context, userID int) (*models.Sessions, error)
+}
+
+type SettingsStore interface {
+	Create(ctx context.Context, s *models.Settings) error
+	Fetch(ctx context.Context, s *models.Settings) error
+	Update(ctx context.Context, s *models.Settings) error
+	RequireAdmin2FA(ctx context.Context) (bool, error)
+}
+
+type SlideStore interface {
+	Create(ctx context.Context, s *models.Slide) error
+	Fetch(ctx context.Context, s *models.Slide) error
+	Update(ctx context.Context, s *models.Slide) error
+	Delete(ctx context.Context, s *models.Slide) error
+	FetchAll(ctx context.Context) (*models.Slides, error)
+	FindActive(ctx context.Context) (*models.Slides, error)
+}
+
+// SubscriberStore keeps the mailing list. Confirm and FindByToken take the
+// tokens from confirmation and unsubscribe links.
+type SubscriberStore interface {
+	Create(ctx context.Context, s *models.Subscriber) error
+	Fetch(ctx context.Context, s *models.Subscriber) error
+	Resubscribe(ctx context.Context, s *models.Subscriber) error
+	Unsubscribe(ctx context.Context, s *models.Subscriber) error
+	SetInterests(ctx context.Context, s *models.Subscriber, categories []string) error
+	Delete(ctx context.Context, s *models.Subscriber) error
+	Confirm(ctx context.Context, token string) (*models.Subscriber, error)
+	FindByToken(ctx context.Context, token string) (*models.Subscriber, error)
+	FetchAll(ctx context.Context) (*models.Subscribers, error)
+	FindActive(ctx context.Context, category string) (models.Subscribers, error)
+}
+
+// TrashStore lists what's in the trash. Restoring and purging is done through
+// each aggregate's own store.
+type TrashStore interface {
+	Fetch(ctx context.Context, t string) (*models.TrashedItems, error)
+	FetchExpired(ctx context.Context, cutoff time.Time) (*models.TrashedItems, error)
+}
+
+type TripStore interface {
+	Create(ctx context.Context, t *models.Trip) error
+	Fetch(ctx context.Context, t *models.Trip) error
+	GetBase(ctx context.Context, t *models.Trip) error
+	Update(ctx context.Context, t *models.Trip) error
+	Delete(ctx context.Context, t *models.Trip) error
+	Restore(ctx context.Context, t *models.Trip) error
+	Purge(ctx context.Context, t *models.Trip) error
+	FindBySlug(ctx context.Context, slug string) (*models.Trip, error)
+	FetchAll(ctx context.Context) (*models.Trips, error)
+	FindUpcoming(ctx context.Context, limit int) (*models.Trips, error)
+	FindUpcomingByMonth(ctx context.Context) (*models.GroupedTrips, error)
+	AttachVendor(ctx context.Context, t *models.Trip, role string, vid string) error
+	DetachVendor(ctx context.Context, t *models.Trip, role string, vid string) error
+	SetVenueStatus(ctx context.Context, t *models.Trip, vid string, isPrimary bool) error
+	FetchCategories(ctx context.Context) ([]string, error)
+}
+
+// UserStore keeps accounts along with what guards them: the password, failed
+// logins and lockouts, and the second factor. VerifyUser and
+// VerifyAndUpdatePassword are domain.ErrInvalidCredentials for a wrong
+// password.
+type UserStore interface {
+	Create(ctx context.Context, u *models.User) error
+	Fetch(ctx context.Context, u *models.User) error
+	Update(ctx context.Context, u *models.User) error
+	UpdatePassword(ctx context.Context, u *models.User, pw string) error
+	VerifyAndUpdatePassword(ctx context.Context, u *models.User, old string, pw string) error
+	SetVerified(ctx context.Context, u *models.User, verified bool) error
+	Delete(ctx context.Context, u *models.User) error
+	Restore(ctx context.Context, u *models.User) error
+	Purge(ctx context.Context, u *models.User) error
+	FetchAll(ctx context.Context) (models.Users, error)
+	VerifyUser(ctx context.Context, u *models.User, pw string) error
+	EmailInUse(ctx context.Context, email string) (bool, error)
+
+	LoginWait(ctx context.Context, email string) (time.Duration, error)
+	RecordFailedLogin(ctx context.Context, email string) (*models.User, error)
+	ResetFailedLogins(ctx context.Context, u *models.User) error
+	Unlock(ctx context.Context, u *models.User) error
+	FetchLocked(ctx context.Context) (models.Users, error)
+
+	EnableTwoFactor(ctx context.Context, u *models.User, secret string, step int64) error
+	DisableTwoFactor(ctx context.Context, u *models.User) error
+	VerifyTOTP(ctx context.Context, u *models.User, code string) (bool, error)
+	NewRecoveryCodes(ctx context.Context, u *models.User) ([]string, error)
+	UseRecoveryCode(ctx context.Context, u *models.User, code string) (bool, error)
+	RemainingRecoveryCodes(ctx context.Context, u *models.User) (int, error)
+}
+
+// VendorChangeStore keeps the edits partners make to their vendors until an
+// admin approves or rejects them. FindPending is nil when nothing is waiting.
+type VendorChangeStore interface {
+	Submit(ctx context.Context, c *models.VendorChange) error
+	Fetch(ctx context.Context, c *models.VendorChange) error
+	FindPending(ctx context.Context, vendorID int) (*models.VendorChange, error)
+	Approve(ctx context.Context, c *models.VendorChange) error
+	Reject(ctx context.Context, c *models.VendorChange, note string) error
+	FetchPending(ctx context.Context) (*models.VendorChanges, error)
+}
+
+type VendorStore interface {
+	Create(ctx context.Context, v *models.Vendor) error
+	Fetch(ctx context.Context, v *models.Vendor) error
+	GetBase(ctx context.Context, v *models.Vendor) error
+	Update(ctx context.Context, v *models.Vendor) error
+	Delete(ctx context.Context, v *models.Vendor) error
+	Restore(ctx context.Context, v *models.Vendor) error
+	Purge(ctx context.Context, v *models.Vendor) error
+	FetchAll(ctx context.Context, activeOnly bool) (*models.Vendors, error)
+}
+
+// VerificationStore keeps the links that confirm an email address. Find and
+// Redeem are domain.ErrInvalidCredentials for any link that can't be used.
+type VerificationStore interface {
+	Create(ctx context.Context, u *models.User, email string) (*models.EmailVerification, string, error)
+	Find(ctx context.Context, id int, token string) (*models.EmailVerification, error)
+	Redeem(ctx context.Context, v *models.EmailVerification) error
+}
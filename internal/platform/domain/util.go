@@ -1,7 +1,10 @@
 package domain
 
 import (
+	"context"
+	"crypto/rand"
 	"database/sql"
+	"encoding/base64"
 	"errors"
 	"strconv"
 	"time"
@@ -13,6 +16,7 @@ import (
 
 var (
 	ErrCannotDelete       = errors.New("Cannot delete entity")
+	ErrConflict           = errors.New("Changed since it was read")
 	ErrDuplicate          = errors.New("Duplicate entry")
 	ErrDuplicateEmail     = errors.New("Email address already in use")
 	ErrInvalidCredentials = errors.New("Invalid user credentials")
@@ -31,23 +35,34 @@ func ToTime(t string) time.Time {
 	return dt
 }
 
-func GetSlug(str string, t string) string {
+// GetSlug returns a slug for str that isn't used yet in table t.
+func GetSlug(ctx context.Context, str string, t string) (string, error) {
 	var id int
-	var err error
 
-	conn, _ := database.GetConnection()
+	conn, _ := database.Conn(ctx)
 	stmt := `SELECT id FROM ` + t + ` WHERE slug = ?`
 
 	s := slug.Make(str)
 	sl := s
 
-	num := 0
-	for err != sql.ErrNoRows {
-		if num != 0 {
-			sl = s + "-" + strconv.Itoa(num)
+	for num := 1; ; num++ {
+		err := conn.QueryRowContext(ctx, stmt, sl).Scan(&id)
+		if err == sql.ErrNoRows {
+			return sl, nil
 		}
-		err = conn.QueryRow(stmt, sl).Scan(&id)
-		num++
+		if err != nil {
+			return "", err
+		}
+		sl = s + "-" + strconv.Itoa(num)
+	}
+}
+
+// NewToken returns a random, URL safe token suitable for links sent by email.
+func NewToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
 	}
-	return sl
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
@@ -1,11 +1,26 @@
 package emails
 
 import (
+	"context"
+	"html"
+	"net/url"
+	"revelbus/internal/platform/domain/models"
 	"revelbus/internal/platform/forms"
+	"revelbus/internal/platform/outbox"
 	"revelbus/pkg/email"
+	"strconv"
+	"strings"
+
+	"github.com/kennygrant/sanitize"
+	"github.com/spf13/viper"
 )
 
-func NewPassword(e string, pw string) error {
+// link makes an absolute URL to a page on the site for use in emails.
+func link(path string) string {
+	return strings.TrimRight(viper.GetString("url"), "/") + path
+}
+
+func NewPassword(ctx context.Context, e string, pw string) error {
 	m := email.Email{
 		To: []string{
 			e,
@@ -15,31 +30,291 @@ func NewPassword(e string, pw string) error {
 		HTML:    "<p>Your new password is: " + pw + "</p>",
 	}
 
-	err := email.Send(m)
+	err := outbox.Queue(ctx, m)
 	return err
 }
 
-func RecoverAccount(e string, h string) error {
+func RecoverAccount(ctx context.Context, e string, id int, token string) error {
+	l := link("/auth/recover?id=" + strconv.Itoa(id) + "&token=" + url.QueryEscape(token))
+
 	m := email.Email{
 		To: []string{
 			e,
 		},
 		Subject: "Password Recovery",
-		Text:    "Click to reset password: /auth/recover/?email=" + e + "&hash=" + h,
-		HTML:    "<p>Click to reset password: /auth/recover/?email=" + e + "&hash=" + h + "</p>",
+		Text:    "Click to reset password: " + l + "\n\nThis link can only be used once and expires soon. If you didn't ask to reset your password, you can ignore this email.",
+		HTML:    "<p>Click to reset password: <a href=\"" + l + "\">" + l + "</a></p><p>This link can only be used once and expires soon. If you didn't ask to reset your password, you can ignore this email.</p>",
+	}
+
+	err := outbox.Queue(ctx, m)
+	return err
+}
+
+// VerifyEmail sends the link that proves the user owns their address.
+func VerifyEmail(ctx context.Context, u *models.User, id int, token string) error {
+	l := link("/verify?id=" + strconv.Itoa(id) + "&token=" + url.QueryEscape(token))
+
+	m := email.Email{
+		To: []string{
+			u.Email.String,
+		},
+		Subject: "Verify your email address",
+		Text:    "Hi " + u.Name.String + ",\n\nPlease confirm this is your email address so you can book trips: " + l + "\n\nThis link expires in a couple of days. If you didn't sign up for Revel Bus, you can ignore this email.",
+		HTML:    "<p>Hi " + html.EscapeString(u.Name.String) + ",</p><p>Please confirm this is your email address so you can book trips: <a href=\"" + l + "\">" + l + "</a></p><p>This link expires in a couple of days. If you didn't sign up for Revel Bus, you can ignore this email.</p>",
 	}
 
-	err := email.Send(m)
+	err := outbox.Queue(ctx, m)
 	return err
 }
 
-func ContactEmail(f *forms.ContactForm) error {
+// ConfirmEmailChange goes to the new address; the change only happens once
+// the link in it is followed.
+func ConfirmEmailChange(ctx context.Context, u *models.User, to string, id int, token string) error {
+	l := link("/verify?id=" + strconv.Itoa(id) + "&token=" + url.QueryEscape(token))
+
+	m := email.Email{
+		To: []string{
+			to,
+		},
+		Subject: "Confirm your new email address",
+		Text:    "Hi " + u.Name.String + ",\n\nClick to use this address for your Revel Bus account: " + l + "\n\nThis link expires in a couple of days. If you didn't ask for this, you can ignore this email.",
+		HTML:    "<p>Hi " + html.EscapeString(u.Name.String) + ",</p><p>Click to use this address for your Revel Bus account: <a href=\"" + l + "\">" + l + "</a></p><p>This link expires in a couple of days. If you didn't ask for this, you can ignore this email.</p>",
+	}
+
+	err := outbox.Queue(ctx, m)
+	return err
+}
+
+// SecurityAlert tells the account owner about something sensitive that just
+// happened on their account, in case it wasn't them.
+func SecurityAlert(ctx context.Context, to string, e *models.SecurityEvent) error {
+	forgot := link("/auth/forgot")
+	activity := link("/u/security")
+	when := e.Created.Format("Mon, Jan 2, 2006 at 3:04 PM MST")
+	what := e.Summary() + " on " + when + " from " + e.Device()
+	if e.IP.String != "" {
+		what += " (" + e.IP.String + ")"
+	}
+
+	m := email.Email{
+		To: []string{
+			to,
+		},
+		Subject: "Security alert: " + e.Summary(),
+		Text:    what + ".\n\nIf this was you, there's nothing else to do. If it wasn't, reset your password right away: " + forgot + "\n\nYou can review recent activity on your account here: " + activity,
+		HTML:    "<p>" + html.EscapeString(what) + ".</p><p>If this was you, there's nothing else to do. If it wasn't, <a href=\"" + forgot + "\">reset your password</a> right away.</p><p>You can <a href=\"" + activity + "\">review recent activity</a> on your account.</p>",
+	}
+
+	err := outbox.Queue(ctx, m)
+	return err
+}
+
+// AccountLocked lets the owner know someone kept getting their password wrong.
+func AccountLocked(ctx context.Context, u *models.User) error {
+	l := link("/auth/forgot")
+	until := u.LockedUntil.Time.Format("3:04 PM MST")
+
+	m := email.Email{
+		To: []string{
+			u.Email.String,
+		},
+		Subject: "Your Revel Bus account has been locked",
+		Text:    "There have been too many failed attempts to log in to your account, so it's locked until " + until + ".\n\nIf this wasn't you, someone may be trying to guess your password. You can reset it here: " + l,
+		HTML:    "<p>There have been too many failed attempts to log in to your account, so it's locked until " + until + ".</p><p>If this wasn't you, someone may be trying to guess your password. You can <a href=\"" + l + "\">reset it here</a>.</p>",
+	}
+
+	err := outbox.Queue(ctx, m)
+	return err
+}
+
+func ContactEmail(ctx context.Context, f *forms.ContactForm, id int) error {
+	l := link("/admin/message/" + strconv.Itoa(id))
+
 	m := email.Email{
 		Subject: "Revel Bus Contact Form",
-		Text:    "Name: " + f.Name + " \nEmail: " + f.Email + " \nPhone: " + f.Phone + " \nMessage: " + f.Message,
-		HTML:    "Name: " + f.Name + " \nEmail: " + f.Email + " \nPhone: " + f.Phone + " \nMessage: " + f.Message,
+		Text:    "Name: " + f.Name + " \nEmail: " + f.Email + " \nPhone: " + f.Phone + " \nMessage: " + f.Message + " \n\nReply from the inbox: " + l,
+		HTML:    "<p>Name: " + html.EscapeString(f.Name) + "<br>Email: " + html.EscapeString(f.Email) + "<br>Phone: " + html.EscapeString(f.Phone) + "</p><p>" + strings.Replace(html.EscapeString(f.Message), "\n", "<br>", -1) + "</p><p><a href=\"" + l + "\">Reply from the inbox</a></p>",
+	}
+
+	err := outbox.Queue(ctx, m)
+	return err
+}
+
+func ConfirmSubscription(ctx context.Context, s *models.Subscriber) error {
+	l := link("/subscribe/confirm?token=" + url.QueryEscape(s.ConfirmToken.String))
+
+	m := email.Email{
+		To: []string{
+			s.Email.String,
+		},
+		Subject: "Confirm your Revel Bus subscription",
+		Text:    "Please confirm you want to hear about upcoming Revel Bus trips: " + l + "\n\nIf you didn't sign up, ignore this email and you won't hear from us.",
+		HTML:    "<p>Please confirm you want to hear about upcoming Revel Bus trips: <a href=\"" + l + "\">" + l + "</a></p><p>If you didn't sign up, ignore this email and you won't hear from us.</p>",
+	}
+
+	err := outbox.Queue(ctx, m)
+	return err
+}
+
+// ContactReply answers a contact form message from the admin inbox.
+func ContactReply(ctx context.Context, msg *models.Message, subject string, body string) error {
+	m := email.Email{
+		To: []string{
+			msg.Email.String,
+		},
+		Subject: subject,
+		Text:    body + "\n\n--\nOn " + msg.Created.Format("Mon, Jan 2, 2006") + ", you wrote:\n" + msg.Body.String,
+		HTML:    "<p>" + strings.Replace(html.EscapeString(body), "\n", "<br>", -1) + "</p><hr><p>On " + msg.Created.Format("Mon, Jan 2, 2006") + ", you wrote:</p><blockquote>" + strings.Replace(html.EscapeString(msg.Body.String), "\n", "<br>", -1) + "</blockquote>",
+	}
+
+	err := outbox.Queue(ctx, m)
+	return err
+}
+
+// Newsletter sends body to one subscriber with their own unsubscribe and
+// preferences links added at the bottom.
+func Newsletter(ctx context.Context, s *models.Subscriber, subject string, body string) error {
+	token := url.QueryEscape(s.UnsubscribeToken.String)
+	prefs := link("/subscribe/preferences?token=" + token)
+	unsub := link("/unsubscribe?token=" + token)
+
+	m := email.Email{
+		To: []string{
+			s.Email.String,
+		},
+		Subject: subject,
+		Text:    sanitize.HTML(body) + "\n\n--\nUpdate your preferences: " + prefs + "\nUnsubscribe: " + unsub,
+		HTML:    body + "<hr><p><a href=\"" + prefs + "\">Update your preferences</a> | <a href=\"" + unsub + "\">Unsubscribe</a></p>",
+	}
+
+	err := outbox.Queue(ctx, m)
+	return err
+}
+
+// TripsDigest builds the body of a "new trips this month" newsletter.
+func TripsDigest(trips models.Trips) string {
+	if len(trips) == 0 {
+		return ""
+	}
+
+	b := "<h2>Upcoming trips this month</h2>"
+	for _, t := range trips {
+		l := link("/trip/" + t.Slug.String)
+		b += "<h3><a href=\"" + l + "\">" + html.EscapeString(t.Title.String) + "</a></h3>"
+		b += "<p><strong>" + t.Start.Format("Mon, Jan 2 at 3:04 PM") + "</strong></p>"
+		if t.Blurb.String != "" {
+			b += "<p>" + html.EscapeString(t.Blurb.String) + "</p>"
+		}
+	}
+	return b
+}
+
+// TripReminder tells a booked rider when and where to board. ics is the trip
+// as a calendar file so it can go straight into their calendar.
+func TripReminder(ctx context.Context, u *models.User, t *models.Trip, ics []byte) error {
+	l := link("/trip/" + t.Slug.String)
+	when := t.Start.Format("Mon, Jan 2 at 3:04 PM")
+
+	text := "Hi " + u.Name.String + ",\n\nThis is a reminder that " + t.Title.String + " departs " + when + "."
+	body := "<p>Hi " + html.EscapeString(u.Name.String) + ",</p><p>This is a reminder that <strong>" + html.EscapeString(t.Title.String) + "</strong> departs <strong>" + when + "</strong>.</p>"
+
+	if t.Pickup.String != "" {
+		text += "\n\nPickup:\n" + t.Pickup.String
+		body += "<p><strong>Pickup</strong><br>" + strings.Replace(html.EscapeString(t.Pickup.String), "\n", "<br>", -1) + "</p>"
+	}
+
+	text += "\n\nTrip details: " + l
+	body += "<p>Trip details: <a href=\"" + l + "\">" + l + "</a></p>"
+
+	m := email.Email{
+		To: []string{
+			u.Email.String,
+		},
+		Subject: "Reminder: " + t.Title.String + " is coming up",
+		Text:    text,
+		HTML:    body,
+		Attachments: []email.Attachment{
+			{
+				Name:        t.Slug.String + ".ics",
+				ContentType: "text/calendar",
+				Data:        ics,
+			},
+		},
+	}
+
+	err := outbox.Queue(ctx, m)
+	return err
+}
+
+// TripFollowUp thanks a rider after the trip, points them at the photos and
+// asks for a review.
+func TripFollowUp(ctx context.Context, u *models.User, t *models.Trip) error {
+	l := link("/trip/" + t.Slug.String)
+
+	review := viper.GetString("reviews.url")
+	if review == "" {
+		review = link("/contact")
+	}
+
+	text := "Hi " + u.Name.String + ",\n\nThanks for riding with us on " + t.Title.String + "!"
+	body := "<p>Hi " + html.EscapeString(u.Name.String) + ",</p><p>Thanks for riding with us on <strong>" + html.EscapeString(t.Title.String) + "</strong>!</p>"
+
+	if t.GalleryID.Valid {
+		text += "\n\nSee the photos from the trip: " + l
+		body += "<p><a href=\"" + l + "\">See the photos from the trip</a></p>"
+	}
+
+	text += "\n\nHow did we do? Leave us a review: " + review
+	body += "<p>How did we do? <a href=\"" + review + "\">Leave us a review</a></p>"
+
+	m := email.Email{
+		To: []string{
+			u.Email.String,
+		},
+		Subject: "Thanks for riding with Revel Bus",
+		Text:    text,
+		HTML:    body,
+	}
+
+	err := outbox.Queue(ctx, m)
+	return err
+}
+
+func VendorChangeSubmitted(ctx context.Context, v *models.Vendor, u *models.User) error {
+	l := link("/admin/vendor-changes")
+
+	m := email.Email{
+		Subject: "Vendor changes to review: " + v.Name.String,
+		Text:    u.Name.String + " (" + u.Email.String + ") submitted changes to " + v.Name.String + ".\n\nReview them here: " + l,
+		HTML:    "<p>" + html.EscapeString(u.Name.String) + " (" + html.EscapeString(u.Email.String) + ") submitted changes to " + html.EscapeString(v.Name.String) + ".</p><p><a href=\"" + l + "\">Review them</a></p>",
+	}
+
+	err := outbox.Queue(ctx, m)
+	return err
+}
+
+func VendorChangeReviewed(ctx context.Context, c *models.VendorChange) error {
+	l := link("/partner/vendor/" + strconv.Itoa(c.VendorID))
+
+	text := "Your changes to " + c.Name.String + " have been approved and are now live."
+	if c.Status.String == models.ChangeRejected {
+		text = "Your changes to " + c.Name.String + " were not approved."
+	}
+
+	note := ""
+	if c.Note.String != "" {
+		note = "\n\n" + c.Note.String
+	}
+
+	m := email.Email{
+		To: []string{
+			c.User.Email.String,
+		},
+		Subject: "Your vendor changes were " + c.Status.String,
+		Text:    text + note + "\n\n" + l,
+		HTML:    "<p>" + html.EscapeString(text) + "</p>" + strings.Replace(html.EscapeString(note), "\n", "<br>", -1) + "<p><a href=\"" + l + "\">View your listing</a></p>",
 	}
 
-	err := email.Send(m)
+	err := outbox.Queue(ctx, m)
 	return err
 }
@@ -5,6 +5,8 @@ type ContactForm struct {
 	Phone   string
 	Email   string
 	Message string
+	Stamp   string
+	Website string
 	Errors  map[string]string
 }
 
@@ -13,6 +15,7 @@ func (f *ContactForm) Valid() bool {
 
 	v.Required("Name", f.Name)
 	v.Required("Email", f.Email)
+	v.ValidEmail("Email", f.Email)
 	v.Required("Message", f.Message)
 
 	f.Errors = v.Errors
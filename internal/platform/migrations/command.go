@@ -0,0 +1,63 @@
+package migrations
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var ErrUsage = errors.New("usage: revelbus migrate up|down|status")
+
+// Command runs `revelbus migrate up|down|status`, writing what it did to out.
+func Command(db *sql.DB, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return ErrUsage
+	}
+
+	switch args[0] {
+	case "up":
+		ms, err := Up(db)
+		for _, m := range ms {
+			fmt.Fprintf(out, "applied  %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(ms) == 0 {
+			fmt.Fprintln(out, "database is up to date")
+		}
+
+	case "down":
+		m, err := Down(db)
+		if err != nil {
+			return err
+		}
+
+		if m == nil {
+			fmt.Fprintln(out, "nothing to roll back")
+			return nil
+		}
+		fmt.Fprintf(out, "reverted %04d_%s\n", m.Version, m.Name)
+
+	case "status":
+		statuses, err := Statuses(db)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range statuses {
+			if s.Applied() {
+				fmt.Fprintf(out, "%04d_%s  applied %s\n", s.Version, s.Name, s.AppliedAt.Time.Format("2006-01-02 15:04:05"))
+			} else {
+				fmt.Fprintf(out, "%04d_%s  pending\n", s.Version, s.Name)
+			}
+		}
+
+	default:
+		return ErrUsage
+	}
+
+	return nil
+}
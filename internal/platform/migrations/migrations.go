@@ -0,0 +1,375 @@
+// Package migrations keeps the database schema in step with the code. Each
+// change is a pair of files in sql/, NNNN_name.up.sql and
+// NNNN_name.down.sql, built into the binary and recorded in the
+// schema_migrations table once applied.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+const lockName = "revelbus.migrations"
+
+var (
+	ErrOutdated = errors.New("migrations: database schema is out of date, run `revelbus migrate up`")
+	ErrUnknown  = errors.New("migrations: database has migrations this build doesn't know about")
+	ErrLocked   = errors.New("migrations: another migration is running")
+)
+
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+type Status struct {
+	Migration
+	AppliedAt mysql.NullTime
+}
+
+func (s *Status) Applied() bool {
+	return s.AppliedAt.Valid
+}
+
+// All returns every migration built in, oldest first.
+func All() ([]*Migration, error) {
+	entries, err := files.ReadDir("sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		name := e.Name()
+
+		var dir string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			dir = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			dir = "down"
+		default:
+			return nil, fmt.Errorf("migrations: %s isn't an up or down file", name)
+		}
+
+		base := strings.TrimSuffix(name, "."+dir+".sql")
+		parts := strings.SplitN(base, "_", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("migrations: %s isn't named NNNN_name", name)
+		}
+
+		version, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("migrations: %s isn't named NNNN_name", name)
+		}
+
+		b, err := files.ReadFile(path.Join("sql", name))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: parts[1]}
+			byVersion[version] = m
+		} else if m.Name != parts[1] {
+			return nil, fmt.Errorf("migrations: version %d is used by %s and %s", version, m.Name, parts[1])
+		}
+
+		if dir == "up" {
+			m.up = string(b)
+		} else {
+			m.down = string(b)
+		}
+	}
+
+	ms := []*Migration{}
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migrations: %04d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		ms = append(ms, m)
+	}
+
+	sort.Slice(ms, func(i, j int) bool {
+		return ms[i].Version < ms[j].Version
+	})
+
+	return ms, nil
+}
+
+// Up applies every migration the database doesn't have yet, in order, and
+// returns the ones it applied.
+func Up(db *sql.DB) ([]*Migration, error) {
+	ms, err := All()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	conn, err := lock(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock(ctx, conn)
+
+	applied, err := fetchApplied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	done := []*Migration{}
+	for _, m := range ms {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+
+		err = run(ctx, conn, m.up)
+		if err != nil {
+			return done, fmt.Errorf("migrations: %04d_%s up : %v", m.Version, m.Name, err)
+		}
+
+		_, err = conn.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES(?, ?, UTC_TIMESTAMP())`, m.Version, m.Name)
+		if err != nil {
+			return done, err
+		}
+
+		done = append(done, m)
+	}
+
+	return done, nil
+}
+
+// Down reverses the most recently applied migration and returns it, or nil
+// if there's nothing to reverse.
+func Down(db *sql.DB) (*Migration, error) {
+	ms, err := All()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	conn, err := lock(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock(ctx, conn)
+
+	var version int
+	err = conn.QueryRowContext(ctx, `SELECT IFNULL(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return nil, err
+	}
+
+	if version == 0 {
+		return nil, nil
+	}
+
+	var m *Migration
+	for _, mm := range ms {
+		if mm.Version == version {
+			m = mm
+		}
+	}
+
+	if m == nil {
+		return nil, ErrUnknown
+	}
+
+	err = run(ctx, conn, m.down)
+	if err != nil {
+		return nil, fmt.Errorf("migrations: %04d_%s down : %v", m.Version, m.Name, err)
+	}
+
+	_, err = conn.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = ?`, m.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Statuses lists every migration built in and when it was applied.
+func Statuses(db *sql.DB) ([]*Status, error) {
+	ms, err := All()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	applied, err := fetchApplied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []*Status{}
+	for _, m := range ms {
+		statuses = append(statuses, &Status{
+			Migration: *m,
+			AppliedAt: applied[m.Version],
+		})
+	}
+
+	return statuses, nil
+}
+
+// Check returns ErrOutdated if the database is missing any migration, or
+// ErrUnknown if it has one from a newer build.
+func Check(db *sql.DB) error {
+	ms, err := All()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	applied, err := fetchApplied(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	known := map[int]bool{}
+	for _, m := range ms {
+		if _, ok := applied[m.Version]; !ok {
+			return ErrOutdated
+		}
+		known[m.Version] = true
+	}
+
+	for version := range applied {
+		if !known[version] {
+			return ErrUnknown
+		}
+	}
+
+	return nil
+}
+
+func fetchApplied(ctx context.Context, conn *sql.Conn) (map[int]mysql.NullTime, error) {
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+  version INT(11) NOT NULL,
+  name VARCHAR(255) NOT NULL,
+  applied_at DATETIME NOT NULL,
+  PRIMARY KEY (version))
+ENGINE = InnoDB
+DEFAULT CHARACTER SET = utf8mb4
+COLLATE = utf8mb4_unicode_ci`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]mysql.NullTime{}
+	for rows.Next() {
+		var version int
+		var at mysql.NullTime
+
+		err = rows.Scan(&version, &at)
+		if err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+
+	return applied, rows.Err()
+}
+
+// run executes a migration file a statement at a time. MySQL commits schema
+// changes as it goes, so a file that fails partway has to be fixed by hand;
+// keep each migration small.
+func run(ctx context.Context, conn *sql.Conn, file string) error {
+	for _, stmt := range statements(file) {
+		_, err := conn.ExecContext(ctx, stmt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// statements splits a file into statements on lines ending in a semicolon,
+// dropping comment lines.
+func statements(file string) []string {
+	stmts := []string{}
+	lines := []string{}
+
+	for _, line := range strings.Split(file, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		lines = append(lines, line)
+		if strings.HasSuffix(trimmed, ";") {
+			stmt := strings.TrimSuffix(strings.TrimSpace(strings.Join(lines, "\n")), ";")
+			stmts = append(stmts, stmt)
+			lines = []string{}
+		}
+	}
+
+	if len(lines) > 0 {
+		stmts = append(stmts, strings.Join(lines, "\n"))
+	}
+
+	return stmts
+}
+
+// lock takes a connection and holds a named lock on it so two deploys can't
+// migrate at once. Settings like FOREIGN_KEY_CHECKS also only last for the
+// connection, so everything in a migration runs on this one.
+func lock(ctx context.Context, db *sql.DB) (*sql.Conn, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var got sql.NullInt64
+	err = conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, 10)`, lockName).Scan(&got)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if got.Int64 != 1 {
+		conn.Close()
+		return nil, ErrLocked
+	}
+
+	return conn, nil
+}
+
+func unlock(ctx context.Context, conn *sql.Conn) {
+	conn.ExecContext(ctx, `SELECT RELEASE_LOCK(?)`, lockName)
+	conn.Close()
+}
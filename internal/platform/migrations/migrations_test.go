@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAll(t *testing.T) {
+	ms, err := All()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, m := range ms {
+		if m.Version != i+1 {
+			t.Fatalf("migration %d is numbered %04d_%s; numbers shouldn't skip", i+1, m.Version, m.Name)
+		}
+
+		if len(statements(m.up)) == 0 || len(statements(m.down)) == 0 {
+			t.Errorf("%04d_%s has an empty up or down file", m.Version, m.Name)
+		}
+	}
+}
+
+func TestStatements(t *testing.T) {
+	file := "-- a comment\nCREATE TABLE `a` (\n  `id` INT(11));\n\nDROP TABLE `b`;\n"
+
+	got := statements(file)
+	want := []string{"CREATE TABLE `a` (\n  `id` INT(11))", "DROP TABLE `b`"}
+
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,15 @@
+SET FOREIGN_KEY_CHECKS = 0;
+
+DROP TABLE IF EXISTS `users`;
+DROP TABLE IF EXISTS `trips_venues`;
+DROP TABLE IF EXISTS `trips_partners`;
+DROP TABLE IF EXISTS `vendors`;
+DROP TABLE IF EXISTS `trips`;
+DROP TABLE IF EXISTS `slides`;
+DROP TABLE IF EXISTS `settings`;
+DROP TABLE IF EXISTS `galleries_images`;
+DROP TABLE IF EXISTS `galleries`;
+DROP TABLE IF EXISTS `files`;
+DROP TABLE IF EXISTS `faqs`;
+
+SET FOREIGN_KEY_CHECKS = 1;
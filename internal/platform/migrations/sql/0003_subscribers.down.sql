@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS `subscribers_interests`;
+DROP TABLE IF EXISTS `subscribers`;
+
+ALTER TABLE `trips` DROP COLUMN `category`;
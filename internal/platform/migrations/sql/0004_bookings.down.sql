@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS `trip_notifications`;
+DROP TABLE IF EXISTS `bookings`;
+
+ALTER TABLE `email_outbox` DROP COLUMN `attachments`;
+ALTER TABLE `trips` DROP COLUMN `pickup`, DROP COLUMN `remind`, DROP COLUMN `follow_up`;
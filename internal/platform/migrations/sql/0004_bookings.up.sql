@@ -0,0 +1,60 @@
+ALTER TABLE `trips`
+  ADD COLUMN `pickup` TEXT CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NULL DEFAULT NULL AFTER `notes`,
+  ADD COLUMN `remind` TINYINT(1) NULL DEFAULT '1' AFTER `pickup`,
+  ADD COLUMN `follow_up` TINYINT(1) NULL DEFAULT '1' AFTER `remind`;
+
+ALTER TABLE `email_outbox`
+  ADD COLUMN `attachments` MEDIUMTEXT CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NULL DEFAULT NULL AFTER `html_body`;
+
+
+-- -----------------------------------------------------
+-- Table `bookings`
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS `bookings` (
+  `id` INT(11) NOT NULL AUTO_INCREMENT,
+  `trip_id` INT(11) NOT NULL,
+  `user_id` INT(11) NOT NULL,
+  `seats` INT(11) NOT NULL DEFAULT '1',
+  `status` VARCHAR(45) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NOT NULL DEFAULT 'booked',
+  `created_at` DATETIME NULL DEFAULT NULL,
+  `updated_at` DATETIME NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `trip_user_UNIQUE` (`trip_id` ASC, `user_id` ASC),
+  INDEX `user_id_booking_idx` (`user_id` ASC),
+  CONSTRAINT `trip_id_booking`
+    FOREIGN KEY (`trip_id`)
+    REFERENCES `trips` (`id`)
+    ON DELETE CASCADE
+    ON UPDATE NO ACTION,
+  CONSTRAINT `user_id_booking`
+    FOREIGN KEY (`user_id`)
+    REFERENCES `users` (`id`)
+    ON DELETE CASCADE
+    ON UPDATE NO ACTION)
+ENGINE = InnoDB
+DEFAULT CHARACTER SET = utf8mb4
+COLLATE = utf8mb4_unicode_ci;
+
+
+-- -----------------------------------------------------
+-- Table `trip_notifications`
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS `trip_notifications` (
+  `trip_id` INT(11) NOT NULL,
+  `user_id` INT(11) NOT NULL,
+  `kind` VARCHAR(45) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NOT NULL,
+  `sent_at` DATETIME NULL DEFAULT NULL,
+  PRIMARY KEY (`trip_id`, `user_id`, `kind`),
+  CONSTRAINT `trip_id_notification`
+    FOREIGN KEY (`trip_id`)
+    REFERENCES `trips` (`id`)
+    ON DELETE CASCADE
+    ON UPDATE NO ACTION,
+  CONSTRAINT `user_id_notification`
+    FOREIGN KEY (`user_id`)
+    REFERENCES `users` (`id`)
+    ON DELETE CASCADE
+    ON UPDATE NO ACTION)
+ENGINE = InnoDB
+DEFAULT CHARACTER SET = utf8mb4
+COLLATE = utf8mb4_unicode_ci;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS `password_resets`;
+
+ALTER TABLE `users`
+  ADD COLUMN `recovery_hash` VARCHAR(25) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NULL DEFAULT NULL AFTER `password`;
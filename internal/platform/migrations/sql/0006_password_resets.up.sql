@@ -0,0 +1,24 @@
+-- resets are looked up by a hashed token in their own table now
+ALTER TABLE `users` DROP COLUMN `recovery_hash`;
+
+
+-- -----------------------------------------------------
+-- Table `password_resets`
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS `password_resets` (
+  `id` INT(11) NOT NULL AUTO_INCREMENT,
+  `user_id` INT(11) NOT NULL,
+  `token_hash` CHAR(64) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NOT NULL,
+  `expires_at` DATETIME NOT NULL,
+  `used_at` DATETIME NULL DEFAULT NULL,
+  `created_at` DATETIME NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  INDEX `user_id_reset_idx` (`user_id` ASC),
+  CONSTRAINT `user_id_reset`
+    FOREIGN KEY (`user_id`)
+    REFERENCES `users` (`id`)
+    ON DELETE CASCADE
+    ON UPDATE NO ACTION)
+ENGINE = InnoDB
+DEFAULT CHARACTER SET = utf8mb4
+COLLATE = utf8mb4_unicode_ci;
@@ -0,0 +1,37 @@
+-- -----------------------------------------------------
+-- Table `sessions`
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS `sessions` (
+  `token` CHAR(43) NOT NULL,
+  `data` BLOB NOT NULL,
+  `expiry` TIMESTAMP(6) NOT NULL,
+  PRIMARY KEY (`token`),
+  INDEX `sessions_expiry_idx` (`expiry` ASC))
+ENGINE = InnoDB
+DEFAULT CHARACTER SET = utf8mb4
+COLLATE = utf8mb4_unicode_ci;
+
+
+-- -----------------------------------------------------
+-- Table `user_sessions`
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS `user_sessions` (
+  `id` INT(11) NOT NULL AUTO_INCREMENT,
+  `token` VARCHAR(64) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NOT NULL,
+  `user_id` INT(11) NOT NULL,
+  `user_agent` VARCHAR(512) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NULL DEFAULT NULL,
+  `ip` VARCHAR(45) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NULL DEFAULT NULL,
+  `last_seen_at` DATETIME NULL DEFAULT NULL,
+  `created_at` DATETIME NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `token_UNIQUE` (`token` ASC),
+  INDEX `user_id_session_idx` (`user_id` ASC),
+  INDEX `created_at_session_idx` (`created_at` ASC),
+  CONSTRAINT `user_id_session`
+    FOREIGN KEY (`user_id`)
+    REFERENCES `users` (`id`)
+    ON DELETE CASCADE
+    ON UPDATE NO ACTION)
+ENGINE = InnoDB
+DEFAULT CHARACTER SET = utf8mb4
+COLLATE = utf8mb4_unicode_ci;
@@ -0,0 +1 @@
+ALTER TABLE `users` DROP COLUMN `failed_logins`, DROP COLUMN `last_failed_at`, DROP COLUMN `locked_until`;
@@ -0,0 +1,4 @@
+ALTER TABLE `users`
+  ADD COLUMN `failed_logins` INT(11) NOT NULL DEFAULT '0' AFTER `role`,
+  ADD COLUMN `last_failed_at` DATETIME NULL DEFAULT NULL AFTER `failed_logins`,
+  ADD COLUMN `locked_until` DATETIME NULL DEFAULT NULL AFTER `last_failed_at`;
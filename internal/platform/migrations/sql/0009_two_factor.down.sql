@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS `recovery_codes`;
+
+ALTER TABLE `users` DROP COLUMN `totp_secret`, DROP COLUMN `totp_enabled`, DROP COLUMN `totp_last_step`;
+ALTER TABLE `settings` DROP COLUMN `require_admin_2fa`;
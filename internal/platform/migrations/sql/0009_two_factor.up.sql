@@ -0,0 +1,28 @@
+ALTER TABLE `settings`
+  ADD COLUMN `require_admin_2fa` TINYINT(1) NOT NULL DEFAULT '0' AFTER `home_gallery_active`;
+
+ALTER TABLE `users`
+  ADD COLUMN `totp_secret` VARCHAR(64) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NULL DEFAULT NULL AFTER `role`,
+  ADD COLUMN `totp_enabled` TINYINT(1) NOT NULL DEFAULT '0' AFTER `totp_secret`,
+  ADD COLUMN `totp_last_step` BIGINT(20) NULL DEFAULT NULL AFTER `totp_enabled`;
+
+
+-- -----------------------------------------------------
+-- Table `recovery_codes`
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS `recovery_codes` (
+  `id` INT(11) NOT NULL AUTO_INCREMENT,
+  `user_id` INT(11) NOT NULL,
+  `code_hash` CHAR(64) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NOT NULL,
+  `used_at` DATETIME NULL DEFAULT NULL,
+  `created_at` DATETIME NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  INDEX `user_id_code_idx` (`user_id` ASC, `code_hash` ASC),
+  CONSTRAINT `user_id_recovery_code`
+    FOREIGN KEY (`user_id`)
+    REFERENCES `users` (`id`)
+    ON DELETE CASCADE
+    ON UPDATE NO ACTION)
+ENGINE = InnoDB
+DEFAULT CHARACTER SET = utf8mb4
+COLLATE = utf8mb4_unicode_ci;
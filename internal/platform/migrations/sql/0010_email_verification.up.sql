@@ -0,0 +1,27 @@
+ALTER TABLE `users`
+  ADD COLUMN `email_verified_at` DATETIME NULL DEFAULT NULL AFTER `totp_last_step`;
+
+-- accounts from before verification existed can still book
+UPDATE `users` SET `email_verified_at` = UTC_TIMESTAMP();
+
+
+-- -----------------------------------------------------
+-- Table `email_verifications`
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS `email_verifications` (
+  `id` INT(11) NOT NULL AUTO_INCREMENT,
+  `user_id` INT(11) NOT NULL,
+  `email` VARCHAR(255) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NOT NULL,
+  `token_hash` CHAR(64) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NOT NULL,
+  `expires_at` DATETIME NOT NULL,
+  `created_at` DATETIME NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  INDEX `user_id_verification_idx` (`user_id` ASC),
+  CONSTRAINT `user_id_verification`
+    FOREIGN KEY (`user_id`)
+    REFERENCES `users` (`id`)
+    ON DELETE CASCADE
+    ON UPDATE NO ACTION)
+ENGINE = InnoDB
+DEFAULT CHARACTER SET = utf8mb4
+COLLATE = utf8mb4_unicode_ci;
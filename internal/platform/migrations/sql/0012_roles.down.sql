@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS `role_permissions`;
+DROP TABLE IF EXISTS `roles`;
+
+ALTER TABLE `bookings` DROP COLUMN `checked_in_at`;
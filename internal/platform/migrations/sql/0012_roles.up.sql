@@ -0,0 +1,55 @@
+ALTER TABLE `bookings`
+  ADD COLUMN `checked_in_at` DATETIME NULL DEFAULT NULL AFTER `status`;
+
+
+-- -----------------------------------------------------
+-- Table `roles`
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS `roles` (
+  `id` INT(11) NOT NULL AUTO_INCREMENT,
+  `name` VARCHAR(45) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NOT NULL,
+  `label` VARCHAR(100) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NULL DEFAULT NULL,
+  `created_at` DATETIME NULL DEFAULT NULL,
+  `updated_at` DATETIME NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `name_UNIQUE` (`name` ASC))
+ENGINE = InnoDB
+DEFAULT CHARACTER SET = utf8mb4
+COLLATE = utf8mb4_unicode_ci;
+
+
+-- -----------------------------------------------------
+-- Table `role_permissions`
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS `role_permissions` (
+  `role_id` INT(11) NOT NULL,
+  `permission` VARCHAR(45) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NOT NULL,
+  PRIMARY KEY (`role_id`, `permission`),
+  CONSTRAINT `role_id_permission`
+    FOREIGN KEY (`role_id`)
+    REFERENCES `roles` (`id`)
+    ON DELETE CASCADE
+    ON UPDATE NO ACTION)
+ENGINE = InnoDB
+DEFAULT CHARACTER SET = utf8mb4
+COLLATE = utf8mb4_unicode_ci;
+
+
+-- -----------------------------------------------------
+-- Default roles
+-- -----------------------------------------------------
+INSERT IGNORE INTO `roles` (`name`, `label`, `created_at`, `updated_at`) VALUES
+  ('admin', 'Admin', UTC_TIMESTAMP(), UTC_TIMESTAMP()),
+  ('user', 'User', UTC_TIMESTAMP(), UTC_TIMESTAMP()),
+  ('editor', 'Editor', UTC_TIMESTAMP(), UTC_TIMESTAMP()),
+  ('driver', 'Driver', UTC_TIMESTAMP(), UTC_TIMESTAMP()),
+  ('vendor-manager', 'Vendor Manager', UTC_TIMESTAMP(), UTC_TIMESTAMP());
+
+INSERT IGNORE INTO `role_permissions` (`role_id`, `permission`)
+  SELECT `id`, 'trips.manage' FROM `roles` WHERE `name` = 'editor' UNION ALL
+  SELECT `id`, 'manifests.view' FROM `roles` WHERE `name` = 'editor' UNION ALL
+  SELECT `id`, 'faqs.manage' FROM `roles` WHERE `name` = 'editor' UNION ALL
+  SELECT `id`, 'content.manage' FROM `roles` WHERE `name` = 'editor' UNION ALL
+  SELECT `id`, 'manifests.view' FROM `roles` WHERE `name` = 'driver' UNION ALL
+  SELECT `id`, 'riders.checkin' FROM `roles` WHERE `name` = 'driver' UNION ALL
+  SELECT `id`, 'vendors.manage' FROM `roles` WHERE `name` = 'vendor-manager';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `vendor_changes`;
+DROP TABLE IF EXISTS `vendor_users`;
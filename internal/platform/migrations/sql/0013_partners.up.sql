@@ -0,0 +1,64 @@
+-- -----------------------------------------------------
+-- Table `vendor_users`
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS `vendor_users` (
+  `vendor_id` INT(11) NOT NULL,
+  `user_id` INT(11) NOT NULL,
+  `created_at` DATETIME NULL DEFAULT NULL,
+  PRIMARY KEY (`vendor_id`, `user_id`),
+  INDEX `user_id_vendor_idx` (`user_id` ASC),
+  CONSTRAINT `vendor_id_user`
+    FOREIGN KEY (`vendor_id`)
+    REFERENCES `vendors` (`id`)
+    ON DELETE CASCADE
+    ON UPDATE NO ACTION,
+  CONSTRAINT `user_id_vendor`
+    FOREIGN KEY (`user_id`)
+    REFERENCES `users` (`id`)
+    ON DELETE CASCADE
+    ON UPDATE NO ACTION)
+ENGINE = InnoDB
+DEFAULT CHARACTER SET = utf8mb4
+COLLATE = utf8mb4_unicode_ci;
+
+
+-- -----------------------------------------------------
+-- Table `vendor_changes`
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS `vendor_changes` (
+  `id` INT(11) NOT NULL AUTO_INCREMENT,
+  `vendor_id` INT(11) NOT NULL,
+  `user_id` INT(11) NULL DEFAULT NULL,
+  `name` VARCHAR(255) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NULL DEFAULT NULL,
+  `address` VARCHAR(255) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NULL DEFAULT NULL,
+  `city` VARCHAR(45) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NULL DEFAULT NULL,
+  `state` VARCHAR(45) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NULL DEFAULT NULL,
+  `zip` VARCHAR(45) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NULL DEFAULT NULL,
+  `phone` VARCHAR(45) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NULL DEFAULT NULL,
+  `email` VARCHAR(45) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NULL DEFAULT NULL,
+  `url` VARCHAR(255) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NULL DEFAULT NULL,
+  `brand_id` INT(11) NULL DEFAULT NULL,
+  `status` VARCHAR(45) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NOT NULL DEFAULT 'pending',
+  `note` TEXT CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NULL DEFAULT NULL,
+  `reviewed_at` DATETIME NULL DEFAULT NULL,
+  `created_at` DATETIME NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  INDEX `vendor_status_idx` (`vendor_id` ASC, `status` ASC),
+  CONSTRAINT `vendor_id_change`
+    FOREIGN KEY (`vendor_id`)
+    REFERENCES `vendors` (`id`)
+    ON DELETE CASCADE
+    ON UPDATE NO ACTION,
+  CONSTRAINT `user_id_vendor_change`
+    FOREIGN KEY (`user_id`)
+    REFERENCES `users` (`id`)
+    ON DELETE SET NULL
+    ON UPDATE NO ACTION,
+  CONSTRAINT `brand_id_vendor_change`
+    FOREIGN KEY (`brand_id`)
+    REFERENCES `files` (`id`)
+    ON DELETE SET NULL
+    ON UPDATE NO ACTION)
+ENGINE = InnoDB
+DEFAULT CHARACTER SET = utf8mb4
+COLLATE = utf8mb4_unicode_ci;
@@ -0,0 +1,22 @@
+-- -----------------------------------------------------
+-- Table `user_identities`
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS `user_identities` (
+  `id` INT(11) NOT NULL AUTO_INCREMENT,
+  `user_id` INT(11) NOT NULL,
+  `issuer` VARCHAR(255) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NOT NULL,
+  `subject` VARCHAR(255) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NOT NULL,
+  `email` VARCHAR(255) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NULL DEFAULT NULL,
+  `created_at` DATETIME NULL DEFAULT NULL,
+  `last_login_at` DATETIME NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `issuer_subject_UNIQUE` (`issuer` ASC, `subject` ASC),
+  INDEX `user_id_identity_idx` (`user_id` ASC),
+  CONSTRAINT `user_id_identity`
+    FOREIGN KEY (`user_id`)
+    REFERENCES `users` (`id`)
+    ON DELETE CASCADE
+    ON UPDATE NO ACTION)
+ENGINE = InnoDB
+DEFAULT CHARACTER SET = utf8mb4
+COLLATE = utf8mb4_unicode_ci;
@@ -0,0 +1,27 @@
+-- -----------------------------------------------------
+-- Table `impersonations`
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS `impersonations` (
+  `id` INT(11) NOT NULL AUTO_INCREMENT,
+  `admin_id` INT(11) NULL DEFAULT NULL,
+  `user_id` INT(11) NOT NULL,
+  `ip` VARCHAR(45) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NULL DEFAULT NULL,
+  `user_agent` VARCHAR(512) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NULL DEFAULT NULL,
+  `started_at` DATETIME NOT NULL,
+  `ended_at` DATETIME NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  INDEX `user_id_impersonation_idx` (`user_id` ASC, `started_at` ASC),
+  INDEX `admin_id_impersonation_idx` (`admin_id` ASC),
+  CONSTRAINT `admin_id_impersonation`
+    FOREIGN KEY (`admin_id`)
+    REFERENCES `users` (`id`)
+    ON DELETE SET NULL
+    ON UPDATE NO ACTION,
+  CONSTRAINT `user_id_impersonation`
+    FOREIGN KEY (`user_id`)
+    REFERENCES `users` (`id`)
+    ON DELETE CASCADE
+    ON UPDATE NO ACTION)
+ENGINE = InnoDB
+DEFAULT CHARACTER SET = utf8mb4
+COLLATE = utf8mb4_unicode_ci;
@@ -0,0 +1,12 @@
+-- anything still in the trash would come back, so it goes for good first
+DELETE FROM `trips` WHERE `deleted_at` IS NOT NULL;
+DELETE FROM `faqs` WHERE `deleted_at` IS NOT NULL;
+DELETE FROM `galleries` WHERE `deleted_at` IS NOT NULL;
+DELETE FROM `users` WHERE `deleted_at` IS NOT NULL;
+DELETE FROM `vendors` WHERE `deleted_at` IS NOT NULL;
+
+ALTER TABLE `trips` DROP INDEX `deleted_at_idx`, DROP COLUMN `deleted_at`;
+ALTER TABLE `vendors` DROP INDEX `deleted_at_idx`, DROP COLUMN `deleted_at`;
+ALTER TABLE `faqs` DROP INDEX `deleted_at_idx`, DROP COLUMN `deleted_at`;
+ALTER TABLE `galleries` DROP INDEX `deleted_at_idx`, DROP COLUMN `deleted_at`;
+ALTER TABLE `users` DROP INDEX `deleted_at_idx`, DROP COLUMN `deleted_at`;
@@ -0,0 +1,23 @@
+-- -----------------------------------------------------
+-- Deleted rows stay in their tables until the trash is
+-- emptied, marked by `deleted_at`
+-- -----------------------------------------------------
+ALTER TABLE `trips`
+  ADD COLUMN `deleted_at` DATETIME NULL DEFAULT NULL,
+  ADD INDEX `deleted_at_idx` (`deleted_at` ASC);
+
+ALTER TABLE `vendors`
+  ADD COLUMN `deleted_at` DATETIME NULL DEFAULT NULL,
+  ADD INDEX `deleted_at_idx` (`deleted_at` ASC);
+
+ALTER TABLE `faqs`
+  ADD COLUMN `deleted_at` DATETIME NULL DEFAULT NULL,
+  ADD INDEX `deleted_at_idx` (`deleted_at` ASC);
+
+ALTER TABLE `galleries`
+  ADD COLUMN `deleted_at` DATETIME NULL DEFAULT NULL,
+  ADD INDEX `deleted_at_idx` (`deleted_at` ASC);
+
+ALTER TABLE `users`
+  ADD COLUMN `deleted_at` DATETIME NULL DEFAULT NULL,
+  ADD INDEX `deleted_at_idx` (`deleted_at` ASC);
@@ -0,0 +1,22 @@
+-- -----------------------------------------------------
+-- Table `revisions`
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS `revisions` (
+  `id` INT(11) NOT NULL AUTO_INCREMENT,
+  `entity_type` VARCHAR(32) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NOT NULL,
+  `entity_id` INT(11) NOT NULL,
+  `content` MEDIUMTEXT CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NOT NULL,
+  `actor_id` INT(11) NULL DEFAULT NULL,
+  `actor_name` VARCHAR(255) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NULL DEFAULT NULL,
+  `created_at` DATETIME NOT NULL,
+  PRIMARY KEY (`id`),
+  INDEX `entity_revision_idx` (`entity_type` ASC, `entity_id` ASC, `id` ASC),
+  INDEX `actor_id_revision_idx` (`actor_id` ASC),
+  CONSTRAINT `actor_id_revision`
+    FOREIGN KEY (`actor_id`)
+    REFERENCES `users` (`id`)
+    ON DELETE SET NULL
+    ON UPDATE NO ACTION)
+ENGINE = InnoDB
+DEFAULT CHARACTER SET = utf8mb4
+COLLATE = utf8mb4_unicode_ci;
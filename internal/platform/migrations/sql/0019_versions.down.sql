@@ -0,0 +1,8 @@
+ALTER TABLE `trips` DROP COLUMN `version`;
+ALTER TABLE `vendors` DROP COLUMN `version`;
+ALTER TABLE `faqs` DROP COLUMN `version`;
+ALTER TABLE `slides` DROP COLUMN `version`;
+ALTER TABLE `galleries` DROP COLUMN `version`;
+ALTER TABLE `settings` DROP COLUMN `version`;
+ALTER TABLE `users` DROP COLUMN `version`;
+ALTER TABLE `roles` DROP COLUMN `version`;
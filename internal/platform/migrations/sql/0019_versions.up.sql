@@ -0,0 +1,27 @@
+-- -----------------------------------------------------
+-- `version` goes up with every save, so an edit made to
+-- an out of date copy can be turned away
+-- -----------------------------------------------------
+ALTER TABLE `trips`
+  ADD COLUMN `version` INT(11) NOT NULL DEFAULT 1;
+
+ALTER TABLE `vendors`
+  ADD COLUMN `version` INT(11) NOT NULL DEFAULT 1;
+
+ALTER TABLE `faqs`
+  ADD COLUMN `version` INT(11) NOT NULL DEFAULT 1;
+
+ALTER TABLE `slides`
+  ADD COLUMN `version` INT(11) NOT NULL DEFAULT 1;
+
+ALTER TABLE `galleries`
+  ADD COLUMN `version` INT(11) NOT NULL DEFAULT 1;
+
+ALTER TABLE `settings`
+  ADD COLUMN `version` INT(11) NOT NULL DEFAULT 1;
+
+ALTER TABLE `users`
+  ADD COLUMN `version` INT(11) NOT NULL DEFAULT 1;
+
+ALTER TABLE `roles`
+  ADD COLUMN `version` INT(11) NOT NULL DEFAULT 1;
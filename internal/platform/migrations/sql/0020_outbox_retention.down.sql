@@ -0,0 +1 @@
+ALTER TABLE `email_outbox` DROP INDEX `status_sent_idx`;
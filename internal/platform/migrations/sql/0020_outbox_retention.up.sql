@@ -0,0 +1,10 @@
+-- -----------------------------------------------------
+-- Sent mail no longer keeps its bodies, and is deleted
+-- once it's older than outbox.keep_sent
+-- -----------------------------------------------------
+UPDATE `email_outbox`
+  SET `text_body` = NULL, `html_body` = NULL, `attachments` = NULL
+  WHERE `status` = 'sent';
+
+ALTER TABLE `email_outbox`
+  ADD INDEX `status_sent_idx` (`status` ASC, `sent_at` ASC);
@@ -0,0 +1 @@
+ALTER TABLE `email_outbox` DROP INDEX `claimed_by_idx`, DROP COLUMN `claimed_at`, DROP COLUMN `claimed_by`;
@@ -0,0 +1,8 @@
+-- -----------------------------------------------------
+-- A worker claims the mail it's about to send, so no
+-- other worker sends it too
+-- -----------------------------------------------------
+ALTER TABLE `email_outbox`
+  ADD COLUMN `claimed_by` VARCHAR(64) CHARACTER SET 'utf8mb4' COLLATE 'utf8mb4_unicode_ci' NULL DEFAULT NULL AFTER `next_attempt_at`,
+  ADD COLUMN `claimed_at` DATETIME NULL DEFAULT NULL AFTER `claimed_by`,
+  ADD INDEX `claimed_by_idx` (`claimed_by` ASC);
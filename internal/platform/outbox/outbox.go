@@ -0,0 +1,237 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"revelbus/internal/platform/domain/models"
+	"revelbus/internal/platform/domain/store"
+	"revelbus/pkg/email"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	batchSize   = 20
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 6 * time.Hour
+)
+
+var (
+	wake    = make(chan struct{}, 1)
+	quit    chan struct{}
+	done    chan struct{}
+	cancel  context.CancelFunc
+	started bool
+	mu      sync.Mutex
+)
+
+// db is where Queue keeps messages. The worker reads them back from MySQL,
+// so UseStore only suits tests that don't run it.
+var db store.OutboxStore = store.NewMySQL().Outbox
+
+func UseStore(s store.OutboxStore) {
+	db = s
+}
+
+// Queue stores the message in the outbox and nudges the worker so it goes
+// out without waiting for the next poll.
+func Queue(ctx context.Context, e email.Email) error {
+	m := &models.OutboxEmail{
+		To:      nullStr(strings.Join(e.To, ",")),
+		Subject: nullStr(e.Subject),
+		Text:    nullStr(e.Text),
+		HTML:    nullStr(e.HTML),
+	}
+
+	if len(e.Attachments) > 0 {
+		b, err := json.Marshal(e.Attachments)
+		if err != nil {
+			return err
+		}
+		m.Attachments = nullStr(string(b))
+	}
+
+	err := db.Create(ctx, m)
+	if err != nil {
+		return err
+	}
+
+	Wake()
+	return nil
+}
+
+func Wake() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the delivery worker in the background until Stop is called.
+func Start() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if started {
+		return
+	}
+	started = true
+
+	// a worker that was stopped has closed these, so each run gets its own
+	quit = make(chan struct{})
+	done = make(chan struct{})
+
+	var ctx context.Context
+	ctx, cancel = context.WithCancel(context.Background())
+	go run(ctx, quit, done)
+}
+
+// Stop asks the worker to finish and waits for it to drain whatever mail is
+// already due. When ctx is done it gives up and cancels the worker's queries.
+func Stop(ctx context.Context) error {
+	mu.Lock()
+	if !started {
+		mu.Unlock()
+		return nil
+	}
+	started = false
+	// Start can make new ones as soon as the lock is let go
+	quit, done, cancel := quit, done, cancel
+	mu.Unlock()
+
+	close(quit)
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		// give up on the queries still running too
+		cancel()
+		return ctx.Err()
+	}
+}
+
+func run(ctx context.Context, quit <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	t := time.NewTicker(interval())
+	defer t.Stop()
+
+	for {
+		select {
+		case <-quit:
+			drain(ctx)
+			return
+		case <-wake:
+			deliver(ctx)
+		case <-t.C:
+			deliver(ctx)
+			purge(ctx)
+		}
+	}
+}
+
+// purge removes sent mail once it's been kept for outbox.keep_sent.
+func purge(ctx context.Context) {
+	_, err := models.PurgeSentEmails(ctx, time.Now().Add(-keepSent()))
+	if err != nil {
+		log.Printf("outbox : Purge sent : %v", err)
+	}
+}
+
+// drain keeps sending until nothing is due, so a shutdown doesn't strand
+// messages that were queued by the last requests served.
+func drain(ctx context.Context) {
+	for deliver(ctx) > 0 {
+	}
+}
+
+// deliver sends one batch of due messages and returns how many were sent.
+func deliver(ctx context.Context) int {
+	due, err := models.ClaimDueEmails(ctx, batchSize)
+	if err != nil {
+		log.Printf("outbox : Claim due : %v", err)
+		return 0
+	}
+
+	sent := 0
+	for _, m := range due {
+		e := email.Email{
+			To:      m.Recipients(),
+			Subject: m.Subject.String,
+			Text:    m.Text.String,
+			HTML:    m.HTML.String,
+		}
+
+		err := unmarshalAttachments(m, &e)
+		if err == nil {
+			err = email.Send(e)
+		}
+		if err != nil {
+			log.Printf("outbox : Send %d attempt %d : %v", m.ID, m.Attempts+1, err)
+
+			err = m.MarkAttemptFailed(ctx, err, time.Now().Add(backoff(m.Attempts)), maxAttempts())
+			if err != nil {
+				log.Printf("outbox : Mark %d failed : %v", m.ID, err)
+			}
+			continue
+		}
+
+		err = m.MarkSent(ctx)
+		if err != nil {
+			log.Printf("outbox : Mark %d sent : %v", m.ID, err)
+			continue
+		}
+		sent++
+	}
+	return sent
+}
+
+func unmarshalAttachments(m *models.OutboxEmail, e *email.Email) error {
+	if m.Attachments.String == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(m.Attachments.String), &e.Attachments)
+}
+
+// backoff doubles the wait after each failed attempt, capped at maxBackoff.
+func backoff(attempts int) time.Duration {
+	d := baseBackoff
+	for i := 0; i < attempts; i++ {
+		d *= 2
+		if d >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return d
+}
+
+func interval() time.Duration {
+	if d := viper.GetDuration("outbox.interval"); d > 0 {
+		return d
+	}
+	return time.Minute
+}
+
+func keepSent() time.Duration {
+	if d := viper.GetDuration("outbox.keep_sent"); d > 0 {
+		return d
+	}
+	return 7 * 24 * time.Hour
+}
+
+func maxAttempts() int {
+	if n := viper.GetInt("outbox.max_attempts"); n > 0 {
+		return n
+	}
+	return 8
+}
+
+func nullStr(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
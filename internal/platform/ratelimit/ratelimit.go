@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepSize is how many keys a limiter holds before it clears out the ones
+// that have gone quiet.
+const sweepSize = 10000
+
+// Limiter allows up to max hits per key in a sliding window. It lives in
+// memory, so limits reset when the server restarts.
+type Limiter struct {
+	max    int
+	window time.Duration
+	hits   map[string][]time.Time
+	mu     sync.Mutex
+}
+
+func New(max int, window time.Duration) *Limiter {
+	return &Limiter{
+		max:    max,
+		window: window,
+		hits:   make(map[string][]time.Time),
+	}
+}
+
+// Allow records a hit for key and reports whether it's within the limit.
+func (l *Limiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+
+	if len(l.hits) >= sweepSize {
+		l.sweep(now)
+	}
+
+	hits := l.recent(key, now)
+	if len(hits) >= l.max {
+		l.hits[key] = hits
+		return false
+	}
+
+	l.hits[key] = append(hits, now)
+	return true
+}
+
+// Exceeded reports whether key has used up its hits without recording one,
+// for checks that should only count failures.
+func (l *Limiter) Exceeded(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.recent(key, time.Now())) >= l.max
+}
+
+// Reset forgets the hits recorded for key.
+func (l *Limiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.hits, key)
+}
+
+func (l *Limiter) recent(key string, now time.Time) []time.Time {
+	hits := l.hits[key]
+
+	i := 0
+	for i < len(hits) && now.Sub(hits[i]) >= l.window {
+		i++
+	}
+	return hits[i:]
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	for k := range l.hits {
+		if hits := l.recent(k, now); len(hits) == 0 {
+			delete(l.hits, k)
+		} else {
+			l.hits[k] = hits
+		}
+	}
+}
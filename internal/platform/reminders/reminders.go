@@ -0,0 +1,183 @@
+package reminders
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"revelbus/internal/platform/cal"
+	"revelbus/internal/platform/domain/models"
+	"revelbus/internal/platform/emails"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// followUpWindow is how long after a trip a follow-up is still worth sending,
+// e.g. if the server was down when it was due.
+const followUpWindow = 7 * 24 * time.Hour
+
+var (
+	quit    = make(chan struct{})
+	done    = make(chan struct{})
+	cancel  context.CancelFunc
+	started bool
+	mu      sync.Mutex
+)
+
+// Start runs the scheduler in the background until Stop is called.
+func Start() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if started {
+		return
+	}
+	started = true
+
+	var ctx context.Context
+	ctx, cancel = context.WithCancel(context.Background())
+	go run(ctx)
+}
+
+// Stop asks the scheduler to finish the run in progress. When ctx is done it
+// gives up and cancels the scheduler's queries.
+func Stop(ctx context.Context) error {
+	mu.Lock()
+	if !started {
+		mu.Unlock()
+		return nil
+	}
+	started = false
+	mu.Unlock()
+
+	close(quit)
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		// give up on the queries still running too
+		cancel()
+		return ctx.Err()
+	}
+}
+
+func run(ctx context.Context) {
+	defer close(done)
+
+	t := time.NewTicker(interval())
+	defer t.Stop()
+
+	check(ctx)
+
+	for {
+		select {
+		case <-quit:
+			return
+		case <-t.C:
+			check(ctx)
+		}
+	}
+}
+
+// check sends whatever reminders and follow-ups are due. Each one is recorded
+// before it's queued, so a restart part way through never sends it twice.
+func check(ctx context.Context) {
+	now := time.Now()
+	hours := setting("reminders.hours", 3) * time.Hour
+	days := setting("reminders.days", 3) * 24 * time.Hour
+
+	if days > hours {
+		trips, err := models.FindTripsToRemind(ctx, now.Add(hours), now.Add(days))
+		if err != nil {
+			log.Printf("reminders : Find trips : %v", err)
+		}
+		for _, t := range trips {
+			notify(ctx, t, models.NotifyReminderDays)
+		}
+	}
+
+	trips, err := models.FindTripsToRemind(ctx, now, now.Add(hours))
+	if err != nil {
+		log.Printf("reminders : Find trips : %v", err)
+	}
+	for _, t := range trips {
+		notify(ctx, t, models.NotifyReminderHours)
+	}
+
+	delay := setting("reminders.followup_hours", 24) * time.Hour
+	trips, err = models.FindTripsToFollowUp(ctx, now.Add(-followUpWindow), now.Add(-delay))
+	if err != nil {
+		log.Printf("reminders : Find trips : %v", err)
+	}
+	for _, t := range trips {
+		notify(ctx, t, models.NotifyFollowUp)
+	}
+}
+
+func notify(ctx context.Context, t *models.Trip, kind string) {
+	riders, err := models.FindRidersToNotify(ctx, t.ID, kind)
+	if err != nil {
+		log.Printf("reminders : Find riders for trip %d : %v", t.ID, err)
+		return
+	}
+	if len(riders) == 0 {
+		return
+	}
+
+	err = t.Fetch(ctx)
+	if err != nil {
+		log.Printf("reminders : Fetch trip %d : %v", t.ID, err)
+		return
+	}
+
+	var ics bytes.Buffer
+	if kind != models.NotifyFollowUp {
+		err = cal.WriteICS(&ics, t)
+		if err != nil {
+			log.Printf("reminders : ICS for trip %d : %v", t.ID, err)
+			return
+		}
+	}
+
+	for _, u := range riders {
+		ok, err := models.RecordNotification(ctx, t.ID, u.ID, kind)
+		if err != nil {
+			log.Printf("reminders : Record %s for trip %d user %d : %v", kind, t.ID, u.ID, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		if kind == models.NotifyFollowUp {
+			err = emails.TripFollowUp(ctx, u, t)
+		} else {
+			err = emails.TripReminder(ctx, u, t, ics.Bytes())
+		}
+
+		if err != nil {
+			log.Printf("reminders : Queue %s for trip %d user %d : %v", kind, t.ID, u.ID, err)
+
+			err = models.ForgetNotification(ctx, t.ID, u.ID, kind)
+			if err != nil {
+				log.Printf("reminders : Forget %s for trip %d user %d : %v", kind, t.ID, u.ID, err)
+			}
+		}
+	}
+}
+
+func setting(key string, def int) time.Duration {
+	if n := viper.GetInt(key); n > 0 {
+		return time.Duration(n)
+	}
+	return time.Duration(def)
+}
+
+func interval() time.Duration {
+	if d := viper.GetDuration("reminders.interval"); d > 0 {
+		return d
+	}
+	return 15 * time.Minute
+}
@@ -0,0 +1,115 @@
+package spam
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/spf13/viper"
+)
+
+var (
+	rxLink = regexp.MustCompile(`(?i)(https?://|www\.)`)
+	rxBB   = regexp.MustCompile(`(?i)\[url=|<a\s+href`)
+
+	phrases = []string{
+		"viagra", "cialis", "casino", "crypto", "bitcoin", "forex", "loan",
+		"seo services", "backlinks", "rank your website", "first page of google",
+		"web design services", "marketing services", "increase your traffic",
+		"click here", "buy now", "limited time", "100% free", "guaranteed",
+	}
+)
+
+// Stamp returns a signed timestamp for a form so the time it took to fill in
+// can be checked when it comes back.
+func Stamp() string {
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	return ts + "." + sign(ts)
+}
+
+// TooFast reports whether a form was submitted less than min after stamp was
+// issued. A missing or tampered stamp counts as too fast.
+func TooFast(stamp string, min time.Duration) bool {
+	parts := strings.SplitN(stamp, ".", 2)
+	if len(parts) != 2 || !hmac.Equal([]byte(parts[1]), []byte(sign(parts[0]))) {
+		return true
+	}
+
+	ts, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return true
+	}
+
+	return time.Since(time.Unix(ts, 0)) < min
+}
+
+// Score rates how spammy a message looks. Each sign adds to the score; the
+// caller decides where to draw the line.
+func Score(name string, email string, body string) int {
+	score := 0
+	lower := strings.ToLower(body)
+
+	links := len(rxLink.FindAllString(body, -1))
+	if links > 0 {
+		score += links
+	}
+	if links > 2 {
+		score += 2
+	}
+
+	if rxBB.MatchString(body) {
+		score += 3
+	}
+
+	for _, p := range phrases {
+		if strings.Contains(lower, p) {
+			score++
+		}
+	}
+
+	if rxLink.MatchString(name) {
+		score += 3
+	}
+
+	if len(strings.TrimSpace(body)) < 15 {
+		score++
+	}
+
+	letters, upper, foreign := 0, 0, 0
+	for _, r := range body {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		if unicode.IsUpper(r) {
+			upper++
+		}
+		if r > unicode.MaxLatin1 {
+			foreign++
+		}
+	}
+
+	if letters > 20 && upper*2 > letters {
+		score += 2
+	}
+	if letters > 0 && foreign*2 > letters {
+		score += 2
+	}
+
+	if strings.HasSuffix(strings.ToLower(email), ".ru") || strings.HasSuffix(strings.ToLower(email), ".xyz") {
+		score++
+	}
+
+	return score
+}
+
+func sign(s string) string {
+	m := hmac.New(sha256.New, []byte(viper.GetString("secret")))
+	m.Write([]byte(s))
+	return hex.EncodeToString(m.Sum(nil))
+}
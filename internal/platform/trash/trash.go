@@ -0,0 +1,222 @@
+// Package trash restores and purges deleted records, and runs the job that
+// purges whatever has been in the trash longer than trash.days.
+package trash
+
+import (
+	"context"
+	"log"
+	"revelbus/internal/platform/domain"
+	"revelbus/internal/platform/domain/models"
+	"revelbus/internal/platform/domain/store"
+	"revelbus/internal/platform/uploads"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+var (
+	quit    chan struct{}
+	done    chan struct{}
+	cancel  context.CancelFunc
+	started bool
+	mu      sync.Mutex
+)
+
+// perms is the permission needed to see, restore or purge each kind of
+// record in the trash; the same one it takes to delete it.
+var perms = map[string]string{
+	models.AuditTrip:    models.PermTrips,
+	models.AuditVendor:  models.PermVendors,
+	models.AuditFAQ:     models.PermFAQs,
+	models.AuditGallery: models.PermContent,
+	models.AuditUser:    models.PermUsers,
+}
+
+// Allowed reports whether u may see, restore or purge records of type t.
+func Allowed(u *models.User, t string) bool {
+	p, ok := perms[t]
+	return ok && u != nil && u.Can(p)
+}
+
+// Restore takes the record of type t out of the trash for u. Records u isn't
+// allowed to touch aren't found.
+func Restore(ctx context.Context, s *store.Store, u *models.User, t string, id int) error {
+	if !Allowed(u, t) {
+		return domain.ErrNotFound
+	}
+
+	switch t {
+	case models.AuditTrip:
+		return s.Trips.Restore(ctx, &models.Trip{ID: id})
+	case models.AuditVendor:
+		return s.Vendors.Restore(ctx, &models.Vendor{ID: id})
+	case models.AuditFAQ:
+		return s.FAQs.Restore(ctx, &models.FAQ{ID: id})
+	case models.AuditGallery:
+		return s.Galleries.Restore(ctx, &models.Gallery{ID: id})
+	case models.AuditUser:
+		return s.Users.Restore(ctx, &models.User{ID: id})
+	}
+	return domain.ErrNotFound
+}
+
+// Purge removes the record of type t from the trash for good for u, along
+// with the files that only it used. Records u isn't allowed to touch aren't
+// found.
+func Purge(ctx context.Context, s *store.Store, u *models.User, t string, id int) error {
+	if !Allowed(u, t) {
+		return domain.ErrNotFound
+	}
+	return purge(ctx, s, t, id)
+}
+
+// purge removes the record without asking whose it is, for the retention
+// job. The files stay on disk if the purge fails.
+func purge(ctx context.Context, s *store.Store, t string, id int) error {
+	return s.InTx(ctx, func(ctx context.Context) error {
+		switch t {
+		case models.AuditTrip:
+			trip := &models.Trip{ID: id}
+			err := s.Trips.Purge(ctx, trip)
+			if err != nil {
+				return err
+			}
+			return deleteFile(ctx, s, int(trip.ImageID.Int64))
+		case models.AuditVendor:
+			v := &models.Vendor{ID: id}
+			err := s.Vendors.Purge(ctx, v)
+			if err != nil {
+				return err
+			}
+			return deleteFile(ctx, s, int(v.BrandID.Int64))
+		case models.AuditFAQ:
+			return s.FAQs.Purge(ctx, &models.FAQ{ID: id})
+		case models.AuditGallery:
+			g := &models.Gallery{ID: id}
+			err := s.Galleries.Purge(ctx, g)
+			if err != nil {
+				return err
+			}
+			if g.Folder.String == "" {
+				return nil
+			}
+			return uploads.DeleteFolder(ctx, "uploads/files/"+g.Folder.String)
+		case models.AuditUser:
+			return s.Users.Purge(ctx, &models.User{ID: id})
+		}
+		return domain.ErrNotFound
+	})
+}
+
+// deleteFile deletes the file the purged record used, unless something else
+// still shows it.
+func deleteFile(ctx context.Context, s *store.Store, id int) error {
+	if id == 0 {
+		return nil
+	}
+
+	f := &models.File{ID: id}
+
+	used, err := s.Files.InUse(ctx, f)
+	if err != nil || used {
+		return err
+	}
+	return s.Files.Delete(ctx, f)
+}
+
+// Start runs the retention job in the background until Stop is called.
+func Start() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if started {
+		return
+	}
+	started = true
+
+	// a job that was stopped has closed these, so each run gets its own
+	quit = make(chan struct{})
+	done = make(chan struct{})
+
+	var ctx context.Context
+	ctx, cancel = context.WithCancel(context.Background())
+	go run(ctx, quit, done)
+}
+
+// Stop asks the retention job to finish the purge in progress. When ctx is
+// done it gives up and cancels the job's queries.
+func Stop(ctx context.Context) error {
+	mu.Lock()
+	if !started {
+		mu.Unlock()
+		return nil
+	}
+	started = false
+	// Start can make new ones as soon as the lock is let go
+	quit, done, cancel := quit, done, cancel
+	mu.Unlock()
+
+	close(quit)
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		cancel()
+		return ctx.Err()
+	}
+}
+
+func run(ctx context.Context, quit <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	t := time.NewTicker(interval())
+	defer t.Stop()
+
+	s := store.Audited(store.NewMySQL())
+	expire(ctx, s)
+
+	for {
+		select {
+		case <-quit:
+			return
+		case <-t.C:
+			expire(ctx, s)
+		}
+	}
+}
+
+// expire purges everything that's been in the trash longer than trash.days.
+// Something that can't be purged, like a vendor a trashed trip still uses,
+// is tried again next time.
+func expire(ctx context.Context, s *store.Store) {
+	cutoff := time.Now().UTC().Add(-days() * 24 * time.Hour)
+
+	items, err := s.Trash.FetchExpired(ctx, cutoff)
+	if err != nil {
+		log.Printf("trash : Find expired : %v", err)
+		return
+	}
+
+	for _, i := range *items {
+		err := purge(ctx, s, i.Type, i.ID)
+		if err != nil && err != domain.ErrNotFound {
+			log.Printf("trash : Purge %s %d : %v", i.Type, i.ID, err)
+		}
+	}
+}
+
+func days() time.Duration {
+	if n := viper.GetInt("trash.days"); n > 0 {
+		return time.Duration(n)
+	}
+	return 30
+}
+
+func interval() time.Duration {
+	if d := viper.GetDuration("trash.interval"); d > 0 {
+		return d
+	}
+	return time.Hour
+}
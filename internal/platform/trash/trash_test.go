@@ -0,0 +1,49 @@
+package trash
+
+import (
+	"context"
+	"revelbus/internal/platform/domain"
+	"revelbus/internal/platform/domain/models"
+	"revelbus/internal/platform/domain/store"
+	"testing"
+)
+
+func TestAllowed(t *testing.T) {
+	s := store.NewMemory()
+	ctx := context.Background()
+
+	f := &models.FAQ{}
+	err := s.FAQs.Create(ctx, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.FAQs.Delete(ctx, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vendors := &models.User{Permissions: map[string]bool{models.PermVendors: true}}
+	faqs := &models.User{Permissions: map[string]bool{models.PermFAQs: true}}
+
+	// someone who can't delete FAQs can't purge or restore them either
+	err = Purge(ctx, s, vendors, models.AuditFAQ, f.ID)
+	if err != domain.ErrNotFound {
+		t.Fatalf("got %v purging without the permission, want ErrNotFound", err)
+	}
+
+	err = Restore(ctx, s, nil, models.AuditFAQ, f.ID)
+	if err != domain.ErrNotFound {
+		t.Fatalf("got %v restoring with no one signed in, want ErrNotFound", err)
+	}
+
+	err = Restore(ctx, s, faqs, models.AuditFAQ, f.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.FAQs.Fetch(ctx, &models.FAQ{ID: f.ID})
+	if err != nil {
+		t.Fatalf("got %v fetching the restored FAQ", err)
+	}
+}
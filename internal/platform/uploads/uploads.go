@@ -0,0 +1,61 @@
+// Package uploads removes uploaded files from the database and disk together.
+// Removing from disk waits for the transaction, if there is one, to commit.
+package uploads
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"revelbus/internal/platform/domain"
+	"revelbus/internal/platform/domain/models"
+	"revelbus/pkg/database"
+
+	"github.com/spf13/viper"
+)
+
+func Delete(ctx context.Context, f *models.File) error {
+	if f.Name.String == "" {
+		err := f.Fetch(ctx)
+		if err != nil {
+			if err == domain.ErrNotFound {
+				return nil
+			}
+			return err
+		}
+	}
+
+	err := f.Delete(ctx)
+	if err != nil {
+		return err
+	}
+
+	// if ctx is part of a transaction, the file stays on disk until it
+	// commits, in case the record comes back
+	return database.AfterCommit(ctx, func() error {
+		return Remove(f)
+	})
+}
+
+// Remove takes the file and its thumbnail off disk, leaving the record.
+func Remove(f *models.File) error {
+	err := os.Remove(filepath.Join(viper.GetString("files.static"), f.Name.String))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if len(f.Thumb.String) > 0 {
+		err := os.Remove(filepath.Join(viper.GetString("files.static"), f.Thumb.String))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteFolder takes a folder of uploads off disk once ctx's transaction, if
+// any, commits.
+func DeleteFolder(ctx context.Context, path string) error {
+	return database.AfterCommit(ctx, func() error {
+		return os.RemoveAll(filepath.Join(viper.GetString("files.static") + path))
+	})
+}
@@ -1,26 +1,141 @@
 package database
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"database/sql"
+	"errors"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"sync"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	"github.com/spf13/viper"
 )
 
-var db *sql.DB
+const tlsConfigName = "revelbus"
 
+var (
+	db    *sql.DB
+	dbErr error
+	once  sync.Once
+)
+
+// GetConnection returns the site's connection pool, opening it the first
+// time it's asked for. Everything shares the one pool; don't close it.
 func GetConnection() (*sql.DB, error) {
-	if db == nil {
-		return createConnection()
-	}
-	return db, nil
+	once.Do(func() {
+		db, dbErr = createConnection()
+	})
+	return db, dbErr
+}
+
+// UseConnection makes conn the site's pool in place of the one the config
+// describes, so the models can be run against another driver.
+func UseConnection(conn *sql.DB) {
+	once.Do(func() {})
+	db, dbErr = conn, nil
 }
 
 func createConnection() (*sql.DB, error) {
-	db, err := sql.Open("mysql", viper.GetString("db.user")+":"+viper.GetString("db.password")+"@/"+viper.GetString("db.name")+"?parseTime=true")
+	cfg, err := config()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := sql.Open("mysql", cfg.FormatDSN())
 	if err != nil {
 		return nil, err
 	}
 
-	return db, nil
+	conn.SetMaxOpenConns(setting("db.max_open", 25))
+	conn.SetMaxIdleConns(setting("db.max_idle", 10))
+	conn.SetConnMaxLifetime(duration("db.conn_lifetime", 5*time.Minute))
+
+	return conn, nil
+}
+
+// config builds the driver settings from the db config. The server is
+// reached over db.socket if it's set, otherwise db.host and db.port.
+func config() (*mysql.Config, error) {
+	cfg := &mysql.Config{
+		User:                 viper.GetString("db.user"),
+		Passwd:               viper.GetString("db.password"),
+		DBName:               viper.GetString("db.name"),
+		Collation:            "utf8mb4_unicode_ci",
+		Loc:                  time.UTC,
+		ParseTime:            true,
+		AllowNativePasswords: true,
+		Timeout:              duration("db.dial_timeout", 5*time.Second),
+		ReadTimeout:          duration("db.read_timeout", 30*time.Second),
+		WriteTimeout:         duration("db.write_timeout", 30*time.Second),
+	}
+
+	host := viper.GetString("db.host")
+	if host == "" {
+		host = "127.0.0.1"
+	}
+
+	if socket := viper.GetString("db.socket"); socket != "" {
+		cfg.Net = "unix"
+		cfg.Addr = socket
+	} else {
+		cfg.Net = "tcp"
+		cfg.Addr = net.JoinHostPort(host, strconv.Itoa(setting("db.port", 3306)))
+	}
+
+	// db.tls is "true", "skip-verify" or empty for none; db.tls_ca verifies
+	// the server against a CA of our own instead
+	cfg.TLSConfig = viper.GetString("db.tls")
+
+	if ca := viper.GetString("db.tls_ca"); ca != "" {
+		pem, err := ioutil.ReadFile(ca)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("database: no certificates in " + ca)
+		}
+
+		err = mysql.RegisterTLSConfig(tlsConfigName, &tls.Config{
+			RootCAs:    pool,
+			ServerName: host,
+		})
+		if err != nil {
+			return nil, err
+		}
+		cfg.TLSConfig = tlsConfigName
+	}
+
+	return cfg, nil
+}
+
+func setting(key string, def int) int {
+	if n := viper.GetInt(key); n > 0 {
+		return n
+	}
+	return def
+}
+
+func duration(key string, def time.Duration) time.Duration {
+	if d := viper.GetDuration(key); d > 0 {
+		return d
+	}
+	return def
+}
+
+// WithTimeout bounds the queries run with the returned context by the
+// db.query_timeout config, so a slow query gives up rather than outliving
+// the request that asked for it.
+func WithTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, queryTimeout())
+}
+
+func queryTimeout() time.Duration {
+	return duration("db.query_timeout", 3*time.Second)
 }
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log"
+)
+
+// Querier is what the models run their queries on: the pool, or the
+// transaction they're part of.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type txKey struct{}
+
+type txState struct {
+	tx         *sql.Tx
+	onCommit   []func() error
+	onRollback []func()
+}
+
+// Conn returns the transaction ctx belongs to, or the pool when there isn't
+// one.
+func Conn(ctx context.Context) (Querier, error) {
+	if s, ok := ctx.Value(txKey{}).(*txState); ok {
+		return s.tx, nil
+	}
+	return GetConnection()
+}
+
+// InTx runs fn in a transaction, committing if it returns nil and rolling
+// back otherwise. Queries made with the context fn is given join the
+// transaction; calling InTx inside fn joins the outer one rather than
+// starting another.
+func InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*txState); ok {
+		return fn(ctx)
+	}
+
+	db, err := GetConnection()
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	s := &txState{tx: tx}
+
+	defer func() {
+		if p := recover(); p != nil {
+			s.rollback()
+			panic(p)
+		}
+	}()
+
+	err = fn(context.WithValue(ctx, txKey{}, s))
+	if err != nil {
+		s.rollback()
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		s.rollback()
+		return err
+	}
+
+	// the writes are in by now, so a hook that fails mustn't make the caller
+	// think they weren't
+	for _, f := range s.onCommit {
+		if ferr := f(); ferr != nil {
+			log.Printf("database : After commit : %v", ferr)
+		}
+	}
+	return nil
+}
+
+// rollback undoes the transaction and then its side effects, newest first.
+func (s *txState) rollback() {
+	s.tx.Rollback()
+	for i := len(s.onRollback) - 1; i >= 0; i-- {
+		s.onRollback[i]()
+	}
+}
+
+// AfterCommit holds fn back until the transaction ctx belongs to commits, for
+// side effects like removing files that can't be undone if it rolls back.
+// Its error is only logged then, since the transaction has already committed.
+// Outside a transaction fn runs straight away and its error is returned.
+func AfterCommit(ctx context.Context, fn func() error) error {
+	if s, ok := ctx.Value(txKey{}).(*txState); ok {
+		s.onCommit = append(s.onCommit, fn)
+		return nil
+	}
+	return fn()
+}
+
+// OnRollback registers fn to undo a side effect, like writing a file, if the
+// transaction ctx belongs to rolls back. Outside a transaction there's
+// nothing to roll back, so it does nothing.
+func OnRollback(ctx context.Context, fn func()) {
+	if s, ok := ctx.Value(txKey{}).(*txState); ok {
+		s.onRollback = append(s.onRollback, fn)
+	}
+}
@@ -0,0 +1,112 @@
+// Package diff lines up two versions of a text line by line, for showing them
+// side by side.
+package diff
+
+import "strings"
+
+// The ways a line can differ between the old text and the new.
+const (
+	Equal  = "equal"
+	Delete = "delete"
+	Insert = "insert"
+	Change = "change"
+)
+
+// maxCells caps the size of the table Lines builds. Texts bigger than that
+// are shown as one change rather than lined up.
+const maxCells = 4000000
+
+// Line is a row of a side by side diff. Old is empty for an insert and New
+// for a delete.
+type Line struct {
+	Op  string
+	Old string
+	New string
+}
+
+// Lines compares a and b a line at a time. Lines that were replaced, rather
+// than only added or removed, are paired up as changes.
+func Lines(a, b string) []Line {
+	old := split(a)
+	new := split(b)
+
+	if len(old)*len(new) > maxCells {
+		return pair(nil, old, new)
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of old[i:]
+	// and new[j:]
+	lcs := make([][]int, len(old)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(new)+1)
+	}
+	for i := len(old) - 1; i >= 0; i-- {
+		for j := len(new) - 1; j >= 0; j-- {
+			if old[i] == new[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	lines := []Line{}
+	var dels, ins []string
+
+	i, j := 0, 0
+	for i < len(old) && j < len(new) {
+		switch {
+		case old[i] == new[j]:
+			lines = pair(lines, dels, ins)
+			dels, ins = nil, nil
+			lines = append(lines, Line{Op: Equal, Old: old[i], New: new[j]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			dels = append(dels, old[i])
+			i++
+		default:
+			ins = append(ins, new[j])
+			j++
+		}
+	}
+	dels = append(dels, old[i:]...)
+	ins = append(ins, new[j:]...)
+
+	return pair(lines, dels, ins)
+}
+
+// Changed reports whether any of lines differ.
+func Changed(lines []Line) bool {
+	for _, l := range lines {
+		if l.Op != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+// pair adds a run of deleted and inserted lines to lines, matching them up as
+// changes for as long as there are both.
+func pair(lines []Line, dels, ins []string) []Line {
+	for k := 0; k < len(dels) || k < len(ins); k++ {
+		switch {
+		case k < len(dels) && k < len(ins):
+			lines = append(lines, Line{Op: Change, Old: dels[k], New: ins[k]})
+		case k < len(dels):
+			lines = append(lines, Line{Op: Delete, Old: dels[k]})
+		default:
+			lines = append(lines, Line{Op: Insert, New: ins[k]})
+		}
+	}
+	return lines
+}
+
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.Replace(s, "\r\n", "\n", -1), "\n")
+}
@@ -1,27 +1,80 @@
 package email
 
 import (
+	"io"
+	"sync"
+
 	"github.com/spf13/viper"
 	gomail "gopkg.in/gomail.v2"
 )
 
 type Email struct {
-	To      []string
-	Subject string
-	Text    string
-	HTML    string
+	To          []string
+	Subject     string
+	Text        string
+	HTML        string
+	Attachments []Attachment
+}
+
+type Attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// Mailer delivers a message over some transport.
+type Mailer interface {
+	Send(e Email) error
+}
+
+// Mailbox is implemented by mailers that keep what they send around so it
+// can be looked at, e.g. the in-memory capture mailer used in development.
+type Mailbox interface {
+	Messages() []Message
+}
+
+var (
+	mailer Mailer
+	mu     sync.Mutex
+)
+
+func GetMailer() Mailer {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if mailer == nil {
+		mailer = createMailer()
+	}
+	return mailer
+}
+
+// SetMailer swaps the transport, mostly for tests and local tooling.
+func SetMailer(m Mailer) {
+	mu.Lock()
+	defer mu.Unlock()
+	mailer = m
+}
+
+func createMailer() Mailer {
+	switch viper.GetString("mail.transport") {
+	case "file":
+		return NewFileMailer(viper.GetString("mail.dir"))
+	case "memory":
+		return NewMemoryMailer(viper.GetInt("mail.keep"))
+	default:
+		return NewSMTPMailer(viper.GetString("smtp.host"), viper.GetInt("smtp.port"), viper.GetString("smtp.user"), viper.GetString("smtp.password"))
+	}
 }
 
 func Send(e Email) error {
+	return GetMailer().Send(e)
+}
+
+func newMessage(e Email) *gomail.Message {
 	m := gomail.NewMessage()
-	d := gomail.NewPlainDialer(viper.GetString("smtp.host"), viper.GetInt("smtp.port"), viper.GetString("smtp.user"), viper.GetString("smtp.password"))
 
 	m.SetBody("text/html", e.HTML)
 	m.AddAlternative("text/plain", e.Text)
-	m.SetHeaders(map[string][]string{
-		"From":    []string{viper.GetString("from")},
-		"Subject": []string{e.Subject},
-	})
 
 	headers := map[string][]string{
 		"From":    []string{viper.GetString("from")},
@@ -36,9 +89,16 @@ func Send(e Email) error {
 
 	m.SetHeaders(headers)
 
-	err := d.DialAndSend(m)
-	if err != nil {
-		return err
+	for _, a := range e.Attachments {
+		data := a.Data
+		m.Attach(a.Name,
+			gomail.SetHeader(map[string][]string{"Content-Type": {a.ContentType}}),
+			gomail.SetCopyFunc(func(w io.Writer) error {
+				_, err := w.Write(data)
+				return err
+			}),
+		)
 	}
-	return nil
+
+	return m
 }
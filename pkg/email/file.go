@@ -0,0 +1,44 @@
+package email
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// FileMailer writes every message as an .eml file in dir instead of sending
+// it, which any mail client can open.
+type FileMailer struct {
+	dir string
+	seq uint64
+}
+
+func NewFileMailer(dir string) *FileMailer {
+	if dir == "" {
+		dir = "./mail"
+	}
+	return &FileMailer{
+		dir: dir,
+	}
+}
+
+func (f *FileMailer) Send(e Email) error {
+	err := os.MkdirAll(f.dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	n := atomic.AddUint64(&f.seq, 1)
+	fn := time.Now().Format("20060102T150405.000000000") + "-" + strconv.FormatUint(n, 10) + ".eml"
+
+	out, err := os.Create(filepath.Join(f.dir, fn))
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = newMessage(e).WriteTo(out)
+	return err
+}
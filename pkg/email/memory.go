@@ -0,0 +1,65 @@
+package email
+
+import (
+	"sync"
+	"time"
+)
+
+type Message struct {
+	ID   int
+	Sent time.Time
+	Email
+}
+
+// MemoryMailer keeps the most recent messages in memory instead of sending
+// them.
+type MemoryMailer struct {
+	mu       sync.Mutex
+	keep     int
+	next     int
+	messages []Message
+}
+
+func NewMemoryMailer(keep int) *MemoryMailer {
+	if keep <= 0 {
+		keep = 100
+	}
+	return &MemoryMailer{
+		keep: keep,
+	}
+}
+
+func (m *MemoryMailer) Send(e Email) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.next++
+	m.messages = append(m.messages, Message{
+		ID:    m.next,
+		Sent:  time.Now(),
+		Email: e,
+	})
+
+	if len(m.messages) > m.keep {
+		m.messages = m.messages[len(m.messages)-m.keep:]
+	}
+	return nil
+}
+
+// Messages returns the captured messages, newest first.
+func (m *MemoryMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	msgs := make([]Message, len(m.messages))
+	for i := range m.messages {
+		msgs[len(m.messages)-1-i] = m.messages[i]
+	}
+	return msgs
+}
+
+func (m *MemoryMailer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = nil
+}
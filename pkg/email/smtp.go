@@ -0,0 +1,17 @@
+package email
+
+import gomail "gopkg.in/gomail.v2"
+
+type SMTPMailer struct {
+	dialer *gomail.Dialer
+}
+
+func NewSMTPMailer(host string, port int, user string, password string) *SMTPMailer {
+	return &SMTPMailer{
+		dialer: gomail.NewPlainDialer(host, port, user, password),
+	}
+}
+
+func (s *SMTPMailer) Send(e Email) error {
+	return s.dialer.DialAndSend(newMessage(e))
+}
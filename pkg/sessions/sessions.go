@@ -1,24 +1,55 @@
 package sessions
 
 import (
+	"revelbus/pkg/database"
+	"sync"
 	"time"
 
 	"github.com/alexedwards/scs"
+	"github.com/alexedwards/scs/stores/mysqlstore"
 	"github.com/spf13/viper"
 )
 
-var session *scs.Manager
+var (
+	session *scs.Manager
+	mu      sync.Mutex
+)
 
 func GetSession() *scs.Manager {
+	mu.Lock()
+	defer mu.Unlock()
+
 	if session == nil {
-		return createSession()
+		session = createSession()
 	}
 	return session
 }
 
+// SetManager swaps the session manager, mostly for tests, which have no
+// database to keep sessions in.
+func SetManager(m *scs.Manager) {
+	mu.Lock()
+	defer mu.Unlock()
+	session = m
+}
+
+// Lifetime is how long a login lasts before the user has to sign in again.
+func Lifetime() time.Duration {
+	if d := viper.GetDuration("session.lifetime"); d > 0 {
+		return d
+	}
+	return 12 * time.Hour
+}
+
+// createSession keeps session data in MySQL so the cookie only carries a
+// token and sessions can be ended from the server.
 func createSession() *scs.Manager {
-	sessionManager := scs.NewCookieManager(viper.GetString("secret"))
-	sessionManager.Lifetime(12 * time.Hour)
+	db, _ := database.GetConnection()
+
+	sessionManager := scs.NewManager(mysqlstore.New(db, 5*time.Minute))
+	sessionManager.Lifetime(Lifetime())
 	sessionManager.Persist(true)
+	sessionManager.HttpOnly(true)
+	sessionManager.Secure(viper.GetBool("session.secure"))
 	return sessionManager
 }
@@ -0,0 +1,57 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 key from RFC 6238 Appendix B, "12345678901234567890",
+// base32 encoded.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	// the RFC's codes are 8 digits; ours are their last 6
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("at %d got %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	at := time.Unix(1111111111, 0)
+
+	step, ok := Validate(rfcSecret, "050 471", at, 0)
+	if !ok || step != Step(at) {
+		t.Fatalf("got step %d, %v, want %d", step, ok, Step(at))
+	}
+
+	// the step before is allowed for drift
+	if _, ok := Validate(rfcSecret, "081804", at, 0); !ok {
+		t.Fatal("the code from the step before was turned down")
+	}
+
+	if _, ok := Validate(rfcSecret, "050471", at, step); ok {
+		t.Fatal("a code was accepted twice")
+	}
+
+	if _, ok := Validate(rfcSecret, "123456", at, 0); ok {
+		t.Fatal("a wrong code was accepted")
+	}
+}